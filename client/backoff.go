@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// retrySleep waits between report attempts
+var retrySleep = sleepContext
+
+// sleepContext waits for d, returning ctx's error early if it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoffDelay returns the wait before retry number attempt (1 for the first
+// retry) using exponential backoff with full jitter: a uniformly random delay
+// between zero and base*2^(attempt-1), capped at max when it is positive. Randomizing the whole
+// delay keeps clients that failed together from retrying in lockstep.
+func backoffDelay(attempt int, base, max time.Duration) time.Duration {
+	if base <= 0 || attempt < 1 {
+		return 0
+	}
+
+	ceiling := base
+	for i := 1; i < attempt && (max <= 0 || ceiling < max) && ceiling < math.MaxInt64/2; i++ {
+		ceiling *= 2
+	}
+	if max > 0 && ceiling > max {
+		ceiling = max
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelayBounds(t *testing.T) {
+	const samples = 500
+	tests := []struct {
+		name      string
+		base, max time.Duration
+		ceilings  []time.Duration // largest delay allowed for attempts 1, 2, ...
+	}{
+		{"doubling", time.Second, time.Minute, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{"capped", time.Second, 5 * time.Second, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}},
+		{"uncapped", 100 * time.Millisecond, 0, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		for i, ceiling := range tt.ceilings {
+			attempt := i + 1
+			var longest time.Duration
+			for n := 0; n < samples; n++ {
+				delay := backoffDelay(attempt, tt.base, tt.max)
+				if delay < 0 || delay > ceiling {
+					t.Fatalf("%s: retry %d waited %v, want within [0, %v]", tt.name, attempt, delay, ceiling)
+				}
+				longest = max(longest, delay)
+			}
+			// Full jitter spreads delays over the whole range
+			if longest < ceiling*8/10 {
+				t.Errorf("%s: retry %d waited at most %v over %d tries, want close to %v", tt.name, attempt, longest, samples, ceiling)
+			}
+		}
+	}
+}
+
+func TestBackoffDelayEdgeCases(t *testing.T) {
+	if delay := backoffDelay(3, 0, time.Minute); delay != 0 {
+		t.Errorf("zero base = %v, want no wait", delay)
+	}
+	if delay := backoffDelay(0, time.Second, time.Minute); delay != 0 {
+		t.Errorf("attempt 0 = %v, want no wait", delay)
+	}
+	// Doubling stops before it overflows into a negative delay
+	for i := 0; i < 100; i++ {
+		if delay := backoffDelay(200, time.Second, 0); delay < 0 {
+			t.Fatalf("retry 200 without a cap = %v", delay)
+		}
+	}
+}
+
+func TestReportStatusBacksOffBetweenAttempts(t *testing.T) {
+	var delays []time.Duration
+	defer func(sleep func(context.Context, time.Duration) error) { retrySleep = sleep }(retrySleep)
+	retrySleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	var attempts int
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, "--retry-attempts", "5", "--retry-delay", "2", "--retry-max-delay", "10", "--breaker-threshold", "0")
+
+	if err := dc.reportStatus(context.Background()); err == nil {
+		t.Fatal("report to a failing server succeeded")
+	}
+	if attempts != 5 || len(delays) != 4 {
+		t.Fatalf("%d attempts with %d waits, want 5 attempts and 4 waits", attempts, len(delays))
+	}
+	for i, delay := range delays {
+		ceiling := min(2*time.Second<<i, 10*time.Second)
+		if delay < 0 || delay > ceiling {
+			t.Errorf("wait %d = %v, want within [0, %v]", i+1, delay, ceiling)
+		}
+	}
+
+	// A cancelled wait ends the report without further attempts
+	attempts = 0
+	retrySleep = func(ctx context.Context, d time.Duration) error { return context.Canceled }
+	if err := dc.reportStatus(context.Background()); err == nil || attempts != 1 {
+		t.Errorf("cancelled backoff: %d attempts, err %v; want one attempt and an error", attempts, err)
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("uncancelled sleep = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if err := sleepContext(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("cancelled sleep = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled sleep took %v", elapsed)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// BatchResult is the server's outcome for one report in a batch
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// BatchResponse is the server's reply to a batch report
+type BatchResponse struct {
+	Accepted            int           `json:"accepted"`
+	Rejected            int           `json:"rejected"`
+	Results             []BatchResult `json:"results"`
+	NextIntervalSeconds int           `json:"next_interval_seconds,omitempty"` // as in StatusResponse
+}
+
+// additionalServiceNames parses the comma-separated AdditionalServices setting
+func additionalServiceNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// expandServices returns req followed by a copy of it for each additional
+// service this host reports under
+func (dc *S01Client) expandServices(req StatusRequest) []StatusRequest {
+	reqs := []StatusRequest{req}
+	for _, name := range dc.additionalServices {
+		extra := req
+		extra.ServiceName = name
+		reqs = append(reqs, extra)
+	}
+	return reqs
+}
+
+// encodeReports marshals reports for sending and returns the path to send
+// them to. A single report goes to /api/v1/report; several are sent together
+// to /api/v1/report/batch.
+func (dc *S01Client) encodeReports(reqs []StatusRequest) (path string, body []byte, err error) {
+	if len(reqs) == 1 {
+		body, err = json.Marshal(reqs[0])
+		return "/api/v1/report", body, err
+	}
+	body, err = json.Marshal(reqs)
+	return "/api/v1/report/batch", body, err
+}
+
+// reportAccepted reports whether the server took a report request. A batch
+// answered with 207 was processed, so the reports it rejected are logged
+// rather than retried. A 409 means the server already holds a report with
+// this sequence, e.g. when a retry follows a lost response. An accepted
+// request's reply may carry the report interval the server asks for.
+func (dc *S01Client) reportAccepted(logger *slog.Logger, resp *http.Response, reqs []StatusRequest) bool {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Single and batch replies both carry next_interval_seconds
+		var statusResp StatusResponse
+		if err := json.NewDecoder(resp.Body).Decode(&statusResp); err == nil {
+			dc.adviseInterval(statusResp.NextIntervalSeconds)
+		}
+		return true
+	case http.StatusConflict:
+		logger.Debug("Server already has this report", "sequence", reqs[0].Sequence)
+		return true
+	case http.StatusMultiStatus:
+		var batchResp BatchResponse
+		if err := json.NewDecoder(resp.Body).Decode(&batchResp); err != nil {
+			logger.Warn("Failed to decode batch response", "error", err)
+			return true
+		}
+		dc.adviseInterval(batchResp.NextIntervalSeconds)
+		for _, result := range batchResp.Results {
+			if result.Error == nil || result.Index < 0 || result.Index >= len(reqs) {
+				continue
+			}
+			logger.Error("Server rejected batched report",
+				"service_name", reqs[result.Index].ServiceName,
+				"instance_name", reqs[result.Index].InstanceName,
+				"code", result.Error.Code,
+				"message", result.Error.Message,
+			)
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAdditionalServicesReportInOneBatch(t *testing.T) {
+	type request struct {
+		path     string
+		services []string
+	}
+	var requests []request
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var reports []StatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&reports); err != nil {
+			t.Errorf("%s body is not a batch: %v", r.URL.Path, err)
+		}
+		req := request{path: r.URL.Path}
+		for _, report := range reports {
+			req.services = append(req.services, report.ServiceName)
+		}
+		requests = append(requests, req)
+
+		// The server turns one report away
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"accepted": 2, "rejected": 1, "results": [
+			{"index": 0, "status": "ok"},
+			{"index": 1, "status": "error", "error": {"code": "invalid_request", "message": "nope"}},
+			{"index": 2, "status": "ok"}]}`))
+	}, "--additional-services", " billing, ,search ", "--retry-attempts", "3")
+
+	if err := dc.reportStatus(context.Background()); err != nil {
+		t.Fatalf("reportStatus = %v; a partly rejected batch is still delivered", err)
+	}
+	want := []request{{"/api/v1/report/batch", []string{"test-service", "billing", "search"}}}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %+v, want one batch %+v without retries", requests, want)
+	}
+}
+
+func TestEncodeReports(t *testing.T) {
+	dc := &S01Client{}
+	single := []StatusRequest{{ServiceName: "web", InstanceName: "w1", Status: "healthy"}}
+	if path, body, _ := dc.encodeReports(single); path != "/api/v1/report" || body[0] != '{' {
+		t.Errorf("one report sent to %s as %s, want a single object to /api/v1/report", path, body)
+	}
+
+	dc.additionalServices = []string{"billing"}
+	reqs := dc.expandServices(single[0])
+	if len(reqs) != 2 || reqs[1].ServiceName != "billing" || reqs[1].InstanceName != "w1" || reqs[0].ServiceName != "web" {
+		t.Fatalf("expandServices = %+v, want web then billing for w1", reqs)
+	}
+	if path, body, _ := dc.encodeReports(reqs); path != "/api/v1/report/batch" || body[0] != '[' {
+		t.Errorf("two reports sent to %s as %s, want an array to /api/v1/report/batch", path, body)
+	}
+}
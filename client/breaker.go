@@ -0,0 +1,58 @@
+package main
+
+import "sync"
+
+// Circuit breaker states
+const (
+	breakerClosed = "closed"
+	breakerOpen   = "open"
+)
+
+// circuitBreaker opens after a run of fully failed report cycles so the client
+// backs off during a prolonged outage instead of retrying every interval
+type circuitBreaker struct {
+	threshold int // consecutive failed cycles before opening; 0 disables
+	failures  int
+	open      bool
+	mutex     sync.Mutex
+}
+
+// newCircuitBreaker creates a closed breaker
+func newCircuitBreaker(threshold int) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold}
+}
+
+// recordFailure counts a failed report cycle and reports whether the breaker just opened
+func (cb *circuitBreaker) recordFailure() bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.failures++
+	if cb.threshold > 0 && !cb.open && cb.failures >= cb.threshold {
+		cb.open = true
+		return true
+	}
+	return false
+}
+
+// recordSuccess resets the failure run and reports whether the breaker just closed
+func (cb *circuitBreaker) recordSuccess() bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	wasOpen := cb.open
+	cb.failures = 0
+	cb.open = false
+	return wasOpen
+}
+
+// state returns the current breaker state and consecutive failure count
+func (cb *circuitBreaker) state() (string, int) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.open {
+		return breakerOpen, cb.failures
+	}
+	return breakerClosed, cb.failures
+}
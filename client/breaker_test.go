@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestCircuitBreaker(t *testing.T) {
+	// Each step is a report cycle: f fails, s succeeds. opened and closed
+	// are the steps at which the breaker should report a transition.
+	tests := []struct {
+		name      string
+		threshold int
+		steps     string
+		opened    []int
+		closed    []int
+		wantState string
+	}{
+		{"stays closed below threshold", 3, "ff", nil, nil, breakerClosed},
+		{"opens once at threshold", 3, "fffff", []int{2}, nil, breakerOpen},
+		{"success resets the run", 3, "ffsff", nil, nil, breakerClosed},
+		{"probe success closes", 2, "fffs", []int{1}, []int{3}, breakerClosed},
+		{"reopens after closing", 1, "fsf", []int{0, 2}, []int{1}, breakerOpen},
+		{"disabled never opens", 0, "ffffffffff", nil, nil, breakerClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := newCircuitBreaker(tt.threshold)
+			var opened, closed []int
+			for i, step := range tt.steps {
+				if step == 'f' && cb.recordFailure() {
+					opened = append(opened, i)
+				}
+				if step == 's' && cb.recordSuccess() {
+					closed = append(closed, i)
+				}
+			}
+			if !equalInts(opened, tt.opened) || !equalInts(closed, tt.closed) {
+				t.Errorf("opened at %v and closed at %v, want %v and %v", opened, closed, tt.opened, tt.closed)
+			}
+			if state, _ := cb.state(); state != tt.wantState {
+				t.Errorf("state = %s, want %s", state, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerCountsFailuresWhileOpen(t *testing.T) {
+	cb := newCircuitBreaker(2)
+	for i := 0; i < 5; i++ {
+		cb.recordFailure()
+	}
+	if state, failures := cb.state(); state != breakerOpen || failures != 5 {
+		t.Errorf("state = %s after %d failures, want open after 5", state, failures)
+	}
+}
+
+// equalInts reports whether a and b hold the same values, treating nil and empty alike
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLoadConfigBreakerSettings(t *testing.T) {
+	config, err := loadTestConfig(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BreakerThreshold != 5 || config.BreakerInterval != 300 {
+		t.Errorf("defaults = %d cycles, %ds; want 5 and 300", config.BreakerThreshold, config.BreakerInterval)
+	}
+
+	t.Setenv("BREAKER_THRESHOLD", "0")
+	config, err = loadTestConfig(t, "--breaker-interval", "60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BreakerThreshold != 0 || config.BreakerInterval != 60 {
+		t.Errorf("overridden = %d cycles, %ds; want 0 from the env and 60 from the flag", config.BreakerThreshold, config.BreakerInterval)
+	}
+}
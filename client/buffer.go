@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// bufferFlushBatch is how many buffered reports are sent per batch request,
+// keeping each request well under the server's body size limit
+const bufferFlushBatch = 25
+
+// reportBuffer keeps reports that could not be delivered in a JSON-lines file,
+// holding at most maxReports and dropping the oldest beyond that
+type reportBuffer struct {
+	path       string
+	maxReports int
+	mutex      sync.Mutex
+}
+
+// newReportBuffer creates a buffer at path; it returns nil when path is empty
+func newReportBuffer(path string, maxReports int) *reportBuffer {
+	if path == "" {
+		return nil
+	}
+	return &reportBuffer{path: path, maxReports: maxReports}
+}
+
+// load returns the buffered reports, oldest first. A missing file is an
+// empty buffer; undecodable lines are skipped.
+func (rb *reportBuffer) load() ([]StatusRequest, error) {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	return rb.read()
+}
+
+// read loads the file; the caller must hold rb.mutex
+func (rb *reportBuffer) read() ([]StatusRequest, error) {
+	file, err := os.Open(rb.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reqs []StatusRequest
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var req StatusRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read report buffer %s: %v", rb.path, err)
+	}
+	return reqs, nil
+}
+
+// add appends reports to the buffer and returns how many of the oldest
+// reports were dropped to stay within maxReports
+func (rb *reportBuffer) add(reqs []StatusRequest) (int, error) {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	buffered, err := rb.read()
+	if err != nil {
+		return 0, err
+	}
+	buffered = append(buffered, reqs...)
+
+	dropped := 0
+	if rb.maxReports > 0 && len(buffered) > rb.maxReports {
+		dropped = len(buffered) - rb.maxReports
+		buffered = buffered[dropped:]
+	}
+	return dropped, rb.write(buffered)
+}
+
+// replace rewrites the buffer to hold exactly reqs
+func (rb *reportBuffer) replace(reqs []StatusRequest) error {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	return rb.write(reqs)
+}
+
+// write atomically replaces the file with reqs; the caller must hold rb.mutex
+func (rb *reportBuffer) write(reqs []StatusRequest) error {
+	if len(reqs) == 0 {
+		if err := os.Remove(rb.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(rb.path), filepath.Base(rb.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, req := range reqs {
+		if err = encoder.Encode(req); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(0600)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report buffer %s: %v", rb.path, err)
+	}
+	return os.Rename(tmp.Name(), rb.path)
+}
+
+// flushBuffer sends buffered reports to the server in batches, oldest first,
+// removing each batch once the server has processed it. A batch the server
+// refuses outright with a 4xx is dropped so it cannot block the buffer; on a
+// connection failure, 429 or 5xx the remaining reports are kept for later.
+func (dc *S01Client) flushBuffer(ctx context.Context) error {
+	buffered, err := dc.buffer.load()
+	if err != nil {
+		return err
+	}
+
+	for len(buffered) > 0 {
+		batch := buffered[:min(len(buffered), bufferFlushBatch)]
+
+		path, jsonData, err := dc.encodeReports(batch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal buffered reports: %v", err)
+		}
+		url, err := dc.serverURL(ctx, path)
+		if err != nil {
+			return err
+		}
+		requestID := newRequestID()
+		logger := dc.logger.With("request_id", requestID)
+
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(requestIDHeader, requestID)
+
+		resp, err := dc.httpClient.Do(req)
+		if err != nil {
+			dc.srv.failover()
+			return fmt.Errorf("failed to send buffered reports: %v", err)
+		}
+		accepted := dc.reportAccepted(logger, resp, batch)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case accepted:
+			logger.Info("Delivered buffered reports", "reports", len(batch))
+		case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+			return fmt.Errorf("server returned status %d for buffered reports: %s", resp.StatusCode, string(body))
+		default:
+			logger.Error("Server refused buffered reports, dropping them",
+				"reports", len(batch),
+				"status_code", resp.StatusCode,
+				"response", string(body),
+			)
+		}
+
+		buffered = buffered[len(batch):]
+		if err := dc.buffer.replace(buffered); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bufferUndelivered keeps reports that could not be delivered for a later
+// flush, when buffering is enabled, and returns err unchanged
+func (dc *S01Client) bufferUndelivered(reqs []StatusRequest, err error) error {
+	if dc.buffer == nil {
+		return err
+	}
+
+	dropped, bufErr := dc.buffer.add(reqs)
+	if bufErr != nil {
+		dc.logger.Error("Failed to buffer undelivered reports", "path", dc.config.BufferPath, "error", bufErr)
+		return err
+	}
+	dc.logger.Warn("Buffered undelivered reports",
+		"reports", len(reqs),
+		"dropped_oldest", dropped,
+		"path", dc.config.BufferPath,
+	)
+	return err
+}
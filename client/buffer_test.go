@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportBufferKeepsNewest(t *testing.T) {
+	reports := func(services ...string) []StatusRequest {
+		reqs := make([]StatusRequest, len(services))
+		for i, service := range services {
+			reqs[i] = StatusRequest{ServiceName: service, InstanceName: "w1", Status: "healthy"}
+		}
+		return reqs
+	}
+	tests := []struct {
+		name        string
+		maxReports  int
+		adds        [][]StatusRequest
+		wantDropped []int
+		want        []string
+	}{
+		{"within bounds", 5, [][]StatusRequest{reports("a", "b"), reports("c")}, []int{0, 0}, []string{"a", "b", "c"}},
+		{"oldest dropped", 3, [][]StatusRequest{reports("a", "b"), reports("c", "d")}, []int{0, 1}, []string{"b", "c", "d"}},
+		{"one add past the bound", 2, [][]StatusRequest{reports("a", "b", "c", "d")}, []int{2}, []string{"c", "d"}},
+		{"unbounded", 0, [][]StatusRequest{reports("a", "b"), reports("c", "d")}, []int{0, 0}, []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		buffer := newReportBuffer(filepath.Join(t.TempDir(), "buffer.jsonl"), tt.maxReports)
+		for i, reqs := range tt.adds {
+			dropped, err := buffer.add(reqs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dropped != tt.wantDropped[i] {
+				t.Errorf("%s: add %d dropped %d, want %d", tt.name, i+1, dropped, tt.wantDropped[i])
+			}
+		}
+		buffered, err := buffer.load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, req := range buffered {
+			got = append(got, req.ServiceName)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: buffered %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if newReportBuffer("", 10) != nil {
+		t.Error("buffer created without a path")
+	}
+}
+
+func TestReportBufferFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer.jsonl")
+	buffer := newReportBuffer(path, 10)
+	if buffered, err := buffer.load(); err != nil || len(buffered) != 0 {
+		t.Fatalf("missing file loaded as %v, %v; want an empty buffer", buffered, err)
+	}
+
+	if _, err := buffer.add([]StatusRequest{{ServiceName: "web", InstanceName: "w1", Status: "healthy"}}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("buffer file = %v, %v; want it readable only by its owner", info, err)
+	}
+
+	// A line torn by a crash is skipped and the rest still loads
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"service_name":"web","insta` + "\n")
+	file.Close()
+	if _, err := buffer.add([]StatusRequest{{ServiceName: "db", InstanceName: "d1", Status: "healthy"}}); err != nil {
+		t.Fatal(err)
+	}
+	buffered, err := buffer.load()
+	if err != nil || len(buffered) != 2 || buffered[1].ServiceName != "db" {
+		t.Fatalf("after a torn line loaded %+v, %v; want web then db", buffered, err)
+	}
+
+	if err := buffer.replace(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("emptied buffer left its file behind: %v", err)
+	}
+}
+
+func TestBufferedReportsDeliveredAfterOutage(t *testing.T) {
+	defer func(sleep func(context.Context, time.Duration) error) { retrySleep = sleep }(retrySleep)
+	retrySleep = func(context.Context, time.Duration) error { return nil }
+
+	bufferPath := filepath.Join(t.TempDir(), "buffer.jsonl")
+	down := true
+	var refuseBefore time.Time // reports taken before this are refused with a 400
+	var batches [][]StatusRequest
+	var singles []StatusRequest
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var reports []StatusRequest
+		if r.URL.Path == "/api/v1/report/batch" {
+			json.NewDecoder(r.Body).Decode(&reports)
+			batches = append(batches, reports)
+		} else {
+			var report StatusRequest
+			json.NewDecoder(r.Body).Decode(&report)
+			reports = append(reports, report)
+			singles = append(singles, report)
+		}
+		if reports[0].Timestamp.Before(refuseBefore) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"success": true}`))
+	}, "--buffer-path", bufferPath, "--buffer-max-reports", "2", "--retry-attempts", "2", "--breaker-threshold", "0")
+
+	// Three intervals of outage; the bound keeps the last two
+	var taken []time.Time
+	for i := 0; i < 3; i++ {
+		if err := dc.reportStatus(context.Background()); err == nil {
+			t.Fatal("report during the outage succeeded")
+		}
+		buffered, _ := dc.buffer.load()
+		taken = append(taken, *buffered[len(buffered)-1].Timestamp)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	down = false
+	if err := dc.reportStatus(context.Background()); err != nil {
+		t.Fatalf("report after recovery = %v", err)
+	}
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("flushed %d batches %+v, want one batch of the two newest buffered reports", len(batches), batches)
+	}
+	for i, report := range batches[0] {
+		if report.Timestamp == nil || !report.Timestamp.Equal(taken[i+1]) {
+			t.Errorf("buffered report %d sent with timestamp %v, want the original %v", i+1, report.Timestamp, taken[i+1])
+		}
+	}
+	if len(singles) != 1 || !singles[0].Timestamp.After(taken[2]) {
+		t.Errorf("current report after the flush = %+v, want one fresh report", singles)
+	}
+	if _, err := os.Stat(bufferPath); !os.IsNotExist(err) {
+		t.Errorf("buffer file kept after delivery: %v", err)
+	}
+
+	// A buffered report the server refuses is dropped rather than resent forever
+	down = true
+	dc.reportStatus(context.Background())
+	down, refuseBefore = false, time.Now()
+	singles = nil
+	for i := 0; i < 2; i++ {
+		if err := dc.reportStatus(context.Background()); err != nil {
+			t.Fatalf("report %d after the refusal = %v", i+1, err)
+		}
+	}
+	if len(singles) != 3 || !singles[0].Timestamp.Before(refuseBefore) {
+		t.Errorf("sent %d reports, want the refused buffered one once and two fresh ones", len(singles))
+	}
+	if buffered, _ := dc.buffer.load(); len(buffered) != 0 {
+		t.Errorf("%d reports left buffered after the server refused them", len(buffered))
+	}
+}
+
+func TestFlushBufferKeepsReportsWhenRateLimited(t *testing.T) {
+	code := http.StatusTooManyRequests
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if code == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.WriteHeader(code)
+	}, "--buffer-path", filepath.Join(t.TempDir(), "buffer.jsonl"))
+
+	queued := []StatusRequest{
+		{ServiceName: "test-service", InstanceName: "w1", Status: "healthy"},
+		{ServiceName: "test-service", InstanceName: "w1", Status: "degraded"},
+	}
+	if err := dc.buffer.replace(queued); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.flushBuffer(context.Background()); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("flush while rate limited = %v, want the 429", err)
+	}
+	if buffered, _ := dc.buffer.load(); len(buffered) != 2 {
+		t.Errorf("%d reports buffered after a 429, want both kept for later", len(buffered))
+	}
+
+	// Other refusals drop the batch so it cannot block the buffer forever
+	code = http.StatusBadRequest
+	if err := dc.flushBuffer(context.Background()); err != nil {
+		t.Errorf("flush of a refused batch = %v", err)
+	}
+	if buffered, _ := dc.buffer.load(); len(buffered) != 0 {
+		t.Errorf("%d reports buffered after a 400, want them dropped", len(buffered))
+	}
+}
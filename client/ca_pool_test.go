@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetupTLSConfigTrustsEveryCA(t *testing.T) {
+	// writeSelfSigned certificates are their own CA, standing in for servers
+	// signed by the old and the new root
+	oldCert, _ := writeSelfSigned(t, t.TempDir(), "s01 CA 2025", time.Now().Add(24*time.Hour))
+	newDir := t.TempDir()
+	newCert, _ := writeSelfSigned(t, newDir, "s01 CA 2026", time.Now().Add(24*time.Hour))
+	clientCert, clientKey := writeSelfSigned(t, t.TempDir(), "web-w1", time.Now().Add(24*time.Hour))
+
+	parse := func(path string) *x509.Certificate {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode(data)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		trusted map[string]bool
+	}{
+		{"old only", oldCert, map[string]bool{oldCert: true, newCert: false}},
+		{"old and new", oldCert + "," + newCert, map[string]bool{oldCert: true, newCert: true}},
+		// The directory also holds key.pem, which is skipped
+		{"file and directory", oldCert + ", " + newDir, map[string]bool{oldCert: true, newCert: true}},
+	}
+	for _, tt := range tests {
+		config := &Config{CertFile: clientCert, KeyFile: clientKey, CACertFile: tt.spec, TLSMinVersion: "1.2"}
+		tlsConfig, err := setupTLSConfig(config, discardLogger)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for path, want := range tt.trusted {
+			cert := parse(path)
+			_, err := cert.Verify(x509.VerifyOptions{Roots: tlsConfig.RootCAs, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+			if (err == nil) != want {
+				t.Errorf("%s: %s verified %v, want %v", tt.name, cert.Subject.CommonName, err == nil, want)
+			}
+		}
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("-----BEGIN NONSENSE-----"), 0o600)
+	config := &Config{CertFile: clientCert, KeyFile: clientKey, CACertFile: notPEM, TLSMinVersion: "1.2"}
+	if _, err := setupTLSConfig(config, discardLogger); err == nil || !strings.Contains(err.Error(), "no certificate found") {
+		t.Errorf("CA list without a certificate = %v, want it refused", err)
+	}
+}
+
+func TestLoadConfigChecksEveryCAPath(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, "web-w1", time.Now().Add(24*time.Hour))
+	missing := filepath.Join(dir, "ca-next.pem")
+	t.Setenv("CERT_FILE", certFile)
+	t.Setenv("KEY_FILE", keyFile)
+
+	t.Setenv("CA_CERT_FILE", certFile+","+missing)
+	if _, err := loadTestConfig(t, "--server-url", "https://s01.example:8443"); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("CA list with a missing file = %v, want it named", err)
+	}
+	t.Setenv("CA_CERT_FILE", certFile+","+dir)
+	if _, err := loadTestConfig(t, "--server-url", "https://s01.example:8443"); err != nil {
+		t.Errorf("CA list of a file and a directory = %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// checkCertExpiry warns when a certificate expires within warnWindow. An
+// already expired certificate is an error when rejectExpired is set.
+func checkCertExpiry(leaf *x509.Certificate, path string, warnWindow time.Duration, rejectExpired bool, logger *slog.Logger) error {
+	remaining := time.Until(leaf.NotAfter)
+	switch {
+	case remaining <= 0 && rejectExpired:
+		return fmt.Errorf("certificate %s expired at %s", path, leaf.NotAfter.Format(time.RFC3339))
+	case remaining <= 0:
+		logger.Warn("Certificate has expired",
+			"cert_file", path,
+			"not_after", leaf.NotAfter,
+		)
+	case remaining <= warnWindow:
+		logger.Warn("Certificate expires soon",
+			"cert_file", path,
+			"not_after", leaf.NotAfter,
+			"expires_in", remaining.Round(time.Minute).String(),
+		)
+	}
+	return nil
+}
+
+// caCertPaths splits a CA_CERT_FILE value, a comma-separated list of PEM
+// files and directories, into its entries
+func caCertPaths(spec string) []string {
+	var paths []string
+	for _, path := range strings.Split(spec, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// loadCAPool reads every CA certificate named by spec into one pool, so
+// certificates signed by any of them verify, e.g. by both the old and the new
+// CA during a rotation. A directory contributes each file directly inside it,
+// skipping hidden entries such as the ..data links of mounted Kubernetes
+// secrets. Files without a certificate are skipped with a warning; it is an
+// error when none is found at all.
+func loadCAPool(spec string, logger *slog.Logger) (*x509.CertPool, error) {
+	var files []string
+	for _, path := range caCertPaths(spec) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA directory: %v", err)
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			file := filepath.Join(path, entry.Name())
+			if info, err := os.Stat(file); err != nil || info.IsDir() {
+				continue
+			}
+			files = append(files, file)
+		}
+	}
+
+	pool := x509.NewCertPool()
+	parsed := 0
+	for _, file := range files {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			logger.Warn("No CA certificate found in file, skipping it", "ca_cert_file", file)
+			continue
+		}
+		parsed++
+	}
+	if parsed == 0 {
+		return nil, fmt.Errorf("failed to parse CA certificate: no certificate found in %s", spec)
+	}
+	return pool, nil
+}
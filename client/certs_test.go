@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a self-signed certificate for cn, valid until
+// notAfter, and its key into dir as cert.pem and key.pem, replacing any
+// written before. The certificate is its own CA.
+func writeSelfSigned(t *testing.T, dir, cn string, notAfter time.Time) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notAfter.Add(-48 * time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for path, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func TestReloadCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, "web-w1", time.Now().Add(24*time.Hour))
+	config := &Config{
+		ServerURL:         "https://s01.example:8443",
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		CACertFile:        certFile,
+		Timeout:           5,
+		TLSMinVersion:     "1.2",
+		RejectExpiredCert: true,
+	}
+	httpClient, err := newHTTPClient(config, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := &S01Client{config: config, logger: discardLogger, httpClient: httpClient}
+
+	// presented returns the CN of the certificate the client currently presents
+	presented := func() string {
+		t.Helper()
+		tlsConfig := dc.httpClient.Transport.(*http.Transport).TLSClientConfig
+		leaf, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	writeSelfSigned(t, dir, "web-w1-rotated", time.Now().Add(24*time.Hour))
+	if cn := presented(); cn != "web-w1" {
+		t.Fatalf("client presents %q before the reload", cn)
+	}
+	dc.reloadCertificates()
+	if cn := presented(); cn != "web-w1-rotated" {
+		t.Fatalf("client presents %q after the reload, want the rotated certificate", cn)
+	}
+
+	// An expired replacement is refused and the current certificate kept
+	current := dc.httpClient
+	writeSelfSigned(t, dir, "web-w1-expired", time.Now().Add(-time.Hour))
+	dc.reloadCertificates()
+	if dc.httpClient != current || presented() != "web-w1-rotated" {
+		t.Errorf("client presents %q after reloading an expired certificate, want web-w1-rotated kept", presented())
+	}
+}
+
+func TestSetupTLSConfigCertExpiry(t *testing.T) {
+	tests := []struct {
+		name          string
+		validFor      time.Duration
+		rejectExpired bool
+		wantLog       string
+		wantErr       bool
+	}{
+		{"long-lived", 365 * 24 * time.Hour, true, "", false},
+		{"short-lived", 2 * 24 * time.Hour, false, "Certificate expires soon", false},
+		{"expired", -time.Minute, false, "Certificate has expired", false},
+		{"expired and rejected", -time.Minute, true, "", true},
+	}
+	for _, tt := range tests {
+		certFile, keyFile := writeSelfSigned(t, t.TempDir(), "web-w1", time.Now().Add(tt.validFor))
+		config := &Config{
+			CertFile:           certFile,
+			KeyFile:            keyFile,
+			CACertFile:         certFile,
+			TLSMinVersion:      "1.2",
+			CertExpiryWarnDays: 14,
+			RejectExpiredCert:  tt.rejectExpired,
+		}
+		var logs strings.Builder
+		_, err := setupTLSConfig(config, slog.New(slog.NewTextHandler(&logs, nil)))
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantLog == "" && strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), tt.wantLog) {
+			t.Errorf("%s: logged %q, want %q", tt.name, logs.String(), tt.wantLog)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cgroup modes, selecting where CPU and memory usage are read from. Inside a
+// container /proc shows the whole host, so usage there says little about how
+// close the container is to its own limits.
+const (
+	cgroupModeAuto   = "auto"   // the cgroup when it sets a limit, /proc otherwise
+	cgroupModeCgroup = "cgroup" // the cgroup, against its limits or else the host's capacity
+	cgroupModeHost   = "host"   // /proc only
+)
+
+// cgroupRoot is where the process's cgroup hierarchy is mounted. Container
+// runtimes mount the container's own cgroup here.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupUnlimited is the smallest cgroup v1 limit treated as "no limit"; v1
+// reports an unset limit as a page-aligned math.MaxInt64
+const cgroupUnlimited = 1 << 62
+
+// cgroupVersion reports whether cgroupRoot holds a unified (2) or legacy (1)
+// hierarchy, or 0 when neither is found
+func cgroupVersion() int {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		return 2
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "memory")); err == nil {
+		return 1
+	}
+	return 0
+}
+
+// cgroupMemory returns the cgroup's working set, i.e. usage minus inactive
+// file cache the kernel reclaims before OOM-killing, and its memory limit,
+// which is 0 when none is set
+func cgroupMemory() (used, limit uint64, err error) {
+	var usageFile, limitFile, statFile, inactiveKey string
+	switch cgroupVersion() {
+	case 2:
+		usageFile, limitFile, statFile, inactiveKey = "memory.current", "memory.max", "memory.stat", "inactive_file"
+	case 1:
+		usageFile, limitFile, statFile, inactiveKey = "memory/memory.usage_in_bytes", "memory/memory.limit_in_bytes", "memory/memory.stat", "total_inactive_file"
+	default:
+		return 0, 0, fmt.Errorf("no cgroup hierarchy at %s", cgroupRoot)
+	}
+
+	used, err = readCgroupValue(usageFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	limit, err = readCgroupValue(limitFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	if limit >= cgroupUnlimited {
+		limit = 0
+	}
+
+	if stat, err := os.ReadFile(filepath.Join(cgroupRoot, statFile)); err == nil {
+		if inactive, ok := cgroupStatValue(string(stat), inactiveKey); ok && inactive <= used {
+			used -= inactive
+		}
+	}
+	return used, limit, nil
+}
+
+// cgroupCPU returns the CPU time the cgroup has consumed and the number of
+// CPUs its quota allows, which is 0 when no quota is set
+func cgroupCPU() (usage time.Duration, cpus float64, err error) {
+	switch cgroupVersion() {
+	case 2:
+		stat, err := os.ReadFile(filepath.Join(cgroupRoot, "cpu.stat"))
+		if err != nil {
+			return 0, 0, err
+		}
+		usec, ok := cgroupStatValue(string(stat), "usage_usec")
+		if !ok {
+			return 0, 0, fmt.Errorf("no usage_usec in cpu.stat")
+		}
+
+		// cpu.max is "<quota> <period>" with quota "max" when unlimited
+		if data, err := os.ReadFile(filepath.Join(cgroupRoot, "cpu.max")); err == nil {
+			fields := strings.Fields(string(data))
+			if len(fields) == 2 && fields[0] != "max" {
+				cpus = cpuQuota(fields[0], fields[1])
+			}
+		}
+		return time.Duration(usec) * time.Microsecond, cpus, nil
+	case 1:
+		nanos, err := readCgroupValue("cpuacct/cpuacct.usage")
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// cfs_quota_us is -1 when unlimited
+		quota, quotaErr := os.ReadFile(filepath.Join(cgroupRoot, "cpu/cpu.cfs_quota_us"))
+		period, periodErr := os.ReadFile(filepath.Join(cgroupRoot, "cpu/cpu.cfs_period_us"))
+		if quotaErr == nil && periodErr == nil {
+			cpus = cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+		}
+		return time.Duration(nanos), cpus, nil
+	default:
+		return 0, 0, fmt.Errorf("no cgroup hierarchy at %s", cgroupRoot)
+	}
+}
+
+// cpuQuota converts a CFS quota and period, both in microseconds, to a
+// number of CPUs, or 0 when either is invalid or the quota is unlimited
+func cpuQuota(quota, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// getCgroupCPUUsage measures the cgroup's CPU usage over sampleInterval as a
+// percentage of its quota, or of every host CPU when it has none. ok is false
+// when the cgroup is unreadable, or in auto mode when it sets no quota.
+func getCgroupCPUUsage(sampleInterval time.Duration, mode string) (float64, bool) {
+	usage1, cpus, err := cgroupCPU()
+	if err != nil || (cpus == 0 && mode != cgroupModeCgroup) {
+		return 0, false
+	}
+	start := time.Now()
+	time.Sleep(sampleInterval)
+	usage2, _, err := cgroupCPU()
+	if err != nil {
+		return 0, false
+	}
+
+	if cpus == 0 {
+		cpus = float64(runtime.NumCPU())
+	}
+	return cgroupCPUPercent(usage2-usage1, time.Since(start), cpus), true
+}
+
+// cgroupCPUPercent is the share of cpus fully used for elapsed that used
+// represents, capped at 100
+func cgroupCPUPercent(used, elapsed time.Duration, cpus float64) float64 {
+	if used <= 0 || elapsed <= 0 || cpus <= 0 {
+		return 0
+	}
+	return min(float64(used)/(float64(elapsed)*cpus)*100.0, 100.0)
+}
+
+// getCgroupMemoryUsage returns the cgroup's working set as a percentage of
+// its limit, or of host memory when it has none. ok is false when the cgroup
+// is unreadable, or in auto mode when it sets no limit.
+func getCgroupMemoryUsage(mode string) (float64, bool) {
+	used, limit, err := cgroupMemory()
+	if err != nil || (limit == 0 && mode != cgroupModeCgroup) {
+		return 0, false
+	}
+	if limit == 0 {
+		data, err := os.ReadFile(meminfoPath)
+		if err != nil {
+			return 0, false
+		}
+		limit = memTotalBytes(string(data))
+		if limit == 0 {
+			return 0, false
+		}
+	}
+	return min(float64(used)/float64(limit)*100.0, 100.0), true
+}
+
+// memTotalBytes returns MemTotal from /proc/meminfo contents in bytes
+func memTotalBytes(content string) uint64 {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "MemTotal:") {
+			return parseMemInfoValue(line) * 1024
+		}
+	}
+	return 0
+}
+
+// readCgroupValue reads a single-number cgroup file relative to cgroupRoot.
+// v2's "max" reads as math.MaxUint64, i.e. unlimited.
+func readCgroupValue(name string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(cgroupRoot, name))
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return ^uint64(0), nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// cgroupStatValue finds key in a flat-keyed cgroup file such as memory.stat
+// or cpu.stat, whose lines are "<key> <value>"
+func cgroupStatValue(content, key string) (uint64, bool) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			value, err := strconv.ParseUint(fields[1], 10, 64)
+			return value, err == nil
+		}
+	}
+	return 0, false
+}
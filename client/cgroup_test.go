@@ -0,0 +1,201 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const mib = 1 << 20
+
+// fakeCgroup points cgroupRoot at a directory holding files, keyed by their
+// path relative to the hierarchy root
+func fakeCgroup(t *testing.T, files map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := cgroupRoot
+	cgroupRoot = root
+	t.Cleanup(func() { cgroupRoot = saved })
+}
+
+// v2Memory is a unified hierarchy whose memory use includes inactive bytes
+// of file cache, limited to limit ("max" for none)
+func v2Memory(usage, inactive int, limit string) map[string]string {
+	return map[string]string{
+		"cgroup.controllers": "cpu memory pids\n",
+		"memory.current":     strconv.Itoa(usage) + "\n",
+		"memory.max":         limit + "\n",
+		"memory.stat":        "anon 4096\ninactive_file " + strconv.Itoa(inactive) + "\nactive_file 0\n",
+	}
+}
+
+// v1Memory is the same for a legacy hierarchy
+func v1Memory(usage, inactive int, limit string) map[string]string {
+	return map[string]string{
+		"memory/memory.usage_in_bytes": strconv.Itoa(usage) + "\n",
+		"memory/memory.limit_in_bytes": limit + "\n",
+		"memory/memory.stat":           "cache 0\ntotal_inactive_file " + strconv.Itoa(inactive) + "\n",
+	}
+}
+
+func TestCgroupMemory(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		wantUsed  uint64
+		wantLimit uint64
+		wantErr   bool
+	}{
+		{"v2 limited", v2Memory(600*mib, 100*mib, strconv.Itoa(1024*mib)), 500 * mib, 1024 * mib, false},
+		{"v2 unlimited", v2Memory(600*mib, 100*mib, "max"), 500 * mib, 0, false},
+		{"v2 cache above usage is ignored", v2Memory(100*mib, 200*mib, strconv.Itoa(1024*mib)), 100 * mib, 1024 * mib, false},
+		{"v1 limited", v1Memory(300*mib, 44*mib, strconv.Itoa(512*mib)), 256 * mib, 512 * mib, false},
+		{"v1 unlimited", v1Memory(300*mib, 44*mib, "9223372036854771712"), 256 * mib, 0, false},
+		{"v2 missing usage", map[string]string{"cgroup.controllers": "", "memory.max": "max"}, 0, 0, true},
+		{"no hierarchy", map[string]string{"unrelated": ""}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		fakeCgroup(t, tt.files)
+		used, limit, err := cgroupMemory()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if used != tt.wantUsed || limit != tt.wantLimit {
+			t.Errorf("%s: used %d of %d, want %d of %d", tt.name, used, limit, tt.wantUsed, tt.wantLimit)
+		}
+	}
+}
+
+func TestGetMemoryUsageCgroupModes(t *testing.T) {
+	defer func(path string) { meminfoPath = path }(meminfoPath)
+	// The host has 4 GiB, 75% of it in use
+	meminfoPath = writeFile(t, "meminfo", "MemTotal: 4194304 kB\nMemAvailable: 1048576 kB\n")
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		mode  string
+		want  float64
+	}{
+		{"v2 limit in auto", v2Memory(600*mib, 88*mib, strconv.Itoa(1024*mib)), cgroupModeAuto, 50},
+		{"v1 limit in auto", v1Memory(448*mib, 0, strconv.Itoa(512*mib)), cgroupModeAuto, 87.5},
+		{"host mode ignores the cgroup", v2Memory(600*mib, 88*mib, strconv.Itoa(1024*mib)), cgroupModeHost, 75},
+		{"no limit in auto reads /proc", v2Memory(1024*mib, 0, "max"), cgroupModeAuto, 75},
+		{"no limit in cgroup mode uses host memory", v2Memory(1024*mib, 0, "max"), cgroupModeCgroup, 25},
+		{"usage over the limit is capped", v2Memory(2048*mib, 0, strconv.Itoa(1024*mib)), cgroupModeAuto, 100},
+		{"bare metal", nil, cgroupModeAuto, 75},
+	}
+	for _, tt := range tests {
+		fakeCgroup(t, tt.files)
+		if got := getMemoryUsage(tt.mode); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: usage %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCgroupCPU(t *testing.T) {
+	v2 := func(cpuMax string) map[string]string {
+		return map[string]string{
+			"cgroup.controllers": "cpu memory\n",
+			"cpu.stat":           "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n",
+			"cpu.max":            cpuMax,
+		}
+	}
+	v1 := func(quota string) map[string]string {
+		return map[string]string{
+			"memory/memory.usage_in_bytes": "0",
+			"cpuacct/cpuacct.usage":        "2500000000\n",
+			"cpu/cpu.cfs_quota_us":         quota,
+			"cpu/cpu.cfs_period_us":        "100000\n",
+		}
+	}
+	tests := []struct {
+		name     string
+		files    map[string]string
+		wantCPUs float64
+		wantErr  bool
+	}{
+		{"v2 quota", v2("150000 100000\n"), 1.5, false},
+		{"v2 unlimited", v2("max 100000\n"), 0, false},
+		{"v2 malformed cpu.max", v2("lots\n"), 0, false},
+		{"v1 quota", v1("50000\n"), 0.5, false},
+		{"v1 unlimited", v1("-1\n"), 0, false},
+		{"v2 without usage", map[string]string{"cgroup.controllers": "", "cpu.stat": "user_usec 1\n"}, 0, true},
+	}
+	for _, tt := range tests {
+		fakeCgroup(t, tt.files)
+		usage, cpus, err := cgroupCPU()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if usage != 2500*time.Millisecond || cpus != tt.wantCPUs {
+			t.Errorf("%s: %v used with %v CPUs, want 2.5s with %v", tt.name, usage, cpus, tt.wantCPUs)
+		}
+	}
+}
+
+func TestCgroupCPUPercent(t *testing.T) {
+	tests := []struct {
+		used, elapsed time.Duration
+		cpus          float64
+		want          float64
+	}{
+		{500 * time.Millisecond, time.Second, 1, 50},
+		{500 * time.Millisecond, time.Second, 0.5, 100},
+		{time.Second, time.Second, 4, 25},
+		{3 * time.Second, time.Second, 2, 100}, // capped
+		{0, time.Second, 2, 0},
+		{time.Second, 0, 2, 0},
+	}
+	for _, tt := range tests {
+		if got := cgroupCPUPercent(tt.used, tt.elapsed, tt.cpus); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("cgroupCPUPercent(%v, %v, %v) = %v, want %v", tt.used, tt.elapsed, tt.cpus, got, tt.want)
+		}
+	}
+}
+
+func TestGetCgroupCPUUsageNeedsQuotaInAuto(t *testing.T) {
+	fakeCgroup(t, map[string]string{"cgroup.controllers": "", "cpu.stat": "usage_usec 100\n", "cpu.max": "max 100000\n"})
+	if _, ok := getCgroupCPUUsage(time.Millisecond, cgroupModeAuto); ok {
+		t.Error("auto mode measured a cgroup without a CPU quota")
+	}
+	if usage, ok := getCgroupCPUUsage(time.Millisecond, cgroupModeCgroup); !ok || usage != 0 {
+		t.Errorf("cgroup mode = %v, %v; want the idle cgroup measured against the host CPUs", usage, ok)
+	}
+}
+
+func TestCgroupModeFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", cgroupModeAuto},
+		{"cgroup", cgroupModeCgroup},
+		{"host", cgroupModeHost},
+		{"container", cgroupModeAuto},
+		{"HOST", cgroupModeAuto},
+	}
+	for _, tt := range tests {
+		t.Setenv("HEALTH_CGROUP_MODE", tt.value)
+		if got := defaultHealthConfig(t).CgroupMode; got != tt.want {
+			t.Errorf("HEALTH_CGROUP_MODE=%q loaded as %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// hangStatfs makes statfs block, as on a dead NFS mount, until the test ends
+func hangStatfs(t *testing.T) {
+	t.Helper()
+	release := make(chan struct{})
+	saved := statfs
+	t.Cleanup(func() {
+		close(release)
+		statfs = saved
+	})
+	statfs = func(string, *syscall.Statfs_t) error {
+		<-release
+		return syscall.EIO
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	hangStatfs(t)
+	t.Setenv("HEALTH_CHECK_TIMEOUT", "1")
+	config := defaultHealthConfig(t)
+	config.disableChecks([]string{"cpu", "network", "load_average", "process", "gpu", "temperature", "interface"}, discardLogger)
+	config.HealthChecks.Disk.Paths = []string{"/mnt/nfs"}
+
+	start := time.Now()
+	metrics := performHealthChecks(context.Background(), config)
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("health checks took %v, want the 1s deadline", elapsed)
+	}
+
+	checks := make(map[string]HealthCheck)
+	for _, check := range metrics.Checks {
+		checks[check.Name] = check
+	}
+	disk := checks["Disk Usage"]
+	if disk.Status != "unknown" || !strings.HasPrefix(disk.Message, "timeout") {
+		t.Errorf("hung disk check = %+v, want unknown with a timeout message", disk)
+	}
+	if memory := checks["Memory Usage"]; memory.Status == "unknown" || memory.Status == "" {
+		t.Errorf("memory check = %+v, want it to finish despite the hung disk", memory)
+	}
+	for _, contribution := range metrics.ScoreBreakdown {
+		if contribution.Check == "Disk Usage" && contribution.Points != 0 {
+			t.Errorf("timed out disk check earned %d points", contribution.Points)
+		}
+	}
+}
+
+func TestReportSentDespiteHungCheck(t *testing.T) {
+	hangStatfs(t)
+	var sent StatusRequest
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Write([]byte(`{"success": true}`))
+	})
+	dc.healthConfig.HealthChecks.Disk.Enabled = true
+	dc.healthConfig.HealthChecks.Disk.Paths = []string{"/mnt/nfs"}
+	dc.healthConfig.Reporting.CheckTimeoutSeconds = 1
+
+	done := make(chan error, 1)
+	go func() { done <- dc.reportStatus(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("report blocked on the hung disk check")
+	}
+	if sent.HealthMetrics == nil || len(sent.HealthMetrics.Checks) != 1 || sent.HealthMetrics.Checks[0].Status != "unknown" {
+		t.Errorf("report sent with metrics %+v, want the timed out disk check", sent.HealthMetrics)
+	}
+}
+
+func TestHealthCheckTimeoutFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 30},
+		{"5", 5},
+		{"0", 0}, // no deadline
+		{"-1", 30},
+		{"soon", 30},
+	}
+	for _, tt := range tests {
+		t.Setenv("HEALTH_CHECK_TIMEOUT", tt.value)
+		if got := defaultHealthConfig(t).Reporting.CheckTimeoutSeconds; got != tt.want {
+			t.Errorf("HEALTH_CHECK_TIMEOUT=%q loaded as %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
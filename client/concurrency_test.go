@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sleepySection is a scored section taking delay to produce one healthy check
+func sleepySection(name string, delay time.Duration) healthSection {
+	return healthSection{name: name, weight: 10, scored: true, run: func() sectionResult {
+		time.Sleep(delay)
+		return sectionResult{checks: []HealthCheck{{Name: name, Status: "healthy"}}, points: 10}
+	}}
+}
+
+func TestHealthSectionsRunConcurrently(t *testing.T) {
+	// Finishing in reverse order; run one after another they take 1.2s
+	sections := []healthSection{
+		sleepySection("CPU Usage", 400*time.Millisecond),
+		sleepySection("Memory Usage", 300*time.Millisecond),
+		sleepySection("Disk Usage", 200*time.Millisecond),
+		sleepySection("Network Connectivity", 300*time.Millisecond),
+	}
+
+	start := time.Now()
+	metrics := runHealthSections(context.Background(), sections)
+	elapsed := time.Since(start)
+
+	if elapsed >= 800*time.Millisecond {
+		t.Errorf("sections took %v, want about the slowest one's 400ms", elapsed)
+	}
+	var names []string
+	for _, check := range metrics.Checks {
+		names = append(names, check.Name)
+	}
+	if len(names) != 4 || names[0] != "CPU Usage" || names[1] != "Memory Usage" || names[2] != "Disk Usage" || names[3] != "Network Connectivity" {
+		t.Errorf("checks in order %v, want section order whatever order they finished in", names)
+	}
+	if metrics.OverallScore != 40 {
+		t.Errorf("score = %d, want every section's 10 points", metrics.OverallScore)
+	}
+}
+
+func TestAloneSectionFinishesFirst(t *testing.T) {
+	var aloneDone atomic.Bool
+	var startedEarly atomic.Bool
+	alone := healthSection{name: "CPU Usage", weight: 10, scored: true, alone: true, run: func() sectionResult {
+		time.Sleep(100 * time.Millisecond)
+		aloneDone.Store(true)
+		return sectionResult{points: 10}
+	}}
+	other := func(name string) healthSection {
+		return healthSection{name: name, weight: 10, scored: true, run: func() sectionResult {
+			if !aloneDone.Load() {
+				startedEarly.Store(true)
+			}
+			time.Sleep(100 * time.Millisecond)
+			return sectionResult{points: 10}
+		}}
+	}
+
+	start := time.Now()
+	metrics := runHealthSections(context.Background(), []healthSection{alone, other("Memory Usage"), other("Disk Usage")})
+	elapsed := time.Since(start)
+
+	if startedEarly.Load() {
+		t.Error("a section started while the alone section was still sampling")
+	}
+	// The alone section, then the rest together
+	if elapsed < 200*time.Millisecond || elapsed >= 350*time.Millisecond {
+		t.Errorf("sections took %v, want about 200ms", elapsed)
+	}
+	if metrics.OverallScore != 30 {
+		t.Errorf("score = %d, want 30", metrics.OverallScore)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClientYAMLConfigMatchesJSON(t *testing.T) {
+	const yaml = `# client settings
+service_name: "billing"
+report_interval: 45
+reject_expired_cert: true
+error_log_pattern: '\bFATAL\b # not a comment'
+labels: zone=us-east-1a, tier=web
+`
+	const json = `{
+	"service_name": "billing",
+	"report_interval": 45,
+	"reject_expired_cert": true,
+	"error_log_pattern": "\\bFATAL\\b # not a comment",
+	"labels": {"tier": "web", "zone": "us-east-1a"}
+}`
+
+	// load writes content to path under a fresh working directory; the
+	// server URL flag spares the certificate checks
+	load := func(path, content string) *Config {
+		t.Helper()
+		chdirTemp(t)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig([]string{"--server-url", "unix:///run/s01.sock"})
+		if err != nil {
+			t.Fatalf("loadConfig from %s: %v", path, err)
+		}
+		return config
+	}
+
+	fromJSON := load("client-config.json", json)
+	if fromJSON.ServiceName != "billing" || fromJSON.ReportInterval != 45 || fromJSON.Labels["zone"] != "us-east-1a" {
+		t.Fatalf("JSON settings not applied: %+v", fromJSON)
+	}
+	for _, path := range []string{"client-config.yaml", "client-config.yml", filepath.Join("config", "client-config.yaml")} {
+		if fromYAML := load(path, yaml); !reflect.DeepEqual(fromYAML, fromJSON) {
+			t.Errorf("%s = %+v\nwant the JSON file's %+v", path, fromYAML, fromJSON)
+		}
+	}
+}
+
+func TestClientYAMLConfigErrors(t *testing.T) {
+	for _, content := range []string{
+		"report_interval: soon\n",
+		"labels: zone\n",
+		"service_name\n",
+	} {
+		chdirTemp(t)
+		if err := os.WriteFile("client-config.yaml", []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig([]string{"--server-url", "unix:///run/s01.sock"}); err == nil {
+			t.Errorf("%q loaded without error", content)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// reportCounters tracks how full report cycles fare, so a flaky server
+// (failures and retries climbing while reports still get through) can be told
+// apart from a client that stopped reporting (nothing increasing at all)
+type reportCounters struct {
+	attempted   atomic.Uint64 // report cycles started
+	succeeded   atomic.Uint64 // cycles the server accepted, retries included
+	failed      atomic.Uint64 // cycles that gave up or were cancelled
+	retries     atomic.Uint64 // extra attempts within cycles
+	lastSuccess atomic.Int64  // unix time of the last accepted cycle; 0 before the first
+}
+
+// recordResult counts the outcome of a report cycle that ended at now
+func (rc *reportCounters) recordResult(err error, now time.Time) {
+	if err != nil {
+		rc.failed.Add(1)
+		return
+	}
+	rc.succeeded.Add(1)
+	rc.lastSuccess.Store(now.Unix())
+}
+
+// log writes the counters at info level
+func (rc *reportCounters) log(logger *slog.Logger) {
+	logger.Info("Report counters",
+		"reports_attempted", rc.attempted.Load(),
+		"reports_succeeded", rc.succeeded.Load(),
+		"reports_failed", rc.failed.Load(),
+		"retries_total", rc.retries.Load(),
+		"last_success_unix", rc.lastSuccess.Load(),
+	)
+}
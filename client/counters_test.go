@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportCountersFollowOutcomes(t *testing.T) {
+	defer func(sleep func(context.Context, time.Duration) error) { retrySleep = sleep }(retrySleep)
+	retrySleep = func(context.Context, time.Duration) error { return nil }
+
+	// Status codes the server answers with, one per request, in order
+	var replies []int
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		code := http.StatusOK
+		if len(replies) > 0 {
+			code, replies = replies[0], replies[1:]
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(`{"status": "ok"}`))
+	}, "--retry-attempts", "3", "--breaker-threshold", "0")
+
+	type counts struct{ attempted, succeeded, failed, retries uint64 }
+	tests := []struct {
+		name    string
+		replies []int
+		wantErr bool
+		want    counts
+	}{
+		{"first try", []int{200}, false, counts{1, 1, 0, 0}},
+		{"after a retry", []int{503, 200}, false, counts{2, 2, 0, 1}},
+		{"server down", []int{503, 503, 503}, true, counts{3, 2, 1, 3}},
+		{"rejected every time", []int{400, 400, 400}, true, counts{4, 2, 2, 5}},
+		{"recovered", []int{200}, false, counts{5, 3, 2, 5}},
+	}
+	var lastSuccess int64
+	for _, tt := range tests {
+		replies = tt.replies
+		before := time.Now().Unix()
+		err := dc.reportStatus(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		got := counts{dc.counters.attempted.Load(), dc.counters.succeeded.Load(), dc.counters.failed.Load(), dc.counters.retries.Load()}
+		if got != tt.want {
+			t.Errorf("%s: counters %+v, want %+v", tt.name, got, tt.want)
+		}
+
+		success := dc.counters.lastSuccess.Load()
+		if tt.wantErr && success != lastSuccess {
+			t.Errorf("%s: failure moved last_success_unix from %d to %d", tt.name, lastSuccess, success)
+		}
+		if !tt.wantErr && success < before {
+			t.Errorf("%s: last_success_unix %d, want at least %d", tt.name, success, before)
+		}
+		lastSuccess = success
+	}
+
+	// The exporter and the periodic log line show the same numbers
+	_, body := scrape(dc, http.MethodGet)
+	for _, line := range []string{
+		"client_reports_attempted_total 5",
+		"client_reports_succeeded_total 3",
+		"client_reports_failed_total 2",
+		"client_report_retries_total 5",
+		"# TYPE client_reports_failed_total counter",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+
+	if !strings.Contains(body, "client_last_success_unix "+strconv.FormatFloat(float64(lastSuccess), 'g', -1, 64)+"\n") {
+		t.Errorf("client_last_success_unix is not %d in:\n%s", lastSuccess, body)
+	}
+
+	var logs bytes.Buffer
+	dc.counters.log(slog.New(slog.NewJSONHandler(&logs, nil)))
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]float64{"reports_attempted": 5, "reports_succeeded": 3, "reports_failed": 2, "retries_total": 5, "last_success_unix": float64(lastSuccess)} {
+		if entry[key] != want {
+			t.Errorf("logged %s = %v, want %v", key, entry[key], want)
+		}
+	}
+}
+
+func TestCancelledReportCountsAsFailed(t *testing.T) {
+	var rc reportCounters
+	rc.recordResult(context.Canceled, time.Unix(100, 0))
+	rc.recordResult(nil, time.Unix(200, 0))
+	rc.recordResult(errors.New("connection refused"), time.Unix(300, 0))
+	if rc.failed.Load() != 2 || rc.succeeded.Load() != 1 || rc.lastSuccess.Load() != 200 {
+		t.Errorf("failed %d, succeeded %d, last success %d; want 2, 1 and 200", rc.failed.Load(), rc.succeeded.Load(), rc.lastSuccess.Load())
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CustomCheck is an operator-supplied check run as an external command. Its
+// exit code is the status: 0 healthy, 1 degraded, 2 unhealthy; anything else,
+// or a failure to run, makes it unknown. The first line of stdout is the value.
+type CustomCheck struct {
+	Name           string   `json:"name"`
+	Command        []string `json:"command"`         // program and arguments, run without a shell
+	TimeoutSeconds int      `json:"timeout_seconds"` // defaults to defaultCustomCheckTimeout
+	Weight         int      `json:"weight"`
+}
+
+const (
+	// defaultCustomCheckTimeout bounds a custom check without timeout_seconds
+	defaultCustomCheckTimeout = 10 * time.Second
+	// maxCustomCheckOutput is how much of a custom check's stdout is read
+	maxCustomCheckOutput = 4096
+	// maxCustomCheckValue is the longest value kept from that output
+	maxCustomCheckValue = 256
+)
+
+// runCustomCheck runs check and scores it from the command's exit code
+func runCustomCheck(check CustomCheck, factors scoreFactors) (HealthCheck, int) {
+	result := HealthCheck{
+		Name: check.Name,
+	}
+
+	timeout := time.Duration(check.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultCustomCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout limitedBuffer
+	cmd := exec.CommandContext(ctx, check.Command[0], check.Command[1:]...)
+	cmd.Stdout = &stdout
+	// Children that inherited stdout must not keep Wait blocked past the timeout
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	result.Value = sanitizeCheckOutput(stdout.String())
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.Status = "unknown"
+		result.Message = fmt.Sprintf("timeout: command did not finish within %s", timeout)
+	case err == nil:
+		result.Status = "healthy"
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		result.Status = "degraded"
+		result.Message = "Custom check reported degraded"
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 2:
+		result.Status = "unhealthy"
+		result.Message = "Custom check reported unhealthy"
+	case errors.As(err, &exitErr):
+		result.Status = "unknown"
+		result.Message = fmt.Sprintf("unexpected exit code %d", exitErr.ExitCode())
+	default:
+		result.Status = "unknown"
+		result.Message = fmt.Sprintf("failed to run command: %v", err)
+	}
+	return result, factors.points(result.Status, check.Weight)
+}
+
+// sanitizeCheckOutput keeps the first line of output, without control
+// characters and bounded to maxCustomCheckValue bytes
+func sanitizeCheckOutput(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	line = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, line)
+	line = strings.TrimSpace(line)
+	if len(line) > maxCustomCheckValue {
+		line = strings.ToValidUTF8(line[:maxCustomCheckValue], "")
+	}
+	return line
+}
+
+// limitedBuffer keeps the first maxCustomCheckOutput bytes written to it and
+// discards the rest, so a chatty command can neither block nor balloon memory
+type limitedBuffer struct {
+	buf []byte
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxCustomCheckOutput - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(len(p), room)]...)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return string(b.buf)
+}
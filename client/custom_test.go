@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCustomCheckExitCodes(t *testing.T) {
+	factors := scoreFactors{degraded: 0.5, unhealthy: 0.1}
+	tests := []struct {
+		script     string
+		wantStatus string
+		wantValue  string
+		wantPoints int
+		wantMsg    string
+	}{
+		{"echo 0 messages queued", "healthy", "0 messages queued", 20, ""},
+		{"echo 1500 messages queued; exit 1", "degraded", "1500 messages queued", 10, "degraded"},
+		{"echo consumer down; exit 2", "unhealthy", "consumer down", 2, "unhealthy"},
+		{"exit 3", "unknown", "", 0, "unexpected exit code 3"},
+		{"echo first; echo second; exit 0", "healthy", "first", 20, ""},
+		{"echo oops >&2; exit 1", "degraded", "", 10, "degraded"},
+	}
+	for _, tt := range tests {
+		check, points := runCustomCheck(CustomCheck{Name: "queue", Command: []string{"sh", "-c", tt.script}, Weight: 20}, factors)
+		if check.Name != "queue" || check.Status != tt.wantStatus || check.Value != tt.wantValue || points != tt.wantPoints {
+			t.Errorf("%q: %+v worth %d, want %s %q worth %d", tt.script, check, points, tt.wantStatus, tt.wantValue, tt.wantPoints)
+		}
+		if !strings.Contains(check.Message, tt.wantMsg) {
+			t.Errorf("%q: message %q, want it to mention %q", tt.script, check.Message, tt.wantMsg)
+		}
+	}
+
+	check, points := runCustomCheck(CustomCheck{Name: "missing", Command: []string{"/nonexistent/check-queue"}, Weight: 20}, factors)
+	if check.Status != "unknown" || points != 0 || !strings.HasPrefix(check.Message, "failed to run command") {
+		t.Errorf("missing command: %+v worth %d, want unknown", check, points)
+	}
+}
+
+func TestRunCustomCheckTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"slow command", "exec sleep 10"},
+		// A background child keeps stdout open after the shell exits
+		{"lingering child", "sleep 10 & echo started; sleep 10"},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		check, points := runCustomCheck(CustomCheck{Name: "slow", Command: []string{"sh", "-c", tt.script}, TimeoutSeconds: 1, Weight: 10}, scoreFactors{})
+		if elapsed := time.Since(start); elapsed > 4*time.Second {
+			t.Errorf("%s: check took %v despite its 1s timeout", tt.name, elapsed)
+		}
+		if check.Status != "unknown" || points != 0 || !strings.HasPrefix(check.Message, "timeout") {
+			t.Errorf("%s: %+v worth %d, want unknown with a timeout message", tt.name, check, points)
+		}
+	}
+}
+
+func TestSanitizeCheckOutput(t *testing.T) {
+	long := strings.Repeat("x", maxCustomCheckValue+50)
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"", ""},
+		{"  ok  \n", "ok"},
+		{"\n\nlag 3s\nmore detail\n", "lag 3s"},
+		{"\x1b[31mred\x1b[0m\tok\r\n", "[31mred[0mok"},
+		{"bad \xff bytes", "bad  bytes"},
+		{long, long[:maxCustomCheckValue]},
+		// Cutting mid-rune drops the partial character
+		{strings.Repeat("x", maxCustomCheckValue-1) + "é", strings.Repeat("x", maxCustomCheckValue-1)},
+	}
+	for _, tt := range tests {
+		if got := sanitizeCheckOutput(tt.output); got != tt.want {
+			t.Errorf("sanitizeCheckOutput(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestRunCustomCheckBoundsOutput(t *testing.T) {
+	// A megabyte on one line must neither block the command nor be kept whole
+	check, _ := runCustomCheck(CustomCheck{Name: "chatty", Command: []string{"sh", "-c", "head -c 1048576 /dev/zero | tr '\\0' y"}, Weight: 10}, scoreFactors{})
+	if check.Status != "healthy" || len(check.Value) != maxCustomCheckValue {
+		t.Errorf("chatty check = %s with a %d byte value, want healthy with %d", check.Status, len(check.Value), maxCustomCheckValue)
+	}
+}
+
+func TestCustomChecksFromConfigFile(t *testing.T) {
+	chdirTemp(t)
+	config := `{
+  "health_checks": {
+    "cpu": {"enabled": false}, "memory": {"enabled": false},
+    "disk": {"enabled": false}, "network": {"enabled": false}
+  },
+  "custom_checks": [
+    {"name": "Queue Drained", "command": ["sh", "-c", "echo 0; exit 0"], "weight": 30},
+    {"name": "Replica Lag", "command": ["sh", "-c", "echo 42s; exit 1"], "weight": 20},
+    {"name": "", "command": ["true"], "weight": 5},
+    {"name": "No Command", "weight": 5}
+  ]
+}`
+	if err := os.WriteFile("health-config.json", []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	healthConfig := loadHealthConfig(discardLogger)
+	metrics := performHealthChecks(context.Background(), healthConfig)
+
+	var got []string
+	for _, check := range metrics.Checks {
+		got = append(got, check.Name+"="+check.Status+"/"+check.Value)
+	}
+	if strings.Join(got, ",") != "Queue Drained=healthy/0,Replica Lag=degraded/42s" {
+		t.Errorf("checks = %v, want the two valid custom checks in config order", got)
+	}
+	if want := 30 + int(20*healthConfig.Scoring.DegradedFactor+1e-9); metrics.OverallScore != want {
+		t.Errorf("score = %d, want %d", metrics.OverallScore, want)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// enabledChecks lists the checks config leaves on, by config file name
+func enabledChecks(config HealthConfig) []string {
+	checks := config.HealthChecks
+	var on []string
+	for _, check := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"cpu", checks.CPU.Enabled}, {"memory", checks.Memory.Enabled}, {"disk", checks.Disk.Enabled},
+		{"network", checks.Network.Enabled}, {"load_average", checks.LoadAverage.Enabled},
+		{"process", checks.Process.Enabled}, {"gpu", checks.GPU.Enabled},
+		{"temperature", checks.Temperature.Enabled}, {"interface", checks.Interface.Enabled},
+	} {
+		if check.enabled {
+			on = append(on, check.name)
+		}
+	}
+	return on
+}
+
+func TestHealthDisable(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		file     string
+		want     []string
+		wantWarn string
+	}{
+		{"unset", nil, "", []string{"cpu", "memory", "disk", "network"}, ""},
+		{"two checks", map[string]string{"HEALTH_DISABLE": "disk,network"}, "", []string{"cpu", "memory"}, ""},
+		{"spacing and case", map[string]string{"HEALTH_DISABLE": " CPU , ,Memory"}, "", []string{"disk", "network"}, ""},
+		{"overrides HEALTH_*_ENABLED", map[string]string{"HEALTH_DISABLE": "disk,gpu", "HEALTH_DISK_ENABLED": "true", "HEALTH_GPU_ENABLED": "true", "HEALTH_TEMP_ENABLED": "true"},
+			"", []string{"cpu", "memory", "network", "temperature"}, ""},
+		{"overrides the config file", map[string]string{"HEALTH_DISABLE": "load_average"},
+			`{"health_checks": {"load_average": {"enabled": true}, "interface": {"enabled": true}}}`,
+			[]string{"cpu", "memory", "disk", "network", "interface"}, ""},
+		{"unknown name warns", map[string]string{"HEALTH_DISABLE": "disk,dsik"}, "", []string{"cpu", "memory", "network"}, "dsik"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			chdirTemp(t)
+			if tt.file != "" {
+				if err := os.WriteFile("health-config.json", []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var logs strings.Builder
+			config := loadHealthConfig(slog.New(slog.NewTextHandler(&logs, nil)))
+
+			if got := enabledChecks(config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("enabled %v, want %v", got, tt.want)
+			}
+			warned := strings.Contains(logs.String(), "Ignoring unknown check in HEALTH_DISABLE")
+			if warned != (tt.wantWarn != "") || !strings.Contains(logs.String(), tt.wantWarn) {
+				t.Errorf("logged %q, want a warning naming %q", logs.String(), tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestHealthDisableSkipsChecks(t *testing.T) {
+	t.Setenv("HEALTH_DISABLE", "cpu,memory,network")
+	config := defaultHealthConfig(t)
+	// CPU and memory usage are still measured for the report's metrics, but
+	// no longer scored
+	var scored []string
+	for _, section := range healthSections(config) {
+		if section.scored {
+			scored = append(scored, section.name)
+		}
+	}
+	if !reflect.DeepEqual(scored, []string{"Disk Usage"}) || config.totalWeight() != config.HealthChecks.Disk.Weight {
+		t.Errorf("scored sections %v worth %d, want only the disk check", scored, config.totalWeight())
+	}
+}
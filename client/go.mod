@@ -1,3 +1,7 @@
 module github.com/management/s01-client
 
 go 1.21
+
+require github.com/management/s01-shared v0.0.0
+
+replace github.com/management/s01-shared => ../shared
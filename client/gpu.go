@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// drmClassPath holds the DRM cards whose drivers (e.g. amdgpu) expose
+// utilization as device/gpu_busy_percent
+var drmClassPath = "/sys/class/drm"
+
+// nvidiaSMIPath is the nvidia-smi binary queried for NVIDIA GPUs, which do not
+// publish utilization in sysfs
+var nvidiaSMIPath = "nvidia-smi"
+
+// gpuQueryTimeout bounds a single nvidia-smi invocation
+const gpuQueryTimeout = 5 * time.Second
+
+// errNoGPU is returned when neither sysfs nor nvidia-smi reports a GPU
+var errNoGPU = errors.New("no GPU found")
+
+// getGPUUtilization returns the utilization percentage of every GPU, read from
+// sysfs when a driver exposes it and from nvidia-smi otherwise
+func getGPUUtilization() ([]float64, error) {
+	if usages := readDRMBusyPercent(drmClassPath); len(usages) > 0 {
+		return usages, nil
+	}
+	return queryNvidiaSMI(nvidiaSMIPath)
+}
+
+// readDRMBusyPercent reads gpu_busy_percent of each card under drmRoot
+func readDRMBusyPercent(drmRoot string) []float64 {
+	paths, _ := filepath.Glob(filepath.Join(drmRoot, "card*", "device", "gpu_busy_percent"))
+	var usages []float64
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if usage, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64); err == nil {
+			usages = append(usages, usage)
+		}
+	}
+	return usages
+}
+
+// queryNvidiaSMI asks nvidia-smi for per-GPU utilization
+func queryNvidiaSMI(binary string) ([]float64, error) {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, errNoGPU
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gpuQueryTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--query-gpu=utilization.gpu", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi failed: %v", err)
+	}
+	return parseNvidiaSMIUtilization(out)
+}
+
+// parseNvidiaSMIUtilization parses one utilization percentage per line,
+// skipping GPUs that report "[N/A]"
+func parseNvidiaSMIUtilization(out []byte) ([]float64, error) {
+	var usages []float64
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		usage, err := strconv.ParseFloat(strings.TrimSuffix(line, " %"), 64)
+		if err != nil {
+			continue
+		}
+		usages = append(usages, usage)
+	}
+	if len(usages) == 0 {
+		return nil, errNoGPU
+	}
+	return usages, nil
+}
+
+// checkGPU scores the busiest GPU against the thresholds. A host without a
+// readable GPU gets an "unknown" check worth zero points rather than failing.
+func checkGPU(usages []float64, err error, healthyThreshold, degradedThreshold float64, weight int, factors scoreFactors) (HealthCheck, int) {
+	check := HealthCheck{
+		Name: "GPU Usage",
+	}
+	if err != nil {
+		check.Status = "unknown"
+		check.Message = fmt.Sprintf("GPU utilization unavailable: %v", err)
+		return check, 0
+	}
+
+	var busiest float64
+	for _, usage := range usages {
+		if usage > busiest {
+			busiest = usage
+		}
+	}
+	check.Value = fmt.Sprintf("%.1f%%", busiest)
+	if len(usages) > 1 {
+		check.Value = fmt.Sprintf("%.1f%% (busiest of %d GPUs)", busiest, len(usages))
+	}
+
+	switch {
+	case busiest < healthyThreshold:
+		check.Status = "healthy"
+		return check, weight
+	case busiest < degradedThreshold:
+		check.Status = "degraded"
+		check.Message = "High GPU usage"
+		return check, factors.points(check.Status, weight)
+	default:
+		check.Status = "unhealthy"
+		check.Message = "Critical GPU usage"
+		return check, factors.points(check.Status, weight)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeNvidiaSMI installs a script printing output and exiting with status
+// code as the nvidia-smi the GPU check runs
+func fakeNvidiaSMI(t *testing.T, output string, code int) {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "nvidia-smi")
+	content := fmt.Sprintf("#!/bin/sh\ncat <<'EOF'\n%sEOF\nexit %d\n", output, code)
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	saved := nvidiaSMIPath
+	t.Cleanup(func() { nvidiaSMIPath = saved })
+	nvidiaSMIPath = script
+}
+
+// noDRM points the sysfs reader at an empty class directory
+func noDRM(t *testing.T) {
+	t.Helper()
+	saved := drmClassPath
+	t.Cleanup(func() { drmClassPath = saved })
+	drmClassPath = t.TempDir()
+}
+
+func TestParseNvidiaSMIUtilization(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    []float64
+		wantErr error
+	}{
+		{"37\n", []float64{37}, nil},
+		{"12\n98\n0\n", []float64{12, 98, 0}, nil},
+		{"45 %\n[N/A]\n", []float64{45}, nil},
+		{"\n  71  \n\n", []float64{71}, nil},
+		{"[N/A]\n", nil, errNoGPU},
+		{"", nil, errNoGPU},
+	}
+	for _, tt := range tests {
+		got, err := parseNvidiaSMIUtilization([]byte(tt.output))
+		if !reflect.DeepEqual(got, tt.want) || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%q = %v, %v; want %v, %v", tt.output, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetGPUUtilizationSources(t *testing.T) {
+	t.Run("sysfs first", func(t *testing.T) {
+		drm := t.TempDir()
+		for card, busy := range map[string]string{"card0": "23\n", "card1": "61\n", "card1-HDMI-A-1": ""} {
+			dir := filepath.Join(drm, card, "device")
+			os.MkdirAll(dir, 0o755)
+			if busy != "" {
+				os.WriteFile(filepath.Join(dir, "gpu_busy_percent"), []byte(busy), 0o644)
+			}
+		}
+		saved := drmClassPath
+		defer func() { drmClassPath = saved }()
+		drmClassPath = drm
+		fakeNvidiaSMI(t, "99\n", 0)
+
+		if got, err := getGPUUtilization(); err != nil || !reflect.DeepEqual(got, []float64{23, 61}) {
+			t.Errorf("getGPUUtilization = %v, %v; want the sysfs readings", got, err)
+		}
+	})
+	t.Run("nvidia-smi", func(t *testing.T) {
+		noDRM(t)
+		fakeNvidiaSMI(t, "40\n85\n", 0)
+		if got, err := getGPUUtilization(); err != nil || !reflect.DeepEqual(got, []float64{40, 85}) {
+			t.Errorf("getGPUUtilization = %v, %v; want the nvidia-smi readings", got, err)
+		}
+	})
+	t.Run("nvidia-smi failing", func(t *testing.T) {
+		noDRM(t)
+		fakeNvidiaSMI(t, "NVIDIA-SMI has failed\n", 9)
+		if _, err := getGPUUtilization(); err == nil || errors.Is(err, errNoGPU) {
+			t.Errorf("getGPUUtilization = %v, want the nvidia-smi failure", err)
+		}
+	})
+	t.Run("no GPU", func(t *testing.T) {
+		noDRM(t)
+		fakeNvidiaSMI(t, "", 0)
+		nvidiaSMIPath = filepath.Join(t.TempDir(), "nvidia-smi")
+		if _, err := getGPUUtilization(); !errors.Is(err, errNoGPU) {
+			t.Errorf("getGPUUtilization = %v, want errNoGPU", err)
+		}
+	})
+}
+
+func TestCheckGPU(t *testing.T) {
+	factors := scoreFactors{degraded: 0.5, unhealthy: 0}
+	tests := []struct {
+		usages     []float64
+		err        error
+		wantStatus string
+		wantValue  string
+		wantPoints int
+	}{
+		{[]float64{30}, nil, "healthy", "30.0%", 10},
+		{[]float64{30, 92}, nil, "degraded", "92.0% (busiest of 2 GPUs)", 5},
+		{[]float64{99}, nil, "unhealthy", "99.0%", 0},
+		{nil, errNoGPU, "unknown", "", 0},
+	}
+	for _, tt := range tests {
+		check, points := checkGPU(tt.usages, tt.err, 90, 95, 10, factors)
+		if check.Name != "GPU Usage" || check.Status != tt.wantStatus || check.Value != tt.wantValue || points != tt.wantPoints {
+			t.Errorf("%v, %v: %+v worth %d, want %s %q worth %d", tt.usages, tt.err, check, points, tt.wantStatus, tt.wantValue, tt.wantPoints)
+		}
+	}
+}
+
+func TestHealthChecksIncludeGPU(t *testing.T) {
+	config := defaultHealthConfig(t)
+	if config.HealthChecks.GPU.Enabled {
+		t.Fatal("GPU check enabled by default")
+	}
+	config.disableChecks([]string{"cpu", "memory", "disk", "network", "load_average", "process", "temperature", "interface"}, discardLogger)
+	config.HealthChecks.GPU.Enabled = true
+	noDRM(t)
+
+	fakeNvidiaSMI(t, "12\n", 0)
+	metrics := performHealthChecks(context.Background(), config)
+	if len(metrics.Checks) != 1 || metrics.Checks[0].Name != "GPU Usage" || metrics.Checks[0].Status != "healthy" || metrics.OverallScore != config.HealthChecks.GPU.Weight {
+		t.Errorf("with an idle GPU: checks %+v score %d", metrics.Checks, metrics.OverallScore)
+	}
+
+	// A host without a GPU is not penalised for it
+	nvidiaSMIPath = filepath.Join(t.TempDir(), "nvidia-smi")
+	metrics = performHealthChecks(context.Background(), config)
+	if len(metrics.Checks) != 1 || metrics.Checks[0].Status != "unknown" {
+		t.Errorf("without a GPU: checks %+v", metrics.Checks)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"syscall"
+	"testing"
+)
+
+// fakeStatfs answers statfs with stats per path
+func fakeStatfs(t *testing.T, stats map[string]syscall.Statfs_t) {
+	t.Helper()
+	saved := statfs
+	t.Cleanup(func() { statfs = saved })
+	statfs = func(path string, stat *syscall.Statfs_t) error {
+		s, ok := stats[path]
+		if !ok {
+			return syscall.ENOENT
+		}
+		*stat = s
+		return nil
+	}
+}
+
+func TestGetInodeUsage(t *testing.T) {
+	tests := []struct {
+		name          string
+		stat          syscall.Statfs_t
+		wantUsage     float64
+		wantHasInodes bool
+	}{
+		{"quarter used", syscall.Statfs_t{Files: 4000, Ffree: 3000}, 25, true},
+		{"exhausted", syscall.Statfs_t{Files: 4000, Ffree: 0}, 100, true},
+		{"untouched", syscall.Statfs_t{Files: 4000, Ffree: 4000}, 0, true},
+		// btrfs and friends allocate inodes dynamically and report none
+		{"no inode table", syscall.Statfs_t{Blocks: 1000, Bavail: 900}, 0, false},
+	}
+	for _, tt := range tests {
+		fakeStatfs(t, map[string]syscall.Statfs_t{"/data": tt.stat})
+		usage, hasInodes, err := getInodeUsage(statfs, "/data")
+		if err != nil || hasInodes != tt.wantHasInodes || math.Abs(usage-tt.wantUsage) > 1e-9 {
+			t.Errorf("%s: getInodeUsage = %v, %v, %v; want %v, %v", tt.name, usage, hasInodes, err, tt.wantUsage, tt.wantHasInodes)
+		}
+	}
+
+	fakeStatfs(t, nil)
+	if _, hasInodes, err := getInodeUsage(statfs, "/gone"); err == nil || hasInodes {
+		t.Errorf("getInodeUsage on a failing statfs = %v, %v; want the error", hasInodes, err)
+	}
+}
+
+func TestDiskSectionFlagsInodeExhaustion(t *testing.T) {
+	config := defaultHealthConfig(t)
+	config.HealthChecks.Disk.Paths = []string{"/var/spool"}
+	factors := config.scoreFactors()
+
+	// Plenty of space, but a maildir of tiny files has eaten the inodes
+	fakeStatfs(t, map[string]syscall.Statfs_t{
+		"/var/spool": {Blocks: 1000, Bfree: 900, Bavail: 900, Files: 10000, Ffree: 100},
+	})
+	result := diskSection(config, factors, statfs)
+	if len(result.checks) != 2 {
+		t.Fatalf("checks = %+v, want space and inodes", result.checks)
+	}
+	space, inodes := result.checks[0], result.checks[1]
+	if space.Name != "Disk Usage (/var/spool)" || space.Status != "healthy" || space.Value != "10.0%" {
+		t.Errorf("space check = %+v, want healthy at 10%%", space)
+	}
+	if inodes.Name != "Inode Usage (/var/spool)" || inodes.Status != "unhealthy" || inodes.Value != "99.0%" || inodes.Message != "Inodes nearly exhausted" {
+		t.Errorf("inode check = %+v, want unhealthy at 99%%", inodes)
+	}
+	// The two checks split the disk weight
+	weight := float64(config.HealthChecks.Disk.Weight)
+	if want := int(weight/2 + weight/2*factors.unhealthy + 1e-9); result.points != want {
+		t.Errorf("points = %d, want %d for half healthy, half unhealthy", result.points, want)
+	}
+	var metrics HealthMetrics
+	result.summary(&metrics)
+	if metrics.DiskUsage != 10 {
+		t.Errorf("summary disk usage = %v, want the space figure alone", metrics.DiskUsage)
+	}
+}
+
+func TestInodeThresholdsFromEnv(t *testing.T) {
+	t.Setenv("HEALTH_DISK_INODE_THRESHOLD", "50")
+	t.Setenv("HEALTH_DISK_INODE_DEGRADED_THRESHOLD", "70")
+	t.Setenv("HEALTH_DISK_INODE_CRITICAL_THRESHOLD", "90")
+	config := defaultHealthConfig(t)
+	disk := config.HealthChecks.Disk
+	if disk.InodeHealthyThreshold != 50 || disk.InodeDegradedThreshold != 70 || disk.InodeCriticalThreshold != 90 {
+		t.Fatalf("inode thresholds = %v/%v/%v, want 50/70/90", disk.InodeHealthyThreshold, disk.InodeDegradedThreshold, disk.InodeCriticalThreshold)
+	}
+
+	config.HealthChecks.Disk.Paths = []string{"/"}
+	tests := []struct {
+		ffree      uint64
+		wantStatus string
+	}{
+		{600, "healthy"},
+		{400, "degraded"},
+		{200, "unhealthy"},
+	}
+	for _, tt := range tests {
+		fakeStatfs(t, map[string]syscall.Statfs_t{"/": {Blocks: 100, Bfree: 100, Bavail: 100, Files: 1000, Ffree: tt.ffree}})
+		result := diskSection(config, config.scoreFactors(), statfs)
+		if len(result.checks) != 2 || result.checks[1].Status != tt.wantStatus {
+			t.Errorf("%d of 1000 inodes free: checks %+v, want inodes %s", tt.ffree, result.checks, tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// interfaceCounters are the error and drop counters of one network interface
+// in /proc/net/dev, receive and transmit combined
+type interfaceCounters struct {
+	errors uint64
+	drops  uint64
+}
+
+// interfaceSampler keeps the counters seen at the previous health check pass,
+// since /proc/net/dev only holds totals since boot and the check is about
+// what changed between reports
+type interfaceSampler struct {
+	mutex    sync.Mutex
+	previous map[string]interfaceCounters
+}
+
+// interfaceSamples holds the client's previous interface sample
+var interfaceSamples = &interfaceSampler{}
+
+// parseNetDev parses /proc/net/dev into the counters of each interface. The
+// two header lines and lines without enough fields are skipped.
+func parseNetDev(content string) map[string]interfaceCounters {
+	counters := make(map[string]interfaceCounters)
+	for _, line := range strings.Split(content, "\n") {
+		// The name is followed directly by the first counter once it is
+		// wide enough, e.g. "eth0:123456789", so split on the colon
+		name, values, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(values)
+		// Receive: bytes packets errs drop fifo frame compressed multicast
+		// Transmit: bytes packets errs drop fifo colls carrier compressed
+		if len(fields) < 12 {
+			continue
+		}
+		var parsed [4]uint64
+		valid := true
+		for i, index := range []int{2, 3, 10, 11} {
+			value, err := strconv.ParseUint(fields[index], 10, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			parsed[i] = value
+		}
+		if !valid {
+			continue
+		}
+		counters[strings.TrimSpace(name)] = interfaceCounters{
+			errors: parsed[0] + parsed[2],
+			drops:  parsed[1] + parsed[3],
+		}
+	}
+	return counters
+}
+
+// sample records current as the latest counters and returns the previous
+// ones. Interfaces no longer listed are forgotten, so one that comes back
+// starts from a fresh baseline.
+func (is *interfaceSampler) sample(current map[string]interfaceCounters) map[string]interfaceCounters {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	previous := is.previous
+	is.previous = current
+	return previous
+}
+
+// checkInterfaces reads procRoot/net/dev and reports one check per named
+// interface. An interface is unhealthy when it is absent or its errors or
+// drops grew by more than maxIncrease since the previous pass; the first pass
+// after startup only records a baseline. Healthy interfaces earn an equal
+// share of weight.
+func checkInterfaces(procRoot string, names []string, maxIncrease uint64, weight int, sampler *interfaceSampler) ([]HealthCheck, int) {
+	data, err := os.ReadFile(filepath.Join(procRoot, "net", "dev"))
+	if err != nil {
+		return []HealthCheck{{
+			Name:    "Network Interfaces",
+			Status:  "unknown",
+			Message: fmt.Sprintf("interface counters unavailable: %v", err),
+		}}, 0
+	}
+	current := parseNetDev(string(data))
+	previous := sampler.sample(current)
+
+	var checks []HealthCheck
+	healthy := 0
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		check := evaluateInterface(name, current, previous, maxIncrease)
+		if check.Status == "healthy" {
+			healthy++
+		}
+		checks = append(checks, check)
+	}
+	if len(checks) == 0 {
+		return []HealthCheck{{
+			Name:    "Network Interfaces",
+			Status:  "unknown",
+			Message: "No interfaces configured",
+		}}, 0
+	}
+	return checks, weight * healthy / len(checks)
+}
+
+// evaluateInterface builds the check of one interface from the current and
+// previous samples
+func evaluateInterface(name string, current, previous map[string]interfaceCounters, maxIncrease uint64) HealthCheck {
+	check := HealthCheck{
+		Name: "Interface " + name,
+	}
+
+	now, present := current[name]
+	if !present {
+		check.Status = "unhealthy"
+		check.Message = "Interface not found"
+		return check
+	}
+	before, sampled := previous[name]
+	// Counters going backwards mean the interface was recreated, e.g. a
+	// reloaded driver; treat it like the first sample
+	if !sampled || now.errors < before.errors || now.drops < before.drops {
+		check.Status = "healthy"
+		check.Value = fmt.Sprintf("%d errors, %d drops", now.errors, now.drops)
+		check.Message = "Baseline sample"
+		return check
+	}
+
+	newErrors := now.errors - before.errors
+	newDrops := now.drops - before.drops
+	check.Value = fmt.Sprintf("+%d errors, +%d drops", newErrors, newDrops)
+	if newErrors > maxIncrease || newDrops > maxIncrease {
+		check.Status = "unhealthy"
+		check.Message = "Errors or drops increasing since last report"
+		return check
+	}
+	check.Status = "healthy"
+	return check
+}
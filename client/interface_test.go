@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const netDevHeader = "Inter-|   Receive                                                |  Transmit\n" +
+	" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n"
+
+// netDevLine is one /proc/net/dev row with the given error and drop counters
+func netDevLine(name string, rxErrs, rxDrop, txErrs, txDrop int) string {
+	return fmt.Sprintf("%6s: 1048576 2048 %d %d 0 0 0 0 524288 1024 %d %d 0 0 0 0\n", name, rxErrs, rxDrop, txErrs, txDrop)
+}
+
+func TestParseNetDev(t *testing.T) {
+	content := netDevHeader +
+		netDevLine("lo", 0, 0, 0, 0) +
+		netDevLine("eth0", 3, 1, 2, 4) +
+		// A long byte count runs into the name
+		"  eth1:123456789012 99 5 6 0 0 0 0 10 1 7 8 0 0 0 0\n" +
+		"  bad0: 1 2 three 4 5 6 7 8 9 10 11 12 13 14 15 16\n" +
+		"  short: 1 2 3\n"
+
+	want := map[string]interfaceCounters{
+		"lo":   {},
+		"eth0": {errors: 5, drops: 5},
+		"eth1": {errors: 12, drops: 14},
+	}
+	if got := parseNetDev(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNetDev = %v, want %v", got, want)
+	}
+}
+
+func TestCheckInterfacesAcrossIntervals(t *testing.T) {
+	procRoot := t.TempDir()
+	if err := os.Mkdir(filepath.Join(procRoot, "net"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sampler := &interfaceSampler{}
+
+	// Each pass rewrites /proc/net/dev and runs the check on eth0 and eth1
+	// with two errors or drops allowed per interval
+	passes := []struct {
+		name       string
+		rows       string
+		wantStatus map[string]string
+		wantValue  map[string]string
+		wantPoints int
+	}{
+		{"baseline", netDevLine("eth0", 10, 10, 0, 0) + netDevLine("eth1", 0, 0, 0, 0),
+			map[string]string{"eth0": "healthy", "eth1": "healthy"},
+			map[string]string{"eth0": "10 errors, 10 drops", "eth1": "0 errors, 0 drops"}, 20},
+		{"within the allowance", netDevLine("eth0", 11, 10, 1, 0) + netDevLine("eth1", 0, 2, 0, 0),
+			map[string]string{"eth0": "healthy", "eth1": "healthy"},
+			map[string]string{"eth0": "+2 errors, +0 drops", "eth1": "+0 errors, +2 drops"}, 20},
+		{"eth1 dropping", netDevLine("eth0", 11, 10, 1, 0) + netDevLine("eth1", 0, 2, 0, 3),
+			map[string]string{"eth0": "healthy", "eth1": "unhealthy"},
+			map[string]string{"eth0": "+0 errors, +0 drops", "eth1": "+0 errors, +3 drops"}, 10},
+		{"eth1 quiet again", netDevLine("eth0", 11, 10, 1, 0) + netDevLine("eth1", 0, 2, 0, 3),
+			map[string]string{"eth0": "healthy", "eth1": "healthy"}, nil, 20},
+		{"eth0 gone", netDevLine("eth1", 0, 2, 0, 3),
+			map[string]string{"eth0": "unhealthy", "eth1": "healthy"}, nil, 10},
+		{"eth0 back, counters reset", netDevLine("eth0", 50, 0, 0, 0) + netDevLine("eth1", 0, 2, 0, 3),
+			map[string]string{"eth0": "healthy", "eth1": "healthy"},
+			map[string]string{"eth0": "50 errors, 0 drops"}, 20},
+		{"eth1 driver reloaded", netDevLine("eth0", 50, 0, 0, 0) + netDevLine("eth1", 0, 0, 0, 0),
+			map[string]string{"eth0": "healthy", "eth1": "healthy"},
+			map[string]string{"eth1": "0 errors, 0 drops"}, 20},
+	}
+	for _, pass := range passes {
+		if err := os.WriteFile(filepath.Join(procRoot, "net", "dev"), []byte(netDevHeader+pass.rows), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		checks, points := checkInterfaces(procRoot, []string{"eth0", " eth1 ", ""}, 2, 20, sampler)
+		if len(checks) != 2 || points != pass.wantPoints {
+			t.Errorf("%s: %d checks worth %d, want 2 worth %d", pass.name, len(checks), points, pass.wantPoints)
+			continue
+		}
+		for _, check := range checks {
+			name := strings.TrimPrefix(check.Name, "Interface ")
+			if check.Status != pass.wantStatus[name] {
+				t.Errorf("%s: %s %s (%s), want %s", pass.name, check.Name, check.Status, check.Message, pass.wantStatus[name])
+			}
+			if want, ok := pass.wantValue[name]; ok && check.Value != want {
+				t.Errorf("%s: %s value %q, want %q", pass.name, check.Name, check.Value, want)
+			}
+		}
+	}
+}
+
+func TestCheckInterfacesUnavailable(t *testing.T) {
+	checks, points := checkInterfaces(t.TempDir(), []string{"eth0"}, 0, 10, &interfaceSampler{})
+	if len(checks) != 1 || checks[0].Status != "unknown" || points != 0 || !strings.Contains(checks[0].Message, "unavailable") {
+		t.Errorf("without /proc/net/dev: %+v worth %d, want one unknown check", checks, points)
+	}
+
+	procRoot := t.TempDir()
+	os.Mkdir(filepath.Join(procRoot, "net"), 0o755)
+	os.WriteFile(filepath.Join(procRoot, "net", "dev"), []byte(netDevHeader+netDevLine("eth0", 0, 0, 0, 0)), 0o644)
+	checks, points = checkInterfaces(procRoot, []string{" "}, 0, 10, &interfaceSampler{})
+	if len(checks) != 1 || checks[0].Status != "unknown" || checks[0].Message != "No interfaces configured" || points != 0 {
+		t.Errorf("no names: %+v worth %d, want one unknown check", checks, points)
+	}
+}
+
+func TestInterfaceCheckFromEnv(t *testing.T) {
+	t.Setenv("HEALTH_INTERFACE_ENABLED", "true")
+	t.Setenv("HEALTH_INTERFACE_NAMES", "eth0,bond0")
+	t.Setenv("HEALTH_INTERFACE_MAX_ERROR_INCREASE", "25")
+	config := defaultHealthConfig(t).HealthChecks.Interface
+	if !config.Enabled || !reflect.DeepEqual(config.Names, []string{"eth0", "bond0"}) || config.MaxErrorIncrease != 25 || config.Weight != 10 {
+		t.Errorf("interface config = %+v", config)
+	}
+
+	t.Setenv("HEALTH_INTERFACE_MAX_ERROR_INCREASE", "-1")
+	if config := defaultHealthConfig(t).HealthChecks.Interface; config.MaxErrorIncrease != 0 {
+		t.Errorf("negative allowance loaded as %d, want the default 0", config.MaxErrorIncrease)
+	}
+}
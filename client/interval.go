@@ -0,0 +1,22 @@
+package main
+
+import "time"
+
+// maxAdvisedInterval caps the interval a server may ask for, so a
+// misconfigured server cannot silence a client for hours
+const maxAdvisedInterval = 3600
+
+// adviseInterval records the report interval, in seconds, the server asked
+// for in its reply; 0 or less withdraws earlier advice
+func (dc *S01Client) adviseInterval(seconds int) {
+	dc.advisedInterval.Store(int64(min(max(seconds, 0), maxAdvisedInterval)))
+}
+
+// reportInterval is the interval between periodic reports: the server's
+// advice when it gave any, the configured ReportInterval otherwise
+func (dc *S01Client) reportInterval() time.Duration {
+	if seconds := dc.advisedInterval.Load(); seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Duration(dc.config.ReportInterval) * time.Second
+}
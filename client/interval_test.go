@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdviseInterval(t *testing.T) {
+	dc := &S01Client{config: &Config{ReportInterval: 30}}
+	tests := []struct {
+		advice int
+		want   time.Duration
+	}{
+		{0, 30 * time.Second},
+		{120, 120 * time.Second},
+		{5, 5 * time.Second},
+		{-10, 30 * time.Second}, // withdraws the advice
+		{1_000_000, maxAdvisedInterval * time.Second},
+		{0, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		dc.adviseInterval(tt.advice)
+		if got := dc.reportInterval(); got != tt.want {
+			t.Errorf("after advice %d: interval %v, want %v", tt.advice, got, tt.want)
+		}
+	}
+}
+
+func TestRepliesCarryIntervalAdvice(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		reply string
+		want  time.Duration
+	}{
+		{"single report", nil, `{"status": "ok", "next_interval_seconds": 90}`, 90 * time.Second},
+		{"batch", []string{"--additional-services", "billing"}, `{"accepted": 2, "rejected": 0, "results": [], "next_interval_seconds": 45}`, 45 * time.Second},
+		{"no advice", nil, `{"status": "ok"}`, 60 * time.Second},
+	}
+	for _, tt := range tests {
+		dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(tt.reply))
+		}, append([]string{"--report-interval", "60"}, tt.args...)...)
+		// Stale advice from an earlier reply is replaced
+		dc.adviseInterval(600)
+		if err := dc.reportStatus(context.Background()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := dc.reportInterval(); got != tt.want {
+			t.Errorf("%s: interval %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientRearmsTickerOnAdvice(t *testing.T) {
+	var mu sync.Mutex
+	var sent []time.Time
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, time.Now())
+		n := len(sent)
+		mu.Unlock()
+		// From the second report on, the server asks for 2s instead of 1s
+		if n >= 2 {
+			fmt.Fprint(w, `{"status": "ok", "next_interval_seconds": 2}`)
+			return
+		}
+		fmt.Fprint(w, `{"status": "ok"}`)
+	}, "--report-interval", "1")
+
+	done := make(chan error, 1)
+	go func() { done <- dc.Start() }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(sent)
+		mu.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	dc.Stop()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) < 3 {
+		t.Fatalf("%d reports within 5s, want 3", len(sent))
+	}
+	gaps := []time.Duration{sent[1].Sub(sent[0]), sent[2].Sub(sent[1])}
+	if gaps[0] > 1500*time.Millisecond || gaps[1] < 1800*time.Millisecond {
+		t.Errorf("gaps between reports %v, want about 1s and then 2s after the advice", gaps)
+	}
+}
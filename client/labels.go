@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// labelSet holds the labels attached to every report. Besides a JSON object
+// it accepts the "key=value,key=value" form used by LABELS and --labels.
+type labelSet map[string]string
+
+// parseLabels parses "key=value,key=value"; whitespace around items is ignored
+func parseLabels(value string) (labelSet, error) {
+	labels := make(labelSet)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", item)
+		}
+		labels[key] = strings.TrimSpace(val)
+	}
+	return labels, nil
+}
+
+// UnmarshalJSON accepts either an object or a "key=value,..." string
+func (ls *labelSet) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return ls.Set(text)
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return fmt.Errorf("labels must be an object or a key=value list: %v", err)
+	}
+	*ls = labels
+	return nil
+}
+
+// String formats the labels as a sorted "key=value,..." list
+func (ls labelSet) String() string {
+	items := make([]string, 0, len(ls))
+	for key, value := range ls {
+		items = append(items, key+"="+value)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// Set replaces the labels with those parsed from value, so labelSet can be
+// used as a flag.Value
+func (ls *labelSet) Set(value string) error {
+	labels, err := parseLabels(value)
+	if err != nil {
+		return err
+	}
+	*ls = labels
+	return nil
+}
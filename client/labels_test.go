@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    labelSet
+		wantErr bool
+	}{
+		{"", labelSet{}, false},
+		{"region=us-east", labelSet{"region": "us-east"}, false},
+		{" region = us-east , zone=a ,", labelSet{"region": "us-east", "zone": "a"}, false},
+		{"canary=", labelSet{"canary": ""}, false},
+		{"expr=a=b", labelSet{"expr": "a=b"}, false},
+		{"region=us-east,region=eu-west", labelSet{"region": "eu-west"}, false},
+		{"region", nil, true},
+		{"=us-east", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLabels(tt.value)
+		if (err != nil) != tt.wantErr || !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLabels(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReportCarriesLabels(t *testing.T) {
+	t.Setenv("LABELS", "region=us-east,zone=a")
+	var sent map[string]string
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req StatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode report: %v", err)
+		}
+		sent = req.Labels
+		w.Write([]byte(`{"success": true}`))
+	})
+	if err := dc.reportStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"region": "us-east", "zone": "a"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("labels sent = %v, want %v", sent, want)
+	}
+
+	t.Setenv("LABELS", "region")
+	if _, err := loadTestConfig(t); err == nil {
+		t.Error("LABELS without a value accepted")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxLogReadBytes bounds how much of the log is scanned per report
+	maxLogReadBytes = 1 << 20
+	// maxErrorSamples bounds how many sample lines are sent per report
+	maxErrorSamples = 5
+	// maxErrorSampleLen bounds the length of each sample line
+	maxErrorSampleLen = 200
+)
+
+// redactPatterns mask values that should not leave the host in samples
+var redactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key)\s*[=:]\s*)\S+`),
+	regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`),
+}
+
+// logTailer follows a local log file and extracts lines matching an error pattern
+type logTailer struct {
+	path    string
+	pattern *regexp.Regexp
+	offset  int64
+}
+
+// newLogTailer creates a tailer starting at the current end of the file,
+// so only errors logged after startup are reported
+func newLogTailer(path, pattern string) (*logTailer, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &logTailer{path: path, pattern: re}
+	if info, err := os.Stat(path); err == nil {
+		t.offset = info.Size()
+	}
+	return t, nil
+}
+
+// collect scans lines appended since the last call and summarizes the matching ones
+func (t *logTailer) collect() (*RecentErrors, error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// The file shrank, so it was truncated or rotated: start over
+	if info.Size() < t.offset {
+		t.offset = 0
+	}
+	// Skip ahead rather than scanning an unbounded backlog
+	if info.Size()-t.offset > maxLogReadBytes {
+		t.offset = info.Size() - maxLogReadBytes
+	}
+
+	if _, err := file.Seek(t.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	summary := &RecentErrors{}
+	reader := bufio.NewReader(io.LimitReader(file, info.Size()-t.offset))
+	for {
+		line, err := reader.ReadString('\n')
+		// Leave a partially written last line for the next scan
+		if err != nil {
+			break
+		}
+		t.offset += int64(len(line))
+
+		line = strings.TrimRight(line, "\r\n")
+		if !t.pattern.MatchString(line) {
+			continue
+		}
+		summary.Count++
+		if len(summary.Samples) < maxErrorSamples {
+			summary.Samples = append(summary.Samples, sanitizeLogLine(line))
+		}
+	}
+
+	return summary, nil
+}
+
+// sanitizeLogLine redacts sensitive values and truncates a sample line
+func sanitizeLogLine(line string) string {
+	for _, re := range redactPatterns {
+		line = re.ReplaceAllStringFunc(line, func(match string) string {
+			if sub := re.FindStringSubmatch(match); len(sub) > 1 {
+				return sub[1] + "[REDACTED]"
+			}
+			return "[REDACTED]"
+		})
+	}
+	if len(line) > maxErrorSampleLen {
+		line = line[:maxErrorSampleLen] + "..."
+	}
+	return line
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// appendLog appends content to the log at path
+func appendLog(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogTailerCollectsNewErrors(t *testing.T) {
+	path := writeFile(t, "app.log", "ERROR logged before startup\n")
+	tailer, err := newLogTailer(path, `ERROR|FATAL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendLog(t, path, "INFO all good\nERROR db login password=hunter2 from 10.1.2.3\nFATAL out of memory\nERROR half writ")
+	summary, err := tailer.collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ERROR db login password=[REDACTED] from [REDACTED]", "FATAL out of memory"}
+	if summary.Count != 2 || strings.Join(summary.Samples, "|") != strings.Join(want, "|") {
+		t.Errorf("first scan = %d %q, want 2 %q", summary.Count, summary.Samples, want)
+	}
+
+	// The partial line is picked up once it is finished
+	appendLog(t, path, "ten\n")
+	if summary, _ := tailer.collect(); summary.Count != 1 || summary.Samples[0] != "ERROR half written" {
+		t.Errorf("second scan = %d %q, want the completed line", summary.Count, summary.Samples)
+	}
+	if summary, _ := tailer.collect(); summary.Count != 0 || len(summary.Samples) != 0 {
+		t.Errorf("scan without new lines = %d %q, want nothing", summary.Count, summary.Samples)
+	}
+
+	// A rotated, shorter file is read from the start
+	if err := os.WriteFile(path, []byte("ERROR new file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if summary, _ := tailer.collect(); summary.Count != 1 || summary.Samples[0] != "ERROR new file" {
+		t.Errorf("scan after rotation = %d %q, want the new file's error", summary.Count, summary.Samples)
+	}
+}
+
+func TestLogTailerCapsSamples(t *testing.T) {
+	path := writeFile(t, "app.log", "")
+	tailer, err := newLogTailer(path, `(?i)error`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, path, strings.Repeat("error: disk full\n", maxErrorSamples+3))
+
+	summary, _ := tailer.collect()
+	if summary.Count != maxErrorSamples+3 || len(summary.Samples) != maxErrorSamples {
+		t.Errorf("count %d with %d samples, want every error counted and %d sampled", summary.Count, len(summary.Samples), maxErrorSamples)
+	}
+}
+
+func TestLogTailerRejectsBadPattern(t *testing.T) {
+	if _, err := newLogTailer(writeFile(t, "app.log", ""), `ERROR(`); err == nil {
+		t.Error("newLogTailer accepted an invalid pattern")
+	}
+}
+
+func TestSanitizeLogLine(t *testing.T) {
+	long := strings.Repeat("x", maxErrorSampleLen+50)
+	tests := []struct{ line, want string }{
+		{"plain failure", "plain failure"},
+		{"Token: abc123 expired", "Token: [REDACTED] expired"},
+		{"API_KEY=k1 and secret = s2", "API_KEY=[REDACTED] and secret = [REDACTED]"},
+		{"peer 192.168.0.10 reset", "peer [REDACTED] reset"},
+		{"version 1.2.3 failed", "version 1.2.3 failed"},
+		{long, long[:maxErrorSampleLen] + "..."},
+	}
+	for _, tt := range tests {
+		if got := sanitizeLogLine(tt.line); got != tt.want {
+			t.Errorf("sanitizeLogLine(%.40q) = %.60q, want %.60q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log/slog"
@@ -14,101 +17,214 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/management/s01-shared"
 )
 
-// Config holds client configuration
+// Config holds client configuration. Config file keys are the lowercased
+// environment variable names.
 type Config struct {
-	ServerURL      string
-	ServiceName    string
-	InstanceName   string
-	ReportInterval int
-	CertFile       string
-	KeyFile        string
-	CACertFile     string
-	LogLevel       string
-	Timeout        int
-	RetryAttempts  int
-	RetryDelay     int
+	ServerURL          string   `json:"server_url"`
+	ServiceName        string   `json:"service_name"`
+	InstanceName       string   `json:"instance_name"`
+	ReportInterval     int      `json:"report_interval"`
+	CertFile           string   `json:"cert_file"`
+	KeyFile            string   `json:"key_file"`
+	CACertFile         string   `json:"ca_cert_file"`
+	LogLevel           string   `json:"log_level"`
+	LogFormat          string   `json:"log_format"` // json or text
+	LogOutput          string   `json:"log_output"` // stdout, stderr or a file path
+	Timeout            int      `json:"timeout"`
+	RetryAttempts      int      `json:"retry_attempts"`
+	RetryDelay         int      `json:"retry_delay"`
+	RetryMaxDelay      int      `json:"retry_max_delay"`       // cap in seconds on the backoff between attempts
+	HeartbeatInterval  int      `json:"heartbeat_interval"`    // seconds between status-only heartbeats; 0 disables
+	StatsLogInterval   int      `json:"stats_log_interval"`    // seconds between log lines summarizing the report counters; 0 disables
+	BreakerThreshold   int      `json:"breaker_threshold"`     // consecutive failed report cycles before backing off; 0 disables
+	BreakerInterval    int      `json:"breaker_interval"`      // seconds between probe reports while the breaker is open
+	ErrorLogPath       string   `json:"error_log_path"`        // optional local log scanned for recent errors
+	ErrorLogMatch      string   `json:"error_log_pattern"`     // regular expression selecting error lines
+	MetricsPort        string   `json:"metrics_port"`          // port for the local Prometheus exporter; empty disables
+	CertExpiryWarnDays int      `json:"cert_expiry_warn_days"` // warn when the certificate expires within this many days
+	RejectExpiredCert  bool     `json:"reject_expired_cert"`   // refuse to start or reload with an expired certificate
+	AdditionalServices string   `json:"additional_services"`   // comma-separated extra service names reported in one batch
+	BufferPath         string   `json:"buffer_path"`           // file undelivered reports are kept in until the server is back; empty disables
+	BufferMaxReports   int      `json:"buffer_max_reports"`    // most reports kept in the buffer; the oldest are dropped first
+	TLSMinVersion      string   `json:"tls_min_version"`       // "1.2" or "1.3"
+	CipherSuites       string   `json:"cipher_suites"`         // comma-separated TLS 1.2 suite names; empty uses shared.DefaultCipherSuites
+	OTLPEndpoint       string   `json:"otlp_endpoint"`         // OpenTelemetry collector base URL spans are exported to; empty disables tracing
+	SelfTest           bool     `json:"selftest"`              // run the health checks once, print them and exit without reporting
+	Once               bool     `json:"once"`                  // send a single report, with retries, and exit instead of reporting periodically
+	Labels             labelSet `json:"labels"`                // tags such as region or zone attached to every report
 }
 
-// StatusRequest represents the status report sent to the server
-type StatusRequest struct {
-	ServiceName   string         `json:"service_name"`
-	InstanceName  string         `json:"instance_name"`
-	Status        string         `json:"status"`
-	HealthMetrics *HealthMetrics `json:"health_metrics,omitempty"`
-}
+// Report payload types shared with the server, so both sides serialize them
+// identically
+type (
+	StatusRequest     = shared.StatusRequest
+	HealthCheck       = shared.HealthCheck
+	HealthMetrics     = shared.HealthMetrics
+	ScoreContribution = shared.ScoreContribution
+	RecentErrors      = shared.RecentErrors
+	StatusResponse    = shared.StatusResponse
+)
 
-// StatusResponse represents the response from the server
-type StatusResponse struct {
-	Status string `json:"status"`
-}
+// Report detail levels
+const (
+	reportDetailFull      = shared.ReportDetailFull
+	reportDetailHeartbeat = shared.ReportDetailHeartbeat
+)
 
 // S01Client handles communication with the s01 server
 type S01Client struct {
 	config     *Config
 	logger     *slog.Logger
 	httpClient *http.Client
+	baseURL    string // ServerURL, or unixSocketBaseURL when reporting over a Unix socket
 	stopChan   chan struct{}
+	logTail    *logTailer
+	buffer     *reportBuffer  // nil when buffering is disabled
+	tracer     *shared.Tracer // nil when tracing is disabled
+	srv        *srvLocator    // nil unless ServerURL names an SRV record
+	// sequence numbers reports; seeded from the clock at startup so it keeps
+	// increasing across restarts
+	sequence   atomic.Uint64
+	breaker    *circuitBreaker
+	systemInfo SystemInfo
+	// additionalServices are reported alongside ServiceName in one batch
+	additionalServices []string
+	lastStatus         string // status from the most recent full report, repeated by heartbeats
+	// healthConfig is loaded once at startup and replaced on SIGHUP
+	healthConfig HealthConfig
+
+	// latestMetrics and latestHostStatus hold the most recent health check
+	// pass so the metrics exporter can serve it without recomputing
+	metricsMutex     sync.RWMutex
+	latestMetrics    *HealthMetrics
+	latestHostStatus string
+
+	// advisedInterval is the report interval in seconds the server last asked
+	// for; 0 means the configured ReportInterval applies
+	advisedInterval atomic.Int64
+	// counters track report cycles for the metrics exporter and the log
+	counters reportCounters
 }
 
 // NewS01Client creates a new s01 client instance
 func NewS01Client(config *Config, logger *slog.Logger) (*S01Client, error) {
-	tlsConfig, err := setupTLSConfig(config)
+	httpClient, err := newHTTPClient(config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var logTail *logTailer
+	if config.ErrorLogPath != "" {
+		logTail, err = newLogTailer(config.ErrorLogPath, config.ErrorLogMatch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid error log pattern: %v", err)
+		}
+	}
+
+	dc := &S01Client{
+		config:       config,
+		logger:       logger,
+		httpClient:   httpClient,
+		baseURL:      serverBaseURL(config.ServerURL),
+		srv:          newSRVLocator(config.ServerURL, logger),
+		stopChan:     make(chan struct{}),
+		logTail:      logTail,
+		buffer:       newReportBuffer(config.BufferPath, config.BufferMaxReports),
+		tracer:       shared.NewTracer(config.OTLPEndpoint, "s01-client", logger),
+		breaker:      newCircuitBreaker(config.BreakerThreshold),
+		systemInfo:   getSystemInfo(),
+		healthConfig: loadHealthConfig(logger),
+
+		additionalServices: additionalServiceNames(config.AdditionalServices),
+	}
+	dc.sequence.Store(uint64(time.Now().UnixNano()))
+	return dc, nil
+}
+
+// newHTTPClient creates an mTLS HTTP client from the certificate files on
+// disk, or a plain HTTP one when ServerURL names a Unix socket
+func newHTTPClient(config *Config, logger *slog.Logger) (*http.Client, error) {
+	if path := unixSocketPath(config.ServerURL); path != "" {
+		return &http.Client{
+			Timeout:   time.Duration(config.Timeout) * time.Second,
+			Transport: newUnixSocketTransport(path),
+		}, nil
+	}
+
+	tlsConfig, err := setupTLSConfig(config, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup TLS: %v", err)
 	}
 
-	httpClient := &http.Client{
+	return &http.Client{
 		Timeout: time.Duration(config.Timeout) * time.Second,
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
 			MaxIdleConns:    10,
 			IdleConnTimeout: 30 * time.Second,
 		},
+	}, nil
+}
+
+// reloadCertificates rebuilds the HTTP client from the certificate files on
+// disk, keeping the current client if they fail to load
+func (dc *S01Client) reloadCertificates() {
+	httpClient, err := newHTTPClient(dc.config, dc.logger)
+	if err != nil {
+		dc.logger.Error("Certificate reload failed, keeping current certificates", "error", err)
+		return
 	}
 
-	return &S01Client{
-		config:     config,
-		logger:     logger,
-		httpClient: httpClient,
-		stopChan:   make(chan struct{}),
-	}, nil
+	previous := dc.httpClient
+	dc.httpClient = httpClient
+	previous.CloseIdleConnections()
+	dc.logger.Info("Certificates reloaded")
 }
 
 // setupTLSConfig configures mTLS for the client
-func setupTLSConfig(config *Config) (*tls.Config, error) {
+func setupTLSConfig(config *Config, logger *slog.Logger) (*tls.Config, error) {
 	// Load client certificate and key
 	clientCert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load client certificate: %v", err)
 	}
 
-	// Load CA certificate
-	caCertPEM, err := os.ReadFile(config.CACertFile)
+	leaf, err := x509.ParseCertificate(clientCert.Certificate[0])
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		return nil, fmt.Errorf("failed to parse client certificate: %v", err)
+	}
+	warnWindow := time.Duration(config.CertExpiryWarnDays) * 24 * time.Hour
+	if err := checkCertExpiry(leaf, config.CertFile, warnWindow, config.RejectExpiredCert, logger); err != nil {
+		return nil, err
 	}
 
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCertPEM) {
-		return nil, fmt.Errorf("failed to parse CA certificate")
+	// Load CA certificates; several are trusted at once during a CA rotation
+	caCertPool, err := loadCAPool(config.CACertFile, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	minVersion, cipherSuites, err := shared.ParseTLSOptions(config.TLSMinVersion, config.CipherSuites)
+	if err != nil {
+		return nil, err
 	}
 
 	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{clientCert},
 		RootCAs:      caCertPool,
-		MinVersion:   tls.VersionTLS12,
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-		},
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
 	}
 
 	return tlsConfig, nil
@@ -126,24 +242,6 @@ func getLocalIP() (string, error) {
 	return localAddr.IP.String(), nil
 }
 
-// HealthCheck represents a single health check result
-type HealthCheck struct {
-	Name    string `json:"name"`
-	Status  string `json:"status"`
-	Message string `json:"message,omitempty"`
-	Value   string `json:"value,omitempty"`
-}
-
-// HealthMetrics contains system health metrics
-type HealthMetrics struct {
-	CPUUsage     float64       `json:"cpu_usage"`
-	MemoryUsage  float64       `json:"memory_usage"`
-	DiskUsage    float64       `json:"disk_usage"`
-	NetworkOk    bool          `json:"network_ok"`
-	Checks       []HealthCheck `json:"checks"`
-	OverallScore int           `json:"overall_score"`
-}
-
 // HealthConfig represents health check configuration
 type HealthConfig struct {
 	HealthChecks struct {
@@ -153,6 +251,7 @@ type HealthConfig struct {
 			DegradedThreshold float64 `json:"degraded_threshold"`
 			CriticalThreshold float64 `json:"critical_threshold"`
 			Weight            int     `json:"weight"`
+			SampleMillis      int     `json:"sample_ms"` // interval between the two /proc/stat reads
 		} `json:"cpu"`
 		Memory struct {
 			Enabled           bool    `json:"enabled"`
@@ -168,6 +267,12 @@ type HealthConfig struct {
 			CriticalThreshold float64  `json:"critical_threshold"`
 			Weight            int      `json:"weight"`
 			Paths             []string `json:"paths"`
+			Auto              bool     `json:"auto"`
+			ExcludeFSTypes    []string `json:"exclude_fs_types"`
+			// Inode usage % thresholds, checked per path alongside space
+			InodeHealthyThreshold  float64 `json:"inode_healthy_threshold"`
+			InodeDegradedThreshold float64 `json:"inode_degraded_threshold"`
+			InodeCriticalThreshold float64 `json:"inode_critical_threshold"`
 		} `json:"disk"`
 		Network struct {
 			Enabled           bool `json:"enabled"`
@@ -175,19 +280,91 @@ type HealthConfig struct {
 			TimeoutSeconds    int  `json:"timeout_seconds"`
 			RequiredTestsPass int  `json:"required_tests_pass"`
 		} `json:"network"`
+		LoadAverage struct {
+			Enabled           bool    `json:"enabled"`
+			HealthyThreshold  float64 `json:"healthy_threshold"` // 1-minute load per core
+			DegradedThreshold float64 `json:"degraded_threshold"`
+			CriticalThreshold float64 `json:"critical_threshold"`
+			Weight            int     `json:"weight"`
+		} `json:"load_average"`
+		Process struct {
+			Enabled  bool     `json:"enabled"`
+			Weight   int      `json:"weight"`
+			Names    []string `json:"names"`     // process names matched against /proc/<pid>/comm
+			PidFiles []string `json:"pid_files"` // pidfiles whose process must be alive
+		} `json:"process"`
+		GPU struct {
+			Enabled           bool    `json:"enabled"`
+			HealthyThreshold  float64 `json:"healthy_threshold"` // utilization % of the busiest GPU
+			DegradedThreshold float64 `json:"degraded_threshold"`
+			CriticalThreshold float64 `json:"critical_threshold"`
+			Weight            int     `json:"weight"`
+		} `json:"gpu"`
+		Temperature struct {
+			Enabled           bool    `json:"enabled"`
+			HealthyThreshold  float64 `json:"healthy_threshold"` // °C of the hottest sensor
+			DegradedThreshold float64 `json:"degraded_threshold"`
+			CriticalThreshold float64 `json:"critical_threshold"`
+			Weight            int     `json:"weight"`
+			Sensor            string  `json:"sensor"` // hwmon device name to prefer, e.g. "coretemp"; empty uses all
+		} `json:"temperature"`
+		Interface struct {
+			Enabled          bool     `json:"enabled"`
+			Weight           int      `json:"weight"`
+			Names            []string `json:"names"`              // interfaces in /proc/net/dev, e.g. "eth0"
+			MaxErrorIncrease uint64   `json:"max_error_increase"` // errors or drops allowed to accrue between reports
+		} `json:"interface"`
 	} `json:"health_checks"`
-	Scoring struct {
-		HealthyScoreMin   int `json:"healthy_score_min"`
-		DegradedScoreMin  int `json:"degraded_score_min"`
-		UnhealthyScoreMax int `json:"unhealthy_score_max"`
+	CustomChecks []CustomCheck `json:"custom_checks"` // external commands scored by exit code
+	CgroupMode   string        `json:"cgroup_mode"`   // where CPU and memory usage are read: auto, cgroup or host
+	Scoring      struct {
+		HealthyScoreMin   int     `json:"healthy_score_min"`
+		DegradedScoreMin  int     `json:"degraded_score_min"`
+		UnhealthyScoreMax int     `json:"unhealthy_score_max"`
+		DegradedFactor    float64 `json:"degraded_factor"`   // share of a check's weight earned when degraded
+		UnhealthyFactor   float64 `json:"unhealthy_factor"`  // share of a check's weight earned when unhealthy
+		NormalizeWeights  bool    `json:"normalize_weights"` // scale OverallScore to 0-100 when the enabled weights sum to something else
 	} `json:"scoring"`
+	Reporting struct {
+		CheckTimeoutSeconds int `json:"check_timeout_seconds"` // deadline for a whole health check pass; 0 disables
+	} `json:"reporting"`
+}
+
+// scoreFactors are the shares of its weight a check earns when degraded or
+// unhealthy; a healthy check earns all of it
+type scoreFactors struct {
+	degraded  float64
+	unhealthy float64
+}
+
+// scoreFactors returns the configured degraded and unhealthy factors
+func (config HealthConfig) scoreFactors() scoreFactors {
+	return scoreFactors{degraded: config.Scoring.DegradedFactor, unhealthy: config.Scoring.UnhealthyFactor}
+}
+
+// share is the fraction of its weight a check with the given status earns
+func (f scoreFactors) share(status string) float64 {
+	switch status {
+	case "healthy":
+		return 1
+	case "degraded":
+		return f.degraded
+	case "unhealthy":
+		return f.unhealthy
+	default:
+		return 0
+	}
 }
 
-// getHostStatus determines the current status of the host with comprehensive checks
-func getHostStatus() string {
-	config := loadHealthConfig()
-	metrics := performHealthChecks(config)
+// points is the share of weight earned for status, truncated to whole points.
+// The epsilon absorbs float error such as 100*0.29 = 28.999..., which would
+// otherwise lose a point.
+func (f scoreFactors) points(status string, weight int) int {
+	return int(float64(weight)*f.share(status) + 1e-9)
+}
 
+// getHostStatus maps already-computed health metrics to a host status
+func getHostStatus(metrics HealthMetrics, config HealthConfig) string {
 	// Determine overall status based on configurable score thresholds
 	switch {
 	case metrics.OverallScore >= config.Scoring.HealthyScoreMin:
@@ -199,8 +376,9 @@ func getHostStatus() string {
 	}
 }
 
-// loadHealthConfig loads health check configuration from file and environment variables
-func loadHealthConfig() HealthConfig {
+// loadHealthConfig loads health check configuration from file and environment
+// variables, logging settings it ignores through logger
+func loadHealthConfig(logger *slog.Logger) HealthConfig {
 	// Default configuration
 	config := HealthConfig{}
 	config.HealthChecks.CPU.Enabled = true
@@ -208,6 +386,7 @@ func loadHealthConfig() HealthConfig {
 	config.HealthChecks.CPU.DegradedThreshold = 90.0
 	config.HealthChecks.CPU.CriticalThreshold = 95.0
 	config.HealthChecks.CPU.Weight = 25
+	config.HealthChecks.CPU.SampleMillis = 100
 
 	config.HealthChecks.Memory.Enabled = true
 	config.HealthChecks.Memory.HealthyThreshold = 85.0
@@ -221,15 +400,50 @@ func loadHealthConfig() HealthConfig {
 	config.HealthChecks.Disk.CriticalThreshold = 98.0
 	config.HealthChecks.Disk.Weight = 25
 	config.HealthChecks.Disk.Paths = []string{"/"}
+	config.HealthChecks.Disk.ExcludeFSTypes = defaultExcludeFSTypes
+	config.HealthChecks.Disk.InodeHealthyThreshold = 85.0
+	config.HealthChecks.Disk.InodeDegradedThreshold = 95.0
+	config.HealthChecks.Disk.InodeCriticalThreshold = 98.0
 
 	config.HealthChecks.Network.Enabled = true
 	config.HealthChecks.Network.Weight = 25
 	config.HealthChecks.Network.TimeoutSeconds = 5
 	config.HealthChecks.Network.RequiredTestsPass = 2
 
+	config.HealthChecks.LoadAverage.Enabled = false
+	config.HealthChecks.LoadAverage.HealthyThreshold = 0.7
+	config.HealthChecks.LoadAverage.DegradedThreshold = 1.0
+	config.HealthChecks.LoadAverage.CriticalThreshold = 2.0
+	config.HealthChecks.LoadAverage.Weight = 10
+
+	config.HealthChecks.Process.Enabled = false
+	config.HealthChecks.Process.Weight = 10
+
+	config.HealthChecks.GPU.Enabled = false
+	config.HealthChecks.GPU.HealthyThreshold = 90.0
+	config.HealthChecks.GPU.DegradedThreshold = 95.0
+	config.HealthChecks.GPU.CriticalThreshold = 98.0
+	config.HealthChecks.GPU.Weight = 10
+
+	config.HealthChecks.Temperature.Enabled = false
+	config.HealthChecks.Temperature.HealthyThreshold = 80.0
+	config.HealthChecks.Temperature.DegradedThreshold = 90.0
+	config.HealthChecks.Temperature.CriticalThreshold = 95.0
+	config.HealthChecks.Temperature.Weight = 10
+
+	config.HealthChecks.Interface.Enabled = false
+	config.HealthChecks.Interface.Weight = 10
+	config.HealthChecks.Interface.MaxErrorIncrease = 0
+
 	config.Scoring.HealthyScoreMin = 80
 	config.Scoring.DegradedScoreMin = 60
 	config.Scoring.UnhealthyScoreMax = 59
+	config.Scoring.DegradedFactor = 0.6
+	config.Scoring.UnhealthyFactor = 0.2
+
+	config.CgroupMode = cgroupModeAuto
+
+	config.Reporting.CheckTimeoutSeconds = 30
 
 	// Try to load from config file
 	configPaths := []string{
@@ -265,6 +479,11 @@ func loadHealthConfig() HealthConfig {
 	if envVal := os.Getenv("HEALTH_CPU_ENABLED"); envVal != "" {
 		config.HealthChecks.CPU.Enabled = envVal == "true"
 	}
+	if envVal := os.Getenv("HEALTH_CPU_SAMPLE_MS"); envVal != "" {
+		if val, err := strconv.Atoi(envVal); err == nil {
+			config.HealthChecks.CPU.SampleMillis = val
+		}
+	}
 
 	if envVal := os.Getenv("HEALTH_MEMORY_THRESHOLD"); envVal != "" {
 		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
@@ -300,9 +519,30 @@ func loadHealthConfig() HealthConfig {
 			config.HealthChecks.Disk.CriticalThreshold = val
 		}
 	}
+	if envVal := os.Getenv("HEALTH_DISK_INODE_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.Disk.InodeHealthyThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_DISK_INODE_DEGRADED_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.Disk.InodeDegradedThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_DISK_INODE_CRITICAL_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.Disk.InodeCriticalThreshold = val
+		}
+	}
 	if envVal := os.Getenv("HEALTH_DISK_ENABLED"); envVal != "" {
 		config.HealthChecks.Disk.Enabled = envVal == "true"
 	}
+	if envVal := os.Getenv("HEALTH_DISK_AUTO"); envVal != "" {
+		config.HealthChecks.Disk.Auto = envVal == "true"
+	}
+	if envVal := os.Getenv("HEALTH_DISK_EXCLUDE_FS_TYPES"); envVal != "" {
+		config.HealthChecks.Disk.ExcludeFSTypes = strings.Split(envVal, ",")
+	}
 
 	if envVal := os.Getenv("HEALTH_NETWORK_ENABLED"); envVal != "" {
 		config.HealthChecks.Network.Enabled = envVal == "true"
@@ -312,6 +552,90 @@ func loadHealthConfig() HealthConfig {
 			config.HealthChecks.Network.TimeoutSeconds = val
 		}
 	}
+	if envVal := os.Getenv("HEALTH_NETWORK_REQUIRED_TESTS"); envVal != "" {
+		if val, err := strconv.Atoi(envVal); err == nil {
+			config.HealthChecks.Network.RequiredTestsPass = val
+		}
+	}
+
+	if envVal := os.Getenv("HEALTH_LOAD_ENABLED"); envVal != "" {
+		config.HealthChecks.LoadAverage.Enabled = envVal == "true"
+	}
+	if envVal := os.Getenv("HEALTH_LOAD_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.LoadAverage.HealthyThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_LOAD_DEGRADED_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.LoadAverage.DegradedThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_LOAD_CRITICAL_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.LoadAverage.CriticalThreshold = val
+		}
+	}
+
+	if envVal := os.Getenv("HEALTH_PROCESS_ENABLED"); envVal != "" {
+		config.HealthChecks.Process.Enabled = envVal == "true"
+	}
+	if envVal := os.Getenv("HEALTH_PROCESS_NAMES"); envVal != "" {
+		config.HealthChecks.Process.Names = strings.Split(envVal, ",")
+	}
+
+	if envVal := os.Getenv("HEALTH_GPU_ENABLED"); envVal != "" {
+		config.HealthChecks.GPU.Enabled = envVal == "true"
+	}
+	if envVal := os.Getenv("HEALTH_GPU_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.GPU.HealthyThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_GPU_DEGRADED_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.GPU.DegradedThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_GPU_CRITICAL_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.GPU.CriticalThreshold = val
+		}
+	}
+
+	if envVal := os.Getenv("HEALTH_TEMP_ENABLED"); envVal != "" {
+		config.HealthChecks.Temperature.Enabled = envVal == "true"
+	}
+	if envVal := os.Getenv("HEALTH_TEMP_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.Temperature.HealthyThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_TEMP_DEGRADED_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.Temperature.DegradedThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_TEMP_CRITICAL_THRESHOLD"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil {
+			config.HealthChecks.Temperature.CriticalThreshold = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_TEMP_SENSOR"); envVal != "" {
+		config.HealthChecks.Temperature.Sensor = envVal
+	}
+
+	if envVal := os.Getenv("HEALTH_INTERFACE_ENABLED"); envVal != "" {
+		config.HealthChecks.Interface.Enabled = envVal == "true"
+	}
+	if envVal := os.Getenv("HEALTH_INTERFACE_NAMES"); envVal != "" {
+		config.HealthChecks.Interface.Names = strings.Split(envVal, ",")
+	}
+	if envVal := os.Getenv("HEALTH_INTERFACE_MAX_ERROR_INCREASE"); envVal != "" {
+		if val, err := strconv.ParseUint(envVal, 10, 64); err == nil {
+			config.HealthChecks.Interface.MaxErrorIncrease = val
+		}
+	}
 
 	if envVal := os.Getenv("HEALTH_SCORE_HEALTHY_MIN"); envVal != "" {
 		if val, err := strconv.Atoi(envVal); err == nil {
@@ -328,177 +652,612 @@ func loadHealthConfig() HealthConfig {
 			config.Scoring.UnhealthyScoreMax = val
 		}
 	}
+	if envVal := os.Getenv("HEALTH_SCORE_DEGRADED_FACTOR"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil && val >= 0 && val <= 1 {
+			config.Scoring.DegradedFactor = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_SCORE_UNHEALTHY_FACTOR"); envVal != "" {
+		if val, err := strconv.ParseFloat(envVal, 64); err == nil && val >= 0 && val <= 1 {
+			config.Scoring.UnhealthyFactor = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_SCORE_NORMALIZE"); envVal != "" {
+		config.Scoring.NormalizeWeights = envVal == "true"
+	}
+
+	if envVal := os.Getenv("HEALTH_CHECK_TIMEOUT"); envVal != "" {
+		if val, err := strconv.Atoi(envVal); err == nil && val >= 0 {
+			config.Reporting.CheckTimeoutSeconds = val
+		}
+	}
+	if envVal := os.Getenv("HEALTH_CGROUP_MODE"); envVal != "" {
+		config.CgroupMode = envVal
+	}
+	switch config.CgroupMode {
+	case cgroupModeAuto, cgroupModeCgroup, cgroupModeHost:
+	default:
+		config.CgroupMode = cgroupModeAuto
+	}
+
+	// HEALTH_DISABLE turns several checks off at once, overriding their Enabled flags
+	if envVal := os.Getenv("HEALTH_DISABLE"); envVal != "" {
+		config.disableChecks(strings.Split(envVal, ","), logger)
+	}
+
+	// The score thresholds assume the enabled checks' weights add up to 100
+	if total := config.totalWeight(); total != 100 {
+		switch {
+		case total == 0:
+			logger.Warn("No weighted health checks enabled; the overall score is always 0")
+		case config.Scoring.NormalizeWeights:
+			logger.Info("Health check weights do not sum to 100, scaling the overall score to 0-100",
+				"total_weight", total,
+			)
+		default:
+			logger.Warn("Enabled health check weights do not sum to 100, skewing the score thresholds; set scoring.normalize_weights to scale the score",
+				"total_weight", total,
+				"healthy_score_min", config.Scoring.HealthyScoreMin,
+				"degraded_score_min", config.Scoring.DegradedScoreMin,
+			)
+		}
+	}
 
 	return config
 }
 
-// performHealthChecks runs comprehensive system health checks
-func performHealthChecks(config HealthConfig) HealthMetrics {
-	var checks []HealthCheck
-	var score int
-
-	// Check CPU usage
-	if config.HealthChecks.CPU.Enabled {
-		cpuUsage := getCPUUsage()
-		cpuCheck := HealthCheck{
-			Name:  "CPU Usage",
-			Value: fmt.Sprintf("%.1f%%", cpuUsage),
-		}
-		if cpuUsage < config.HealthChecks.CPU.HealthyThreshold {
-			cpuCheck.Status = "healthy"
-			score += config.HealthChecks.CPU.Weight
-		} else if cpuUsage < config.HealthChecks.CPU.DegradedThreshold {
-			cpuCheck.Status = "degraded"
-			cpuCheck.Message = "High CPU usage"
-			score += config.HealthChecks.CPU.Weight * 60 / 100 // 60% of weight
-		} else {
-			cpuCheck.Status = "unhealthy"
-			cpuCheck.Message = "Critical CPU usage"
-			score += config.HealthChecks.CPU.Weight * 20 / 100 // 20% of weight
-		}
-		checks = append(checks, cpuCheck)
-	}
-
-	// Check memory usage
-	memUsage := getMemoryUsage()
-	if config.HealthChecks.Memory.Enabled {
-		memCheck := HealthCheck{
-			Name:  "Memory Usage",
-			Value: fmt.Sprintf("%.1f%%", memUsage),
-		}
-		if memUsage < config.HealthChecks.Memory.HealthyThreshold {
-			memCheck.Status = "healthy"
-			score += config.HealthChecks.Memory.Weight
-		} else if memUsage < config.HealthChecks.Memory.DegradedThreshold {
-			memCheck.Status = "degraded"
-			memCheck.Message = "High memory usage"
-			score += config.HealthChecks.Memory.Weight * 60 / 100
+// totalWeight is the most points a health check pass can score: the sum of
+// the weights of the checks that count towards OverallScore
+func (config HealthConfig) totalWeight() int {
+	total := 0
+	for _, section := range healthSections(config) {
+		if section.scored {
+			total += section.weight
+		}
+	}
+	return total
+}
+
+// normalizeScore scales score out of totalWeight to 0-100, leaving it as is
+// when totalWeight is 0
+func normalizeScore(score, totalWeight int) int {
+	if totalWeight == 0 {
+		return score
+	}
+	return int(math.Round(float64(score) * 100 / float64(totalWeight)))
+}
+
+// disableChecks turns off the checks named as in the health_checks section
+// of the config file, e.g. "disk" or "load_average". Unknown names are logged
+// and skipped.
+func (config *HealthConfig) disableChecks(names []string, logger *slog.Logger) {
+	checks := &config.HealthChecks
+	enabled := map[string]*bool{
+		"cpu":          &checks.CPU.Enabled,
+		"memory":       &checks.Memory.Enabled,
+		"disk":         &checks.Disk.Enabled,
+		"network":      &checks.Network.Enabled,
+		"load_average": &checks.LoadAverage.Enabled,
+		"process":      &checks.Process.Enabled,
+		"gpu":          &checks.GPU.Enabled,
+		"temperature":  &checks.Temperature.Enabled,
+		"interface":    &checks.Interface.Enabled,
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		flag, known := enabled[name]
+		if !known {
+			logger.Warn("Ignoring unknown check in HEALTH_DISABLE", "check", name)
+			continue
+		}
+		*flag = false
+	}
+}
+
+// healthSection is one independent health check. Sections run concurrently,
+// so a slow one such as the network test does not delay the others.
+type healthSection struct {
+	name   string // breakdown entry name
+	weight int
+	scored bool // false when the check is disabled and only its summary figure is gathered
+	alone  bool // finishes before the other sections start, so their work does not skew it
+	run    func() sectionResult
+}
+
+// sectionResult is what a healthSection produced: its checks in display
+// order, the points they earned and the summary figure it sets, if any
+type sectionResult struct {
+	checks  []HealthCheck
+	points  int
+	summary func(*HealthMetrics)
+}
+
+// performHealthChecks runs comprehensive system health checks. The pass is
+// bounded by Reporting.CheckTimeoutSeconds so a hung check, e.g. statfs on a
+// dead NFS mount, cannot hold up the report.
+func performHealthChecks(ctx context.Context, config HealthConfig) HealthMetrics {
+	if timeout := config.Reporting.CheckTimeoutSeconds; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+	metrics := runHealthSections(ctx, healthSections(config))
+	if config.Scoring.NormalizeWeights {
+		totalWeight := 0
+		for _, contribution := range metrics.ScoreBreakdown {
+			totalWeight += contribution.MaxPoints
+		}
+		metrics.OverallScore = normalizeScore(metrics.OverallScore, totalWeight)
+	}
+	return metrics
+}
+
+// runHealthSections runs sections concurrently and combines their checks and
+// points into HealthMetrics, in section order. A section still running when
+// ctx is done is reported as an "unknown" check worth zero points; its
+// goroutine is left to finish on its own.
+func runHealthSections(ctx context.Context, sections []healthSection) HealthMetrics {
+	// Each section reports on its own channel so results are collected in
+	// section order whatever order they finish in
+	results := make([]chan sectionResult, len(sections))
+	collected := make([]sectionResult, len(sections))
+	finished := make([]bool, len(sections))
+	for i, section := range sections {
+		results[i] = make(chan sectionResult, 1)
+		go func(run func() sectionResult, result chan<- sectionResult) {
+			result <- run()
+		}(section.run, results[i])
+		if section.alone {
+			collected[i], finished[i] = awaitSection(ctx, results[i])
+		}
+	}
+
+	var metrics HealthMetrics
+	for i, section := range sections {
+		result, ok := collected[i], finished[i]
+		if !section.alone {
+			result, ok = awaitSection(ctx, results[i])
+		}
+		if !ok {
+			result = sectionResult{checks: []HealthCheck{{
+				Name:    section.name,
+				Status:  "unknown",
+				Message: fmt.Sprintf("timeout: check did not finish (%v)", ctx.Err()),
+			}}}
+		}
+		if result.summary != nil {
+			result.summary(&metrics)
+		}
+		if !section.scored {
+			continue
+		}
+		metrics.Checks = append(metrics.Checks, result.checks...)
+		metrics.OverallScore += result.points
+		metrics.ScoreBreakdown = append(metrics.ScoreBreakdown, ScoreContribution{Check: section.name, Points: result.points, MaxPoints: section.weight})
+	}
+	return metrics
+}
+
+// awaitSection waits for a section's result until ctx is done, preferring a
+// result that is already available
+func awaitSection(ctx context.Context, result <-chan sectionResult) (sectionResult, bool) {
+	select {
+	case r := <-result:
+		return r, true
+	case <-ctx.Done():
+		select {
+		case r := <-result:
+			return r, true
+		default:
+			return sectionResult{}, false
+		}
+	}
+}
+
+// healthSections lists the health checks in the order they are reported.
+// CPU and memory are always sampled for the summary figures, even when their
+// checks are disabled. CPU is sampled alone since the other checks would
+// otherwise count towards it.
+func healthSections(config HealthConfig) []healthSection {
+	checks := config.HealthChecks
+	factors := config.scoreFactors()
+
+	sections := []healthSection{
+		{name: "CPU Usage", weight: checks.CPU.Weight, scored: checks.CPU.Enabled, alone: true, run: func() sectionResult {
+			return cpuSection(config, factors)
+		}},
+	}
+	if checks.LoadAverage.Enabled {
+		sections = append(sections, healthSection{name: "Load Average", weight: checks.LoadAverage.Weight, scored: true, run: func() sectionResult {
+			return loadAverageSection(config, factors)
+		}})
+	}
+	sections = append(sections, healthSection{name: "Memory Usage", weight: checks.Memory.Weight, scored: checks.Memory.Enabled, run: func() sectionResult {
+		return memorySection(config, factors)
+	}})
+	if checks.Disk.Enabled {
+		// Bind statfs now: a hung section outlives the pass and must not
+		// read the variable after it has returned
+		statfs := statfs
+		sections = append(sections, healthSection{name: "Disk Usage", weight: checks.Disk.Weight, scored: true, run: func() sectionResult {
+			return diskSection(config, factors, statfs)
+		}})
+	}
+	if checks.Network.Enabled {
+		sections = append(sections, healthSection{name: "Network Connectivity", weight: checks.Network.Weight, scored: true, run: func() sectionResult {
+			return networkSection(config)
+		}})
+	}
+	if checks.Process.Enabled {
+		// Check that required processes are running
+		sections = append(sections, healthSection{name: "Process", weight: checks.Process.Weight, scored: true, run: func() sectionResult {
+			check, points := checkProcesses(procPath, checks.Process.Names, checks.Process.PidFiles, checks.Process.Weight)
+			return sectionResult{checks: []HealthCheck{check}, points: points}
+		}})
+	}
+	if checks.GPU.Enabled {
+		// Check GPU utilization, e.g. on ML hosts
+		sections = append(sections, healthSection{name: "GPU Usage", weight: checks.GPU.Weight, scored: true, run: func() sectionResult {
+			usages, err := getGPUUtilization()
+			check, points := checkGPU(usages, err, checks.GPU.HealthyThreshold, checks.GPU.DegradedThreshold, checks.GPU.Weight, factors)
+			return sectionResult{checks: []HealthCheck{check}, points: points}
+		}})
+	}
+	if checks.Temperature.Enabled {
+		// Check the hottest temperature sensor to surface thermal throttling
+		sections = append(sections, healthSection{name: "CPU Temperature", weight: checks.Temperature.Weight, scored: true, run: func() sectionResult {
+			readings, err := readHwmonTemperatures(hwmonClassPath, checks.Temperature.Sensor)
+			check, points := checkTemperature(readings, err, checks.Temperature.HealthyThreshold, checks.Temperature.DegradedThreshold, checks.Temperature.Weight, factors)
+			return sectionResult{checks: []HealthCheck{check}, points: points}
+		}})
+	}
+	if checks.Interface.Enabled {
+		// Check that interfaces exist and are not accruing errors or drops
+		sections = append(sections, healthSection{name: "Network Interfaces", weight: checks.Interface.Weight, scored: true, run: func() sectionResult {
+			interfaceChecks, points := checkInterfaces(procPath, checks.Interface.Names, checks.Interface.MaxErrorIncrease, checks.Interface.Weight, interfaceSamples)
+			return sectionResult{checks: interfaceChecks, points: points}
+		}})
+	}
+	for _, custom := range config.CustomChecks {
+		if custom.Name == "" || len(custom.Command) == 0 {
+			continue
+		}
+		custom := custom
+		sections = append(sections, healthSection{name: custom.Name, weight: custom.Weight, scored: true, run: func() sectionResult {
+			check, points := runCustomCheck(custom, factors)
+			return sectionResult{checks: []HealthCheck{check}, points: points}
+		}})
+	}
+	return sections
+}
+
+// cpuSection samples CPU usage once; the same reading feeds the check and the metrics
+func cpuSection(config HealthConfig, factors scoreFactors) sectionResult {
+	cpuUsage := sampleCPUUsage(time.Duration(config.HealthChecks.CPU.SampleMillis)*time.Millisecond, config.CgroupMode)
+	cpuCheck := HealthCheck{
+		Name:  "CPU Usage",
+		Value: fmt.Sprintf("%.1f%%", cpuUsage),
+	}
+	if cpuUsage < config.HealthChecks.CPU.HealthyThreshold {
+		cpuCheck.Status = "healthy"
+	} else if cpuUsage < config.HealthChecks.CPU.DegradedThreshold {
+		cpuCheck.Status = "degraded"
+		cpuCheck.Message = "High CPU usage"
+	} else {
+		cpuCheck.Status = "unhealthy"
+		cpuCheck.Message = "Critical CPU usage"
+	}
+	return sectionResult{
+		checks:  []HealthCheck{cpuCheck},
+		points:  factors.points(cpuCheck.Status, config.HealthChecks.CPU.Weight),
+		summary: func(m *HealthMetrics) { m.CPUUsage = cpuUsage },
+	}
+}
+
+// loadAverageSection checks load average relative to core count
+func loadAverageSection(config HealthConfig, factors scoreFactors) sectionResult {
+	loadCheck := HealthCheck{
+		Name: "Load Average",
+	}
+	if load, err := getLoadAverage(); err != nil {
+		loadCheck.Status = "unknown"
+		loadCheck.Message = fmt.Sprintf("failed to read load average: %v", err)
+	} else {
+		perCore := loadPerCore(load, runtime.NumCPU())
+		loadCheck.Value = fmt.Sprintf("%.2f (%.2f per core)", load, perCore)
+		if perCore < config.HealthChecks.LoadAverage.HealthyThreshold {
+			loadCheck.Status = "healthy"
+		} else if perCore < config.HealthChecks.LoadAverage.DegradedThreshold {
+			loadCheck.Status = "degraded"
+			loadCheck.Message = "High load average"
 		} else {
-			memCheck.Status = "unhealthy"
-			memCheck.Message = "Critical memory usage"
-			score += config.HealthChecks.Memory.Weight * 20 / 100
+			loadCheck.Status = "unhealthy"
+			loadCheck.Message = "Run queue backed up"
 		}
-		checks = append(checks, memCheck)
+	}
+	return sectionResult{
+		checks: []HealthCheck{loadCheck},
+		points: factors.points(loadCheck.Status, config.HealthChecks.LoadAverage.Weight),
+	}
+}
+
+// memorySection checks memory usage
+func memorySection(config HealthConfig, factors scoreFactors) sectionResult {
+	memUsage := sampleMemoryUsage(config.CgroupMode)
+	memCheck := HealthCheck{
+		Name:  "Memory Usage",
+		Value: fmt.Sprintf("%.1f%%", memUsage),
+	}
+	if memUsage < config.HealthChecks.Memory.HealthyThreshold {
+		memCheck.Status = "healthy"
+	} else if memUsage < config.HealthChecks.Memory.DegradedThreshold {
+		memCheck.Status = "degraded"
+		memCheck.Message = "High memory usage"
+	} else {
+		memCheck.Status = "unhealthy"
+		memCheck.Message = "Critical memory usage"
+	}
+	return sectionResult{
+		checks:  []HealthCheck{memCheck},
+		points:  factors.points(memCheck.Status, config.HealthChecks.Memory.Weight),
+		summary: func(m *HealthMetrics) { m.MemoryUsage = memUsage },
+	}
+}
+
+// diskSection checks disk usage, one check per path, reading filesystem
+// statistics with statfs
+func diskSection(config HealthConfig, factors scoreFactors, statfs func(string, *syscall.Statfs_t) error) sectionResult {
+	diskPaths := config.HealthChecks.Disk.Paths
+	if config.HealthChecks.Disk.Auto {
+		// Check every real filesystem rather than a fixed list
+		diskPaths = discoverMounts(mountsPath, config.HealthChecks.Disk.ExcludeFSTypes)
+	} else if len(diskPaths) == 0 {
+		diskPaths = []string{"/"}
 	}
 
-	// Check disk usage
 	var diskUsage float64
-	if config.HealthChecks.Disk.Enabled {
-		// Check primary disk path
-		diskPath := "/"
-		if len(config.HealthChecks.Disk.Paths) > 0 {
-			diskPath = config.HealthChecks.Disk.Paths[0]
-		}
-		diskUsage = getDiskUsage(diskPath)
+	var diskChecks []HealthCheck
+	var diskPoints float64
+	for _, path := range diskPaths {
+		usage, err := getDiskUsage(statfs, path)
 		diskCheck := HealthCheck{
-			Name:  "Disk Usage",
-			Value: fmt.Sprintf("%.1f%%", diskUsage),
+			Name: fmt.Sprintf("Disk Usage (%s)", path),
+		}
+		if err != nil {
+			diskCheck.Status = "unknown"
+			diskCheck.Message = fmt.Sprintf("statfs failed: %v", err)
+			diskChecks = append(diskChecks, diskCheck)
+			continue
 		}
-		if diskUsage < config.HealthChecks.Disk.HealthyThreshold {
+		diskCheck.Value = fmt.Sprintf("%.1f%%", usage)
+		// The summary figure is the fullest path so it stays conservative
+		if usage > diskUsage {
+			diskUsage = usage
+		}
+		if usage < config.HealthChecks.Disk.HealthyThreshold {
 			diskCheck.Status = "healthy"
-			score += config.HealthChecks.Disk.Weight
-		} else if diskUsage < config.HealthChecks.Disk.DegradedThreshold {
+		} else if usage < config.HealthChecks.Disk.DegradedThreshold {
 			diskCheck.Status = "degraded"
 			diskCheck.Message = "High disk usage"
-			score += config.HealthChecks.Disk.Weight * 60 / 100
 		} else {
 			diskCheck.Status = "unhealthy"
 			diskCheck.Message = "Critical disk usage"
-			score += config.HealthChecks.Disk.Weight * 20 / 100
 		}
-		checks = append(checks, diskCheck)
-	}
+		diskPoints += factors.share(diskCheck.Status)
+		diskChecks = append(diskChecks, diskCheck)
 
-	// Check network connectivity
-	var networkOk bool
-	if config.HealthChecks.Network.Enabled {
-		networkOk = checkNetworkConnectivity()
-		netCheck := HealthCheck{
-			Name:  "Network Connectivity",
-			Value: fmt.Sprintf("%t", networkOk),
+		// A filesystem can run out of inodes with plenty of space left
+		inodeUsage, hasInodes, err := getInodeUsage(statfs, path)
+		if err != nil || !hasInodes {
+			continue
+		}
+		inodeCheck := HealthCheck{
+			Name:  fmt.Sprintf("Inode Usage (%s)", path),
+			Value: fmt.Sprintf("%.1f%%", inodeUsage),
 		}
-		if networkOk {
-			netCheck.Status = "healthy"
-			score += config.HealthChecks.Network.Weight
+		if inodeUsage < config.HealthChecks.Disk.InodeHealthyThreshold {
+			inodeCheck.Status = "healthy"
+		} else if inodeUsage < config.HealthChecks.Disk.InodeDegradedThreshold {
+			inodeCheck.Status = "degraded"
+			inodeCheck.Message = "High inode usage"
 		} else {
-			netCheck.Status = "unhealthy"
-			netCheck.Message = "Network connectivity issues"
-			score += 0
+			inodeCheck.Status = "unhealthy"
+			inodeCheck.Message = "Inodes nearly exhausted"
 		}
-		checks = append(checks, netCheck)
+		diskPoints += factors.share(inodeCheck.Status)
+		diskChecks = append(diskChecks, inodeCheck)
 	}
 
-	return HealthMetrics{
-		CPUUsage:     getCPUUsage(),
-		MemoryUsage:  memUsage,
-		DiskUsage:    diskUsage,
-		NetworkOk:    networkOk,
-		Checks:       checks,
-		OverallScore: score,
+	// Each space and inode check carries an equal share of the disk weight
+	var points int
+	if len(diskChecks) > 0 {
+		points = int(float64(config.HealthChecks.Disk.Weight)*diskPoints/float64(len(diskChecks)) + 1e-9)
+	}
+	return sectionResult{
+		checks:  diskChecks,
+		points:  points,
+		summary: func(m *HealthMetrics) { m.DiskUsage = diskUsage },
 	}
 }
 
-// getCPUUsage returns CPU usage percentage
-func getCPUUsage() float64 {
-	// Read from /proc/loadavg on Linux
-	if data, err := os.ReadFile("/proc/loadavg"); err == nil {
-		loadStr := strings.Fields(string(data))
-		if len(loadStr) > 0 {
-			if load, err := strconv.ParseFloat(loadStr[0], 64); err == nil {
-				// Convert load average to approximate CPU percentage (rough estimate)
-				// This is a simplified calculation
-				return math.Min(load*100, 100.0)
-			}
+// networkSection checks network connectivity
+func networkSection(config HealthConfig) sectionResult {
+	networkOk := checkNetworkConnectivity(config)
+	netCheck := HealthCheck{
+		Name:  "Network Connectivity",
+		Value: fmt.Sprintf("%t", networkOk),
+	}
+	var points int
+	if networkOk {
+		netCheck.Status = "healthy"
+		points = config.HealthChecks.Network.Weight
+	} else {
+		netCheck.Status = "unhealthy"
+		netCheck.Message = "Network connectivity issues"
+	}
+	return sectionResult{
+		checks:  []HealthCheck{netCheck},
+		points:  points,
+		summary: func(m *HealthMetrics) { m.NetworkOk = networkOk },
+	}
+}
+
+// procStatPath is read for CPU time accounting
+var procStatPath = "/proc/stat"
+
+// sampleCPUUsage and sampleMemoryUsage take the readings behind the CPU and
+// memory checks, once per health check pass
+var (
+	sampleCPUUsage    = getCPUUsage
+	sampleMemoryUsage = getMemoryUsage
+)
+
+// getCPUUsage returns CPU usage percentage measured over sampleInterval,
+// from the cgroup when cgroupMode selects it. The aggregate "cpu" line of
+// /proc/stat sums every core, so the ratio of busy to total jiffies is
+// already normalized to 0-100 regardless of core count.
+func getCPUUsage(sampleInterval time.Duration, cgroupMode string) float64 {
+	if cgroupMode != cgroupModeHost {
+		if usage, ok := getCgroupCPUUsage(sampleInterval, cgroupMode); ok {
+			return usage
 		}
 	}
 
-	// Fallback method using /proc/stat
-	if data, err := os.ReadFile("/proc/stat"); err == nil {
-		lines := strings.Split(string(data), "\n")
-		if len(lines) > 0 && strings.HasPrefix(lines[0], "cpu") {
-			fields := strings.Fields(lines[0])
-			if len(fields) >= 8 {
-				var total, idle uint64
-				for i := 1; i < len(fields); i++ {
-					if val, err := strconv.ParseUint(fields[i], 10, 64); err == nil {
-						total += val
-						if i == 4 { // idle time is the 4th field
-							idle = val
-						}
-					}
-				}
-				if total > 0 {
-					return float64(total-idle) / float64(total) * 100.0
-				}
-			}
+	if idle1, total1, err := readCPUTimes(procStatPath); err == nil {
+		time.Sleep(sampleInterval)
+		if idle2, total2, err := readCPUTimes(procStatPath); err == nil {
+			return cpuUsageBetween(idle1, total1, idle2, total2)
 		}
 	}
 
+	// Last resort: approximate from the 1-minute load average per core
+	if load, err := getLoadAverage(); err == nil {
+		return math.Min(loadPerCore(load, runtime.NumCPU())*100, 100.0)
+	}
+
 	// If we can't determine CPU usage, return a conservative estimate
 	return 25.0
 }
 
-// getMemoryUsage returns memory usage percentage
-func getMemoryUsage() float64 {
-	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
-		var memTotal, memFree, buffers, cached uint64
-
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			if strings.HasPrefix(line, "MemTotal:") {
-				memTotal = parseMemInfoValue(line)
-			} else if strings.HasPrefix(line, "MemFree:") {
-				memFree = parseMemInfoValue(line)
-			} else if strings.HasPrefix(line, "Buffers:") {
-				buffers = parseMemInfoValue(line)
-			} else if strings.HasPrefix(line, "Cached:") {
-				cached = parseMemInfoValue(line)
+// loadavgPath is read for the system load average
+var loadavgPath = "/proc/loadavg"
+
+// getLoadAverage returns the 1-minute load average
+func getLoadAverage() (float64, error) {
+	data, err := os.ReadFile(loadavgPath)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty %s", loadavgPath)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// loadPerCore normalizes a load average by the number of CPUs
+func loadPerCore(load float64, cpus int) float64 {
+	if cpus < 1 {
+		cpus = 1
+	}
+	return load / float64(cpus)
+}
+
+// readCPUTimes reads the aggregate idle and total jiffies from a /proc/stat file
+func readCPUTimes(path string) (idle, total uint64, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseCPUTimes(string(data))
+}
+
+// cpuTimes holds the aggregate jiffy counters from the "cpu" line of /proc/stat
+type cpuTimes struct {
+	User, Nice, System, Idle, IOWait, IRQ, SoftIRQ, Steal uint64
+}
+
+// idle returns the jiffies spent not doing work; time waiting on I/O counts as idle
+func (t cpuTimes) idle() uint64 {
+	return t.Idle + t.IOWait
+}
+
+// total returns all accounted jiffies. guest and guest_nice are already
+// included in user and nice, so they are deliberately left out.
+func (t cpuTimes) total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+// parseCPUTimes extracts the aggregate idle and total jiffies from /proc/stat contents
+func parseCPUTimes(content string) (idle, total uint64, err error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "cpu" {
+			continue
+		}
+
+		// cpu user nice system idle iowait irq softirq steal [guest guest_nice]
+		var values [8]uint64
+		for i := range values {
+			if i+1 >= len(fields) {
+				break // older kernels report fewer columns
+			}
+			val, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid /proc/stat value %q: %v", fields[i+1], err)
 			}
+			values[i] = val
+		}
+		if len(fields) < 5 {
+			return 0, 0, fmt.Errorf("truncated cpu line in /proc/stat")
+		}
+
+		times := cpuTimes{
+			User:    values[0],
+			Nice:    values[1],
+			System:  values[2],
+			Idle:    values[3],
+			IOWait:  values[4],
+			IRQ:     values[5],
+			SoftIRQ: values[6],
+			Steal:   values[7],
+		}
+		return times.idle(), times.total(), nil
+	}
+	return 0, 0, fmt.Errorf("no aggregate cpu line in /proc/stat")
+}
+
+// cpuUsageBetween computes the busy percentage between two /proc/stat samples
+func cpuUsageBetween(idle1, total1, idle2, total2 uint64) float64 {
+	if total2 <= total1 || idle2 < idle1 {
+		return 0
+	}
+	totalDelta := total2 - total1
+	idleDelta := idle2 - idle1
+	if idleDelta > totalDelta {
+		return 0
+	}
+	return float64(totalDelta-idleDelta) / float64(totalDelta) * 100.0
+}
+
+// meminfoPath is read for memory accounting
+var meminfoPath = "/proc/meminfo"
+
+// getMemoryUsage returns memory usage percentage, from the cgroup when
+// cgroupMode selects it
+func getMemoryUsage(cgroupMode string) float64 {
+	if cgroupMode != cgroupModeHost {
+		if usage, ok := getCgroupMemoryUsage(cgroupMode); ok {
+			return usage
 		}
+	}
 
-		if memTotal > 0 {
-			memUsed := memTotal - memFree - buffers - cached
-			return float64(memUsed) / float64(memTotal) * 100.0
+	if data, err := os.ReadFile(meminfoPath); err == nil {
+		if usage, ok := parseMemoryUsage(string(data)); ok {
+			return usage
 		}
 	}
 
@@ -506,6 +1265,42 @@ func getMemoryUsage() float64 {
 	return 50.0
 }
 
+// parseMemoryUsage computes the used memory percentage from /proc/meminfo contents.
+// MemAvailable (Linux 3.14+) accounts for reclaimable slab and is preferred;
+// older kernels fall back to MemTotal - MemFree - Buffers - Cached.
+func parseMemoryUsage(content string) (float64, bool) {
+	var memTotal, memFree, memAvailable, buffers, cached uint64
+	var hasAvailable bool
+
+	lines := strings.Split(content, "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "MemTotal:") {
+			memTotal = parseMemInfoValue(line)
+		} else if strings.HasPrefix(line, "MemFree:") {
+			memFree = parseMemInfoValue(line)
+		} else if strings.HasPrefix(line, "MemAvailable:") {
+			memAvailable = parseMemInfoValue(line)
+			hasAvailable = true
+		} else if strings.HasPrefix(line, "Buffers:") {
+			buffers = parseMemInfoValue(line)
+		} else if strings.HasPrefix(line, "Cached:") {
+			cached = parseMemInfoValue(line)
+		}
+	}
+
+	if memTotal == 0 {
+		return 0, false
+	}
+
+	var memUsed uint64
+	if hasAvailable && memAvailable <= memTotal {
+		memUsed = memTotal - memAvailable
+	} else if reclaimable := memFree + buffers + cached; reclaimable <= memTotal {
+		memUsed = memTotal - reclaimable
+	}
+	return float64(memUsed) / float64(memTotal) * 100.0, true
+}
+
 // parseMemInfoValue parses values from /proc/meminfo
 func parseMemInfoValue(line string) uint64 {
 	fields := strings.Fields(line)
@@ -517,65 +1312,150 @@ func parseMemInfoValue(line string) uint64 {
 	return 0
 }
 
-// getDiskUsage returns disk usage percentage for given path
-func getDiskUsage(path string) float64 {
-	if stat, err := os.Stat(path); err == nil && stat.IsDir() {
-		// Try to read from /proc/mounts to find the right filesystem
-		if data, err := os.ReadFile("/proc/mounts"); err == nil {
-			lines := strings.Split(string(data), "\n")
-			for _, line := range lines {
-				fields := strings.Fields(line)
-				if len(fields) >= 6 && fields[1] == path {
-					// Found the mount point, try to get statvfs-like info
-					// This is a simplified approach - in production you might use syscalls
-					break
-				}
-			}
+// statfs reads filesystem statistics for the disk checks; healthSections
+// binds it when a pass starts
+var statfs = syscall.Statfs
+
+// getDiskUsage returns the used percentage of the filesystem holding path.
+// Like df, reserved blocks count as unavailable: used / (used + available).
+func getDiskUsage(statfs func(string, *syscall.Statfs_t) error, path string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	used := uint64(stat.Blocks) - uint64(stat.Bfree)
+	total := used + uint64(stat.Bavail)
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(total) * 100.0, nil
+}
+
+// getInodeUsage returns the used percentage of inodes on the filesystem
+// holding path. hasInodes is false for filesystems without a fixed inode
+// table (e.g. btrfs), which report zero total inodes.
+func getInodeUsage(statfs func(string, *syscall.Statfs_t) error, path string) (usage float64, hasInodes bool, err error) {
+	var stat syscall.Statfs_t
+	if err := statfs(path, &stat); err != nil {
+		return 0, false, err
+	}
+	if stat.Files == 0 {
+		return 0, false, nil
+	}
+
+	used := uint64(stat.Files) - uint64(stat.Ffree)
+	return float64(used) / float64(stat.Files) * 100.0, true, nil
+}
+
+// defaultExcludeFSTypes lists pseudo and virtual filesystems skipped by automatic mount discovery
+var defaultExcludeFSTypes = []string{
+	"proc", "sysfs", "tmpfs", "devtmpfs", "devpts", "overlay", "squashfs",
+	"cgroup", "cgroup2", "securityfs", "debugfs", "tracefs", "pstore", "bpf",
+	"mqueue", "hugetlbfs", "configfs", "fusectl", "autofs", "binfmt_misc",
+	"nsfs", "rpc_pipefs", "ramfs", "efivarfs", "selinuxfs",
+}
+
+// mountsPath is the mount table read by automatic disk discovery
+var mountsPath = "/proc/mounts"
+
+// discoverMounts returns the mount points of real filesystems listed in the mount table
+func discoverMounts(path string, excludeFSTypes []string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	excluded := make(map[string]bool, len(excludeFSTypes))
+	for _, fsType := range excludeFSTypes {
+		excluded[strings.TrimSpace(fsType)] = true
+	}
+
+	var mounts []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
 		}
+		mountPoint, fsType := unescapeMountField(fields[1]), fields[2]
+		if excluded[fsType] || seen[mountPoint] {
+			continue
+		}
+		seen[mountPoint] = true
+		mounts = append(mounts, mountPoint)
 	}
+	return mounts
+}
 
-	// Simplified disk check by trying to create a temp file
-	tmpFile := filepath.Join(path, ".health_check_tmp")
-	if file, err := os.Create(tmpFile); err == nil {
-		file.Close()
-		os.Remove(tmpFile)
-		// If we can create files, assume disk is not full (< 95%)
-		return 70.0 // Conservative estimate
+// unescapeMountField decodes the octal escapes (e.g. \040 for space) used in /proc/mounts
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, "\\") {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if val, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(val))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
 	}
+	return b.String()
+}
 
-	// If we can't create files, disk might be full
-	return 95.0
+// networkTests are the connectivity tests run by checkNetworkConnectivity
+var networkTests = []func(time.Duration) bool{
+	testDNSResolution,
+	testExternalConnectivity,
+	testLocalNetworking,
 }
 
-// checkNetworkConnectivity tests network connectivity
-func checkNetworkConnectivity() bool {
-	// Test multiple connectivity methods
-	tests := []func() bool{
-		testDNSResolution,
-		testExternalConnectivity,
-		testLocalNetworking,
+// checkNetworkConnectivity tests network connectivity, passing when at least
+// RequiredTestsPass of the tests succeed within the configured timeout
+func checkNetworkConnectivity(config HealthConfig) bool {
+	timeout := time.Duration(config.HealthChecks.Network.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	// Clamp the requirement to the tests that actually run
+	required := config.HealthChecks.Network.RequiredTestsPass
+	if required < 1 {
+		required = 1
+	}
+	if required > len(networkTests) {
+		required = len(networkTests)
 	}
 
 	successCount := 0
-	for _, test := range tests {
-		if test() {
+	for _, test := range networkTests {
+		if test(timeout) {
 			successCount++
 		}
 	}
 
-	// Require at least 2 out of 3 tests to pass
-	return successCount >= 2
+	return successCount >= required
 }
 
+// dnsResolver performs lookups for the DNS connectivity test
+var dnsResolver = net.DefaultResolver
+
 // testDNSResolution tests DNS resolution
-func testDNSResolution() bool {
-	_, err := net.LookupHost("google.com")
+func testDNSResolution(timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := dnsResolver.LookupHost(ctx, "google.com")
 	return err == nil
 }
 
 // testExternalConnectivity tests external network connectivity
-func testExternalConnectivity() bool {
-	conn, err := net.DialTimeout("tcp", "8.8.8.8:53", 5*time.Second)
+func testExternalConnectivity(timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", "8.8.8.8:53", timeout)
 	if err != nil {
 		return false
 	}
@@ -583,8 +1463,9 @@ func testExternalConnectivity() bool {
 	return true
 }
 
-// testLocalNetworking tests local networking stack
-func testLocalNetworking() bool {
+// testLocalNetworking tests local networking stack. It never leaves the
+// host, so the timeout is not needed.
+func testLocalNetworking(time.Duration) bool {
 	// Test if we can get local IP (networking stack is working)
 	if _, err := getLocalIP(); err != nil {
 		return false
@@ -600,60 +1481,119 @@ func testLocalNetworking() bool {
 }
 
 // reportStatus sends a status report to the s01 server
-func (dc *S01Client) reportStatus() error {
+func (dc *S01Client) reportStatus(ctx context.Context) (err error) {
+	span := dc.tracer.Start("report status", shared.SpanKindClient, nil)
+	dc.counters.attempted.Add(1)
+	defer func() {
+		dc.counters.recordResult(err, time.Now())
+		if err != nil {
+			span.SetError(err.Error())
+		}
+		span.End()
+	}()
+
 	// Get comprehensive health metrics
-	config := loadHealthConfig()
-	healthMetrics := performHealthChecks(config)
+	config := dc.healthConfig
+	healthMetrics := performHealthChecks(ctx, config)
 
 	// Determine status from health metrics using config thresholds
-	status := getHostStatus()
+	status := getHostStatus(healthMetrics, config)
+	dc.setLatestMetrics(healthMetrics, status)
 
+	takenAt := time.Now()
 	statusReq := StatusRequest{
 		ServiceName:   dc.config.ServiceName,
 		InstanceName:  dc.config.InstanceName,
 		Status:        status,
+		Detail:        reportDetailFull,
+		Timestamp:     &takenAt,
+		Sequence:      dc.sequence.Add(1),
+		Labels:        dc.config.Labels,
 		HealthMetrics: &healthMetrics,
+		KernelVersion: dc.systemInfo.KernelVersion,
+		OSRelease:     dc.systemInfo.OSRelease,
+		Arch:          dc.systemInfo.Arch,
+	}
+	span.SetAttribute("service_name", statusReq.ServiceName)
+	span.SetAttribute("instance_name", statusReq.InstanceName)
+	span.SetAttribute("status", status)
+
+	if dc.logTail != nil {
+		recentErrors, err := dc.logTail.collect()
+		if err != nil {
+			dc.logger.Warn("Failed to scan error log", "path", dc.config.ErrorLogPath, "error", err)
+		} else {
+			statusReq.RecentErrors = recentErrors
+		}
 	}
 
-	jsonData, err := json.Marshal(statusReq)
+	reqs := dc.expandServices(statusReq)
+	path, jsonData, err := dc.encodeReports(reqs)
 	if err != nil {
 		return fmt.Errorf("failed to marshal status request: %v", err)
 	}
 
-	url := fmt.Sprintf("%s/api/v1/report", dc.config.ServerURL)
+	// One ID per report, kept across retries, so server log lines match ours
+	requestID := newRequestID()
+	logger := dc.logger.With("request_id", requestID)
+
+	// Deliver reports buffered during an outage first so history stays in order
+	if dc.buffer != nil {
+		if err := dc.flushBuffer(ctx); err != nil {
+			logger.Warn("Failed to flush buffered reports", "error", err)
+		}
+	}
 
 	var lastErr error
 	for attempt := 0; attempt < dc.config.RetryAttempts; attempt++ {
 		if attempt > 0 {
-			dc.logger.Warn("Retrying status report", "attempt", attempt+1)
-			time.Sleep(time.Duration(dc.config.RetryDelay) * time.Second)
+			delay := backoffDelay(attempt,
+				time.Duration(dc.config.RetryDelay)*time.Second,
+				time.Duration(dc.config.RetryMaxDelay)*time.Second,
+			)
+			logger.Warn("Retrying status report", "attempt", attempt+1, "delay", delay.Round(time.Millisecond).String())
+			dc.counters.retries.Add(1)
+			if err := retrySleep(ctx, delay); err != nil {
+				return dc.bufferUndelivered(reqs, fmt.Errorf("status report cancelled: %w", err))
+			}
 		}
 
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		// Resolved per attempt so a retry can move to another SRV target
+		url, err := dc.serverURL(ctx, path)
+		if err != nil {
+			lastErr = err
+			logger.Error("Failed to locate server", "error", err, "attempt", attempt+1)
+			continue
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 		if err != nil {
 			lastErr = fmt.Errorf("failed to create request: %v", err)
 			continue
 		}
 
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(requestIDHeader, requestID)
+		span.Inject(req.Header)
 
 		resp, err := dc.httpClient.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return dc.bufferUndelivered(reqs, fmt.Errorf("status report cancelled: %w", ctx.Err()))
+			}
 			lastErr = fmt.Errorf("failed to send request: %v", err)
-			dc.logger.Error("Failed to report status", "error", err, "attempt", attempt+1)
+			logger.Error("Failed to report status", "error", err, "attempt", attempt+1)
+			dc.srv.failover()
 			continue
 		}
 
-		if resp.StatusCode == http.StatusOK {
-			var statusResp StatusResponse
-			if err := json.NewDecoder(resp.Body).Decode(&statusResp); err != nil {
-				dc.logger.Warn("Failed to decode response", "error", err)
-			}
+		if dc.reportAccepted(logger, resp, reqs) {
+			io.Copy(io.Discard, resp.Body)
 			resp.Body.Close()
 
-			dc.logger.Info("Status reported successfully",
+			logger.Info("Status reported successfully",
 				"service_name", dc.config.ServiceName,
 				"instance_name", dc.config.InstanceName,
+				"additional_services", len(dc.additionalServices),
 				"status", status,
 				"cpu_usage", healthMetrics.CPUUsage,
 				"memory_usage", healthMetrics.MemoryUsage,
@@ -663,21 +1603,86 @@ func (dc *S01Client) reportStatus() error {
 				"attempt", attempt+1,
 			)
 
+			dc.lastStatus = status
 			return nil
 		}
 
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		lastErr = fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+		if resp.StatusCode >= 500 {
+			dc.srv.failover()
+		}
 
-		dc.logger.Error("Server error",
+		logger.Error("Server error",
 			"status_code", resp.StatusCode,
 			"response", string(body),
 			"attempt", attempt+1,
 		)
 	}
 
-	return fmt.Errorf("failed to report status after %d attempts: %v", dc.config.RetryAttempts, lastErr)
+	return dc.bufferUndelivered(reqs, fmt.Errorf("failed to report status after %d attempts: %v", dc.config.RetryAttempts, lastErr))
+}
+
+// sendHeartbeat sends a lightweight status-only report so the server sees the
+// host as alive between full reports. Heartbeats are not retried.
+func (dc *S01Client) sendHeartbeat(ctx context.Context) error {
+	if dc.lastStatus == "" {
+		return nil
+	}
+
+	// Buffered reports carry older sequences; deliver them before a heartbeat
+	// moves the server's sequence past them
+	if dc.buffer != nil {
+		if err := dc.flushBuffer(ctx); err != nil {
+			return fmt.Errorf("failed to flush buffered reports: %v", err)
+		}
+	}
+
+	takenAt := time.Now()
+	statusReq := StatusRequest{
+		ServiceName:  dc.config.ServiceName,
+		InstanceName: dc.config.InstanceName,
+		Status:       dc.lastStatus,
+		Detail:       reportDetailHeartbeat,
+		Timestamp:    &takenAt,
+		Sequence:     dc.sequence.Add(1),
+	}
+
+	reqs := dc.expandServices(statusReq)
+	path, jsonData, err := dc.encodeReports(reqs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal heartbeat: %v", err)
+	}
+	url, err := dc.serverURL(ctx, path)
+	if err != nil {
+		return err
+	}
+	requestID := newRequestID()
+	logger := dc.logger.With("request_id", requestID)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create heartbeat request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(requestIDHeader, requestID)
+
+	resp, err := dc.httpClient.Do(req)
+	if err != nil {
+		dc.srv.failover()
+		return fmt.Errorf("failed to send heartbeat: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if !dc.reportAccepted(logger, resp, reqs) {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	logger.Debug("Heartbeat sent", "status", dc.lastStatus)
+	return nil
 }
 
 // Start begins the periodic status reporting
@@ -689,35 +1694,127 @@ func (dc *S01Client) Start() error {
 		"report_interval", dc.config.ReportInterval,
 	)
 
+	// Optional local Prometheus exporter; pointless for a single report
+	if dc.config.MetricsPort != "" && !dc.config.Once {
+		metricsServer := dc.startMetricsServer()
+		defer metricsServer.Close()
+	}
+
+	// Handle shutdown signals. Cancelling ctx interrupts a report that is
+	// waiting to retry or still in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	go func() {
+		select {
+		case <-sigChan:
+			dc.logger.Info("Received shutdown signal")
+		case <-dc.stopChan:
+			dc.logger.Info("Stop signal received")
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	// Export trace spans in the background when OTLP_ENDPOINT is set
+	go dc.tracer.Run(ctx)
+	defer dc.tracer.Flush()
+
 	// Test initial connection
-	if err := dc.reportStatus(); err != nil {
+	if err := dc.reportStatus(ctx); err != nil {
+		if ctx.Err() != nil && !dc.config.Once {
+			return nil
+		}
 		dc.logger.Error("Initial status report failed", "error", err)
 		return fmt.Errorf("initial status report failed: %v", err)
 	}
 
+	// A cron-driven client is done after one report
+	if dc.config.Once {
+		dc.logger.Info("Single status report sent, exiting")
+		return nil
+	}
+
 	// Start periodic reporting
-	ticker := time.NewTicker(time.Duration(dc.config.ReportInterval) * time.Second)
+	interval := dc.reportInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	// Handle shutdown signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// Optional fast status-only heartbeats between full reports
+	var heartbeatC <-chan time.Time
+	if dc.config.HeartbeatInterval > 0 {
+		heartbeatTicker := time.NewTicker(time.Duration(dc.config.HeartbeatInterval) * time.Second)
+		defer heartbeatTicker.Stop()
+		heartbeatC = heartbeatTicker.C
+	}
+
+	// Optional periodic summary of the report counters
+	var statsLogC <-chan time.Time
+	if dc.config.StatsLogInterval > 0 {
+		statsLogTicker := time.NewTicker(time.Duration(dc.config.StatsLogInterval) * time.Second)
+		defer statsLogTicker.Stop()
+		statsLogC = statsLogTicker.C
+	}
+
+	// Reload health check configuration and certificates on demand
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	defer signal.Stop(reloadChan)
 
 	dc.logger.Info("S01 client started, reporting status periodically")
 
 	for {
 		select {
 		case <-ticker.C:
-			if err := dc.reportStatus(); err != nil {
+			if err := dc.reportStatus(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
 				dc.logger.Error("Failed to report status", "error", err)
+				if dc.breaker.recordFailure() {
+					_, failures := dc.breaker.state()
+					dc.logger.Error("Circuit breaker open, backing off report interval",
+						"consecutive_failures", failures,
+						"probe_interval", dc.config.BreakerInterval,
+					)
+					if dc.config.BreakerInterval > 0 {
+						ticker.Reset(time.Duration(dc.config.BreakerInterval) * time.Second)
+					}
+				}
+			} else if dc.breaker.recordSuccess() {
+				interval = dc.reportInterval()
+				dc.logger.Info("Circuit breaker closed, resuming normal report interval",
+					"report_interval", interval.Seconds(),
+				)
+				ticker.Reset(interval)
+			} else if next := dc.reportInterval(); next != interval {
+				// The server asked for a different interval
+				dc.logger.Info("Report interval changed by server",
+					"previous_interval", interval.Seconds(),
+					"report_interval", next.Seconds(),
+				)
+				interval = next
+				ticker.Reset(interval)
 			}
 
-		case <-sigChan:
-			dc.logger.Info("Received shutdown signal")
-			return nil
+		case <-heartbeatC:
+			if err := dc.sendHeartbeat(ctx); err != nil {
+				dc.logger.Warn("Failed to send heartbeat", "error", err)
+			}
 
-		case <-dc.stopChan:
-			dc.logger.Info("Stop signal received")
+		case <-statsLogC:
+			dc.counters.log(dc.logger)
+
+		case <-reloadChan:
+			dc.healthConfig = loadHealthConfig(dc.logger)
+			dc.logger.Info("Health check configuration reloaded")
+			dc.reloadCertificates()
+
+		case <-ctx.Done():
 			return nil
 		}
 	}
@@ -746,67 +1843,192 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
-// loadConfig loads configuration from environment variables
-func loadConfig() (*Config, error) {
-	config := &Config{
-		ServerURL:      getEnv("SERVER_URL", "https://localhost:8443"),
-		ServiceName:    getEnv("SERVICE_NAME", "default-service"),
-		InstanceName:   getEnv("INSTANCE_NAME", "default-instance"),
-		ReportInterval: getEnvInt("REPORT_INTERVAL", 30),
-		CertFile:       getEnv("CERT_FILE", "/etc/ssl/certs/client.crt"),
-		KeyFile:        getEnv("KEY_FILE", "/etc/ssl/certs/client.key"),
-		CACertFile:     getEnv("CA_CERT_FILE", "/etc/ssl/certs/root_ca.crt"),
-		LogLevel:       getEnv("LOG_LEVEL", "info"),
-		Timeout:        getEnvInt("TIMEOUT", 30),
-		RetryAttempts:  getEnvInt("RETRY_ATTEMPTS", 3),
-		RetryDelay:     getEnvInt("RETRY_DELAY", 5),
+// getEnvBool gets an environment variable as boolean with a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
 	}
+	return defaultValue
+}
 
-	// Auto-generate instance name if not provided
-	if config.InstanceName == "default-instance" {
-		hostname, err := os.Hostname()
-		if err == nil {
-			config.InstanceName = hostname
-		}
+// newFlagSet binds a flag to every Config field, named after its environment
+// variable (SERVER_URL becomes --server-url). Each flag defaults to the
+// field's current value, so only flags that are passed override it.
+func newFlagSet(config *Config) *flag.FlagSet {
+	flags := flag.NewFlagSet("s01-client", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	flags.StringVar(&config.ServerURL, "server-url", config.ServerURL, "S01 server URL, unix:///path for a Unix socket, or dns+srv://name for an SRV record")
+	flags.StringVar(&config.ServiceName, "service-name", config.ServiceName, "Name of the service")
+	flags.StringVar(&config.InstanceName, "instance-name", config.InstanceName, "Instance identifier")
+	flags.IntVar(&config.ReportInterval, "report-interval", config.ReportInterval, "Status report interval in seconds")
+	flags.StringVar(&config.CertFile, "cert-file", config.CertFile, "Client certificate file")
+	flags.StringVar(&config.KeyFile, "key-file", config.KeyFile, "Client private key file")
+	flags.StringVar(&config.CACertFile, "ca-cert-file", config.CACertFile, "Root CA certificate files or directories, comma-separated")
+	flags.StringVar(&config.LogLevel, "log-level", config.LogLevel, "Log level (debug, info, warn, error)")
+	flags.StringVar(&config.LogFormat, "log-format", config.LogFormat, "Log format (json, text)")
+	flags.StringVar(&config.LogOutput, "log-output", config.LogOutput, "Log destination (stdout, stderr or a file path)")
+	flags.IntVar(&config.Timeout, "timeout", config.Timeout, "HTTP request timeout in seconds")
+	flags.IntVar(&config.RetryAttempts, "retry-attempts", config.RetryAttempts, "Attempts per status report")
+	flags.IntVar(&config.RetryDelay, "retry-delay", config.RetryDelay, "Base backoff in seconds between report attempts")
+	flags.IntVar(&config.RetryMaxDelay, "retry-max-delay", config.RetryMaxDelay, "Maximum backoff in seconds between report attempts")
+	flags.IntVar(&config.HeartbeatInterval, "heartbeat-interval", config.HeartbeatInterval, "Seconds between status-only heartbeats (0 disables)")
+	flags.IntVar(&config.StatsLogInterval, "stats-log-interval", config.StatsLogInterval, "Seconds between report counter log lines (0 disables)")
+	flags.IntVar(&config.BreakerThreshold, "breaker-threshold", config.BreakerThreshold, "Failed report cycles before backing off (0 disables)")
+	flags.IntVar(&config.BreakerInterval, "breaker-interval", config.BreakerInterval, "Probe interval in seconds while backed off")
+	flags.StringVar(&config.ErrorLogPath, "error-log-path", config.ErrorLogPath, "Local log file scanned for recent errors")
+	flags.StringVar(&config.ErrorLogMatch, "error-log-pattern", config.ErrorLogMatch, "Regular expression matching error lines")
+	flags.StringVar(&config.MetricsPort, "metrics-port", config.MetricsPort, "Port for the local Prometheus exporter (empty disables)")
+	flags.IntVar(&config.CertExpiryWarnDays, "cert-expiry-warn-days", config.CertExpiryWarnDays, "Warn when the client certificate expires within this many days")
+	flags.BoolVar(&config.RejectExpiredCert, "reject-expired-cert", config.RejectExpiredCert, "Refuse to start with an expired client certificate")
+	flags.StringVar(&config.AdditionalServices, "additional-services", config.AdditionalServices, "Comma-separated extra service names reported in the same batch")
+	flags.Var(&config.Labels, "labels", "Comma-separated key=value labels attached to every report")
+	flags.StringVar(&config.BufferPath, "buffer-path", config.BufferPath, "File undelivered reports are kept in until the server is reachable (empty disables)")
+	flags.IntVar(&config.BufferMaxReports, "buffer-max-reports", config.BufferMaxReports, "Most reports kept in the buffer file")
+	flags.StringVar(&config.TLSMinVersion, "tls-min-version", config.TLSMinVersion, "Lowest TLS version used: 1.2 or 1.3")
+	flags.StringVar(&config.CipherSuites, "cipher-suites", config.CipherSuites, "Comma-separated TLS 1.2 cipher suite names (empty uses the built-in list)")
+	flags.StringVar(&config.OTLPEndpoint, "otlp-endpoint", config.OTLPEndpoint, "OpenTelemetry collector URL for trace export (empty disables tracing)")
+	flags.BoolVar(&config.Once, "once", config.Once, "Send a single status report and exit, e.g. from cron")
+	flags.BoolVar(&config.SelfTest, "selftest", config.SelfTest, "Run the health checks once, print the result and exit 0/1/2 for healthy/degraded/unhealthy")
+
+	return flags
+}
+
+// loadConfig loads configuration from defaults, then the first config file
+// found, then environment variables, then command-line args, each layer
+// overriding the previous one
+func loadConfig(args []string) (*Config, error) {
+	config := &Config{
+		ServerURL:          "https://localhost:8443",
+		ServiceName:        "default-service",
+		InstanceName:       "default-instance",
+		ReportInterval:     30,
+		CertFile:           "/etc/ssl/certs/client.crt",
+		KeyFile:            "/etc/ssl/certs/client.key",
+		CACertFile:         "/etc/ssl/certs/root_ca.crt",
+		LogLevel:           "info",
+		LogFormat:          "json",
+		LogOutput:          "stdout",
+		Timeout:            30,
+		RetryAttempts:      3,
+		RetryDelay:         5,
+		RetryMaxDelay:      60,
+		HeartbeatInterval:  0,
+		StatsLogInterval:   300,
+		BreakerThreshold:   5,
+		BreakerInterval:    300,
+		ErrorLogMatch:      `\bERROR\b`,
+		CertExpiryWarnDays: 14,
+		BufferMaxReports:   1000,
+		TLSMinVersion:      "1.2",
 	}
 
 	// Try to read config file if it exists
-	configPaths := []string{
-		"/etc/s01/client-config.json",
-		"./config/client-config.json",
-		"./client-config.json",
+	// JSON is preferred when both formats exist in the same directory
+	var configPaths []string
+	for _, dir := range []string{"/etc/s01", "./config", "."} {
+		for _, ext := range []string{".json", ".yaml", ".yml"} {
+			configPaths = append(configPaths, filepath.Join(dir, "client-config"+ext))
+		}
 	}
 
 	for _, configPath := range configPaths {
 		if data, err := os.ReadFile(configPath); err == nil {
-			var fileConfig Config
-			if err := json.Unmarshal(data, &fileConfig); err == nil {
-				// Override defaults with file config, but let env vars take precedence
-				if config.ServerURL == "https://localhost:8443" && fileConfig.ServerURL != "" {
-					config.ServerURL = fileConfig.ServerURL
-				}
-				if config.ServiceName == "default-service" && fileConfig.ServiceName != "" {
-					config.ServiceName = fileConfig.ServiceName
-				}
-				if config.InstanceName == "default-instance" && fileConfig.InstanceName != "" {
-					config.InstanceName = fileConfig.InstanceName
-				}
-				// ... continue for other fields as needed
+			// Fields missing from the file keep their defaults
+			if err := shared.UnmarshalConfig(configPath, data, config); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %v", configPath, err)
 			}
 			break
 		}
 	}
 
+	// Override with environment variables (higher priority than config file)
+	config.ServerURL = getEnv("SERVER_URL", config.ServerURL)
+	config.ServiceName = getEnv("SERVICE_NAME", config.ServiceName)
+	config.InstanceName = getEnv("INSTANCE_NAME", config.InstanceName)
+	config.ReportInterval = getEnvInt("REPORT_INTERVAL", config.ReportInterval)
+	config.CertFile = getEnv("CERT_FILE", config.CertFile)
+	config.KeyFile = getEnv("KEY_FILE", config.KeyFile)
+	config.CACertFile = getEnv("CA_CERT_FILE", config.CACertFile)
+	config.LogLevel = getEnv("LOG_LEVEL", config.LogLevel)
+	config.LogFormat = getEnv("LOG_FORMAT", config.LogFormat)
+	config.LogOutput = getEnv("LOG_OUTPUT", config.LogOutput)
+	config.Timeout = getEnvInt("TIMEOUT", config.Timeout)
+	config.RetryAttempts = getEnvInt("RETRY_ATTEMPTS", config.RetryAttempts)
+	config.RetryDelay = getEnvInt("RETRY_DELAY", config.RetryDelay)
+	config.RetryMaxDelay = getEnvInt("RETRY_MAX_DELAY", config.RetryMaxDelay)
+	config.HeartbeatInterval = getEnvInt("HEARTBEAT_INTERVAL", config.HeartbeatInterval)
+	config.StatsLogInterval = getEnvInt("STATS_LOG_INTERVAL", config.StatsLogInterval)
+	config.BreakerThreshold = getEnvInt("BREAKER_THRESHOLD", config.BreakerThreshold)
+	config.BreakerInterval = getEnvInt("BREAKER_INTERVAL", config.BreakerInterval)
+	config.ErrorLogPath = getEnv("ERROR_LOG_PATH", config.ErrorLogPath)
+	config.ErrorLogMatch = getEnv("ERROR_LOG_PATTERN", config.ErrorLogMatch)
+	config.MetricsPort = getEnv("METRICS_PORT", config.MetricsPort)
+	config.CertExpiryWarnDays = getEnvInt("CERT_EXPIRY_WARN_DAYS", config.CertExpiryWarnDays)
+	config.RejectExpiredCert = getEnvBool("REJECT_EXPIRED_CERT", config.RejectExpiredCert)
+	config.AdditionalServices = getEnv("ADDITIONAL_SERVICES", config.AdditionalServices)
+	config.BufferPath = getEnv("BUFFER_PATH", config.BufferPath)
+	config.BufferMaxReports = getEnvInt("BUFFER_MAX_REPORTS", config.BufferMaxReports)
+	config.TLSMinVersion = getEnv("TLS_MIN_VERSION", config.TLSMinVersion)
+	config.CipherSuites = getEnv("CIPHER_SUITES", config.CipherSuites)
+	config.OTLPEndpoint = getEnv("OTLP_ENDPOINT", config.OTLPEndpoint)
+	config.SelfTest = getEnvBool("SELFTEST", config.SelfTest)
+	config.Once = getEnvBool("ONCE", config.Once)
+	if value := os.Getenv("LABELS"); value != "" {
+		if err := config.Labels.Set(value); err != nil {
+			return nil, fmt.Errorf("invalid LABELS: %v", err)
+		}
+	}
+
+	// Override with command-line flags (highest priority)
+	flags := newFlagSet(config)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument: %s", flags.Arg(0))
+	}
+
+	// Auto-generate instance name if not provided
+	if config.InstanceName == "default-instance" {
+		hostname, err := os.Hostname()
+		if err == nil {
+			config.InstanceName = hostname
+		}
+	}
+
+	// A self-test never contacts the server, so it needs no identity or certificates
+	if config.SelfTest {
+		return config, nil
+	}
+
 	// Validate required fields
 	if config.ServiceName == "" || config.ServiceName == "default-service" {
-		return nil, fmt.Errorf("service_name is required (set SERVICE_NAME environment variable)")
+		return nil, fmt.Errorf("service_name is required (set SERVICE_NAME or --service-name)")
 	}
 	if config.InstanceName == "" {
 		return nil, fmt.Errorf("instance_name is required")
 	}
+	if _, _, err := shared.ParseTLSOptions(config.TLSMinVersion, config.CipherSuites); err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(config.ServerURL, srvScheme) && srvName(config.ServerURL) == "" {
+		return nil, fmt.Errorf("server_url %q names no SRV record (expected dns+srv://_service._tcp.example.com)", config.ServerURL)
+	}
+
+	// A Unix socket connection is plain HTTP, so no certificates are needed
+	if strings.HasPrefix(config.ServerURL, unixSocketScheme) {
+		if unixSocketPath(config.ServerURL) == "" {
+			return nil, fmt.Errorf("server_url %q names no socket path (expected unix:///path/to/socket)", config.ServerURL)
+		}
+		return config, nil
+	}
 
 	// Validate required files exist
-	for _, file := range []string{config.CertFile, config.KeyFile, config.CACertFile} {
+	for _, file := range append([]string{config.CertFile, config.KeyFile}, caCertPaths(config.CACertFile)...) {
 		if _, err := os.Stat(file); os.IsNotExist(err) {
 			return nil, fmt.Errorf("required file not found: %s", file)
 		}
@@ -815,8 +2037,10 @@ func loadConfig() (*Config, error) {
 	return config, nil
 }
 
-// setupLogger configures the structured logger
-func setupLogger(level string) *slog.Logger {
+// setupLogger configures the structured logger. format is json or text
+// (logfmt); output is stdout, stderr or a file path appended to. An unknown
+// format falls back to json and an unopenable file to stdout, with a warning.
+func setupLogger(level, format, output string) *slog.Logger {
 	var logLevel slog.Level
 	switch strings.ToLower(level) {
 	case "debug":
@@ -835,50 +2059,131 @@ func setupLogger(level string) *slog.Logger {
 		Level: logLevel,
 	}
 
-	// Use JSON handler for structured logging
-	handler := slog.NewJSONHandler(os.Stdout, opts)
-	return slog.New(handler)
+	var writer io.Writer = os.Stdout
+	var outputErr error
+	switch output {
+	case "stdout", "":
+	case "stderr":
+		writer = os.Stderr
+	default:
+		file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			outputErr = err
+		} else {
+			writer = file
+		}
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text", "logfmt":
+		handler = slog.NewTextHandler(writer, opts)
+	default:
+		handler = slog.NewJSONHandler(writer, opts)
+	}
+	logger := slog.New(handler)
+
+	// Report fallbacks through the logger we ended up with
+	if outputErr != nil {
+		logger.Warn("Failed to open log output, logging to stdout", "log_output", output, "error", outputErr)
+	}
+	switch strings.ToLower(format) {
+	case "json", "text", "logfmt", "":
+	default:
+		logger.Warn("Unknown log format, using json", "log_format", format)
+	}
+	return logger
+}
+
+// printHelp prints usage, configuration variables, and features
+func printHelp() {
+	fmt.Println("S01 Client - Host health monitoring and service discovery client")
+	fmt.Println("")
+	fmt.Println("Environment Variables:")
+	fmt.Println("  SERVICE_NAME       - Name of the service (required)")
+	fmt.Println("  INSTANCE_NAME      - Instance identifier")
+	fmt.Println("  SERVER_URL         - S01 server URL, unix:///path for the server's UNIX_SOCKET, or dns+srv://name")
+	fmt.Println("  CERT_FILE          - Client certificate file")
+	fmt.Println("  KEY_FILE           - Client private key file")
+	fmt.Println("  CA_CERT_FILE       - Root CA certificate files or directories, comma-separated")
+	fmt.Println("  REPORT_INTERVAL    - Status report interval in seconds")
+	fmt.Println("  LOG_LEVEL          - Log level (debug, info, warn, error)")
+	fmt.Println("  LOG_FORMAT         - Log format (json, text)")
+	fmt.Println("  LOG_OUTPUT         - Log destination (stdout, stderr or a file path)")
+	fmt.Println("  HEARTBEAT_INTERVAL - Seconds between status-only heartbeats (0 disables)")
+	fmt.Println("  STATS_LOG_INTERVAL - Seconds between report counter log lines (0 disables)")
+	fmt.Println("  BREAKER_THRESHOLD  - Failed report cycles before backing off (0 disables)")
+	fmt.Println("  BREAKER_INTERVAL   - Probe interval in seconds while backed off")
+	fmt.Println("  ERROR_LOG_PATH     - Local log file scanned for recent errors")
+	fmt.Println("  ERROR_LOG_PATTERN  - Regular expression matching error lines")
+	fmt.Println("  RETRY_DELAY        - Base backoff in seconds between report attempts")
+	fmt.Println("  RETRY_MAX_DELAY    - Maximum backoff in seconds between report attempts")
+	fmt.Println("  METRICS_PORT       - Port for the local Prometheus exporter (empty disables)")
+	fmt.Println("  CERT_EXPIRY_WARN_DAYS - Warn when the client certificate expires within this many days")
+	fmt.Println("  REJECT_EXPIRED_CERT   - Refuse to start with an expired client certificate (true/false)")
+	fmt.Println("  ADDITIONAL_SERVICES   - Comma-separated extra service names reported in the same batch")
+	fmt.Println("  LABELS                - Comma-separated key=value labels attached to every report")
+	fmt.Println("  BUFFER_PATH           - File undelivered reports are kept in until the server is reachable")
+	fmt.Println("  BUFFER_MAX_REPORTS    - Most reports kept in the buffer file (oldest dropped first)")
+	fmt.Println("  TLS_MIN_VERSION       - Lowest TLS version used: 1.2 or 1.3")
+	fmt.Println("  CIPHER_SUITES         - Comma-separated TLS 1.2 cipher suite names (empty uses the built-in list)")
+	fmt.Println("  OTLP_ENDPOINT         - OpenTelemetry collector URL for trace export (empty disables tracing)")
+	fmt.Println("  ONCE                  - Send a single status report, with retries, and exit non-zero if it fails (true/false)")
+	fmt.Println("  SELFTEST              - Run the health checks once, print them as JSON and exit (true/false);")
+	fmt.Println("                          exit code 0 healthy, 1 degraded, 2 unhealthy")
+	fmt.Println("")
+	fmt.Println("Each variable above can also be passed as a flag, which takes precedence,")
+	fmt.Println("e.g. --server-url for SERVER_URL or --report-interval=10 for REPORT_INTERVAL.")
+	fmt.Println("")
+	fmt.Println("Health Check Environment Variables:")
+	fmt.Println("  HEALTH_CPU_THRESHOLD         - CPU usage healthy threshold (%)")
+	fmt.Println("  HEALTH_MEMORY_THRESHOLD      - Memory usage healthy threshold (%)")
+	fmt.Println("  HEALTH_DISK_THRESHOLD        - Disk usage healthy threshold (%)")
+	fmt.Println("  HEALTH_NETWORK_ENABLED       - Enable network connectivity checks")
+	fmt.Println("  HEALTH_GPU_ENABLED           - Enable the GPU utilization check")
+	fmt.Println("  HEALTH_TEMP_ENABLED          - Enable the CPU temperature check (hwmon)")
+	fmt.Println("  HEALTH_DISABLE               - Comma-separated checks to turn off, e.g. disk,network; overrides the")
+	fmt.Println("                                 *_ENABLED variables (cpu, memory, disk, network, load_average, process,")
+	fmt.Println("                                 gpu, temperature, interface)")
+	fmt.Println("  HEALTH_INTERFACE_ENABLED     - Enable the network interface check (/proc/net/dev)")
+	fmt.Println("  HEALTH_INTERFACE_NAMES       - Comma-separated interfaces to check, e.g. eth0,eth1")
+	fmt.Println("  HEALTH_SCORE_HEALTHY_MIN     - Minimum score for healthy status")
+	fmt.Println("  HEALTH_SCORE_DEGRADED_MIN    - Minimum score for degraded status")
+	fmt.Println("  HEALTH_SCORE_NORMALIZE       - Scale the overall score to 0-100 when check weights do not sum to 100")
+	fmt.Println("  HEALTH_CHECK_TIMEOUT         - Seconds a health check pass may take; slower checks report unknown")
+	fmt.Println("  HEALTH_CGROUP_MODE           - CPU and memory source: auto (cgroup limits when set), cgroup or host")
+	fmt.Println("")
+	fmt.Println("Features:")
+	fmt.Println("  • Real-time system health monitoring (CPU, Memory, Disk, Network)")
+	fmt.Println("  • mTLS authentication and encryption")
+	fmt.Println("  • Configurable health check thresholds")
+	fmt.Println("  • Zero external dependencies")
+	fmt.Println("  • Comprehensive health scoring system")
 }
 
 func main() {
 	// Handle help flag for Docker health checks
 	if len(os.Args) > 1 && (os.Args[1] == "--help" || os.Args[1] == "-h") {
-		fmt.Println("S01 Client - Host health monitoring and service discovery client")
-		fmt.Println("")
-		fmt.Println("Environment Variables:")
-		fmt.Println("  SERVICE_NAME       - Name of the service (required)")
-		fmt.Println("  INSTANCE_NAME      - Instance identifier")
-		fmt.Println("  SERVER_URL         - S01 server URL")
-		fmt.Println("  CERT_FILE          - Client certificate file")
-		fmt.Println("  KEY_FILE           - Client private key file")
-		fmt.Println("  CA_CERT_FILE       - Root CA certificate file")
-		fmt.Println("  REPORT_INTERVAL    - Status report interval in seconds")
-		fmt.Println("  LOG_LEVEL          - Log level (debug, info, warn, error)")
-		fmt.Println("")
-		fmt.Println("Health Check Environment Variables:")
-		fmt.Println("  HEALTH_CPU_THRESHOLD         - CPU usage healthy threshold (%)")
-		fmt.Println("  HEALTH_MEMORY_THRESHOLD      - Memory usage healthy threshold (%)")
-		fmt.Println("  HEALTH_DISK_THRESHOLD        - Disk usage healthy threshold (%)")
-		fmt.Println("  HEALTH_NETWORK_ENABLED       - Enable network connectivity checks")
-		fmt.Println("  HEALTH_SCORE_HEALTHY_MIN     - Minimum score for healthy status")
-		fmt.Println("  HEALTH_SCORE_DEGRADED_MIN    - Minimum score for degraded status")
-		fmt.Println("")
-		fmt.Println("Features:")
-		fmt.Println("  • Real-time system health monitoring (CPU, Memory, Disk, Network)")
-		fmt.Println("  • mTLS authentication and encryption")
-		fmt.Println("  • Configurable health check thresholds")
-		fmt.Println("  • Zero external dependencies")
-		fmt.Println("  • Comprehensive health scoring system")
+		printHelp()
 		os.Exit(0)
 	}
 
-	config, err := loadConfig()
+	config, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printHelp()
+		os.Exit(0)
+	}
 	if err != nil {
 		fmt.Printf("Failed to load config: %v\n", err)
 		os.Exit(1)
 	}
 
-	logger := setupLogger(config.LogLevel)
+	if config.SelfTest {
+		// Logs go to stderr so stdout holds only the JSON result
+		os.Exit(runSelfTest(os.Stdout, setupLogger(config.LogLevel, config.LogFormat, "stderr")))
+	}
+
+	logger := setupLogger(config.LogLevel, config.LogFormat, config.LogOutput)
 
 	client, err := NewS01Client(config, logger)
 	if err != nil {
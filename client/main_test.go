@@ -0,0 +1,1141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// discardLogger drops everything logged through it
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// chdirTemp moves the test into an empty directory, so config files next to
+// the tests, such as the sample health-config.json, are not read
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// defaultHealthConfig returns the built-in health config
+func defaultHealthConfig(t *testing.T) HealthConfig {
+	t.Helper()
+	chdirTemp(t)
+	return loadHealthConfig(discardLogger)
+}
+
+// loadTestConfig loads the client config with args, which may override the
+// service name and the Unix socket server URL that spares certificate checks
+func loadTestConfig(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	chdirTemp(t)
+	return loadConfig(append([]string{"--service-name", "test-service", "--server-url", "unix:///run/s01.sock"}, args...))
+}
+
+// writeFile writes content to name in a fresh temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// socketServer serves handler on a Unix socket and returns the socket's path
+func socketServer(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "s01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "s01.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return socket
+}
+
+// socketClient serves handler on a Unix socket and returns a client loaded
+// with args that reports to it. Every health check is disabled so a report
+// does not wait on the host.
+func socketClient(t *testing.T, handler http.HandlerFunc, args ...string) *S01Client {
+	t.Helper()
+	socket := socketServer(t, handler)
+	config, err := loadTestConfig(t, append([]string{"--server-url", "unix://" + socket}, args...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err := NewS01Client(config, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc.healthConfig.disableChecks([]string{"cpu", "memory", "disk", "network", "load_average", "process", "gpu", "temperature", "interface"}, discardLogger)
+	return dc
+}
+
+// runMain runs the client's main in a child process with env added to the
+// environment, from an empty directory, and returns its stdout, stderr and
+// exit code
+func runMain(t *testing.T, env ...string) (stdout []byte, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMainProcess$")
+	cmd.Dir = t.TempDir()
+	cmd.Env = append(append(os.Environ(), "S01_MAIN_PROCESS=1"), env...)
+	var errBuf strings.Builder
+	cmd.Stderr = &errBuf
+	stdout, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return stdout, errBuf.String(), code
+}
+
+// TestMainProcess is the child process of runMain
+func TestMainProcess(t *testing.T) {
+	if os.Getenv("S01_MAIN_PROCESS") != "1" {
+		return
+	}
+	os.Args = []string{"s01-client"}
+	main()
+	os.Exit(0)
+}
+
+func TestDiscoverMounts(t *testing.T) {
+	mounts := writeFile(t, "mounts", `/dev/sda1 / ext4 rw,relatime 0 0
+proc /proc proc rw,nosuid 0 0
+tmpfs /run tmpfs rw,nosuid 0 0
+/dev/sdb1 /mnt/my\040data xfs rw 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+overlay /var/lib/docker/overlay2/x/merged overlay rw 0 0
+truncated-line
+/dev/sdc1 /srv btrfs rw 0 0
+`)
+
+	tests := []struct {
+		name    string
+		path    string
+		exclude []string
+		want    []string
+	}{
+		{"default exclusions", mounts, defaultExcludeFSTypes, []string{"/", "/mnt/my data", "/srv"}},
+		{"env list with spaces", mounts, []string{"proc", " tmpfs", "overlay ", "btrfs"}, []string{"/", "/mnt/my data"}},
+		{"nothing excluded", mounts, nil, []string{"/", "/proc", "/run", "/mnt/my data", "/var/lib/docker/overlay2/x/merged", "/srv"}},
+		{"missing mount table", filepath.Join(t.TempDir(), "absent"), nil, nil},
+	}
+	for _, tt := range tests {
+		if got := discoverMounts(tt.path, tt.exclude); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: discoverMounts = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	for field, want := range map[string]string{
+		`/plain`:            "/plain",
+		`/with\040space`:    "/with space",
+		`/tab\011and\134bs`: "/tab\tand\\bs",
+		`/trailing\040`:     "/trailing ",
+		`/not\09octal`:      `/not\09octal`,
+		`/short\04`:         `/short\04`,
+		`\040\040`:          "  ",
+	} {
+		if got := unescapeMountField(field); got != want {
+			t.Errorf("unescapeMountField(%q) = %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestDiskSectionAutoChecksEveryMount(t *testing.T) {
+	config := defaultHealthConfig(t)
+	config.HealthChecks.Disk.Auto = true
+	config.HealthChecks.Disk.Paths = []string{"/configured"}
+
+	defer func(path string) { mountsPath = path }(mountsPath)
+	mountsPath = writeFile(t, "mounts", "/dev/sda1 / ext4 rw 0 0\nproc /proc proc rw 0 0\n/dev/sdb1 /data xfs rw 0 0\n")
+
+	// Percent used per mount; no inode table keeps the inode checks out
+	used := map[string]uint64{"/": 40, "/data": 97}
+	defer func(fn func(string, *syscall.Statfs_t) error) { statfs = fn }(statfs)
+	statfs = func(path string, stat *syscall.Statfs_t) error {
+		if _, ok := used[path]; !ok {
+			t.Errorf("statfs called for %s, which auto discovery should not check", path)
+		}
+		*stat = syscall.Statfs_t{Blocks: 100, Bfree: 100 - used[path], Bavail: 100 - used[path]}
+		return nil
+	}
+
+	result := diskSection(config, config.scoreFactors(), statfs)
+	got := make(map[string]string)
+	for _, check := range result.checks {
+		got[check.Name] = check.Status
+	}
+	want := map[string]string{"Disk Usage (/)": "healthy", "Disk Usage (/data)": "unhealthy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checks = %v, want %v", got, want)
+	}
+
+	var metrics HealthMetrics
+	result.summary(&metrics)
+	if metrics.DiskUsage != 97 {
+		t.Errorf("DiskUsage = %v, want the fullest mount's 97", metrics.DiskUsage)
+	}
+}
+
+func TestRunHealthSectionsScoreBreakdown(t *testing.T) {
+	section := func(name string, weight, points int, scored bool) healthSection {
+		return healthSection{name: name, weight: weight, scored: scored, run: func() sectionResult {
+			return sectionResult{checks: []HealthCheck{{Name: name, Status: "healthy"}}, points: points}
+		}}
+	}
+	hung := healthSection{name: "NFS", weight: 15, scored: true, run: func() sectionResult {
+		time.Sleep(time.Second)
+		return sectionResult{points: 15}
+	}}
+
+	tests := []struct {
+		name      string
+		sections  []healthSection
+		want      []ScoreContribution
+		wantScore int
+	}{
+		{"no sections", nil, nil, 0},
+		{
+			"partial points",
+			[]healthSection{section("CPU Usage", 25, 25, true), section("Memory Usage", 25, 5, true)},
+			[]ScoreContribution{{Check: "CPU Usage", Points: 25, MaxPoints: 25}, {Check: "Memory Usage", Points: 5, MaxPoints: 25}},
+			30,
+		},
+		{
+			"unscored section left out",
+			[]healthSection{section("Disk Usage", 30, 30, true), section("Network Connectivity", 20, 20, false)},
+			[]ScoreContribution{{Check: "Disk Usage", Points: 30, MaxPoints: 30}},
+			30,
+		},
+		{
+			"timed out section earns nothing",
+			[]healthSection{hung, section("CPU Usage", 25, 25, true)},
+			[]ScoreContribution{{Check: "NFS", Points: 0, MaxPoints: 15}, {Check: "CPU Usage", Points: 25, MaxPoints: 25}},
+			25,
+		},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		metrics := runHealthSections(ctx, tt.sections)
+		cancel()
+		if !reflect.DeepEqual(metrics.ScoreBreakdown, tt.want) {
+			t.Errorf("%s: breakdown = %+v, want %+v", tt.name, metrics.ScoreBreakdown, tt.want)
+		}
+		if metrics.OverallScore != tt.wantScore {
+			t.Errorf("%s: score = %d, want %d", tt.name, metrics.OverallScore, tt.wantScore)
+		}
+	}
+}
+
+func TestHeartbeatsBetweenFullReports(t *testing.T) {
+	var mutex sync.Mutex
+	var reports []StatusRequest
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req StatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode report: %v", err)
+		}
+		mutex.Lock()
+		reports = append(reports, req)
+		mutex.Unlock()
+		w.Write([]byte(`{"status":"ok"}`))
+	}, "--report-interval", "2", "--heartbeat-interval", "1")
+
+	// Full reports at 0s and 2s, heartbeats at 1s and 2s
+	time.AfterFunc(2500*time.Millisecond, dc.Stop)
+	if err := dc.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(reports) == 0 || reports[0].Detail != reportDetailFull {
+		t.Fatalf("reports = %+v, want a full report first", reports)
+	}
+	counts := make(map[string]int)
+	var lastSequence uint64
+	for _, report := range reports {
+		counts[report.Detail]++
+		switch report.Detail {
+		case reportDetailFull:
+			if report.HealthMetrics == nil {
+				t.Error("full report without health metrics")
+			}
+		case reportDetailHeartbeat:
+			if report.HealthMetrics != nil || report.Labels != nil || report.KernelVersion != "" {
+				t.Errorf("heartbeat carries more than the status: %+v", report)
+			}
+			if report.Status != reports[0].Status {
+				t.Errorf("heartbeat status %q, want the full report's %q", report.Status, reports[0].Status)
+			}
+		}
+		if report.Sequence <= lastSequence {
+			t.Errorf("sequence %d after %d", report.Sequence, lastSequence)
+		}
+		lastSequence = report.Sequence
+	}
+	if counts[reportDetailFull] != 2 || counts[reportDetailHeartbeat] != 2 {
+		t.Errorf("sent %v, want 2 full reports and 2 heartbeats", counts)
+	}
+}
+
+func TestParseCPUTimes(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		idle      uint64
+		total     uint64
+		wantError bool
+	}{
+		{"full line", "cpu  100 20 30 400 50 6 7 8 90 10\ncpu0 1 2 3 4 5 6 7 8 0 0\n", 450, 621, false},
+		{"aggregate after per-core lines", "intr 1\ncpu0 1 1 1 1\ncpu 10 0 10 80\n", 80, 100, false},
+		{"older kernel columns", "cpu 10 0 10 70 10\n", 80, 100, false},
+		{"truncated", "cpu 10 0 10\n", 0, 0, true},
+		{"not a number", "cpu 10 x 10 70 10\n", 0, 0, true},
+		{"no aggregate line", "cpu0 10 0 10 70 10\n", 0, 0, true},
+	}
+	for _, tt := range tests {
+		idle, total, err := parseCPUTimes(tt.content)
+		if (err != nil) != tt.wantError {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantError)
+			continue
+		}
+		if idle != tt.idle || total != tt.total {
+			t.Errorf("%s: idle/total = %d/%d, want %d/%d", tt.name, idle, total, tt.idle, tt.total)
+		}
+	}
+}
+
+func TestCPUUsageBetween(t *testing.T) {
+	for _, tt := range []struct {
+		idle1, total1, idle2, total2 uint64
+		want                         float64
+	}{
+		{800, 1000, 850, 1200, 75},
+		{800, 1000, 1000, 1200, 0},
+		{800, 1000, 800, 1400, 100},
+		{800, 1000, 800, 1000, 0}, // no time passed
+		{800, 1000, 700, 1200, 0}, // counters reset
+		{800, 1000, 1100, 1200, 0},
+	} {
+		if got := cpuUsageBetween(tt.idle1, tt.total1, tt.idle2, tt.total2); got != tt.want {
+			t.Errorf("cpuUsageBetween(%d, %d, %d, %d) = %v, want %v", tt.idle1, tt.total1, tt.idle2, tt.total2, got, tt.want)
+		}
+	}
+}
+
+func TestGetCPUUsageSources(t *testing.T) {
+	defer func(stat, loadavg string) { procStatPath, loadavgPath = stat, loadavg }(procStatPath, loadavgPath)
+
+	// /proc/stat advances while the sample interval passes
+	procStatPath = writeFile(t, "stat", "cpu 100 0 100 800 0 0 0 0\n")
+	time.AfterFunc(50*time.Millisecond, func() {
+		os.WriteFile(procStatPath, []byte("cpu 250 0 250 1000 0 0 0 0\n"), 0o644)
+	})
+	if got := getCPUUsage(300*time.Millisecond, cgroupModeHost); got != 60 {
+		t.Errorf("sampled usage = %v, want 60", got)
+	}
+
+	// Without /proc/stat the load average stands in
+	procStatPath = filepath.Join(t.TempDir(), "absent")
+	loadavgPath = writeFile(t, "loadavg", "0.50 0.40 0.30 1/200 1234\n")
+	want := math.Min(0.5/float64(runtime.NumCPU())*100, 100)
+	if got := getCPUUsage(time.Millisecond, cgroupModeHost); got != want {
+		t.Errorf("load average usage = %v, want %v", got, want)
+	}
+
+	loadavgPath = procStatPath
+	if got := getCPUUsage(time.Millisecond, cgroupModeHost); got != 25 {
+		t.Errorf("usage with neither source = %v, want the conservative 25", got)
+	}
+}
+
+func TestCPUSampleIntervalConfig(t *testing.T) {
+	if got := defaultHealthConfig(t).HealthChecks.CPU.SampleMillis; got != 100 {
+		t.Errorf("default sample_ms = %d, want 100", got)
+	}
+	t.Setenv("HEALTH_CPU_SAMPLE_MS", "750")
+	if got := defaultHealthConfig(t).HealthChecks.CPU.SampleMillis; got != 750 {
+		t.Errorf("sample_ms with HEALTH_CPU_SAMPLE_MS=750 = %d", got)
+	}
+}
+
+func TestCPUUsageFromRealProcStat(t *testing.T) {
+	// Two reads of /proc/stat on a 2-core box, two seconds apart
+	before := `cpu  2255 34 2290 22625563 6290 127 456 0 0 0
+cpu0 1132 34 1441 11311718 3675 127 438 0 0 0
+cpu1 1123 0 849 11313845 2614 0 18 0 0 0
+intr 114930548 113199788 3 0 5 263 0 4 [... 253 more numbers ...]
+ctxt 1990473
+btime 1062191376
+processes 2915
+procs_running 1
+procs_blocked 0
+softirq 183433 0 21755 12 39 1137 231 21459 2263
+`
+	after := `cpu  2262 34 2294 22625763 6292 127 457 0 0 0
+cpu0 1136 34 1443 11311818 3676 127 439 0 0 0
+cpu1 1126 0 851 11313945 2616 0 18 0 0 0
+`
+	idle1, total1, err := parseCPUTimes(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle2, total2, err := parseCPUTimes(after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Busy: user +7, system +4, softirq +1. Idle: idle +200, iowait +2.
+	if idle2-idle1 != 202 || total2-total1 != 214 {
+		t.Fatalf("idle/total deltas = %d/%d, want 202/214", idle2-idle1, total2-total1)
+	}
+	if got, want := cpuUsageBetween(idle1, total1, idle2, total2), 12.0/214*100; math.Abs(got-want) > 1e-9 {
+		t.Errorf("usage = %v, want %v", got, want)
+	}
+}
+
+func TestGetDiskUsage(t *testing.T) {
+	defer func(fn func(string, *syscall.Statfs_t) error) { statfs = fn }(statfs)
+	tests := []struct {
+		name string
+		stat syscall.Statfs_t
+		want float64
+	}{
+		{"half used", syscall.Statfs_t{Blocks: 1000, Bfree: 500, Bavail: 500}, 50},
+		// 50 reserved blocks are free but unavailable, as df counts them
+		{"reserved blocks", syscall.Statfs_t{Blocks: 1000, Bfree: 550, Bavail: 500}, 45.0 / 95 * 100},
+		{"full to non-root", syscall.Statfs_t{Blocks: 1000, Bfree: 50, Bavail: 0}, 100},
+		{"empty filesystem", syscall.Statfs_t{}, 0},
+	}
+	for _, tt := range tests {
+		statfs = func(_ string, stat *syscall.Statfs_t) error {
+			*stat = tt.stat
+			return nil
+		}
+		got, err := getDiskUsage(statfs, "/")
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: getDiskUsage = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	statfs = func(string, *syscall.Statfs_t) error { return syscall.ENOENT }
+	if _, err := getDiskUsage(statfs, "/gone"); err == nil {
+		t.Error("getDiskUsage succeeded though statfs failed")
+	}
+}
+
+func TestGetDiskUsageMatchesDf(t *testing.T) {
+	dir := t.TempDir()
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		t.Skipf("statfs unavailable: %v", err)
+	}
+	got, err := getDiskUsage(statfs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// df's Use%: used / (used + available), rounded up; the usage may shift
+	// by a few blocks between the two reads
+	used := float64(stat.Blocks - stat.Bfree)
+	df := math.Ceil(used / (used + float64(stat.Bavail)) * 100)
+	if got < 0 || got > 100 || math.Abs(math.Ceil(got)-df) > 1 {
+		t.Errorf("getDiskUsage(%s) = %.2f, df reports %.0f%%", dir, got, df)
+	}
+}
+
+func TestDiskSectionReportsStatfsFailure(t *testing.T) {
+	config := defaultHealthConfig(t)
+	config.HealthChecks.Disk.Paths = []string{"/", "/mnt/stale-nfs"}
+
+	defer func(fn func(string, *syscall.Statfs_t) error) { statfs = fn }(statfs)
+	statfs = func(path string, stat *syscall.Statfs_t) error {
+		if path != "/" {
+			return syscall.EIO
+		}
+		*stat = syscall.Statfs_t{Blocks: 100, Bfree: 90, Bavail: 90}
+		return nil
+	}
+
+	result := diskSection(config, config.scoreFactors(), statfs)
+	if len(result.checks) != 2 {
+		t.Fatalf("checks = %+v, want one per path", result.checks)
+	}
+	failed := result.checks[1]
+	if failed.Name != "Disk Usage (/mnt/stale-nfs)" || failed.Status != "unknown" || failed.Value != "" || !strings.Contains(failed.Message, "statfs failed") {
+		t.Errorf("failed path check = %+v, want unknown with the statfs error and no guessed value", failed)
+	}
+	var metrics HealthMetrics
+	result.summary(&metrics)
+	if metrics.DiskUsage != 10 {
+		t.Errorf("DiskUsage = %v, want the readable path's 10", metrics.DiskUsage)
+	}
+}
+
+func TestDiskSectionPerPath(t *testing.T) {
+	config := defaultHealthConfig(t)
+	config.HealthChecks.Disk.Paths = []string{"/", "/var", "/data"}
+	config.HealthChecks.Disk.Weight = 30
+	factors := scoreFactors{degraded: 0.5, unhealthy: 0}
+
+	tests := []struct {
+		name       string
+		used       [3]uint64 // percent used of /, /var and /data
+		want       [3]string
+		wantUsage  float64
+		wantPoints int
+	}{
+		{"all healthy", [3]uint64{10, 20, 30}, [3]string{"healthy", "healthy", "healthy"}, 30, 30},
+		{"mixed", [3]uint64{40, 90, 97}, [3]string{"healthy", "degraded", "unhealthy"}, 97, 15},
+		{"fullest first", [3]uint64{99, 20, 30}, [3]string{"unhealthy", "healthy", "healthy"}, 99, 20},
+	}
+	defer func(fn func(string, *syscall.Statfs_t) error) { statfs = fn }(statfs)
+	for _, tt := range tests {
+		used := map[string]uint64{"/": tt.used[0], "/var": tt.used[1], "/data": tt.used[2]}
+		statfs = func(path string, stat *syscall.Statfs_t) error {
+			*stat = syscall.Statfs_t{Blocks: 100, Bfree: 100 - used[path], Bavail: 100 - used[path]}
+			return nil
+		}
+
+		result := diskSection(config, factors, statfs)
+		if len(result.checks) != 3 {
+			t.Fatalf("%s: %d checks, want one per path", tt.name, len(result.checks))
+		}
+		for i, path := range config.HealthChecks.Disk.Paths {
+			check := result.checks[i]
+			if check.Name != "Disk Usage ("+path+")" || check.Status != tt.want[i] {
+				t.Errorf("%s: check %d = %s %s, want Disk Usage (%s) %s", tt.name, i, check.Name, check.Status, path, tt.want[i])
+			}
+		}
+		var metrics HealthMetrics
+		result.summary(&metrics)
+		if metrics.DiskUsage != tt.wantUsage || result.points != tt.wantPoints {
+			t.Errorf("%s: DiskUsage %v for %d points, want %v for %d", tt.name, metrics.DiskUsage, result.points, tt.wantUsage, tt.wantPoints)
+		}
+	}
+}
+
+func TestLoadPerCore(t *testing.T) {
+	// A simulated 4-core host, plus guards for a bogus core count
+	for _, tt := range []struct {
+		load float64
+		cpus int
+		want float64
+	}{
+		{2.8, 4, 0.7},
+		{4, 4, 1},
+		{10, 4, 2.5},
+		{0, 4, 0},
+		{1.5, 0, 1.5},
+		{1.5, -2, 1.5},
+	} {
+		if got := loadPerCore(tt.load, tt.cpus); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("loadPerCore(%v, %d) = %v, want %v", tt.load, tt.cpus, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAverageSection(t *testing.T) {
+	config := defaultHealthConfig(t)
+	config.HealthChecks.LoadAverage.HealthyThreshold = 0.7
+	config.HealthChecks.LoadAverage.DegradedThreshold = 1.0
+	config.HealthChecks.LoadAverage.Weight = 10
+
+	defer func(path string) { loadavgPath = path }(loadavgPath)
+	cpus := float64(runtime.NumCPU())
+	tests := []struct {
+		perCore    float64
+		want       string
+		wantPoints int
+	}{
+		{0.25, "healthy", 10},
+		{0.85, "degraded", 5},
+		{3, "unhealthy", 0},
+	}
+	for _, tt := range tests {
+		loadavgPath = writeFile(t, "loadavg", fmt.Sprintf("%.4f 0.10 0.05 2/300 4242\n", tt.perCore*cpus))
+		result := loadAverageSection(config, scoreFactors{degraded: 0.5})
+		check := result.checks[0]
+		if check.Status != tt.want || result.points != tt.wantPoints {
+			t.Errorf("%v per core: %s for %d points, want %s for %d", tt.perCore, check.Status, result.points, tt.want, tt.wantPoints)
+		}
+		if want := fmt.Sprintf("(%.2f per core)", tt.perCore); !strings.HasSuffix(check.Value, want) {
+			t.Errorf("%v per core: value %q, want it to end %q", tt.perCore, check.Value, want)
+		}
+	}
+
+	loadavgPath = filepath.Join(t.TempDir(), "absent")
+	if result := loadAverageSection(config, scoreFactors{}); result.checks[0].Status != "unknown" || result.points != 0 {
+		t.Errorf("unreadable load average = %+v for %d points, want unknown for 0", result.checks[0], result.points)
+	}
+}
+
+func TestParseMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name    string
+		meminfo string
+		want    float64
+		ok      bool
+	}{
+		{"MemAvailable preferred", `MemTotal:       16000000 kB
+MemFree:         1000000 kB
+MemAvailable:    4000000 kB
+Buffers:          500000 kB
+Cached:          6000000 kB
+SwapCached:        20000 kB
+SReclaimable:     900000 kB
+`, 75, true},
+		{"older kernel without MemAvailable", `MemTotal:        8000000 kB
+MemFree:         1000000 kB
+Buffers:          200000 kB
+Cached:          2800000 kB
+SwapCached:       100000 kB
+`, 50, true},
+		{"MemAvailable beyond MemTotal falls back", "MemTotal: 1000 kB\nMemFree: 100 kB\nMemAvailable: 5000 kB\nBuffers: 100 kB\nCached: 300 kB\n", 50, true},
+		{"no MemTotal", "MemFree: 100 kB\nMemAvailable: 200 kB\n", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseMemoryUsage(tt.meminfo)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: parseMemoryUsage = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGetMemoryUsageFallsBack(t *testing.T) {
+	defer func(path string) { meminfoPath = path }(meminfoPath)
+	meminfoPath = writeFile(t, "meminfo", "MemTotal: 2000 kB\nMemAvailable: 500 kB\n")
+	if got := getMemoryUsage(cgroupModeHost); got != 75 {
+		t.Errorf("usage = %v, want 75", got)
+	}
+	meminfoPath = filepath.Join(t.TempDir(), "absent")
+	if got := getMemoryUsage(cgroupModeHost); got != 50 {
+		t.Errorf("usage without /proc/meminfo = %v, want the moderate 50", got)
+	}
+}
+
+func TestDNSResolutionHonorsTimeout(t *testing.T) {
+	defer func(resolver *net.Resolver) { dnsResolver = resolver }(dnsResolver)
+	// A resolver that never answers holds the lookup until its context ends
+	dnsResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	budget := 300 * time.Millisecond
+	start := time.Now()
+	if testDNSResolution(budget) {
+		t.Fatal("lookup through an unanswering resolver succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > budget+time.Second {
+		t.Errorf("lookup took %v with a %v timeout", elapsed, budget)
+	}
+}
+
+func TestNetworkTestsGetConfiguredTimeout(t *testing.T) {
+	defer func(tests []func(time.Duration) bool) { networkTests = tests }(networkTests)
+	var timeouts []time.Duration
+	networkTests = []func(time.Duration) bool{func(timeout time.Duration) bool {
+		timeouts = append(timeouts, timeout)
+		return true
+	}}
+
+	config := HealthConfig{}
+	checkNetworkConnectivity(config)
+	config.HealthChecks.Network.TimeoutSeconds = 2
+	checkNetworkConnectivity(config)
+	if len(timeouts) != 2 || timeouts[0] != 5*time.Second || timeouts[1] != 2*time.Second {
+		t.Errorf("timeouts = %v, want 5s when unset, then the configured 2s", timeouts)
+	}
+}
+
+func TestCheckNetworkConnectivityRequiredTests(t *testing.T) {
+	defer func(tests []func(time.Duration) bool) { networkTests = tests }(networkTests)
+	pass := func(time.Duration) bool { return true }
+	fail := func(time.Duration) bool { return false }
+
+	tests := []struct {
+		required int
+		outcomes []func(time.Duration) bool
+		want     bool
+	}{
+		{1, []func(time.Duration) bool{fail, fail, pass}, true},
+		{1, []func(time.Duration) bool{fail, fail, fail}, false},
+		{2, []func(time.Duration) bool{pass, fail, pass}, true},
+		{2, []func(time.Duration) bool{pass, fail, fail}, false},
+		{3, []func(time.Duration) bool{pass, pass, pass}, true},
+		{3, []func(time.Duration) bool{pass, pass, fail}, false},
+		{0, []func(time.Duration) bool{fail, pass, fail}, true}, // raised to 1
+		{5, []func(time.Duration) bool{pass, pass, pass}, true}, // clamped to the 3 tests run
+		{5, []func(time.Duration) bool{pass, pass}, true},       // clamped to the 2 tests run
+		{2, []func(time.Duration) bool{fail, pass}, false},
+	}
+	for i, tt := range tests {
+		networkTests = tt.outcomes
+		config := HealthConfig{}
+		config.HealthChecks.Network.RequiredTestsPass = tt.required
+		if got := checkNetworkConnectivity(config); got != tt.want {
+			t.Errorf("case %d: required %d of %d = %v, want %v", i, tt.required, len(tt.outcomes), got, tt.want)
+		}
+	}
+}
+
+func TestPerformHealthChecksSamplesOnce(t *testing.T) {
+	defer func(cpu func(time.Duration, string) float64, memory func(string) float64) {
+		sampleCPUUsage, sampleMemoryUsage = cpu, memory
+	}(sampleCPUUsage, sampleMemoryUsage)
+
+	for _, enabled := range []bool{true, false} {
+		// Each reading differs from the last, so a second sample would show
+		var cpuSamples, memorySamples int
+		sampleCPUUsage = func(time.Duration, string) float64 {
+			cpuSamples++
+			return float64(10 * cpuSamples)
+		}
+		sampleMemoryUsage = func(string) float64 {
+			memorySamples++
+			return float64(20 * memorySamples)
+		}
+
+		config := defaultHealthConfig(t)
+		config.disableChecks([]string{"disk", "network", "load_average", "process", "gpu", "temperature", "interface"}, discardLogger)
+		config.HealthChecks.CPU.Enabled = enabled
+		config.HealthChecks.Memory.Enabled = enabled
+
+		metrics := performHealthChecks(context.Background(), config)
+		if cpuSamples != 1 || memorySamples != 1 {
+			t.Errorf("checks enabled %v: CPU sampled %d times, memory %d; want once each", enabled, cpuSamples, memorySamples)
+		}
+		if metrics.CPUUsage != 10 || metrics.MemoryUsage != 20 {
+			t.Errorf("checks enabled %v: metrics CPU %v, memory %v; want the single readings 10 and 20", enabled, metrics.CPUUsage, metrics.MemoryUsage)
+		}
+		if enabled && (metrics.Checks[0].Value != "10.0%" || metrics.Checks[1].Value != "20.0%") {
+			t.Errorf("check values %q and %q disagree with the metrics", metrics.Checks[0].Value, metrics.Checks[1].Value)
+		}
+	}
+}
+
+func TestGetHostStatusThresholds(t *testing.T) {
+	config := HealthConfig{}
+	config.Scoring.HealthyScoreMin = 80
+	config.Scoring.DegradedScoreMin = 60
+	for score, want := range map[int]string{100: "healthy", 80: "healthy", 79: "degraded", 60: "degraded", 59: "unhealthy", 0: "unhealthy"} {
+		if got := getHostStatus(HealthMetrics{OverallScore: score}, config); got != want {
+			t.Errorf("score %d = %s, want %s", score, got, want)
+		}
+	}
+}
+
+func TestReportStatusUsesOneCheckPass(t *testing.T) {
+	defer func(cpu func(time.Duration, string) float64) { sampleCPUUsage = cpu }(sampleCPUUsage)
+
+	reports := make(chan StatusRequest, 4)
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req StatusRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		reports <- req
+		w.Write([]byte(`{"status":"ok"}`))
+	}, "--retry-attempts", "1")
+	dc.healthConfig.HealthChecks.CPU.Enabled = true
+	dc.healthConfig.HealthChecks.CPU.Weight = 100
+
+	// A second pass would read a CPU usage on the other side of the thresholds
+	for _, readings := range [][]float64{{10, 99}, {99, 10}} {
+		var passes int
+		sampleCPUUsage = func(time.Duration, string) float64 {
+			passes++
+			return readings[(passes-1)%2]
+		}
+		if err := dc.reportStatus(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		report := <-reports
+		if passes != 1 {
+			t.Errorf("readings %v: %d check passes for one report, want 1", readings, passes)
+		}
+		if want := getHostStatus(*report.HealthMetrics, dc.healthConfig); report.Status != want {
+			t.Errorf("readings %v: status %s sent with score %d, which maps to %s", readings, report.Status, report.HealthMetrics.OverallScore, want)
+		}
+		if report.HealthMetrics.CPUUsage != readings[0] {
+			t.Errorf("readings %v: CPU usage %v sent, want the first reading", readings, report.HealthMetrics.CPUUsage)
+		}
+		if dc.latestHostStatus != report.Status || dc.latestMetrics.OverallScore != report.HealthMetrics.OverallScore {
+			t.Errorf("readings %v: exporter holds %s/%d, report sent %s/%d", readings, dc.latestHostStatus, dc.latestMetrics.OverallScore, report.Status, report.HealthMetrics.OverallScore)
+		}
+	}
+}
+
+func TestHealthConfigReadOnceUntilSIGHUP(t *testing.T) {
+	// Keep SIGHUP from killing the test binary before the client handles it
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	reports := make(chan StatusRequest, 16)
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req StatusRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		reports <- req
+		w.Write([]byte(`{"status":"ok"}`))
+	}, "--report-interval", "1")
+
+	// The client loaded its config from the empty working directory; the
+	// file written now should be picked up only on SIGHUP
+	if err := os.WriteFile("health-config.json", []byte(`{"health_checks": {
+		"load_average": {"enabled": true, "healthy_threshold": 0.7, "degraded_threshold": 1.0, "weight": 10},
+		"disk": {"enabled": false},
+		"network": {"enabled": false}
+	}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hasLoadCheck := func(report StatusRequest) bool {
+		for _, check := range report.HealthMetrics.Checks {
+			if check.Name == "Load Average" {
+				return true
+			}
+		}
+		return false
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- dc.Start() }()
+	defer func() {
+		dc.Stop()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for cycle := 0; cycle < 2; cycle++ {
+		select {
+		case report := <-reports:
+			if hasLoadCheck(report) {
+				t.Fatalf("cycle %d used the config file before SIGHUP", cycle)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no report for cycle %d", cycle)
+		}
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	deadline := time.After(3 * time.Second)
+	for {
+		select {
+		case report := <-reports:
+			if hasLoadCheck(report) {
+				return
+			}
+		case <-deadline:
+			t.Fatal("config file not picked up after SIGHUP")
+		}
+	}
+}
+
+func TestLoadConfigFileThenEnvThenFlags(t *testing.T) {
+	chdirTemp(t)
+	file := Config{
+		ServerURL: "unix:///run/s01/s01.sock", ServiceName: "payments", InstanceName: "pay-1",
+		ReportInterval: 15, CertFile: "client.crt", KeyFile: "client.key", CACertFile: "ca.crt",
+		LogLevel: "debug", LogFormat: "text", LogOutput: "stderr", Timeout: 7, RetryAttempts: 2,
+		RetryDelay: 1, RetryMaxDelay: 20, HeartbeatInterval: 5, StatsLogInterval: 60,
+		BreakerThreshold: 9, BreakerInterval: 120, ErrorLogPath: "/var/log/app.log",
+		ErrorLogMatch: "FATAL", MetricsPort: "9101", CertExpiryWarnDays: 3, RejectExpiredCert: true,
+		AdditionalServices: "payments-worker", BufferPath: "buffer.jsonl", BufferMaxReports: 10,
+		TLSMinVersion: "1.3", CipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		OTLPEndpoint: "http://otel:4318", SelfTest: false, Once: true,
+		Labels: labelSet{"zone": "us-east-1a"},
+	}
+	fields := reflect.ValueOf(file)
+	for i := 0; i < fields.NumField(); i++ {
+		// SelfTest would skip validation; every other field must be set
+		if name := fields.Type().Field(i).Name; name != "SelfTest" && fields.Field(i).IsZero() {
+			t.Fatalf("test config leaves %s unset", name)
+		}
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("client-config.json", data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if !reflect.DeepEqual(*loaded, file) {
+		t.Errorf("loaded %+v\nwant the file's %+v", *loaded, file)
+	}
+
+	t.Setenv("REPORT_INTERVAL", "45")
+	t.Setenv("ONCE", "false")
+	t.Setenv("LABELS", "zone=eu-west-1b")
+	loaded, err = loadConfig([]string{"--report-interval", "90"})
+	if err != nil {
+		t.Fatalf("loadConfig with overrides: %v", err)
+	}
+	if loaded.ReportInterval != 90 || loaded.Once || loaded.Labels["zone"] != "eu-west-1b" {
+		t.Errorf("overrides ignored: report_interval %d, once %v, labels %v; want the flag's 90, the env's false and zone", loaded.ReportInterval, loaded.Once, loaded.Labels)
+	}
+	if loaded.ServiceName != "payments" || loaded.MetricsPort != "9101" {
+		t.Errorf("fields without overrides lost the file's values: %s, %s", loaded.ServiceName, loaded.MetricsPort)
+	}
+}
+
+func TestFlagSetMirrorsConfig(t *testing.T) {
+	flags := newFlagSet(&Config{})
+	fields := reflect.TypeOf(Config{})
+	for i := 0; i < fields.NumField(); i++ {
+		name := strings.ReplaceAll(fields.Field(i).Tag.Get("json"), "_", "-")
+		if flags.Lookup(name) == nil {
+			t.Errorf("Config.%s has no --%s flag", fields.Field(i).Name, name)
+		}
+	}
+}
+
+func TestLoadConfigFlagArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		check   func(*Config) bool
+		wantErr string
+	}{
+		{args: []string{"--report-interval=15", "-timeout", "3"}, check: func(c *Config) bool { return c.ReportInterval == 15 && c.Timeout == 3 }},
+		{args: []string{"--once", "--reject-expired-cert=false"}, check: func(c *Config) bool { return c.Once && !c.RejectExpiredCert }},
+		{args: []string{"--labels", "zone=us-east-1a,tier=web"}, check: func(c *Config) bool { return c.Labels.String() == "tier=web,zone=us-east-1a" }},
+		{args: []string{"--selftest", "--service-name", ""}, check: func(c *Config) bool { return c.SelfTest }},
+		{args: []string{"--help"}, wantErr: flag.ErrHelp.Error()},
+		{args: []string{"-h"}, wantErr: flag.ErrHelp.Error()},
+		{args: []string{"--report-interval", "soon"}, wantErr: "invalid value"},
+		{args: []string{"--labels", "zone"}, wantErr: "expected key=value"},
+		{args: []string{"--no-such-flag"}, wantErr: "flag provided but not defined"},
+		{args: []string{"run"}, wantErr: "unexpected argument: run"},
+	}
+	for _, tt := range tests {
+		config, err := loadTestConfig(t, tt.args...)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: err = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !tt.check(config) {
+			t.Errorf("%q: config %+v, %v", tt.args, config, err)
+		}
+	}
+}
+
+func TestStopInterruptsReportRetries(t *testing.T) {
+	tests := []struct {
+		name  string
+		stall bool // the server does not answer until the test ends
+	}{
+		// The first attempt fails and the client waits a minute to retry
+		{"waiting to retry", false},
+		{"request in flight", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received, release := make(chan struct{}, 1), make(chan struct{})
+			dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+				if tt.stall {
+					<-release
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}, "--retry-attempts", "5", "--retry-delay", "60", "--timeout", "120")
+			// Registered after the server, so it runs first and lets it close
+			t.Cleanup(func() { close(release) })
+
+			done := make(chan error, 1)
+			go func() { done <- dc.Start() }()
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no report reached the server")
+			}
+
+			start := time.Now()
+			dc.Stop()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Start = %v after Stop, want a clean exit", err)
+				}
+				if elapsed := time.Since(start); elapsed > 2*time.Second {
+					t.Errorf("Start took %v to return after Stop", elapsed)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Start still running 10s after Stop")
+			}
+		})
+	}
+}
+
+func TestReportsAreNumbered(t *testing.T) {
+	defer func(sleep func(context.Context, time.Duration) error) { retrySleep = sleep }(retrySleep)
+	retrySleep = func(context.Context, time.Duration) error { return nil }
+
+	var received []StatusRequest
+	failFirst := false
+	bufferPath := filepath.Join(t.TempDir(), "buffer.jsonl")
+	before := uint64(time.Now().UnixNano())
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req StatusRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received = append(received, req)
+		switch {
+		case failFirst:
+			// The report lands but the reply is lost; the retry is a replay
+			failFirst = false
+			w.WriteHeader(http.StatusBadGateway)
+		case len(received) > 1 && req.Sequence == received[len(received)-2].Sequence:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.Write([]byte(`{"success": true}`))
+		}
+	}, "--retry-attempts", "3", "--breaker-threshold", "0", "--buffer-path", bufferPath)
+
+	ctx := context.Background()
+	if err := dc.reportStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.sendHeartbeat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	failFirst = true
+	if err := dc.reportStatus(ctx); err != nil {
+		t.Fatalf("report whose retry the server already had = %v, want it counted as delivered", err)
+	}
+	if buffered, _ := dc.buffer.load(); len(buffered) != 0 {
+		t.Errorf("%d reports buffered after a 409, want none", len(buffered))
+	}
+
+	if len(received) != 4 {
+		t.Fatalf("%d requests, want report, heartbeat and a report sent twice", len(received))
+	}
+	// Seeded from the clock so numbers keep rising across restarts
+	if received[0].Sequence < before {
+		t.Errorf("first sequence %d is below the start time %d", received[0].Sequence, before)
+	}
+	if received[1].Sequence != received[0].Sequence+1 || received[2].Sequence != received[1].Sequence+1 || received[3].Sequence != received[2].Sequence {
+		t.Errorf("sequences %d %d %d %d, want each report and heartbeat one higher and the retry unchanged",
+			received[0].Sequence, received[1].Sequence, received[2].Sequence, received[3].Sequence)
+	}
+	for i, req := range received {
+		if req.Timestamp == nil {
+			t.Errorf("request %d has no client timestamp", i+1)
+		}
+	}
+}
+
+func TestSetupLoggerOutputs(t *testing.T) {
+	// capture swaps *stream for a pipe while fn runs and returns what was written to it
+	capture := func(stream **os.File, fn func()) string {
+		t.Helper()
+		read, write, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := *stream
+		*stream = write
+		fn()
+		*stream = saved
+		write.Close()
+		out, _ := io.ReadAll(read)
+		return string(out)
+	}
+
+	logFile := filepath.Join(t.TempDir(), "client.log")
+	tests := []struct {
+		output                 string
+		wantStdout, wantStderr bool
+	}{
+		{"stdout", true, false},
+		{"", true, false},
+		{"stderr", false, true},
+		{logFile, false, false},
+		{logFile, false, false},
+	}
+	for _, tt := range tests {
+		var stderr string
+		stdout := capture(&os.Stdout, func() {
+			stderr = capture(&os.Stderr, func() {
+				setupLogger("warn", "text", tt.output).Warn("Report failed", "attempt", 3)
+			})
+		})
+		if strings.Contains(stdout, "Report failed") != tt.wantStdout || strings.Contains(stderr, "Report failed") != tt.wantStderr {
+			t.Errorf("output %q: stdout %q stderr %q", tt.output, stdout, stderr)
+		}
+	}
+
+	// Both loggers appended to the file, as logfmt lines
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "time=") || !strings.Contains(lines[1], `level=WARN msg="Report failed" attempt=3`) {
+		t.Errorf("log file = %q, want two logfmt lines", lines)
+	}
+	if info, _ := os.Stat(logFile); info.Mode().Perm()&0o007 != 0 {
+		t.Errorf("log file mode = %v, want no access for others", info.Mode().Perm())
+	}
+
+	// Fallbacks are announced on stdout
+	stdout := capture(&os.Stdout, func() {
+		setupLogger("info", "xml", filepath.Join(logFile, "not-a-dir.log"))
+	})
+	if !strings.Contains(stdout, "Failed to open log output") || !strings.Contains(stdout, "Unknown log format") || !strings.HasPrefix(stdout, "{") {
+		t.Errorf("fallback logged %q, want both warnings as JSON on stdout", stdout)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// checkStatuses are the states exported for each check's status enum
+var checkStatuses = []string{"healthy", "degraded", "unhealthy", "unknown"}
+
+// setLatestMetrics records the most recent health check pass for the exporter
+func (dc *S01Client) setLatestMetrics(metrics HealthMetrics, status string) {
+	dc.metricsMutex.Lock()
+	defer dc.metricsMutex.Unlock()
+
+	dc.latestMetrics = &metrics
+	dc.latestHostStatus = status
+}
+
+// metricsHandler serves the latest health metrics in the Prometheus text format
+func (dc *S01Client) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dc.metricsMutex.RLock()
+	metrics := dc.latestMetrics
+	status := dc.latestHostStatus
+	dc.metricsMutex.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	breakerState, _ := dc.breaker.state()
+	writeMetrics(w, metrics, status, breakerState, &dc.counters)
+}
+
+// writeMetrics renders health metrics as Prometheus gauges. Nothing but the
+// breaker state and report counters is written before the first health check
+// pass completes.
+func writeMetrics(w io.Writer, metrics *HealthMetrics, status, breakerState string, counters *reportCounters) {
+	writeGauge(w, "client_circuit_breaker_open", "Whether report backoff is active after repeated failures", float64(boolToInt(breakerState == breakerOpen)))
+	writeCounter(w, "client_reports_attempted_total", "Report cycles started", counters.attempted.Load())
+	writeCounter(w, "client_reports_succeeded_total", "Report cycles accepted by the server, after retries", counters.succeeded.Load())
+	writeCounter(w, "client_reports_failed_total", "Report cycles that failed every attempt or were cancelled", counters.failed.Load())
+	writeCounter(w, "client_report_retries_total", "Report attempts beyond the first of their cycle", counters.retries.Load())
+	writeGauge(w, "client_last_success_unix", "Unix time of the last accepted report cycle, 0 before the first", float64(counters.lastSuccess.Load()))
+
+	if metrics == nil {
+		return
+	}
+
+	writeGauge(w, "client_cpu_usage", "CPU usage percentage", metrics.CPUUsage)
+	writeGauge(w, "client_memory_usage", "Memory usage percentage", metrics.MemoryUsage)
+	writeGauge(w, "client_disk_usage", "Disk usage percentage of the fullest checked path", metrics.DiskUsage)
+	writeGauge(w, "client_health_score", "Overall weighted health score", float64(metrics.OverallScore))
+
+	fmt.Fprintln(w, "# HELP client_host_status Current host status, 1 for the active state")
+	fmt.Fprintln(w, "# TYPE client_host_status gauge")
+	for _, s := range []string{"healthy", "degraded", "unhealthy"} {
+		fmt.Fprintf(w, "client_host_status{status=%q} %d\n", s, boolToInt(s == status))
+	}
+
+	fmt.Fprintln(w, "# HELP client_check_status Status of each health check, 1 for the active state")
+	fmt.Fprintln(w, "# TYPE client_check_status gauge")
+	for _, check := range metrics.Checks {
+		name := escapeLabelValue(check.Name)
+		for _, s := range checkStatuses {
+			fmt.Fprintf(w, "client_check_status{check=\"%s\",status=%q} %d\n", name, s, boolToInt(s == check.Status))
+		}
+	}
+}
+
+// writeGauge writes a single unlabeled gauge with its metadata
+func writeGauge(w io.Writer, name, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "%s %g\n", name, value)
+}
+
+// writeCounter writes a single unlabeled counter with its metadata
+func writeCounter(w io.Writer, name, help string, value uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+// escapeLabelValue escapes a string for use as a Prometheus label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// startMetricsServer serves /metrics on the configured port
+func (dc *S01Client) startMetricsServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", dc.metricsHandler)
+
+	server := &http.Server{
+		Addr:         ":" + dc.config.MetricsPort,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	dc.logger.Info("Starting metrics exporter", "port", dc.config.MetricsPort)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			dc.logger.Error("Metrics exporter failed", "error", err)
+		}
+	}()
+	return server
+}
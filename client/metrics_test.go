@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape fetches the exporter's page with method and returns its status and body
+func scrape(dc *S01Client, method string) (int, string) {
+	recorder := httptest.NewRecorder()
+	dc.metricsHandler(recorder, httptest.NewRequest(method, "/metrics", nil))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestMetricsHandlerServesLatestMetrics(t *testing.T) {
+	dc := &S01Client{breaker: newCircuitBreaker(3)}
+
+	_, body := scrape(dc, http.MethodGet)
+	if strings.Contains(body, "client_cpu_usage") || !strings.Contains(body, "client_circuit_breaker_open 0") {
+		t.Errorf("before the first pass:\n%s\nwant only the breaker state and counters", body)
+	}
+
+	dc.setLatestMetrics(HealthMetrics{
+		CPUUsage:     12.5,
+		MemoryUsage:  64,
+		DiskUsage:    91.25,
+		OverallScore: 70,
+		Checks: []HealthCheck{
+			{Name: "CPU Usage", Status: "healthy"},
+			{Name: `Disk Usage (/mnt/"odd")`, Status: "degraded"},
+		},
+	}, "degraded")
+
+	code, body := scrape(dc, http.MethodGet)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	for _, line := range []string{
+		"client_cpu_usage 12.5",
+		"client_memory_usage 64",
+		"client_disk_usage 91.25",
+		"client_health_score 70",
+		`client_host_status{status="healthy"} 0`,
+		`client_host_status{status="degraded"} 1`,
+		`client_check_status{check="CPU Usage",status="healthy"} 1`,
+		`client_check_status{check="CPU Usage",status="unknown"} 0`,
+		`client_check_status{check="Disk Usage (/mnt/\"odd\")",status="degraded"} 1`,
+		`client_check_status{check="Disk Usage (/mnt/\"odd\")",status="healthy"} 0`,
+		"# TYPE client_health_score gauge",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+
+	if code, _ := scrape(dc, http.MethodPost); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /metrics = %d, want 405", code)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartOnce(t *testing.T) {
+	defer func(sleep func(context.Context, time.Duration) error) { retrySleep = sleep }(retrySleep)
+	retrySleep = func(context.Context, time.Duration) error { return nil }
+
+	tests := []struct {
+		name         string
+		failures     int32 // 503s before the server accepts
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"accepted", 0, false, 1},
+		{"accepted on retry", 2, false, 3},
+		{"never accepted", 5, true, 3},
+	}
+	for _, tt := range tests {
+		var requests atomic.Int32
+		dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) <= tt.failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"success": true}`))
+		}, "--once", "--report-interval", "1", "--retry-attempts", "3", "--breaker-threshold", "0")
+
+		done := make(chan error, 1)
+		go func() { done <- dc.Start() }()
+		select {
+		case err := <-done:
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s: Start = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+		case <-time.After(5 * time.Second):
+			dc.Stop()
+			t.Fatalf("%s: Start still running with --once", tt.name)
+		}
+
+		if got := requests.Load(); got != tt.wantRequests {
+			t.Errorf("%s: %d requests, want %d", tt.name, got, tt.wantRequests)
+		}
+	}
+}
+
+func TestOnceExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantCode int
+	}{
+		{"accepted", http.StatusOK, 0},
+		{"refused", http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		var requests atomic.Int32
+		socket := socketServer(t, func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(tt.status)
+			w.Write([]byte(`{}`))
+		})
+		_, stderr, code := runMain(t, "ONCE=true", "SERVER_URL=unix://"+socket, "SERVICE_NAME=cron-job",
+			"HEALTH_DISABLE=cpu,memory,disk,network", "RETRY_ATTEMPTS=1")
+		if code != tt.wantCode {
+			t.Errorf("%s: exit code %d, want %d\n%s", tt.name, code, tt.wantCode, stderr)
+		}
+		if requests.Load() != 1 {
+			t.Errorf("%s: %d reports sent, want exactly one", tt.name, requests.Load())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procPath is the procfs mount scanned for running processes
+var procPath = "/proc"
+
+// maxCommLen is the kernel's limit on /proc/<pid>/comm (TASK_COMM_LEN - 1)
+const maxCommLen = 15
+
+// checkProcesses verifies that each named process and each pidfile's process
+// is alive. Every configured entry carries an equal share of weight, and any
+// missing entry marks the check unhealthy.
+func checkProcesses(procRoot string, names, pidFiles []string, weight int) (HealthCheck, int) {
+	check := HealthCheck{
+		Name: "Process",
+	}
+
+	total := len(names) + len(pidFiles)
+	if total == 0 {
+		check.Status = "unknown"
+		check.Message = "No processes configured"
+		return check, 0
+	}
+
+	var missing []string
+	if len(names) > 0 {
+		running := runningProcessNames(procRoot)
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if len(name) > maxCommLen {
+				name = name[:maxCommLen]
+			}
+			if !running[name] {
+				missing = append(missing, name)
+			}
+		}
+	}
+	for _, pidFile := range pidFiles {
+		if !pidFileAlive(procRoot, pidFile) {
+			missing = append(missing, pidFile)
+		}
+	}
+
+	alive := total - len(missing)
+	check.Value = fmt.Sprintf("%d/%d running", alive, total)
+	if len(missing) == 0 {
+		check.Status = "healthy"
+	} else {
+		check.Status = "unhealthy"
+		check.Message = "Not running: " + strings.Join(missing, ", ")
+	}
+	return check, weight * alive / total
+}
+
+// runningProcessNames returns the comm names of all processes under procRoot
+func runningProcessNames(procRoot string) map[string]bool {
+	names := make(map[string]bool)
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		// Processes may exit between listing and reading
+		if data, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "comm")); err == nil {
+			names[strings.TrimSpace(string(data))] = true
+		}
+	}
+	return names
+}
+
+// pidFileAlive reports whether the process recorded in pidFile exists
+func pidFileAlive(procRoot, pidFile string) bool {
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(procRoot, strconv.Itoa(pid)))
+	return err == nil
+}
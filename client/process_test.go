@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeProc builds a procfs with one process per pid → comm entry
+func fakeProc(t *testing.T, comms map[int]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for pid, comm := range comms {
+		dir := filepath.Join(root, strconv.Itoa(pid))
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Non-process entries are skipped
+	os.WriteFile(filepath.Join(root, "uptime"), []byte("1.00 2.00\n"), 0o644)
+	return root
+}
+
+func TestCheckProcesses(t *testing.T) {
+	root := fakeProc(t, map[int]string{1: "systemd", 812: "nginx", 2001: "postgres: check"})
+	nginxPid := writeFile(t, "nginx.pid", "812\n")
+	deadPid := writeFile(t, "dead.pid", "4242")
+	junkPid := writeFile(t, "junk.pid", "not-a-pid")
+
+	tests := []struct {
+		name       string
+		names      []string
+		pidFiles   []string
+		wantStatus string
+		wantPoints int
+		wantValue  string
+		missing    string
+	}{
+		{"nothing configured", nil, nil, "unknown", 0, "", ""},
+		{"all running", []string{"nginx", " systemd "}, []string{nginxPid}, "healthy", 20, "3/3 running", ""},
+		{"name longer than comm", []string{"postgres: checkpointer"}, nil, "healthy", 20, "1/1 running", ""},
+		{"one missing", []string{"nginx", "redis-server"}, nil, "unhealthy", 10, "1/2 running", "redis-server"},
+		{"dead pidfile", nil, []string{nginxPid, deadPid}, "unhealthy", 10, "1/2 running", deadPid},
+		{"unreadable pidfile", nil, []string{junkPid, filepath.Join(t.TempDir(), "absent.pid")}, "unhealthy", 0, "0/2 running", junkPid},
+	}
+	for _, tt := range tests {
+		check, points := checkProcesses(root, tt.names, tt.pidFiles, 20)
+		if check.Status != tt.wantStatus || points != tt.wantPoints || check.Value != tt.wantValue {
+			t.Errorf("%s: %s %q for %d points, want %s %q for %d", tt.name, check.Status, check.Value, points, tt.wantStatus, tt.wantValue, tt.wantPoints)
+		}
+		if tt.missing != "" && !strings.Contains(check.Message, tt.missing) {
+			t.Errorf("%s: message %q does not name %s", tt.name, check.Message, tt.missing)
+		}
+	}
+}
+
+func TestCheckProcessesFindsTestProcess(t *testing.T) {
+	if _, err := os.Stat("/proc/self/comm"); err != nil {
+		t.Skip("no procfs")
+	}
+	comm, err := os.ReadFile("/proc/self/comm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	self := writeFile(t, "self.pid", strconv.Itoa(os.Getpid()))
+
+	check, points := checkProcesses("/proc", []string{strings.TrimSpace(string(comm))}, []string{self}, 10)
+	if check.Status != "healthy" || points != 10 {
+		t.Errorf("own process: %+v for %d points, want healthy for 10", check, points)
+	}
+
+	check, _ = checkProcesses("/proc", []string{"no-such-daemon"}, nil, 10)
+	if check.Status != "unhealthy" || check.Message != "Not running: no-such-daemon" {
+		t.Errorf("bogus name: %+v, want unhealthy naming it", check)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// requestIDHeader carries the ID the server logs alongside a report
+const requestIDHeader = "X-Request-ID"
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportRequestIDKeptAcrossRetries(t *testing.T) {
+	defer func(sleep func(context.Context, time.Duration) error) { retrySleep = sleep }(retrySleep)
+	retrySleep = func(context.Context, time.Duration) error { return nil }
+
+	var ids []string
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(requestIDHeader))
+		if len(ids)%2 == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}, "--retry-attempts", "3")
+	var logs strings.Builder
+	dc.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	for i := 0; i < 2; i++ {
+		if err := dc.reportStatus(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(ids) != 4 {
+		t.Fatalf("%d requests, want two reports of two attempts each", len(ids))
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(ids[0]) {
+		t.Errorf("request ID %q, want 32 hex digits", ids[0])
+	}
+	if ids[0] != ids[1] || ids[2] != ids[3] || ids[0] == ids[2] {
+		t.Errorf("request IDs %q, want one per report kept across its retry", ids)
+	}
+	for _, id := range []string{ids[0], ids[2]} {
+		if !strings.Contains(logs.String(), "request_id="+id) {
+			t.Errorf("client logs lack request_id=%s:\n%s", id, logs.String())
+		}
+	}
+}
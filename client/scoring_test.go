@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"syscall"
+	"testing"
+)
+
+func TestScoreFactorsPoints(t *testing.T) {
+	factors := scoreFactors{degraded: 0.29, unhealthy: 0.2}
+	tests := []struct {
+		status string
+		weight int
+		want   int
+	}{
+		{"healthy", 25, 25},
+		{"degraded", 100, 29}, // 100*0.29 is 28.999... in floating point
+		{"degraded", 25, 7},
+		{"unhealthy", 25, 5},
+		{"unknown", 25, 0},
+		{"", 25, 0},
+	}
+	for _, tt := range tests {
+		if got := factors.points(tt.status, tt.weight); got != tt.want {
+			t.Errorf("points(%q, %d) = %d, want %d", tt.status, tt.weight, got, tt.want)
+		}
+	}
+}
+
+func TestScoreFactorsFromEnv(t *testing.T) {
+	tests := []struct {
+		degraded, unhealthy string
+		want                scoreFactors
+	}{
+		{"", "", scoreFactors{degraded: 0.6, unhealthy: 0.2}},
+		{"0.9", "0", scoreFactors{degraded: 0.9, unhealthy: 0}},
+		{"1", "0.5", scoreFactors{degraded: 1, unhealthy: 0.5}},
+		// Out of range or unparsable values keep the defaults
+		{"1.5", "-0.1", scoreFactors{degraded: 0.6, unhealthy: 0.2}},
+		{"half", "", scoreFactors{degraded: 0.6, unhealthy: 0.2}},
+	}
+	for _, tt := range tests {
+		t.Setenv("HEALTH_SCORE_DEGRADED_FACTOR", tt.degraded)
+		t.Setenv("HEALTH_SCORE_UNHEALTHY_FACTOR", tt.unhealthy)
+		if got := defaultHealthConfig(t).scoreFactors(); got != tt.want {
+			t.Errorf("factors %q/%q loaded as %+v, want %+v", tt.degraded, tt.unhealthy, got, tt.want)
+		}
+	}
+}
+
+func TestCustomFactorsChangeOverallScore(t *testing.T) {
+	// One path degraded at 90% used, the other unhealthy at 99%
+	fakeStatfs(t, map[string]syscall.Statfs_t{
+		"/":     {Blocks: 100, Bfree: 10, Bavail: 10},
+		"/data": {Blocks: 100, Bfree: 1, Bavail: 1},
+	})
+	tests := []struct {
+		degraded, unhealthy string
+		wantScore           int
+	}{
+		{"", "", 10},       // 25 * (0.6 + 0.2) / 2
+		{"0.8", "0.5", 16}, // 25 * (0.8 + 0.5) / 2 = 16.25
+		{"0", "0", 0},      // only healthy checks earn points
+		{"1", "1", 25},     // status no longer affects the score
+		{"0.6", "0.6", 15}, // unhealthy treated like degraded
+		{"0.25", "0", 3},   // 25 * 0.25 / 2 = 3.125
+	}
+	for _, tt := range tests {
+		t.Setenv("HEALTH_SCORE_DEGRADED_FACTOR", tt.degraded)
+		t.Setenv("HEALTH_SCORE_UNHEALTHY_FACTOR", tt.unhealthy)
+		config := defaultHealthConfig(t)
+		config.disableChecks([]string{"cpu", "memory", "network", "load_average", "process", "gpu", "temperature", "interface"}, discardLogger)
+		config.HealthChecks.Disk.Paths = []string{"/", "/data"}
+
+		metrics := performHealthChecks(context.Background(), config)
+		if len(metrics.Checks) != 2 || metrics.Checks[0].Status != "degraded" || metrics.Checks[1].Status != "unhealthy" {
+			t.Fatalf("checks = %+v, want / degraded and /data unhealthy", metrics.Checks)
+		}
+		if metrics.OverallScore != tt.wantScore {
+			t.Errorf("factors %q/%q: score %d, want %d", tt.degraded, tt.unhealthy, metrics.OverallScore, tt.wantScore)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+)
+
+// selfTestResult is what --selftest prints: the computed metrics, including
+// every HealthCheck, and the status they map to
+type selfTestResult struct {
+	Status        string        `json:"status"`
+	HealthMetrics HealthMetrics `json:"health_metrics"`
+}
+
+// runSelfTest runs the health checks once without contacting the server,
+// writes the result to out as indented JSON and returns the exit code for the
+// resulting status. Configuration warnings are logged through logger.
+func runSelfTest(out io.Writer, logger *slog.Logger) int {
+	healthConfig := loadHealthConfig(logger)
+	metrics := performHealthChecks(context.Background(), healthConfig)
+	result := selfTestResult{
+		Status:        getHostStatus(metrics, healthConfig),
+		HealthMetrics: metrics,
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+
+	return selfTestExitCode(result.Status)
+}
+
+// selfTestExitCode maps a host status to the self-test exit code: 0 healthy,
+// 1 degraded and 2 unhealthy, so the client can serve as a Docker HEALTHCHECK
+func selfTestExitCode(status string) int {
+	switch status {
+	case "healthy":
+		return 0
+	case "degraded":
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+func TestSelfTestExitCode(t *testing.T) {
+	tests := []struct {
+		status string
+		want   int
+	}{
+		{"healthy", 0},
+		{"degraded", 1},
+		{"unhealthy", 2},
+		{"unknown", 2},
+		{"", 2},
+	}
+	for _, tt := range tests {
+		if got := selfTestExitCode(tt.status); got != tt.want {
+			t.Errorf("selfTestExitCode(%q) = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestRunSelfTest(t *testing.T) {
+	t.Setenv("HEALTH_DISABLE", "cpu,memory,network")
+	t.Setenv("HEALTH_SCORE_NORMALIZE", "true")
+	tests := []struct {
+		name       string
+		bfree      uint64
+		wantStatus string
+		wantCode   int
+	}{
+		{"roomy disk", 70, "healthy", 0},
+		{"filling disk", 10, "degraded", 1}, // 60 of 100 after normalizing
+		{"full disk", 1, "unhealthy", 2},
+	}
+	for _, tt := range tests {
+		chdirTemp(t)
+		fakeStatfs(t, map[string]syscall.Statfs_t{"/": {Blocks: 100, Bfree: tt.bfree, Bavail: tt.bfree}})
+
+		var out strings.Builder
+		code := runSelfTest(&out, discardLogger)
+
+		var result selfTestResult
+		if err := json.Unmarshal([]byte(out.String()), &result); err != nil {
+			t.Fatalf("%s: output is not JSON: %v\n%s", tt.name, err, out.String())
+		}
+		if code != tt.wantCode || result.Status != tt.wantStatus {
+			t.Errorf("%s: exit %d status %q, want %d %q", tt.name, code, result.Status, tt.wantCode, tt.wantStatus)
+		}
+		if checks := result.HealthMetrics.Checks; len(checks) != 1 || checks[0].Name != "Disk Usage (/)" {
+			t.Errorf("%s: printed checks %+v, want the disk check alone", tt.name, checks)
+		}
+		if !strings.Contains(out.String(), "\n  \"health_metrics\"") {
+			t.Errorf("%s: output not indented:\n%s", tt.name, out.String())
+		}
+	}
+}
+
+func TestSelfTestProcess(t *testing.T) {
+	var contacted atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contacted.Store(true)
+	}))
+	defer server.Close()
+
+	stdout, stderr, code := runMain(t, "SELFTEST=true", "SERVER_URL="+server.URL, "HEALTH_DISABLE=network")
+
+	// stdout carries the JSON result alone; logs go to stderr
+	var result selfTestResult
+	if err := json.Unmarshal(stdout, &result); err != nil {
+		t.Fatalf("stdout is not the JSON result: %v\nstdout: %s\nstderr: %s", err, stdout, stderr)
+	}
+	if want := selfTestExitCode(result.Status); code != want {
+		t.Errorf("exit code %d for status %q, want %d", code, result.Status, want)
+	}
+	if len(result.HealthMetrics.Checks) == 0 {
+		t.Error("self-test printed no checks")
+	}
+	if contacted.Load() {
+		t.Error("self-test contacted the server")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// srvScheme prefixes a ServerURL naming a DNS SRV record that lists the
+// servers, e.g. dns+srv://_s01._tcp.example.com
+const srvScheme = "dns+srv://"
+
+// lookupSRV resolves the SRV records published under name
+var lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	return records, err
+}
+
+// srvName returns the record name of a dns+srv:// server URL, or "" when
+// serverURL is any other form
+func srvName(serverURL string) string {
+	name, _ := strings.CutPrefix(serverURL, srvScheme)
+	if name == serverURL {
+		return ""
+	}
+	return strings.TrimSuffix(name, "/")
+}
+
+// srvLocator picks the server to report to from an SRV record. The pick is
+// kept until a request to it fails, after which the record is looked up
+// again and another target preferred.
+type srvLocator struct {
+	name   string
+	logger *slog.Logger
+
+	mutex  sync.Mutex
+	target string // host:port in use; empty until resolved and after a failure
+	failed string // target that failed last, avoided while others are listed
+}
+
+// newSRVLocator creates a locator for serverURL, or nil when it names no SRV record
+func newSRVLocator(serverURL string, logger *slog.Logger) *srvLocator {
+	name := srvName(serverURL)
+	if name == "" {
+		return nil
+	}
+	return &srvLocator{name: name, logger: logger}
+}
+
+// baseURL returns the https URL of the current target, looking the record
+// up first when there is none
+func (sl *srvLocator) baseURL(ctx context.Context) (string, error) {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	if sl.target == "" {
+		records, err := lookupSRV(ctx, sl.name)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up SRV record %s: %v", sl.name, err)
+		}
+		picked := pickSRV(records, sl.failed)
+		if picked == nil {
+			return "", fmt.Errorf("SRV record %s lists no servers", sl.name)
+		}
+		sl.target = srvAddress(picked)
+		sl.logger.Info("Selected server from SRV record",
+			"srv_name", sl.name,
+			"target", sl.target,
+			"priority", picked.Priority,
+			"weight", picked.Weight,
+			"records", len(records),
+		)
+	}
+	return "https://" + sl.target, nil
+}
+
+// failover drops the current target so the next request looks the record up
+// again and prefers another server
+func (sl *srvLocator) failover() {
+	if sl == nil {
+		return
+	}
+
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	if sl.target != "" {
+		sl.failed = sl.target
+		sl.target = ""
+	}
+}
+
+// pickSRV chooses a record as RFC 2782 orders them: the lowest priority
+// first, and among equal priorities at random in proportion to weight.
+// Records for avoid (host:port) are skipped unless nothing else is listed.
+// It returns nil when records is empty or only holds the "." no-service
+// target.
+func pickSRV(records []*net.SRV, avoid string) *net.SRV {
+	var candidates []*net.SRV
+	for _, record := range records {
+		if record.Target == "." || record.Target == "" {
+			continue
+		}
+		candidates = append(candidates, record)
+	}
+	if len(candidates) > 1 && avoid != "" {
+		var others []*net.SRV
+		for _, record := range candidates {
+			if srvAddress(record) != avoid {
+				others = append(others, record)
+			}
+		}
+		if len(others) > 0 {
+			candidates = others
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Keep the best priority only
+	best := candidates[0].Priority
+	for _, record := range candidates {
+		best = min(best, record.Priority)
+	}
+	var group []*net.SRV
+	totalWeight := 0
+	for _, record := range candidates {
+		if record.Priority == best {
+			group = append(group, record)
+			totalWeight += int(record.Weight)
+		}
+	}
+
+	// Weight 0 records are only chosen when the whole group has weight 0
+	if totalWeight == 0 {
+		return group[rand.Intn(len(group))]
+	}
+	n := rand.Intn(totalWeight)
+	for _, record := range group {
+		n -= int(record.Weight)
+		if n < 0 {
+			return record
+		}
+	}
+	return group[len(group)-1]
+}
+
+// srvAddress is the host:port a record points at
+func srvAddress(record *net.SRV) string {
+	return net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+}
+
+// serverURL returns the URL of path on the server, resolving ServerURL's SRV
+// record when it names one
+func (dc *S01Client) serverURL(ctx context.Context, path string) (string, error) {
+	if dc.srv == nil {
+		return dc.baseURL + path, nil
+	}
+	base, err := dc.srv.baseURL(ctx)
+	if err != nil {
+		return "", err
+	}
+	return base + path, nil
+}
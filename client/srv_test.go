@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSRV answers SRV lookups with records and counts them
+func fakeSRV(t *testing.T, records ...*net.SRV) (lookups *int) {
+	t.Helper()
+	lookups = new(int)
+	saved := lookupSRV
+	lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+		*lookups++
+		if name != "_s01._tcp.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return records, nil
+	}
+	t.Cleanup(func() { lookupSRV = saved })
+	return lookups
+}
+
+func TestSRVName(t *testing.T) {
+	tests := []struct {
+		serverURL string
+		want      string
+	}{
+		{"dns+srv://_s01._tcp.example.com", "_s01._tcp.example.com"},
+		{"dns+srv://_s01._tcp.example.com/", "_s01._tcp.example.com"},
+		{"dns+srv://", ""},
+		{"https://s01.example.com:8443", ""},
+		{"unix:///run/s01.sock", ""},
+		{"DNS+SRV://_s01._tcp.example.com", ""},
+	}
+	for _, tt := range tests {
+		if got := srvName(tt.serverURL); got != tt.want {
+			t.Errorf("srvName(%q) = %q, want %q", tt.serverURL, got, tt.want)
+		}
+	}
+}
+
+func TestPickSRV(t *testing.T) {
+	a := &net.SRV{Target: "a.example.com.", Port: 8443, Priority: 10, Weight: 1}
+	b := &net.SRV{Target: "b.example.com.", Port: 8443, Priority: 10, Weight: 1}
+	backup := &net.SRV{Target: "backup.example.com.", Port: 9443, Priority: 20, Weight: 100}
+	none := &net.SRV{Target: ".", Priority: 0}
+
+	tests := []struct {
+		name    string
+		records []*net.SRV
+		avoid   string
+		want    []string // acceptable picks; none for nil
+	}{
+		{"lowest priority wins", []*net.SRV{backup, a}, "", []string{"a.example.com:8443"}},
+		{"equal priorities share", []*net.SRV{a, b, backup}, "", []string{"a.example.com:8443", "b.example.com:8443"}},
+		{"failed target avoided", []*net.SRV{a, b, backup}, "a.example.com:8443", []string{"b.example.com:8443"}},
+		{"avoidance falls to the next priority", []*net.SRV{a, backup}, "a.example.com:8443", []string{"backup.example.com:9443"}},
+		{"only target is reused", []*net.SRV{a}, "a.example.com:8443", []string{"a.example.com:8443"}},
+		{"no-service target skipped", []*net.SRV{none, backup}, "", []string{"backup.example.com:9443"}},
+		{"only no-service", []*net.SRV{none}, "", nil},
+		{"empty", nil, "", nil},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			picked := pickSRV(tt.records, tt.avoid)
+			if picked == nil {
+				if tt.want != nil {
+					t.Errorf("%s: picked nothing, want one of %v", tt.name, tt.want)
+				}
+				break
+			}
+			if address := srvAddress(picked); !slices.Contains(tt.want, address) {
+				t.Errorf("%s: picked %s, want one of %v", tt.name, address, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPickSRVFollowsWeights(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "heavy.example.com.", Port: 8443, Priority: 1, Weight: 30},
+		{Target: "light.example.com.", Port: 8443, Priority: 1, Weight: 10},
+		{Target: "idle.example.com.", Port: 8443, Priority: 1, Weight: 0},
+	}
+	counts := make(map[string]int)
+	const picks = 4000
+	for i := 0; i < picks; i++ {
+		counts[pickSRV(records, "").Target]++
+	}
+	if counts["idle.example.com."] != 0 {
+		t.Errorf("weight 0 record picked %d times beside weighted ones", counts["idle.example.com."])
+	}
+	// Expect 3:1; allow generous slack so the test does not flake
+	if heavy := counts["heavy.example.com."]; heavy < picks*65/100 || heavy > picks*85/100 {
+		t.Errorf("weight 30 of 40 picked %d of %d times, want about 75%%", heavy, picks)
+	}
+
+	zero := []*net.SRV{{Target: "x.", Port: 1}, {Target: "y.", Port: 1}}
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		seen[pickSRV(zero, "").Target] = true
+	}
+	if !seen["x."] || !seen["y."] {
+		t.Errorf("all-zero weights picked only %v, want both", seen)
+	}
+}
+
+func TestSRVLocatorResolvesAndFailsOver(t *testing.T) {
+	lookups := fakeSRV(t,
+		&net.SRV{Target: "s01-a.example.com.", Port: 8443, Priority: 10, Weight: 5},
+		&net.SRV{Target: "s01-b.example.com.", Port: 8443, Priority: 20, Weight: 5},
+	)
+	sl := newSRVLocator("dns+srv://_s01._tcp.example.com", discardLogger)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if base, err := sl.baseURL(ctx); err != nil || base != "https://s01-a.example.com:8443" {
+			t.Fatalf("baseURL = %q, %v; want the priority 10 server", base, err)
+		}
+	}
+	if *lookups != 1 {
+		t.Errorf("%d lookups for three requests, want the pick kept", *lookups)
+	}
+
+	sl.failover()
+	if base, err := sl.baseURL(ctx); err != nil || base != "https://s01-b.example.com:8443" {
+		t.Errorf("after a failure baseURL = %q, %v; want the other server", base, err)
+	}
+	if *lookups != 2 {
+		t.Errorf("%d lookups after a failover, want the record looked up again", *lookups)
+	}
+	// With b failing too, a recovers its place
+	sl.failover()
+	if base, _ := sl.baseURL(ctx); base != "https://s01-a.example.com:8443" {
+		t.Errorf("after both failed baseURL = %q, want the priority 10 server again", base)
+	}
+
+	missing := newSRVLocator("dns+srv://_s01._tcp.missing.example.com", discardLogger)
+	if _, err := missing.baseURL(ctx); err == nil || !strings.Contains(err.Error(), "failed to look up SRV record") {
+		t.Errorf("lookup failure = %v", err)
+	}
+	if newSRVLocator("https://s01.example.com", discardLogger) != nil {
+		t.Error("locator created for a plain URL")
+	}
+	var plain *srvLocator
+	plain.failover() // clients without SRV call it too
+}
+
+func TestReportFailsOverToNextSRVTarget(t *testing.T) {
+	defer func(sleep func(context.Context, time.Duration) error) { retrySleep = sleep }(retrySleep)
+	retrySleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	var failingHits, healthyHits int
+	failing := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingHits++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyHits++
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer healthy.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	srvRecord := func(serverURL string, priority uint16) *net.SRV {
+		parsed, _ := url.Parse(serverURL)
+		port, _ := strconv.Atoi(parsed.Port())
+		return &net.SRV{Target: parsed.Hostname() + ".", Port: uint16(port), Priority: priority, Weight: 1}
+	}
+
+	tests := []struct {
+		name        string
+		first       *net.SRV
+		wantFailing int
+	}{
+		{"server error", srvRecord(failing.URL, 1), 1},
+		{"connection refused", srvRecord(down.URL, 1), 0},
+	}
+	for _, tt := range tests {
+		failingHits, healthyHits = 0, 0
+		lookups := fakeSRV(t, tt.first, srvRecord(healthy.URL, 2))
+
+		dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("%s: report sent to the configured socket", tt.name)
+		}, "--retry-attempts", "3", "--breaker-threshold", "0")
+		dc.srv = newSRVLocator("dns+srv://_s01._tcp.example.com", discardLogger)
+		dc.httpClient = healthy.Client() // trusts the certificate both test servers share
+
+		if err := dc.reportStatus(context.Background()); err != nil {
+			t.Errorf("%s: report = %v, want it delivered to the second target", tt.name, err)
+		}
+		if failingHits != tt.wantFailing || healthyHits != 1 || *lookups != 2 {
+			t.Errorf("%s: %d reports to the failing server and %d to the healthy one after %d lookups, want %d and 1 after 2",
+				tt.name, failingHits, healthyHits, *lookups, tt.wantFailing)
+		}
+		// The next report stays on the server that worked
+		dc.reportStatus(context.Background())
+		if healthyHits != 2 || *lookups != 2 {
+			t.Errorf("%s: second report left the working server (%d hits, %d lookups)", tt.name, healthyHits, *lookups)
+		}
+	}
+}
+
+func TestLoadConfigRejectsEmptySRVName(t *testing.T) {
+	_, err := loadTestConfig(t, "--server-url", "dns+srv://")
+	if err == nil || !strings.Contains(err.Error(), "names no SRV record") {
+		t.Errorf("dns+srv:// without a name = %v, want it refused", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Sources read for host inventory information
+var (
+	kernelReleasePath = "/proc/sys/kernel/osrelease"
+	procVersionPath   = "/proc/version"
+	osReleasePaths    = []string{"/etc/os-release", "/usr/lib/os-release"}
+)
+
+// SystemInfo describes the host's kernel, OS release, and CPU architecture
+type SystemInfo struct {
+	KernelVersion string
+	OSRelease     string
+	Arch          string
+}
+
+// getSystemInfo collects inventory details; fields that can't be read are left empty
+func getSystemInfo() SystemInfo {
+	return SystemInfo{
+		KernelVersion: getKernelVersion(),
+		OSRelease:     getOSRelease(),
+		Arch:          runtime.GOARCH,
+	}
+}
+
+// getKernelVersion returns the running kernel release, e.g. "6.1.0-18-amd64"
+func getKernelVersion() string {
+	if data, err := os.ReadFile(kernelReleasePath); err == nil {
+		if release := strings.TrimSpace(string(data)); release != "" {
+			return release
+		}
+	}
+
+	// /proc/version reads "Linux version <release> (...)"
+	if data, err := os.ReadFile(procVersionPath); err == nil {
+		return parseProcVersion(string(data))
+	}
+	return ""
+}
+
+// parseProcVersion extracts the kernel release from /proc/version contents
+func parseProcVersion(content string) string {
+	fields := strings.Fields(content)
+	if len(fields) >= 3 && fields[1] == "version" {
+		return fields[2]
+	}
+	return ""
+}
+
+// getOSRelease returns the distribution name from os-release, e.g. "Ubuntu 22.04.4 LTS"
+func getOSRelease() string {
+	for _, path := range osReleasePaths {
+		if data, err := os.ReadFile(path); err == nil {
+			return parseOSRelease(string(data))
+		}
+	}
+	return ""
+}
+
+// parseOSRelease picks PRETTY_NAME from os-release contents, falling back to NAME and VERSION_ID
+func parseOSRelease(content string) string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		values[key] = strings.Trim(value, `"'`)
+	}
+
+	if pretty := values["PRETTY_NAME"]; pretty != "" {
+		return pretty
+	}
+	return strings.TrimSpace(values["NAME"] + " " + values["VERSION_ID"])
+}
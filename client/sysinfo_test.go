@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseProcVersion(t *testing.T) {
+	tests := map[string]string{
+		"Linux version 6.1.0-18-amd64 (debian-kernel@lists.debian.org) (gcc-12) #1 SMP": "6.1.0-18-amd64",
+		"Linux version 5.15.0-1051-aws\n":                                               "5.15.0-1051-aws",
+		"Linux 6.1.0":                                                                   "",
+		"":                                                                              "",
+	}
+	for content, want := range tests {
+		if got := parseProcVersion(content); got != want {
+			t.Errorf("parseProcVersion(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestParseOSRelease(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"pretty name", "NAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nPRETTY_NAME=\"Ubuntu 22.04.4 LTS\"\n", "Ubuntu 22.04.4 LTS"},
+		{"name and version", "NAME=Alpine Linux\nVERSION_ID=3.19.1\n", "Alpine Linux 3.19.1"},
+		{"single quotes", "PRETTY_NAME='Debian GNU/Linux 12 (bookworm)'", "Debian GNU/Linux 12 (bookworm)"},
+		{"comments and blanks", "# generated\n\n  NAME=\"Fedora Linux\"  \nVERSION_ID=40", "Fedora Linux 40"},
+		{"name only", "NAME=NixOS", "NixOS"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := parseOSRelease(tt.content); got != tt.want {
+			t.Errorf("%s: parseOSRelease = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetSystemInfoFallbacks(t *testing.T) {
+	defer func(kernel, version string, osRelease []string) {
+		kernelReleasePath, procVersionPath, osReleasePaths = kernel, version, osRelease
+	}(kernelReleasePath, procVersionPath, osReleasePaths)
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	kernelReleasePath = writeFile(t, "osrelease", "  \n")
+	procVersionPath = writeFile(t, "version", "Linux version 6.8.0-31-generic (buildd@lcy02) #31-Ubuntu SMP")
+	osReleasePaths = []string{missing, writeFile(t, "os-release", `PRETTY_NAME="Ubuntu 24.04 LTS"`)}
+
+	info := getSystemInfo()
+	want := SystemInfo{KernelVersion: "6.8.0-31-generic", OSRelease: "Ubuntu 24.04 LTS", Arch: runtime.GOARCH}
+	if info != want {
+		t.Errorf("with fallbacks: %+v, want %+v", info, want)
+	}
+
+	kernelReleasePath, procVersionPath, osReleasePaths = missing, missing, []string{missing}
+	if info := getSystemInfo(); info.KernelVersion != "" || info.OSRelease != "" || info.Arch == "" {
+		t.Errorf("with nothing readable: %+v, want only Arch", info)
+	}
+}
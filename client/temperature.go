@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hwmonClassPath holds the kernel's hardware monitoring devices
+var hwmonClassPath = "/sys/class/hwmon"
+
+// errNoSensors is returned when no hwmon temperature sensor can be read
+var errNoSensors = errors.New("no temperature sensors found")
+
+// sensorReading is the temperature of one hwmon sensor
+type sensorReading struct {
+	device  string // hwmon driver name, e.g. "coretemp" or "k10temp"
+	celsius float64
+}
+
+// readHwmonTemperatures reads every temp*_input under hwmonRoot. When
+// preferred names a device that has readings, only that device's sensors are
+// returned, so unrelated sensors (NVMe, ACPI zones) do not drive the check.
+func readHwmonTemperatures(hwmonRoot, preferred string) ([]sensorReading, error) {
+	devices, _ := filepath.Glob(filepath.Join(hwmonRoot, "hwmon*"))
+
+	var all, matched []sensorReading
+	for _, device := range devices {
+		name := filepath.Base(device)
+		if data, err := os.ReadFile(filepath.Join(device, "name")); err == nil {
+			name = strings.TrimSpace(string(data))
+		}
+
+		inputs, _ := filepath.Glob(filepath.Join(device, "temp*_input"))
+		for _, input := range inputs {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				continue
+			}
+			milliCelsius, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				continue
+			}
+			reading := sensorReading{device: name, celsius: float64(milliCelsius) / 1000}
+			all = append(all, reading)
+			if name == preferred {
+				matched = append(matched, reading)
+			}
+		}
+	}
+
+	if len(matched) > 0 {
+		return matched, nil
+	}
+	if len(all) == 0 {
+		return nil, errNoSensors
+	}
+	return all, nil
+}
+
+// checkTemperature scores the hottest sensor against the thresholds. A host
+// without readable sensors gets an "unknown" check worth zero points.
+func checkTemperature(readings []sensorReading, err error, healthyThreshold, degradedThreshold float64, weight int, factors scoreFactors) (HealthCheck, int) {
+	check := HealthCheck{
+		Name: "CPU Temperature",
+	}
+	if err != nil {
+		check.Status = "unknown"
+		check.Message = fmt.Sprintf("temperature unavailable: %v", err)
+		return check, 0
+	}
+
+	hottest := readings[0]
+	for _, reading := range readings[1:] {
+		if reading.celsius > hottest.celsius {
+			hottest = reading
+		}
+	}
+	check.Value = fmt.Sprintf("%.1f°C (%s)", hottest.celsius, hottest.device)
+
+	switch {
+	case hottest.celsius < healthyThreshold:
+		check.Status = "healthy"
+		return check, weight
+	case hottest.celsius < degradedThreshold:
+		check.Status = "degraded"
+		check.Message = "High CPU temperature"
+		return check, factors.points(check.Status, weight)
+	default:
+		check.Status = "unhealthy"
+		check.Message = "Critical CPU temperature, likely throttling"
+		return check, factors.points(check.Status, weight)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeHwmon builds a /sys/class/hwmon tree from device directory to name
+// and temp*_input contents, and returns its root
+func writeHwmon(t *testing.T, devices map[string]map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for device, files := range devices {
+		dir := filepath.Join(root, device)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func TestReadHwmonTemperatures(t *testing.T) {
+	root := writeHwmon(t, map[string]map[string]string{
+		"hwmon0": {"name": "acpitz\n", "temp1_input": "45000\n"},
+		"hwmon1": {"name": "coretemp\n", "temp1_input": "62000\n", "temp2_input": "71500\n", "temp2_label": "Core 0\n", "temp3_input": "N/A\n"},
+		"hwmon2": {"name": "nvme\n", "temp1_input": "88000\n"},
+		"hwmon3": {"temp1_input": "30000\n"},
+	})
+	tests := []struct {
+		preferred   string
+		wantCount   int
+		wantHottest sensorReading
+	}{
+		{"coretemp", 2, sensorReading{"coretemp", 71.5}},
+		{"", 5, sensorReading{"nvme", 88}},
+		{"k10temp", 5, sensorReading{"nvme", 88}},
+		{"hwmon3", 1, sensorReading{"hwmon3", 30}},
+	}
+	for _, tt := range tests {
+		readings, err := readHwmonTemperatures(root, tt.preferred)
+		if err != nil {
+			t.Fatalf("preferring %q: %v", tt.preferred, err)
+		}
+		hottest := readings[0]
+		for _, reading := range readings {
+			if reading.celsius > hottest.celsius {
+				hottest = reading
+			}
+		}
+		if len(readings) != tt.wantCount || hottest != tt.wantHottest {
+			t.Errorf("preferring %q: %d readings, hottest %+v; want %d, %+v", tt.preferred, len(readings), hottest, tt.wantCount, tt.wantHottest)
+		}
+	}
+
+	empty := writeHwmon(t, map[string]map[string]string{"hwmon0": {"name": "acpi_fan\n", "fan1_input": "1200\n"}})
+	if _, err := readHwmonTemperatures(empty, ""); !errors.Is(err, errNoSensors) {
+		t.Errorf("tree without temperature sensors = %v, want errNoSensors", err)
+	}
+}
+
+func TestCheckTemperature(t *testing.T) {
+	factors := scoreFactors{degraded: 0.5, unhealthy: 0.25}
+	tests := []struct {
+		readings   []sensorReading
+		err        error
+		wantStatus string
+		wantPoints int
+	}{
+		{[]sensorReading{{"coretemp", 55}, {"coretemp", 61.2}}, nil, "healthy", 20},
+		{[]sensorReading{{"coretemp", 55}, {"coretemp", 84}}, nil, "degraded", 10},
+		{[]sensorReading{{"k10temp", 90}}, nil, "unhealthy", 5},
+		{nil, errNoSensors, "unknown", 0},
+	}
+	for _, tt := range tests {
+		check, points := checkTemperature(tt.readings, tt.err, 80, 90, 20, factors)
+		if check.Name != "CPU Temperature" || check.Status != tt.wantStatus || points != tt.wantPoints {
+			t.Errorf("%+v: %+v worth %d, want %s worth %d", tt.readings, check, points, tt.wantStatus, tt.wantPoints)
+		}
+	}
+
+	check, _ := checkTemperature([]sensorReading{{"acpitz", 40}, {"coretemp", 61.5}}, nil, 80, 90, 20, factors)
+	if check.Value != "61.5°C (coretemp)" {
+		t.Errorf("value = %q, want the hottest sensor in Celsius with its device", check.Value)
+	}
+}
+
+func TestHealthChecksIncludeTemperature(t *testing.T) {
+	saved := hwmonClassPath
+	defer func() { hwmonClassPath = saved }()
+	hwmonClassPath = writeHwmon(t, map[string]map[string]string{
+		"hwmon0": {"name": "coretemp\n", "temp1_input": "93000\n"},
+		"hwmon1": {"name": "nvme\n", "temp1_input": "40000\n"},
+	})
+
+	t.Setenv("HEALTH_TEMP_ENABLED", "true")
+	t.Setenv("HEALTH_TEMP_SENSOR", "nvme")
+	config := defaultHealthConfig(t)
+	config.disableChecks([]string{"cpu", "memory", "disk", "network", "load_average", "process", "gpu", "interface"}, discardLogger)
+
+	metrics := performHealthChecks(context.Background(), config)
+	if len(metrics.Checks) != 1 || metrics.Checks[0].Status != "healthy" || metrics.Checks[0].Value != "40.0°C (nvme)" {
+		t.Errorf("preferring nvme: checks %+v", metrics.Checks)
+	}
+
+	config.HealthChecks.Temperature.Sensor = ""
+	metrics = performHealthChecks(context.Background(), config)
+	if len(metrics.Checks) != 1 || metrics.Checks[0].Status != "unhealthy" {
+		t.Errorf("hottest of all sensors: checks %+v, want the 93°C core unhealthy", metrics.Checks)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/tls"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/management/s01-shared"
+)
+
+func TestSetupTLSConfigOptions(t *testing.T) {
+	certFile, keyFile := writeSelfSigned(t, t.TempDir(), "web-w1", time.Now().Add(90*24*time.Hour))
+	tests := []struct {
+		minVersion, suites string
+		wantVersion        uint16
+		wantSuites         []uint16
+		wantErr            string
+	}{
+		{"1.2", "", tls.VersionTLS12, shared.DefaultCipherSuites, ""},
+		{"1.3", "", tls.VersionTLS13, shared.DefaultCipherSuites, ""},
+		{"1.2", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_AES_256_GCM_SHA384",
+			tls.VersionTLS12, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, ""},
+		{"1.3", "TLS_CHACHA20_POLY1305_SHA256", tls.VersionTLS13, nil, ""},
+		{"1.0", "", 0, nil, "unsupported tls_min_version"},
+		{"1.2", "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256", 0, nil, "insecure"},
+		{"1.2", "AES128-GCM-SHA256", 0, nil, "unknown cipher suite"},
+		{"1.2", "TLS_AES_128_GCM_SHA256", 0, nil, "names no TLS 1.2 suite"},
+	}
+	for _, tt := range tests {
+		config := &Config{
+			CertFile:      certFile,
+			KeyFile:       keyFile,
+			CACertFile:    certFile,
+			TLSMinVersion: tt.minVersion,
+			CipherSuites:  tt.suites,
+		}
+		tlsConfig, err := setupTLSConfig(config, discardLogger)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q %q: err = %v, want %q", tt.minVersion, tt.suites, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %q: %v", tt.minVersion, tt.suites, err)
+			continue
+		}
+		if tlsConfig.MinVersion != tt.wantVersion || !reflect.DeepEqual(tlsConfig.CipherSuites, tt.wantSuites) {
+			t.Errorf("%q %q: tls.Config has version %x suites %x, want %x %x",
+				tt.minVersion, tt.suites, tlsConfig.MinVersion, tlsConfig.CipherSuites, tt.wantVersion, tt.wantSuites)
+		}
+	}
+}
+
+func TestLoadConfigValidatesTLSOptions(t *testing.T) {
+	if _, err := loadTestConfig(t, "--server-url", "unix:///run/s01.sock", "--tls-min-version", "1.3", "--cipher-suites", "TLS_AES_128_GCM_SHA256"); err != nil {
+		t.Errorf("TLS 1.3 with a 1.3 suite = %v", err)
+	}
+	if _, err := loadTestConfig(t, "--server-url", "unix:///run/s01.sock", "--cipher-suites", "TLS_NOT_A_SUITE"); err == nil || !strings.Contains(err.Error(), "TLS_NOT_A_SUITE") {
+		t.Errorf("unknown cipher suite = %v, want it refused at startup", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/management/s01-shared"
+)
+
+func TestReportSpanPropagatesToServer(t *testing.T) {
+	defer func(sleep func(context.Context, time.Duration) error) { retrySleep = sleep }(retrySleep)
+	retrySleep = func(context.Context, time.Duration) error { return nil }
+
+	var exported []shared.OTLPSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var export shared.OTLPExport
+		json.NewDecoder(r.Body).Decode(&export)
+		for _, resource := range export.ResourceSpans {
+			if name := resource.Resource.Attributes[0].Value.StringValue; name != "s01-client" {
+				t.Errorf("spans exported as service %q", name)
+			}
+			for _, scope := range resource.ScopeSpans {
+				exported = append(exported, scope.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	var sent []string
+	failures := 1
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Header.Get(shared.TraceparentHeader))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"success": true}`))
+	}, "--otlp-endpoint", collector.URL, "--retry-attempts", "2", "--breaker-threshold", "0")
+
+	if err := dc.reportStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	dc.tracer.Flush()
+
+	if len(exported) != 1 {
+		t.Fatalf("%d spans exported, want one for the report", len(exported))
+	}
+	s := exported[0]
+	if s.Name != "report status" || s.Kind != shared.SpanKindClient || s.ParentSpanID != "" || s.Status != nil {
+		t.Errorf("span = %+v, want a successful root client span", s)
+	}
+	// Every attempt carries the report span, so the server span is its child
+	want := "00-" + s.TraceID + "-" + s.SpanID + "-01"
+	if len(sent) != 2 || sent[0] != want || sent[1] != want {
+		t.Errorf("traceparent headers %q, want %q on both attempts", sent, want)
+	}
+	attributes := make(map[string]string)
+	for _, attr := range s.Attributes {
+		attributes[attr.Key] = attr.Value.StringValue
+	}
+	if attributes["service_name"] != "test-service" || attributes["instance_name"] == "" || attributes["status"] == "" {
+		t.Errorf("attributes = %v, want service_name, instance_name and status", attributes)
+	}
+
+	// A report that never gets through ends its span as failed
+	failures = 2
+	exported = nil
+	dc.reportStatus(context.Background())
+	dc.tracer.Flush()
+	if len(exported) != 1 || exported[0].Status == nil || exported[0].Status.Code != shared.OTLPStatusError {
+		t.Errorf("failed report spans = %+v, want one with an error status", exported)
+	}
+}
+
+func TestReportWithoutTracer(t *testing.T) {
+	var headers []string
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get(shared.TraceparentHeader))
+		w.Write([]byte(`{"success": true}`))
+	})
+	if dc.tracer != nil {
+		t.Fatal("tracer created without an OTLP endpoint")
+	}
+	if err := dc.reportStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 1 || headers[0] != "" {
+		t.Errorf("traceparent headers %q, want none without a tracer", headers)
+	}
+}
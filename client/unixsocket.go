@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// unixSocketScheme prefixes a ServerURL naming the server's Unix domain
+// socket, e.g. unix:///run/s01/s01.sock
+const unixSocketScheme = "unix://"
+
+// unixSocketBaseURL is the base of request URLs sent over a Unix socket. The
+// host only fills in the Host header; every connection dials the socket.
+const unixSocketBaseURL = "http://localhost"
+
+// unixSocketPath returns the socket path of a unix:// server URL, or "" when
+// serverURL is an ordinary http(s) URL
+func unixSocketPath(serverURL string) string {
+	path, _ := strings.CutPrefix(serverURL, unixSocketScheme)
+	if path == serverURL {
+		return ""
+	}
+	return path
+}
+
+// serverBaseURL is what request paths are appended to for serverURL
+func serverBaseURL(serverURL string) string {
+	if unixSocketPath(serverURL) != "" {
+		return unixSocketBaseURL
+	}
+	return serverURL
+}
+
+// newUnixSocketTransport sends every request over the Unix socket at path as
+// plain HTTP; the socket's file permissions take the place of mTLS
+func newUnixSocketTransport(path string) *http.Transport {
+	var dialer net.Dialer
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		},
+		MaxIdleConns:    10,
+		IdleConnTimeout: 30 * time.Second,
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUnixSocketPath(t *testing.T) {
+	tests := []struct {
+		serverURL string
+		wantPath  string
+		wantBase  string
+	}{
+		{"unix:///run/s01/s01.sock", "/run/s01/s01.sock", unixSocketBaseURL},
+		{"unix://relative.sock", "relative.sock", unixSocketBaseURL},
+		{"unix://", "", "unix://"},
+		{"https://s01-server:8443", "", "https://s01-server:8443"},
+		{"http://unix:8080", "", "http://unix:8080"},
+	}
+	for _, tt := range tests {
+		if got := unixSocketPath(tt.serverURL); got != tt.wantPath {
+			t.Errorf("unixSocketPath(%q) = %q, want %q", tt.serverURL, got, tt.wantPath)
+		}
+		if got := serverBaseURL(tt.serverURL); got != tt.wantBase {
+			t.Errorf("serverBaseURL(%q) = %q, want %q", tt.serverURL, got, tt.wantBase)
+		}
+	}
+}
+
+func TestLoadConfigUnixSocket(t *testing.T) {
+	// None of the certificate files exist
+	t.Setenv("CERT_FILE", "/nonexistent/client.crt")
+	tests := []struct {
+		serverURL string
+		wantErr   string
+	}{
+		{"unix:///run/s01/s01.sock", ""},
+		{"unix://", "names no socket path"},
+		{"https://s01-server:8443", "client.crt"},
+	}
+	for _, tt := range tests {
+		_, err := loadTestConfig(t, "--server-url", tt.serverURL)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.serverURL, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want it to mention %q", tt.serverURL, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReportOverUnixSocket(t *testing.T) {
+	type request struct {
+		path, host string
+		tls        bool
+	}
+	var got []request
+	dc := socketClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, request{r.URL.Path, r.Host, r.TLS != nil})
+		w.Write([]byte(`{"success": true}`))
+	})
+	if err := dc.reportStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != (request{"/api/v1/report", "localhost", false}) {
+		t.Errorf("requests over the socket = %+v, want one plain HTTP report", got)
+	}
+
+	// Nothing listening on the socket is an ordinary delivery failure
+	config, err := loadTestConfig(t, "--server-url", "unix:///nonexistent/s01.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.RetryAttempts = 1
+	dead, err := NewS01Client(config, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead.healthConfig = dc.healthConfig
+	if err := dead.reportStatus(context.Background()); err == nil {
+		t.Error("report to a missing socket succeeded")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeScore(t *testing.T) {
+	tests := []struct {
+		score, total, want int
+	}{
+		{80, 100, 80},
+		{80, 80, 100},
+		{40, 80, 50},
+		{65, 130, 50},
+		{1, 3, 33},
+		{2, 3, 67}, // rounded, not truncated
+		{0, 80, 0},
+		{12, 0, 12}, // nothing weighted: left alone
+	}
+	for _, tt := range tests {
+		if got := normalizeScore(tt.score, tt.total); got != tt.want {
+			t.Errorf("normalizeScore(%d, %d) = %d, want %d", tt.score, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestLoadHealthConfigChecksWeightTotal(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		normalize string
+		wantLevel string
+		wantMsg   string
+	}{
+		{"defaults", "", "", "", ""},
+		{"sum to 80", `{"health_checks": {"network": {"weight": 5}}}`, "", "WARN", "total_weight=80"},
+		{"sum to 130", `{"health_checks": {"load_average": {"enabled": true, "weight": 30}}}`, "", "WARN", "total_weight=130"},
+		{"custom checks count", `{"custom_checks": [{"name": "Queue", "command": ["true"], "weight": 20}]}`, "", "WARN", "total_weight=120"},
+		{"disabled checks do not", `{"health_checks": {"disk": {"enabled": false}, "load_average": {"weight": 25}}}`, "", "WARN", "total_weight=75"},
+		{"rebalanced to 100", `{"health_checks": {"cpu": {"weight": 10}, "load_average": {"enabled": true, "weight": 15}}}`, "", "", ""},
+		{"normalized", `{"health_checks": {"network": {"weight": 5}}}`, "true", "INFO", "scaling the overall score"},
+		{"nothing weighted", `{"health_checks": {"cpu": {"enabled": false}, "memory": {"enabled": false}, "disk": {"enabled": false}, "network": {"enabled": false}}}`,
+			"", "WARN", "No weighted health checks enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HEALTH_SCORE_NORMALIZE", tt.normalize)
+			chdirTemp(t)
+			if tt.file != "" {
+				if err := os.WriteFile("health-config.json", []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var logs strings.Builder
+			loadHealthConfig(slog.New(slog.NewTextHandler(&logs, nil)))
+
+			weightLines := ""
+			for _, line := range strings.Split(logs.String(), "\n") {
+				if strings.Contains(line, "weight") || strings.Contains(line, "No weighted") {
+					weightLines += line
+				}
+			}
+			if tt.wantLevel == "" {
+				if weightLines != "" {
+					t.Errorf("logged %q for weights summing to 100", weightLines)
+				}
+				return
+			}
+			if !strings.Contains(weightLines, "level="+tt.wantLevel) || !strings.Contains(weightLines, tt.wantMsg) {
+				t.Errorf("logged %q, want %s mentioning %q", weightLines, tt.wantLevel, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestNormalizedScoreUsesThresholdRange(t *testing.T) {
+	// Only two custom checks are scored: 30 healthy and 50 unhealthy, 80 in all
+	config := `{
+  "health_checks": {"cpu": {"enabled": false}, "memory": {"enabled": false}, "disk": {"enabled": false}, "network": {"enabled": false}},
+  "scoring": {"unhealthy_factor": 0.2},
+  "custom_checks": [
+    {"name": "Cache", "command": ["sh", "-c", "exit 0"], "weight": 30},
+    {"name": "Queue", "command": ["sh", "-c", "exit 2"], "weight": 50}
+  ]
+}`
+	tests := []struct {
+		normalize string
+		want      int
+	}{
+		{"", 40},     // 30 + 50 * 0.2
+		{"true", 50}, // 40 of 80
+	}
+	for _, tt := range tests {
+		t.Setenv("HEALTH_SCORE_NORMALIZE", tt.normalize)
+		chdirTemp(t)
+		if err := os.WriteFile("health-config.json", []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		metrics := performHealthChecks(context.Background(), loadHealthConfig(discardLogger))
+		if metrics.OverallScore != tt.want {
+			t.Errorf("normalize %q: score %d, want %d (breakdown %+v)", tt.normalize, metrics.OverallScore, tt.want, metrics.ScoreBreakdown)
+		}
+	}
+}
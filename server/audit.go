@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEvent records one change of a host's status. OldStatus is empty the
+// first time the server sees a host.
+type AuditEvent struct {
+	ServiceName  string    `json:"service_name"`
+	InstanceName string    `json:"instance_name"`
+	OldStatus    string    `json:"old_status"`
+	NewStatus    string    `json:"new_status"`
+	ClientCN     string    `json:"client_cn"` // empty for transitions the server makes itself, e.g. to lost
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// AuditResponse represents the response from the audit endpoint
+type AuditResponse struct {
+	Events []AuditEvent `json:"events"`
+	Total  int          `json:"total"`
+}
+
+// auditLog keeps the most recent status transitions in a fixed-size ring and
+// optionally appends every one to a JSON-lines file, which outlives the ring
+// and is replayed into it at startup
+type auditLog struct {
+	logger *slog.Logger
+
+	mutex  sync.Mutex
+	events []AuditEvent // ring of at most cap(events) entries
+	next   int          // ring slot the next event goes to once full
+	file   *os.File     // nil when no file is configured
+}
+
+// newAuditLog creates an audit log holding up to size transitions in memory,
+// restoring them from path when it is set. A nil log, returned when size is
+// 0, records nothing.
+func newAuditLog(size int, path string, logger *slog.Logger) (*auditLog, error) {
+	if size == 0 {
+		return nil, nil
+	}
+
+	al := &auditLog{
+		logger: logger,
+		events: make([]AuditEvent, 0, size),
+	}
+	if path == "" {
+		return al, nil
+	}
+
+	restored, err := al.replay(path)
+	if err != nil {
+		return nil, err
+	}
+	al.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if restored > 0 {
+		logger.Info("Audit log restored", "path", path, "events", restored, "kept", len(al.events))
+	}
+	return al, nil
+}
+
+// replay loads a JSON-lines audit file into the ring. A missing file is not
+// an error; undecodable lines are skipped.
+func (al *auditLog) replay(path string) (int, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	restored := 0
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			al.logger.Warn("Skipping unreadable audit event", "path", path, "line", line, "error", err)
+			continue
+		}
+		al.append(event)
+		restored++
+	}
+	if err := scanner.Err(); err != nil {
+		return restored, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return restored, nil
+}
+
+// observe records a change of a host's current status as returned by
+// storage, unless the status stayed the same
+func (al *auditLog) observe(serviceName, instanceName string, change StatusChange, clientCN string, at time.Time) {
+	if al == nil || change.Previous == change.Current {
+		return
+	}
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	event := AuditEvent{
+		ServiceName:  serviceName,
+		InstanceName: instanceName,
+		OldStatus:    change.Previous,
+		NewStatus:    change.Current,
+		ClientCN:     clientCN,
+		Timestamp:    at,
+	}
+	al.append(event)
+	al.persist(event)
+}
+
+// append adds an event to the ring, overwriting the oldest once it is full;
+// the caller must hold al.mutex or have sole use of al
+func (al *auditLog) append(event AuditEvent) {
+	if len(al.events) < cap(al.events) {
+		al.events = append(al.events, event)
+		return
+	}
+	al.events[al.next] = event
+	al.next = (al.next + 1) % len(al.events)
+}
+
+// persist appends an event to the audit file; the caller must hold al.mutex.
+// The event is already held in memory, so failures are logged, not returned.
+func (al *auditLog) persist(event AuditEvent) {
+	if al.file == nil {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		al.logger.Error("Failed to encode audit event", "error", err)
+		return
+	}
+	if _, err := al.file.Write(append(data, '\n')); err != nil {
+		al.logger.Error("Failed to write audit event", "path", al.file.Name(), "error", err)
+	}
+}
+
+// query returns the held events for serviceName (any service when empty)
+// with timestamps in [since, until), oldest first. Zero bounds are open.
+func (al *auditLog) query(serviceName string, since, until time.Time) []AuditEvent {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	events := make([]AuditEvent, 0)
+	for i := range al.events {
+		event := al.events[(al.next+i)%len(al.events)]
+		if serviceName != "" && event.ServiceName != serviceName {
+			continue
+		}
+		if !since.IsZero() && event.Timestamp.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !event.Timestamp.Before(until) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// Close flushes and closes the audit file
+func (al *auditLog) Close() error {
+	if al == nil {
+		return nil
+	}
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	if al.file == nil {
+		return nil
+	}
+	err := al.file.Sync()
+	if closeErr := al.file.Close(); err == nil {
+		err = closeErr
+	}
+	al.file = nil
+	return err
+}
+
+// getAudit returns recorded status transitions, filtered by ?service= and a
+// ?since=/?until= RFC 3339 time range
+func (ds *S01Server) getAudit(w http.ResponseWriter, r *http.Request) {
+	if ds.audit == nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Audit log is disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	var bounds [2]time.Time
+	for i, name := range []string{"since", "until"} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid "+name+" value, expected RFC 3339")
+			return
+		}
+		bounds[i] = parsed
+	}
+
+	events := ds.audit.query(query.Get("service"), bounds[0], bounds[1])
+	ds.requestLogger(r).Info("Audit log request",
+		"events", len(events),
+		"client_cn", getClientCN(r),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditResponse{Events: events, Total: len(events)})
+}
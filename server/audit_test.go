@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// transitions renders events as "instance:old>new" for comparison
+func transitions(events []AuditEvent) string {
+	var out []string
+	for _, event := range events {
+		out = append(out, event.InstanceName+":"+event.OldStatus+">"+event.NewStatus)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestAuditRecordsSmoothedStatus(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.StatusSmoothing = smoothingMajority
+		config.SmoothingWindow = 3
+	})
+
+	for _, status := range []string{"healthy", "healthy", "unhealthy", "healthy", "unhealthy", "unhealthy"} {
+		mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: status})
+	}
+
+	var got []string
+	for _, event := range ds.audit.query("web", time.Time{}, time.Time{}) {
+		got = append(got, event.OldStatus+">"+event.NewStatus)
+	}
+	// The flapping reports only show once the majority turns unhealthy
+	if want := ">healthy,healthy>unhealthy"; strings.Join(got, ",") != want {
+		t.Errorf("transitions = %v, want %s", got, want)
+	}
+	if recorder := serve(ds, http.MethodGet, "/api/v1/audit?service=web"); !strings.Contains(recorder.Body.String(), `"total":2`) {
+		t.Errorf("audit endpoint = %s, want the 2 smoothed transitions", recorder.Body)
+	}
+}
+
+func TestAuditLogRing(t *testing.T) {
+	al, err := newAuditLog(3, "", discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	previous := ""
+	for i, status := range []string{"healthy", "healthy", "degraded", "unhealthy", "unhealthy", "healthy"} {
+		al.observe("web", "w1", StatusChange{Previous: previous, Current: status}, "web-w1", base.Add(time.Duration(i)*time.Minute))
+		previous = status
+	}
+	// Repeats are not transitions, and only the newest 3 of 4 are held
+	if got, want := transitions(al.query("", time.Time{}, time.Time{})), "w1:healthy>degraded,w1:degraded>unhealthy,w1:unhealthy>healthy"; got != want {
+		t.Errorf("ring = %s, want %s", got, want)
+	}
+
+	disabled, err := newAuditLog(0, "", discardLogger)
+	if disabled != nil || err != nil {
+		t.Fatalf("newAuditLog(0) = %v, %v; want a nil log", disabled, err)
+	}
+	disabled.observe("web", "w1", StatusChange{Current: "healthy"}, "", base) // must not panic
+	if err := disabled.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditLogFileSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	first, err := newAuditLog(10, path, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	first.observe("web", "w1", StatusChange{Current: "healthy"}, "web-w1", base)
+	first.observe("web", "w2", StatusChange{Current: "healthy"}, "web-w2", base.Add(time.Minute))
+	first.observe("web", "w1", StatusChange{Previous: "healthy", Current: "unhealthy"}, "web-w1", base.Add(2*time.Minute))
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A torn line from a crash is skipped
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"service_name":"web","instan` + "\n")
+	file.Close()
+
+	second, err := newAuditLog(2, path, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if got, want := transitions(second.query("", time.Time{}, time.Time{})), "w2:>healthy,w1:healthy>unhealthy"; got != want {
+		t.Errorf("restored ring = %s, want %s", got, want)
+	}
+	// New events are appended after the restored ones
+	second.observe("web", "w1", StatusChange{Previous: "unhealthy", Current: "unhealthy"}, "web-w1", base.Add(3*time.Minute))
+	second.observe("web", "w2", StatusChange{Previous: "healthy", Current: "degraded"}, "web-w2", base.Add(4*time.Minute))
+	if got, want := transitions(second.query("", base.Add(3*time.Minute), time.Time{})), "w2:healthy>degraded"; got != want {
+		t.Errorf("after restart = %s, want %s", got, want)
+	}
+
+	// Everything, the unreadable line aside, is in the file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 5 {
+		t.Errorf("audit file has %d lines, want 3 events, the torn line and 1 more event", lines)
+	}
+}
+
+func TestAuditEndpoint(t *testing.T) {
+	ds := newTestServer(t, nil)
+	report := func(service, instance, status, cn string) {
+		t.Helper()
+		req := StatusRequest{ServiceName: service, InstanceName: instance, Status: status}
+		if rerr := ds.processReport(ds.logger, req, "192.0.2.1", cn, ""); rerr != nil {
+			t.Fatalf("report: %d %s", rerr.status, rerr.message)
+		}
+	}
+	report("web", "w1", "healthy", "web-w1")
+	report("api", "a1", "healthy", "api-a1")
+	middle := time.Now()
+	report("web", "w1", "unhealthy", "web-w1")
+	report("web", "w1", "unhealthy", "web-w1")
+	// Only w1 goes silent
+	later := time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second)
+	ds.storage.AddStatus(HostStatus{ServiceName: "api", InstanceName: "a1", Status: "healthy", Timestamp: later})
+	ds.sweepStaleHosts(later)
+
+	get := func(target string) (AuditResponse, int) {
+		recorder := serve(ds, http.MethodGet, target)
+		var resp AuditResponse
+		json.NewDecoder(recorder.Body).Decode(&resp)
+		return resp, recorder.Code
+	}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "w1:>healthy,a1:>healthy,w1:healthy>unhealthy,w1:unhealthy>lost"},
+		{"?service=api", "a1:>healthy"},
+		{"?service=web&until=" + middle.Format(time.RFC3339Nano), "w1:>healthy"},
+		{"?service=web&since=" + middle.Format(time.RFC3339Nano), "w1:healthy>unhealthy,w1:unhealthy>lost"},
+		{"?service=nobody", ""},
+	}
+	for _, tt := range tests {
+		resp, code := get("/api/v1/audit" + tt.query)
+		if code != http.StatusOK || transitions(resp.Events) != tt.want || resp.Total != len(resp.Events) {
+			t.Errorf("audit%s = %d %s (total %d), want %s", tt.query, code, transitions(resp.Events), resp.Total, tt.want)
+		}
+	}
+
+	resp, _ := get("/api/v1/audit?service=web")
+	if cns := resp.Events[1].ClientCN + "," + resp.Events[2].ClientCN; cns != "web-w1," {
+		t.Errorf("client CNs = %q, want the reporter's and none for the server's own transition", cns)
+	}
+	if _, code := get("/api/v1/audit?since=yesterday"); code != http.StatusBadRequest {
+		t.Errorf("audit with a bad since = %d, want 400", code)
+	}
+
+	disabled := newTestServer(t, func(config *Config) { config.AuditLogSize = 0 })
+	if code := serve(disabled, http.MethodGet, "/api/v1/audit").Code; code != http.StatusNotFound {
+		t.Errorf("audit while disabled = %d, want 404", code)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// maxBatchReports is the most reports accepted in one batch request
+const maxBatchReports = 100
+
+// BatchResult is the outcome of one report in a batch
+type BatchResult struct {
+	Index  int          `json:"index"`
+	Status string       `json:"status"` // "ok" or "error"
+	Error  *ErrorDetail `json:"error,omitempty"`
+}
+
+// BatchResponse lists the outcome of every report in a batch, in request order
+type BatchResponse struct {
+	Accepted            int           `json:"accepted"`
+	Rejected            int           `json:"rejected"`
+	Results             []BatchResult `json:"results"`
+	NextIntervalSeconds int           `json:"next_interval_seconds,omitempty"` // as in StatusResponse
+}
+
+// reportBatch handles an array of status reports sent in one request. Each
+// report is validated and stored on its own; 207 Multi-Status is returned
+// when any of them was rejected.
+func (ds *S01Server) reportBatch(w http.ResponseWriter, r *http.Request) {
+	logger := ds.requestLogger(r)
+
+	body, ok := ds.readReportBody(w, r)
+	if !ok {
+		return
+	}
+
+	var reqs []StatusRequest
+	if err := json.Unmarshal(body, &reqs); err != nil {
+		logger.Error("Failed to decode batch status request", "error", err)
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON: expected an array of status reports")
+		return
+	}
+	if len(reqs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Batch contains no reports")
+		return
+	}
+	if len(reqs) > maxBatchReports {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Batch exceeds the maximum number of reports")
+		return
+	}
+
+	clientIP := getClientIP(r)
+	clientCN := getClientCN(r)
+	clientID := getClientID(r, ds.config.ClientIDSource)
+
+	// A batch costs each host it reports for one token, so a client flushing
+	// reports buffered during an outage is not throttled by its own backlog
+	limited := make(map[string]bool)
+	allLimited := true
+	for _, req := range reqs {
+		key := hostKey(req.ServiceName, req.InstanceName)
+		if _, seen := limited[key]; !seen {
+			limited[key] = !ds.allowReport(logger, req)
+			allLimited = allLimited && limited[key]
+		}
+	}
+	if allLimited {
+		w.Header().Set("Retry-After", strconv.Itoa(ds.limiter.retryAfter()))
+		writeJSONError(w, http.StatusTooManyRequests, errCodeRateLimited, "Too many reports for these hosts")
+		return
+	}
+
+	response := BatchResponse{Results: make([]BatchResult, len(reqs)), NextIntervalSeconds: ds.advisedInterval()}
+	for i, req := range reqs {
+		result := BatchResult{Index: i, Status: "ok"}
+		if limited[hostKey(req.ServiceName, req.InstanceName)] {
+			result.Status = "error"
+			result.Error = &ErrorDetail{Code: errCodeRateLimited, Message: "Too many reports for this host"}
+			response.Rejected++
+		} else if rerr := ds.processReport(logger, req, clientIP, clientCN, clientID); rerr != nil {
+			result.Status = "error"
+			result.Error = &ErrorDetail{Code: rerr.code, Message: rerr.message}
+			response.Rejected++
+		} else {
+			response.Accepted++
+		}
+		response.Results[i] = result
+	}
+
+	span := requestSpan(r)
+	span.SetAttribute("reports", strconv.Itoa(len(reqs)))
+	span.SetAttribute("rejected", strconv.Itoa(response.Rejected))
+	if len(reqs) > 0 {
+		span.SetAttribute("service_name", reqs[0].ServiceName)
+		span.SetAttribute("instance_name", reqs[0].InstanceName)
+	}
+
+	logger.Debug("Batch status report processed",
+		ds.ipLog.attr(clientIP),
+		"accepted", response.Accepted,
+		"rejected", response.Rejected,
+	)
+
+	statusCode := http.StatusOK
+	if response.Rejected > 0 {
+		statusCode = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
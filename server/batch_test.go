@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReportBatch(t *testing.T) {
+	tooMany := make([]string, maxBatchReports+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`{"service_name": "web", "instance_name": "w%d", "status": "healthy"}`, i)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   int
+		wantErrors []string // error code per item, "" for accepted ones
+		stored     []string // instances of service "web" stored afterwards
+	}{
+		{
+			name:       "all valid",
+			body:       `[{"service_name": "web", "instance_name": "a", "status": "healthy"}, {"service_name": "web", "instance_name": "b", "status": "degraded"}]`,
+			wantCode:   http.StatusOK,
+			wantErrors: []string{"", ""},
+			stored:     []string{"a", "b"},
+		},
+		{
+			name: "one invalid item",
+			body: `[{"service_name": "web", "instance_name": "a", "status": "healthy"},
+				{"service_name": "web", "instance_name": "b", "status": "sideways"},
+				{"service_name": "web", "status": "healthy"},
+				{"service_name": "web", "instance_name": "c", "status": "unhealthy"}]`,
+			wantCode:   http.StatusMultiStatus,
+			wantErrors: []string{"", errCodeInvalidStatus, errCodeInvalidRequest, ""},
+			stored:     []string{"a", "c"},
+		},
+		{name: "empty batch", body: `[]`, wantCode: http.StatusBadRequest},
+		{name: "single object", body: `{"service_name": "web", "instance_name": "a", "status": "healthy"}`, wantCode: http.StatusBadRequest},
+		{name: "too many reports", body: "[" + strings.Join(tooMany, ",") + "]", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestServer(t, nil)
+			recorder := post(ds, "/api/v1/report/batch", tt.body)
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", recorder.Code, tt.wantCode, recorder.Body)
+			}
+
+			if tt.wantErrors != nil {
+				var response BatchResponse
+				if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if len(response.Results) != len(tt.wantErrors) || response.Accepted+response.Rejected != len(tt.wantErrors) {
+					t.Fatalf("response = %+v, want %d results", response, len(tt.wantErrors))
+				}
+				for i, result := range response.Results {
+					var code string
+					if result.Error != nil {
+						code = result.Error.Code
+					}
+					if result.Index != i || code != tt.wantErrors[i] || (code == "") != (result.Status == "ok") {
+						t.Errorf("result %d = %+v, want error code %q", i, result, tt.wantErrors[i])
+					}
+				}
+			}
+
+			for _, instance := range tt.stored {
+				if _, found, _ := ds.storage.GetHostSnapshot("web", instance); !found {
+					t.Errorf("web/%s not stored", instance)
+				}
+			}
+			if hosts, _ := ds.storage.Count(); hosts != len(tt.stored) {
+				t.Errorf("%d hosts stored, want %d", hosts, len(tt.stored))
+			}
+		})
+	}
+}
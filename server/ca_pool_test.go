@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// verifies reports whether pool trusts the certificate in certFile
+func verifies(t *testing.T, pool *x509.CertPool, certFile string) bool {
+	t.Helper()
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	return err == nil
+}
+
+func TestLoadCAPool(t *testing.T) {
+	oldCA, newCA, otherCA := newTestCA(t, "s01 CA 2025"), newTestCA(t, "s01 CA 2026"), newTestCA(t, "elsewhere CA")
+	oldLeaf, _ := oldCA.issue(t, "leaf", &x509.Certificate{Subject: pkix.Name{CommonName: "old"}})
+	newLeaf, _ := newCA.issue(t, "leaf", &x509.Certificate{Subject: pkix.Name{CommonName: "new"}})
+	otherLeaf, _ := otherCA.issue(t, "leaf", &x509.Certificate{Subject: pkix.Name{CommonName: "other"}})
+
+	// A mounted secret: both CAs, a stray key, and a hidden link to elsewhere
+	secret := t.TempDir()
+	for name, source := range map[string]string{"ca-old.pem": oldCA.file(), "ca-new.pem": newCA.file(), "..data": otherCA.file()} {
+		data, _ := os.ReadFile(source)
+		os.WriteFile(filepath.Join(secret, name), data, 0o600)
+	}
+	os.WriteFile(filepath.Join(secret, "tls.key"), []byte("not a certificate"), 0o600)
+	os.Mkdir(filepath.Join(secret, "nested"), 0o755)
+
+	junk := filepath.Join(t.TempDir(), "junk.pem")
+	os.WriteFile(junk, []byte("not a certificate"), 0o600)
+
+	tests := []struct {
+		name      string
+		spec      string
+		trusted   []string
+		untrusted []string
+		wantErr   string
+	}{
+		{"single file", oldCA.file(), []string{oldLeaf}, []string{newLeaf}, ""},
+		{"two files", oldCA.file() + "," + newCA.file(), []string{oldLeaf, newLeaf}, []string{otherLeaf}, ""},
+		{"spaces and empty entries", " " + newCA.file() + " ,, " + oldCA.file() + ",", []string{oldLeaf, newLeaf}, nil, ""},
+		{"directory", secret, []string{oldLeaf, newLeaf}, []string{otherLeaf}, ""},
+		{"junk beside a CA", junk + "," + newCA.file(), []string{newLeaf}, []string{oldLeaf}, ""},
+		{"only junk", junk, nil, nil, "no certificate found"},
+		{"missing entry", oldCA.file() + "," + filepath.Join(secret, "gone.pem"), nil, nil, "failed to read CA certificate"},
+		{"empty", "", nil, nil, "no certificate found"},
+	}
+	for _, tt := range tests {
+		pool, err := loadCAPool(tt.spec, discardLogger)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for _, leaf := range tt.trusted {
+			if !verifies(t, pool, leaf) {
+				t.Errorf("%s: %s does not verify", tt.name, leaf)
+			}
+		}
+		for _, leaf := range tt.untrusted {
+			if verifies(t, pool, leaf) {
+				t.Errorf("%s: %s verifies though its CA is not listed", tt.name, leaf)
+			}
+		}
+	}
+}
+
+func TestServerTrustsClientsOfBothCAs(t *testing.T) {
+	oldCA, newCA, otherCA := newTestCA(t, "s01 CA 2025"), newTestCA(t, "s01 CA 2026"), newTestCA(t, "elsewhere CA")
+	_, url := newTLSTestServer(t, oldCA, func(config *Config) {
+		config.CACertFile = oldCA.file() + "," + newCA.file()
+	})
+
+	for _, tt := range []struct {
+		ca   *testCA
+		want bool
+	}{{oldCA, true}, {newCA, true}, {otherCA, false}} {
+		client := tt.ca.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "web-w1"}})
+		// Every client trusts the server, which still presents its old-CA certificate
+		client.Transport.(*http.Transport).TLSClientConfig.RootCAs.AddCert(oldCA.cert)
+
+		resp, err := client.Get(url + "/api/v1/hosts")
+		if err == nil {
+			resp.Body.Close()
+		}
+		if accepted := err == nil && resp.StatusCode == http.StatusOK; accepted != tt.want {
+			t.Errorf("client of %s accepted %v (%v), want %v", tt.ca.cert.Subject.CommonName, accepted, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway certificate authority whose files live in a
+// temporary directory
+type testCA struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	dir    string
+	serial int64
+}
+
+func newTestCA(t *testing.T, commonName string) *testCA {
+	t.Helper()
+	ca := &testCA{dir: t.TempDir(), serial: 1}
+	ca.key = mustKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(ca.serial),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &ca.key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca.cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, ca.file(), "CERTIFICATE", der)
+	return ca
+}
+
+func mustKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// file returns the path of the CA certificate
+func (ca *testCA) file() string {
+	return filepath.Join(ca.dir, "ca.pem")
+}
+
+// issue signs template, filling in the serial number, validity and key
+// usages it leaves unset, and writes the certificate and its key under name
+func (ca *testCA) issue(t *testing.T, name string, template *x509.Certificate) (certFile, keyFile string) {
+	t.Helper()
+	ca.serial++
+	template.SerialNumber = big.NewInt(ca.serial)
+	if template.NotAfter.IsZero() {
+		template.NotBefore, template.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour)
+	}
+	if template.ExtKeyUsage == nil {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+	key := mustKey(t)
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(ca.dir, name+".pem"), filepath.Join(ca.dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+// client returns an HTTP client trusting ca and presenting a certificate
+// it issues for template
+func (ca *testCA) client(t *testing.T, template *x509.Certificate) *http.Client {
+	t.Helper()
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: ca.clientConfig(t, template)}}
+}
+
+// clientConfig is the TLS configuration of ca.client
+func (ca *testCA) clientConfig(t *testing.T, template *x509.Certificate) *tls.Config {
+	t.Helper()
+	pair, err := tls.LoadX509KeyPair(ca.issue(t, "client", template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		RootCAs:      roots,
+	}
+}
+
+// handshake completes a TLS handshake with the server at url on a new
+// connection, so no pooled connection can hide what the server now accepts
+func handshake(url string, config *tls.Config) (tls.ConnectionState, error) {
+	conn, err := tls.Dial("tcp", strings.TrimPrefix(url, "https://"), config)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState(), nil
+}
+
+// newTLSTestServer serves the API over mutual TLS with a certificate from
+// ca, loaded through the server's own certificate handling, and returns
+// the server and its base URL
+func newTLSTestServer(t *testing.T, ca *testCA, configure func(*Config)) (*S01Server, string) {
+	t.Helper()
+	certFile, keyFile := ca.issue(t, "server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "s01-server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	})
+	ds := newTestServer(t, func(config *Config) {
+		config.EnableTLS = true
+		config.CertFile, config.KeyFile, config.CACertFile = certFile, keyFile, ca.file()
+		if configure != nil {
+			configure(config)
+		}
+	})
+
+	server := httptest.NewUnstartedServer(ds.routes())
+	server.TLS = ds.tlsConfig
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // refused handshakes are expected
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return ds, server.URL
+}
+
+func TestReloadCertificatesSwapsServedLeaf(t *testing.T) {
+	ca := newTestCA(t, "s01 test CA")
+	ds, url := newTLSTestServer(t, ca, nil)
+	config := ca.clientConfig(t, &x509.Certificate{Subject: pkix.Name{CommonName: "web-w1"}})
+
+	// servedLeaf makes a fresh connection and returns the certificate the server presented
+	servedLeaf := func() *x509.Certificate {
+		t.Helper()
+		state, err := handshake(url, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state.PeerCertificates[0]
+	}
+
+	before := servedLeaf()
+	ca.issue(t, "server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "s01-server-rotated"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	})
+	if leaf := servedLeaf(); leaf.SerialNumber.Cmp(before.SerialNumber) != 0 {
+		t.Fatalf("new certificate served before a reload")
+	}
+
+	ds.reloadCertificates()
+	rotated := servedLeaf()
+	if rotated.Subject.CommonName != "s01-server-rotated" {
+		t.Fatalf("after reload the server presents %q, want the rotated certificate", rotated.Subject.CommonName)
+	}
+
+	// A half-written rotation fails to load and the rotated certificate stays
+	if err := os.WriteFile(ds.config.CertFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ds.reloadCertificates()
+	if leaf := servedLeaf(); leaf.SerialNumber.Cmp(rotated.SerialNumber) != 0 {
+		t.Errorf("after a failed reload the server presents serial %v, want %v kept", leaf.SerialNumber, rotated.SerialNumber)
+	}
+}
+
+func TestCheckCertExpiry(t *testing.T) {
+	tests := []struct {
+		name          string
+		remaining     time.Duration
+		rejectExpired bool
+		wantWarning   string
+		wantErr       bool
+	}{
+		{"valid for months", 90 * 24 * time.Hour, true, "", false},
+		{"inside the warning window", 3 * 24 * time.Hour, true, "Certificate expires soon", false},
+		{"expired", -time.Hour, false, "Certificate has expired", false},
+		{"expired and rejected", -time.Hour, true, "", true},
+	}
+	for _, tt := range tests {
+		var logs strings.Builder
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+		leaf := &x509.Certificate{NotAfter: time.Now().Add(tt.remaining)}
+
+		err := checkCertExpiry(leaf, "server.pem", 14*24*time.Hour, tt.rejectExpired, logger)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantWarning == "" && logs.Len() > 0 || !strings.Contains(logs.String(), tt.wantWarning) {
+			t.Errorf("%s: logged %q, want %q", tt.name, logs.String(), tt.wantWarning)
+		}
+	}
+}
+
+func TestServerCertificateExpiry(t *testing.T) {
+	ca := newTestCA(t, "s01 test CA")
+	t.Setenv("ENABLE_TLS", "false")
+
+	// start loads the server with a certificate expiring at notAfter
+	start := func(notAfter time.Time, rejectExpired bool) (*S01Server, error) {
+		certFile, keyFile := ca.issue(t, "server", &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "s01-server"},
+			NotBefore: notAfter.Add(-48 * time.Hour),
+			NotAfter:  notAfter,
+		})
+		config, err := loadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.EnableTLS, config.RejectExpiredCert = true, rejectExpired
+		config.CertFile, config.KeyFile, config.CACertFile = certFile, keyFile, ca.file()
+		return NewS01Server(config, discardLogger)
+	}
+
+	expired := time.Now().Add(-time.Hour)
+	if _, err := start(expired, true); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("start with an expired certificate and REJECT_EXPIRED_CERT = %v, want an expiry error", err)
+	}
+	if ds, err := start(expired, false); err != nil {
+		t.Errorf("start with an expired certificate = %v, want only a warning", err)
+	} else {
+		ds.storage.Close()
+	}
+
+	shortLived := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	ds, err := start(shortLived, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.storage.Close()
+	var health struct {
+		CertificateExpiresAt time.Time `json:"certificate_expires_at"`
+	}
+	if err := json.NewDecoder(serve(ds, http.MethodGet, "/health").Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+	if !health.CertificateExpiresAt.Equal(shortLived) {
+		t.Errorf("/health certificate_expires_at = %v, want %v", health.CertificateExpiresAt, shortLived)
+	}
+}
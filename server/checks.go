@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// CheckSummary counts, for one check name, the hosts whose latest report
+// had that check in each status
+type CheckSummary struct {
+	Name      string `json:"name"`
+	Healthy   int    `json:"healthy"`
+	Degraded  int    `json:"degraded"`
+	Unhealthy int    `json:"unhealthy"`
+	Unknown   int    `json:"unknown"` // "unknown" or any status a client invented
+}
+
+// CheckSummaryResponse tallies check results across the fleet
+type CheckSummaryResponse struct {
+	HostsWithChecks int            `json:"hosts_with_checks"`
+	Checks          []CheckSummary `json:"checks"`
+}
+
+// getCheckSummary tallies the health checks of every host's latest report so
+// a check failing across many hosts stands out
+func (ds *S01Server) getCheckSummary(w http.ResponseWriter, r *http.Request) {
+	logger := ds.requestLogger(r)
+
+	// One snapshot of every host, taken under a single read lock
+	snapshots, err := ds.storage.GetHosts()
+	if err != nil {
+		logger.Error("Failed to load hosts", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load hosts")
+		return
+	}
+
+	summary := computeCheckSummary(snapshots, ds.config.StaleStatus)
+
+	logger.Info("Check summary request",
+		"hosts_with_checks", summary.HostsWithChecks,
+		"checks", len(summary.Checks),
+		"client_cn", getClientCN(r),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// computeCheckSummary tallies checks by name over hosts that are not lost,
+// i.e. not in staleStatus, and whose latest report carried health metrics.
+// The worst checks come first: most unhealthy hosts, then most degraded,
+// then by name.
+func computeCheckSummary(snapshots []HostSnapshot, staleStatus string) CheckSummaryResponse {
+	byName := make(map[string]*CheckSummary)
+	var hosts int
+	for _, snapshot := range snapshots {
+		if snapshot.CurrentStatus == staleStatus || snapshot.Latest == nil || snapshot.Latest.HealthMetrics == nil {
+			continue
+		}
+		hosts++
+
+		for _, check := range snapshot.Latest.HealthMetrics.Checks {
+			summary, ok := byName[check.Name]
+			if !ok {
+				summary = &CheckSummary{Name: check.Name}
+				byName[check.Name] = summary
+			}
+			switch check.Status {
+			case "healthy":
+				summary.Healthy++
+			case "degraded":
+				summary.Degraded++
+			case "unhealthy":
+				summary.Unhealthy++
+			default:
+				summary.Unknown++
+			}
+		}
+	}
+
+	checks := make([]CheckSummary, 0, len(byName))
+	for _, summary := range byName {
+		checks = append(checks, *summary)
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].Unhealthy != checks[j].Unhealthy {
+			return checks[i].Unhealthy > checks[j].Unhealthy
+		}
+		if checks[i].Degraded != checks[j].Degraded {
+			return checks[i].Degraded > checks[j].Degraded
+		}
+		return checks[i].Name < checks[j].Name
+	})
+
+	return CheckSummaryResponse{HostsWithChecks: hosts, Checks: checks}
+}
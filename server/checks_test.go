@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withChecks is a latest status carrying checks given as name, status pairs
+func withChecks(pairs ...string) *HostStatus {
+	metrics := &HealthMetrics{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		metrics.Checks = append(metrics.Checks, HealthCheck{Name: pairs[i], Status: pairs[i+1]})
+	}
+	return &HostStatus{HealthMetrics: metrics}
+}
+
+func TestComputeCheckSummary(t *testing.T) {
+	snapshots := []HostSnapshot{
+		{CurrentStatus: "unhealthy", Latest: withChecks("Network Connectivity", "unhealthy", "Disk Usage (/)", "healthy")},
+		{CurrentStatus: "unhealthy", Latest: withChecks("Network Connectivity", "unhealthy", "Disk Usage (/)", "degraded")},
+		{CurrentStatus: "degraded", Latest: withChecks("Network Connectivity", "unhealthy", "Memory Usage", "degraded")},
+		{CurrentStatus: "healthy", Latest: withChecks("Network Connectivity", "healthy", "Memory Usage", "sluggish")},
+		// Lost hosts and hosts without metrics are left out
+		{CurrentStatus: "lost", Latest: withChecks("Network Connectivity", "unhealthy")},
+		{CurrentStatus: "healthy", Latest: &HostStatus{}},
+		{CurrentStatus: "pending"},
+	}
+	got := computeCheckSummary(snapshots, "lost")
+
+	want := CheckSummaryResponse{
+		HostsWithChecks: 4,
+		Checks: []CheckSummary{
+			{Name: "Network Connectivity", Healthy: 1, Unhealthy: 3},
+			{Name: "Disk Usage (/)", Healthy: 1, Degraded: 1},
+			{Name: "Memory Usage", Degraded: 1, Unknown: 1},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summary = %+v\nwant %+v", got, want)
+	}
+
+	if empty := computeCheckSummary(nil, "lost"); empty.HostsWithChecks != 0 || empty.Checks == nil || len(empty.Checks) != 0 {
+		t.Errorf("summary of no hosts = %+v, want zero hosts and an empty list", empty)
+	}
+}
+
+func TestCheckSummaryEndpoint(t *testing.T) {
+	report := func(instance string, checks ...string) StatusRequest {
+		return StatusRequest{ServiceName: "web", InstanceName: instance, Status: "degraded", HealthMetrics: withChecks(checks...).HealthMetrics}
+	}
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+			})
+			// w1 recovers; only its latest report counts
+			mustReport(t, ds, report("w1", "Network Connectivity", "unhealthy"))
+			mustReport(t, ds, report("w1", "Network Connectivity", "healthy"))
+			for _, instance := range []string{"w2", "w3", "w4"} {
+				mustReport(t, ds, report(instance, "Network Connectivity", "unhealthy", "CPU Usage", "healthy"))
+			}
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w5", Status: "healthy"})
+
+			recorder := serve(ds, http.MethodGet, "/api/v1/checks/summary")
+			var summary CheckSummaryResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&summary); err != nil || recorder.Code != http.StatusOK {
+				t.Fatalf("summary = %d, %v", recorder.Code, err)
+			}
+			want := []CheckSummary{
+				{Name: "Network Connectivity", Healthy: 1, Unhealthy: 3},
+				{Name: "CPU Usage", Healthy: 3},
+			}
+			if summary.HostsWithChecks != 4 || !reflect.DeepEqual(summary.Checks, want) {
+				t.Errorf("summary = %+v, want 4 hosts with %+v", summary, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestYAMLAndJSONConfigsMatch(t *testing.T) {
+	const yaml = `---
+# s01 server settings
+server_port: "9443"
+max_history: 25
+stale_status: offline   # shown for hosts that stop reporting
+enable_tls: false
+smoothing_alpha: 0.5
+webhook_url: 'http://alerts.example/#hook'
+service_thresholds: {"web": {"healthy_score": 80, "degraded_score": 50}}
+unknown_setting: ignored
+`
+	const json = `{
+	"server_port": "9443",
+	"max_history": 25,
+	"stale_status": "offline",
+	"enable_tls": false,
+	"smoothing_alpha": 0.5,
+	"webhook_url": "http://alerts.example/#hook",
+	"service_thresholds": {"web": {"healthy_score": 80, "degraded_score": 50}}
+}`
+
+	load := func(name, content string) *Config {
+		t.Helper()
+		t.Chdir(t.TempDir())
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig()
+		if err != nil {
+			t.Fatalf("loadConfig from %s: %v", name, err)
+		}
+		return config
+	}
+	fromJSON := load("config.json", json)
+	for _, name := range []string{"config.yaml", "config.yml"} {
+		if fromYAML := load(name, yaml); !reflect.DeepEqual(fromYAML, fromJSON) {
+			t.Errorf("%s = %+v\nwant the JSON file's %+v", name, fromYAML, fromJSON)
+		}
+	}
+	if fromJSON.MaxHistory != 25 || fromJSON.StaleStatus != "offline" || fromJSON.WebhookURL != "http://alerts.example/#hook" {
+		t.Errorf("settings not applied: %+v", fromJSON)
+	}
+
+	// JSON wins when both are in the same directory
+	if err := os.WriteFile("config.json", []byte(`{"enable_tls": false, "max_history": 40}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if config, err := loadConfig(); err != nil || config.MaxHistory != 40 {
+		t.Errorf("with config.json and config.yml: max_history = %v, %v; want the JSON file's 40", config, err)
+	}
+}
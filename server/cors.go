@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsPolicy lets browser dashboards on other origins read the health
+// endpoints
+type corsPolicy struct {
+	anyOrigin bool     // "*" was configured
+	origins   []string // exact origins allowed, e.g. https://dash.example.com
+}
+
+// newCORSPolicy parses a comma-separated list of allowed origins, where "*"
+// allows any. It returns nil, disabling CORS, when the list is empty.
+func newCORSPolicy(allowedOrigins string) *corsPolicy {
+	policy := &corsPolicy{}
+	for _, origin := range strings.Split(allowedOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		switch origin {
+		case "":
+		case "*":
+			policy.anyOrigin = true
+		default:
+			policy.origins = append(policy.origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	if !policy.anyOrigin && len(policy.origins) == 0 {
+		return nil
+	}
+	return policy
+}
+
+// withCORS adds CORS headers to responses for allowed origins, including the
+// preflight OPTIONS requests that methodNotAllowed answers
+func (c *corsPolicy) withCORS(next http.HandlerFunc) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin != "" && (c.anyOrigin || slices.Contains(c.origins, origin)) {
+			header := w.Header()
+			if c.anyOrigin {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			header.Set("Access-Control-Expose-Headers", requestIDHeader)
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				header.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+				header.Set("Access-Control-Allow-Headers", requestIDHeader)
+				header.Set("Access-Control-Max-Age", "600")
+			}
+		}
+		next(w, r)
+	}
+}
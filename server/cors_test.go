@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNewCORSPolicy(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *corsPolicy
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"*", &corsPolicy{anyOrigin: true}},
+		{"https://dash.example.com/", &corsPolicy{origins: []string{"https://dash.example.com"}}},
+		{"https://a.example.com, http://localhost:3000", &corsPolicy{origins: []string{"https://a.example.com", "http://localhost:3000"}}},
+		{"https://a.example.com,*", &corsPolicy{anyOrigin: true, origins: []string{"https://a.example.com"}}},
+	}
+	for _, tt := range tests {
+		if got := newCORSPolicy(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("newCORSPolicy(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestHealthCORSHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowed       string
+		method        string
+		origin        string
+		preflight     bool
+		wantOrigin    string
+		wantPreflight bool
+	}{
+		{"listed origin", "https://dash.example.com", http.MethodGet, "https://dash.example.com", false, "https://dash.example.com", false},
+		{"preflight", "https://dash.example.com", http.MethodOptions, "https://dash.example.com", true, "https://dash.example.com", true},
+		{"unlisted origin", "https://dash.example.com", http.MethodOptions, "https://evil.example.com", true, "", false},
+		{"no origin", "https://dash.example.com", http.MethodGet, "", false, "", false},
+		{"any origin", "*", http.MethodGet, "https://elsewhere.example.com", false, "*", false},
+		// A plain OPTIONS is not a preflight
+		{"options without request method", "*", http.MethodOptions, "https://elsewhere.example.com", false, "*", false},
+		{"disabled", "", http.MethodGet, "https://dash.example.com", false, "", false},
+	}
+	for _, tt := range tests {
+		ds := newTestServer(t, func(config *Config) { config.CORSAllowedOrigins = tt.allowed })
+		req := httptest.NewRequest(tt.method, "/readyz", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		recorder := httptest.NewRecorder()
+		ds.cors.withCORS(ds.healthRoutes().ServeHTTP)(recorder, req)
+
+		header := recorder.Header()
+		if got := header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if got := header.Get("Access-Control-Allow-Methods") != ""; got != tt.wantPreflight {
+			t.Errorf("%s: Access-Control-Allow-Methods %q, want preflight headers %v", tt.name, header.Get("Access-Control-Allow-Methods"), tt.wantPreflight)
+		}
+		if tt.wantOrigin != "" && header.Get("Access-Control-Expose-Headers") != requestIDHeader {
+			t.Errorf("%s: exposed headers %q, want %s", tt.name, header.Get("Access-Control-Expose-Headers"), requestIDHeader)
+		}
+		if tt.allowed != "" && header.Get("Vary") != "Origin" {
+			t.Errorf("%s: Vary %q, want Origin", tt.name, header.Get("Vary"))
+		}
+		// The probe itself still answers
+		if tt.method == http.MethodOptions && recorder.Code != http.StatusNoContent {
+			t.Errorf("%s: OPTIONS = %d, want 204", tt.name, recorder.Code)
+		}
+	}
+}
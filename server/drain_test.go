@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDrainFailsReadyzBeforeClosing(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.BindAddress = "127.0.0.1"
+		config.ServerPort, config.HealthPort = freePort(t), freePort(t)
+		config.DrainPeriod = 1
+	})
+	health := "http://127.0.0.1:" + ds.config.HealthPort
+	mainAddr := net.JoinHostPort("127.0.0.1", ds.config.ServerPort)
+	stop := startServer(t, ds, health+"/readyz")
+	defer stop()
+
+	// A report whose body is still arriving when the signal lands. The
+	// server sends 100 Continue once the handler reads the body, so the
+	// report is past the draining check before the signal.
+	body := `{"service_name":"web","instance_name":"slow","status":"healthy"}`
+	conn, err := net.Dial("tcp", mainAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("POST /api/v1/report HTTP/1.1\r\nHost: s01\r\nContent-Type: application/json\r\n" +
+		"Expect: 100-continue\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n"))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+	if resp, err := http.ReadResponse(reader, nil); err != nil || resp.StatusCode != http.StatusContinue {
+		t.Fatalf("report never reached its handler: %v", err)
+	}
+	conn.Write([]byte(body[:10]))
+
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	signalled := time.Now()
+
+	for {
+		resp, err := http.Get(health + "/readyz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusServiceUnavailable {
+				break
+			}
+		}
+		if time.Since(signalled) > 2*time.Second {
+			t.Fatalf("/readyz never went 503 after SIGTERM: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// New reports are turned away at once, so clients retry elsewhere rather
+	// than land on an instance that is about to close
+	resp, err := http.Post("http://"+mainAddr+"/api/v1/report", "application/json",
+		strings.NewReader(`{"service_name":"web","instance_name":"late","status":"healthy"}`))
+	if err != nil {
+		t.Fatalf("report during the drain: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("report during the drain = %d with Retry-After %q, want 503 with one", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if elapsed := time.Since(signalled); elapsed > 900*time.Millisecond {
+		t.Fatalf("checks took %v, past the 1s drain period", elapsed)
+	}
+
+	// Once the drain period is over the listener stops taking connections
+	for {
+		probe, err := net.DialTimeout("tcp", mainAddr, 100*time.Millisecond)
+		if err != nil {
+			break
+		}
+		probe.Close()
+		if time.Since(signalled) > 3*time.Second {
+			t.Fatal("main listener still open 3s into a 1s drain")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if elapsed := time.Since(signalled); elapsed < time.Second {
+		t.Errorf("listener closed %v after SIGTERM, before the 1s drain period", elapsed)
+	}
+
+	// ...and the request in flight all along still completes
+	conn.Write([]byte(body[10:]))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	inflight, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("in-flight report: %v", err)
+	}
+	inflight.Body.Close()
+	if inflight.StatusCode != http.StatusOK {
+		t.Errorf("in-flight report = %d, want 200", inflight.StatusCode)
+	}
+
+	stop()
+	if _, found, err := ds.storage.GetHostSnapshot("web", "slow"); !found || err != nil {
+		t.Errorf("in-flight report lost in the restart: %v", err)
+	}
+	if _, found, _ := ds.storage.GetHostSnapshot("web", "late"); found {
+		t.Error("report sent during the drain was stored")
+	}
+}
+
+func TestSecondSignalEndsDrainEarly(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.BindAddress = "127.0.0.1"
+		config.ServerPort, config.HealthPort = freePort(t), freePort(t)
+		config.DrainPeriod = 30
+	})
+	stop := startServer(t, ds, "http://127.0.0.1:"+ds.config.HealthPort+"/readyz")
+
+	// stop keeps signalling until Start returns, well before 30s
+	start := time.Now()
+	stop()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("shutdown took %v with repeated signals", elapsed)
+	}
+}
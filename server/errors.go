@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Stable error codes returned in ErrorResponse bodies
+const (
+	errCodeMethodNotAllowed = "method_not_allowed"
+	errCodeInvalidRequest   = "invalid_request"
+	errCodeInvalidJSON      = "invalid_json"
+	errCodeInvalidStatus    = "invalid_status"
+	errCodeStaleReport      = "stale_report"
+	errCodeStaleSequence    = "stale_sequence"
+	errCodeForbidden        = "forbidden"
+	errCodeNotFound         = "not_found"
+	errCodeRequestTooLarge  = "request_too_large"
+	errCodeRequestTimeout   = "request_timeout"
+	errCodeClientClosed     = "client_closed_request"
+	errCodeRateLimited      = "rate_limited"
+	errCodeHostLimit        = "host_limit_reached"
+	errCodeNoPriorStatus    = "no_prior_status"
+	errCodeInternal         = "internal_error"
+	errCodeUnavailable      = "unavailable"
+)
+
+// statusClientClosedRequest is the non-standard status (as used by nginx)
+// recorded when the client hangs up before sending its full request body
+const statusClientClosedRequest = 499
+
+// ErrorResponse is the body of every API error
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an API error
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSONError writes an error response with a stable code and a human-readable message
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error: ErrorDetail{Code: code, Message: message},
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorResponses(t *testing.T) {
+	ds := newTestServer(t, nil)
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+
+	tests := []struct {
+		method, target, body string
+		wantStatus           int
+		wantCode             string
+	}{
+		{http.MethodPost, "/api/v1/report", "invalid json", http.StatusBadRequest, errCodeInvalidJSON},
+		{http.MethodPost, "/api/v1/report", `{"service_name": "web", "status": "healthy"}`, http.StatusBadRequest, errCodeInvalidRequest},
+		{http.MethodPost, "/api/v1/report", `{"service_name": "web", "instance_name": "w1", "status": "fine"}`, http.StatusBadRequest, errCodeInvalidStatus},
+		{http.MethodGet, "/api/v1/hosts/web/nobody", "", http.StatusNotFound, errCodeNotFound},
+		{http.MethodGet, "/api/v1/services/nobody/instances", "", http.StatusNotFound, errCodeNotFound},
+		{http.MethodGet, "/api/v1/services/web/instances?include_degraded=perhaps", "", http.StatusBadRequest, errCodeInvalidRequest},
+		{http.MethodGet, "/api/v1/services/web/instances?match==us-east-1", "", http.StatusBadRequest, errCodeInvalidRequest},
+		{http.MethodGet, "/api/v1/services/web/instances?match=", "", http.StatusBadRequest, errCodeInvalidRequest},
+		{http.MethodGet, "/api/v1/hosts?label=!=us-east", "", http.StatusBadRequest, errCodeInvalidRequest},
+		{http.MethodGet, "/api/v1/hosts/export?label=", "", http.StatusBadRequest, errCodeInvalidRequest},
+		{http.MethodGet, "/api/v1/no-such-endpoint", "", http.StatusNotFound, errCodeNotFound},
+		{http.MethodDelete, "/api/v1/hosts", "", http.StatusMethodNotAllowed, errCodeMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		ds.routes().ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, recorder.Code, tt.wantStatus)
+			continue
+		}
+		if ct := recorder.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s Content-Type = %q, want application/json", tt.method, tt.target, ct)
+		}
+
+		// Nothing but the error object is in the body
+		decoder := json.NewDecoder(recorder.Body)
+		decoder.DisallowUnknownFields()
+		var response ErrorResponse
+		if err := decoder.Decode(&response); err != nil {
+			t.Errorf("%s %s body does not decode as an ErrorResponse: %v", tt.method, tt.target, err)
+			continue
+		}
+		if response.Error.Code != tt.wantCode || response.Error.Message == "" {
+			t.Errorf("%s %s error = %+v, want code %s with a message", tt.method, tt.target, response.Error, tt.wantCode)
+		}
+	}
+}
+
+// brokenBody yields data, then fails with err
+type brokenBody struct {
+	data *strings.Reader
+	err  error
+}
+
+func (b *brokenBody) Read(p []byte) (int, error) {
+	if b.data.Len() > 0 {
+		return b.data.Read(p)
+	}
+	return 0, b.err
+}
+
+// timeoutError is a net.Error reporting a timeout, as a read deadline gives
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIncompleteReportBody(t *testing.T) {
+	const partial = `{"service_name": "web", "instance_name": "w1", "sta`
+	tests := []struct {
+		name       string
+		body       io.Reader
+		wantStatus int
+		wantCode   string
+		wantLevel  string
+	}{
+		{"hangup", &brokenBody{strings.NewReader(partial), io.ErrUnexpectedEOF}, statusClientClosedRequest, errCodeClientClosed, "INFO"},
+		{"cancelled", &brokenBody{strings.NewReader(partial), context.Canceled}, statusClientClosedRequest, errCodeClientClosed, "INFO"},
+		{"stalled", &brokenBody{strings.NewReader(partial), timeoutError{}}, http.StatusRequestTimeout, errCodeRequestTimeout, "WARN"},
+		{"truncated but complete", strings.NewReader(partial), http.StatusBadRequest, errCodeInvalidJSON, "ERROR"},
+		{"malformed", strings.NewReader(`{"service_name": "web",}`), http.StatusBadRequest, errCodeInvalidJSON, "ERROR"},
+	}
+	for _, tt := range tests {
+		ds := newTestServer(t, nil)
+		var logs strings.Builder
+		ds.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+		recorder := httptest.NewRecorder()
+		ds.routes().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/report", tt.body))
+
+		var response ErrorResponse
+		json.NewDecoder(recorder.Body).Decode(&response)
+		if recorder.Code != tt.wantStatus || response.Error.Code != tt.wantCode {
+			t.Errorf("%s: %d %s, want %d %s", tt.name, recorder.Code, response.Error.Code, tt.wantStatus, tt.wantCode)
+		}
+		if !strings.Contains(logs.String(), `"level":"`+tt.wantLevel+`"`) {
+			t.Errorf("%s: logged %s, want %s level", tt.name, logs.String(), tt.wantLevel)
+		}
+		if tt.wantLevel != "ERROR" && strings.Contains(logs.String(), "w1") {
+			t.Errorf("%s: the partial body was logged: %s", tt.name, logs.String())
+		}
+	}
+}
+
+func TestClientHangupMidReport(t *testing.T) {
+	ds := newTestServer(t, nil)
+	logs := make(chan string, 10)
+	ds.logger = slog.New(slog.NewJSONHandler(lineWriter(logs), nil))
+	server := httptest.NewServer(ds.routes())
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(conn, "POST /api/v1/report HTTP/1.1\r\nHost: s01\r\nContent-Type: application/json\r\nContent-Length: 200\r\n\r\n%s",
+		`{"service_name": "web", "instance_name": "w1"`)
+	conn.Close()
+
+	select {
+	case line := <-logs:
+		if !strings.Contains(line, "Client disconnected") || !strings.Contains(line, `"level":"INFO"`) {
+			t.Errorf("logged %s, want an info-level disconnect", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing logged for a client hanging up mid-body")
+	}
+	if hosts, _ := ds.storage.Count(); hosts != 0 {
+		t.Errorf("%d hosts stored from a partial report", hosts)
+	}
+}
+
+// lineWriter sends each write, one log line, to lines
+type lineWriter chan<- string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
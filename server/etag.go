@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// hostsChanged bumps the revision behind the host listing ETag; call it after
+// every change to stored host state
+func (ds *S01Server) hostsChanged() {
+	ds.revision.Add(1)
+}
+
+// hostsETag tags the current host state. It is weak because the body may be
+// served gzip-compressed or not. The start time keeps revisions from before
+// a restart from matching.
+func (ds *S01Server) hostsETag() string {
+	return fmt.Sprintf(`W/"%x-%x"`, ds.startedAt.UnixNano(), ds.revision.Load())
+}
+
+// notModified sets the ETag header and, when the request's If-None-Match
+// already names etag, answers 304 Not Modified and reports true
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using the
+// weak comparison RFC 9110 prescribes for If-None-Match
+func etagMatches(header, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETagMatches(t *testing.T) {
+	const etag = `W/"18c-7"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`W/"18c-7"`, true},
+		{`"18c-7"`, true}, // weak comparison ignores W/
+		{`W/"18c-6"`, false},
+		{`W/"1", W/"18c-7"`, true},
+		{` W/"1" ,W/"18c-7" `, true},
+		{"*", true},
+		{`W/"18c-7-gzip"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestHostListingConditionalGet(t *testing.T) {
+	ds := newTestServer(t, nil)
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		recorder := httptest.NewRecorder()
+		ds.routes().ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first listing = %d with ETag %q, want 200 with a tag", first.Code, etag)
+	}
+
+	cached := get(etag)
+	if cached.Code != http.StatusNotModified || cached.Body.Len() != 0 || cached.Header().Get("ETag") != etag {
+		t.Errorf("conditional listing = %d, %d byte body, ETag %q; want an empty 304 with the same tag", cached.Code, cached.Body.Len(), cached.Header().Get("ETag"))
+	}
+	// Reads do not change the tag
+	serve(ds, http.MethodGet, "/api/v1/hosts/web/w1")
+	if again := get(etag); again.Code != http.StatusNotModified {
+		t.Errorf("listing after a read = %d, want 304", again.Code)
+	}
+
+	changes := []struct {
+		name   string
+		change func()
+	}{
+		{"new report", func() {
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "degraded"})
+		}},
+		{"stale sweep", func() {
+			ds.sweepStaleHosts(time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second))
+		}},
+	}
+	for _, tt := range changes {
+		tt.change()
+		fresh := get(etag)
+		if fresh.Code != http.StatusOK || fresh.Header().Get("ETag") == etag {
+			t.Errorf("after a %s: %d with ETag %q, want 200 with a new tag", tt.name, fresh.Code, fresh.Header().Get("ETag"))
+		}
+		etag = fresh.Header().Get("ETag")
+	}
+
+	// A rejected report changes nothing
+	post(ds, "/api/v1/report", `{"service_name":"web","instance_name":"w1","status":"fine"}`)
+	if after := get(etag); after.Code != http.StatusNotModified {
+		t.Errorf("listing after a rejected report = %d, want 304", after.Code)
+	}
+}
+
+func TestHostsETagDiffersAcrossRestarts(t *testing.T) {
+	first, second := newTestServer(t, nil), newTestServer(t, nil)
+	first.startedAt = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	second.startedAt = first.startedAt.Add(time.Minute)
+	if first.hostsETag() == second.hostsETag() {
+		t.Errorf("servers started at different times share ETag %s at the same revision", first.hostsETag())
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Host export formats
+const (
+	exportFormatJSON = "json"
+	exportFormatCSV  = "csv"
+)
+
+// hostCSVHeader names the columns of a CSV host export
+var hostCSVHeader = []string{
+	"service_name",
+	"instance_name",
+	"status",
+	"ip_address",
+	"last_seen",
+	"cpu_usage",
+	"memory_usage",
+	"disk_usage",
+	"overall_score",
+}
+
+// getHostsExport returns the current host list, narrowed by the same filters
+// as getHosts, as ?format=json (the default, shaped like getHosts) or
+// ?format=csv with one flattened row per host, ordered by service and
+// instance
+func (ds *S01Server) getHostsExport(w http.ResponseWriter, r *http.Request) {
+	logger := ds.requestLogger(r)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatJSON
+	}
+	if format != exportFormatJSON && format != exportFormatCSV {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid format: must be json or csv")
+		return
+	}
+
+	labelFilters, err := parseLabelFilters(r.URL.Query()["label"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid label filter: "+err.Error())
+		return
+	}
+	hosts, err := ds.filterHosts(r.URL.Query(), labelFilters)
+	if err != nil {
+		logger.Error("Failed to load hosts", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load hosts")
+		return
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].ServiceName != hosts[j].ServiceName {
+			return hosts[i].ServiceName < hosts[j].ServiceName
+		}
+		return hosts[i].InstanceName < hosts[j].InstanceName
+	})
+
+	logger.Info("Hosts export request",
+		"format", format,
+		"total_hosts", len(hosts),
+		"client_cn", getClientCN(r),
+	)
+
+	if format == exportFormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(DiscoveryResponse{Hosts: hosts, Total: len(hosts)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="hosts.csv"`)
+	writer := csv.NewWriter(w)
+	writer.Write(hostCSVHeader)
+	for _, host := range hosts {
+		writer.Write(hostCSVRow(host))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		logger.Warn("Failed to write hosts export", "error", err)
+	}
+}
+
+// hostCSVRow flattens a host into the columns of hostCSVHeader. Metrics are
+// empty for hosts that sent none, and last_seen for hosts yet to report.
+func hostCSVRow(host HostResponse) []string {
+	row := []string{
+		csvText(host.ServiceName),
+		csvText(host.InstanceName),
+		host.Status,
+		csvText(host.IPAddress), // taken from X-Forwarded-For when present
+		"",
+		"", "", "", "",
+	}
+	if !host.LastSeen.IsZero() {
+		row[4] = host.LastSeen.UTC().Format(time.RFC3339)
+	}
+	if metrics := host.HealthMetrics; metrics != nil {
+		row[5] = strconv.FormatFloat(metrics.CPUUsage, 'f', 2, 64)
+		row[6] = strconv.FormatFloat(metrics.MemoryUsage, 'f', 2, 64)
+		row[7] = strconv.FormatFloat(metrics.DiskUsage, 'f', 2, 64)
+		row[8] = strconv.Itoa(metrics.OverallScore)
+	}
+	return row
+}
+
+// csvText guards a client-supplied value against spreadsheet formula
+// injection by prefixing a quote when it starts like a formula
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostsExportCSV(t *testing.T) {
+	ds := newTestServer(t, nil)
+	seen := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w2", Status: "degraded", IPAddress: "10.0.0.2", Timestamp: seen,
+		HealthMetrics: &HealthMetrics{CPUUsage: 87.125, MemoryUsage: 40, DiskUsage: 12.5, OverallScore: 71}})
+	ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", IPAddress: "10.0.0.1", Timestamp: seen})
+	ds.storage.AddStatus(HostStatus{ServiceName: "db, primary", InstanceName: "d1", Status: "unhealthy", Timestamp: seen})
+
+	recorder := serve(ds, http.MethodGet, "/api/v1/hosts/export?format=csv")
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("export = %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if disposition := recorder.Header().Get("Content-Disposition"); !strings.Contains(disposition, "hosts.csv") {
+		t.Errorf("Content-Disposition = %q, want an attachment named hosts.csv", disposition)
+	}
+	lines := strings.Split(strings.TrimRight(recorder.Body.String(), "\n"), "\n")
+	want := []string{
+		"service_name,instance_name,status,ip_address,last_seen,cpu_usage,memory_usage,disk_usage,overall_score",
+		`"db, primary",d1,unhealthy,,2026-03-01T12:30:00Z,,,,`,
+		"web,w1,healthy,10.0.0.1,2026-03-01T12:30:00Z,,,,",
+		"web,w2,degraded,10.0.0.2,2026-03-01T12:30:00Z,87.12,40.00,12.50,71",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("export:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The listing filters apply
+	filters := []struct {
+		query string
+		want  []string
+	}{
+		{"service=web", []string{"w1", "w2"}},
+		{"status=degraded,unhealthy", []string{"d1", "w2"}},
+		{"service=web&status=unhealthy", nil},
+	}
+	for _, tt := range filters {
+		records, err := csv.NewReader(serve(ds, http.MethodGet, "/api/v1/hosts/export?format=csv&"+tt.query).Body).ReadAll()
+		if err != nil || len(records) == 0 || records[0][0] != "service_name" {
+			t.Errorf("%s: %v records, %v; want a header first", tt.query, records, err)
+			continue
+		}
+		var instances []string
+		for _, record := range records[1:] {
+			instances = append(instances, record[1])
+		}
+		if !reflect.DeepEqual(instances, tt.want) {
+			t.Errorf("%s: instances %v, want %v", tt.query, instances, tt.want)
+		}
+	}
+}
+
+func TestHostsExportFormats(t *testing.T) {
+	ds := newTestServer(t, nil)
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+	mustReport(t, ds, StatusRequest{ServiceName: "api", InstanceName: "a1", Status: "degraded"})
+
+	for _, target := range []string{"/api/v1/hosts/export", "/api/v1/hosts/export?format=json"} {
+		recorder := serve(ds, http.MethodGet, target)
+		var export DiscoveryResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&export); err != nil || recorder.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: %v, Content-Type %q", target, err, recorder.Header().Get("Content-Type"))
+			continue
+		}
+		if export.Total != 2 || len(export.Hosts) != 2 || export.Hosts[0].InstanceName != "a1" {
+			t.Errorf("%s: %+v, want both hosts ordered by service", target, export)
+		}
+	}
+
+	recorder := serve(ds, http.MethodGet, "/api/v1/hosts/export?format=xlsx")
+	var response ErrorResponse
+	json.NewDecoder(recorder.Body).Decode(&response)
+	if recorder.Code != http.StatusBadRequest || response.Error.Code != errCodeInvalidRequest {
+		t.Errorf("unknown format = %d %s, want 400", recorder.Code, response.Error.Code)
+	}
+}
+
+func TestHostCSVRowGuardsClientValues(t *testing.T) {
+	tests := []struct {
+		name string
+		host HostResponse
+		want [4]string // service, instance, status, ip
+	}{
+		{"plain", HostResponse{ServiceName: "web", InstanceName: "w1", Status: "healthy", IPAddress: "10.0.0.1"},
+			[4]string{"web", "w1", "healthy", "10.0.0.1"}},
+		{"formula in names", HostResponse{ServiceName: "=1+1", InstanceName: "@SUM(A1)", Status: "healthy"},
+			[4]string{"'=1+1", "'@SUM(A1)", "healthy", ""}},
+		{"forwarded-for formula", HostResponse{ServiceName: "web", InstanceName: "w1", Status: "healthy", IPAddress: `=HYPERLINK("http://x")`},
+			[4]string{"web", "w1", "healthy", `'=HYPERLINK("http://x")`}},
+		{"leading minus", HostResponse{ServiceName: "web", InstanceName: "-w1", Status: "lost", IPAddress: "+1"},
+			[4]string{"web", "'-w1", "lost", "'+1"}},
+	}
+	for _, tt := range tests {
+		row := hostCSVRow(tt.host)
+		if got := [4]string{row[0], row[1], row[2], row[3]}; got != tt.want {
+			t.Errorf("%s: row = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipMinBytes is the smallest response body worth compressing
+const gzipMinBytes = 1024
+
+// bufferedResponseWriter holds a handler's status and body so the response
+// can be inspected before it is sent
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedResponseWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(p)
+}
+
+// withGzip compresses the handler's response when the client accepts gzip and
+// the body is at least gzipMinBytes
+func withGzip(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next(w, r)
+			return
+		}
+
+		buffered := &bufferedResponseWriter{header: w.Header()}
+		next(buffered, r)
+		if buffered.status == 0 {
+			buffered.status = http.StatusOK
+		}
+
+		body := buffered.body.Bytes()
+		if len(body) >= gzipMinBytes && w.Header().Get("Content-Encoding") == "" {
+			var compressed bytes.Buffer
+			gz := gzip.NewWriter(&compressed)
+			if _, err := gz.Write(body); err == nil && gz.Close() == nil {
+				body = compressed.Bytes()
+				w.Header().Set("Content-Encoding", "gzip")
+			}
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(buffered.status)
+		w.Write(body)
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		// An explicit q=0 refuses the coding
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate, gzip;q=0.5", true},
+		{"br, *", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0, deflate", false},
+		{"identity", false},
+		{"x-gzip-like", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipResponses(t *testing.T) {
+	ds := newTestServer(t, nil)
+	for i := 0; i < 20; i++ {
+		mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: fmt.Sprintf("w%02d", i), Status: "healthy",
+			HealthMetrics: &HealthMetrics{CPUUsage: 12, Checks: []HealthCheck{{Name: "CPU Usage", Status: "healthy", Message: "CPU usage is normal"}}}})
+	}
+
+	// get fetches target, asking for gzip when gzipped is set
+	get := func(target string, gzipped bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if gzipped {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		recorder := httptest.NewRecorder()
+		ds.routes().ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	tests := []struct {
+		target   string
+		wantGzip bool
+	}{
+		{"/api/v1/hosts", true},
+		{"/api/v1/hosts/web/w00", false}, // below gzipMinBytes
+		{"/api/v1/stats", false},
+		{"/api/v1/hosts/web/nobody", false},
+	}
+	for _, tt := range tests {
+		plain := get(tt.target, false)
+		if plain.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s without Accept-Encoding was encoded", tt.target)
+		}
+
+		recorder := get(tt.target, true)
+		if vary := recorder.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%s Vary = %q", tt.target, vary)
+		}
+		if recorder.Code != plain.Code {
+			t.Errorf("%s = %d with gzip, %d without", tt.target, recorder.Code, plain.Code)
+		}
+		if length, _ := strconv.Atoi(recorder.Header().Get("Content-Length")); length != recorder.Body.Len() {
+			t.Errorf("%s Content-Length %d for a %d-byte body", tt.target, length, recorder.Body.Len())
+		}
+
+		body := recorder.Body.Bytes()
+		if gzipped := recorder.Header().Get("Content-Encoding") == "gzip"; gzipped != tt.wantGzip {
+			t.Errorf("%s gzipped = %v for a %d-byte body, want %v", tt.target, gzipped, plain.Body.Len(), tt.wantGzip)
+			continue
+		} else if gzipped {
+			reader, err := gzip.NewReader(recorder.Body)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.target, err)
+			}
+			if body, err = io.ReadAll(reader); err != nil {
+				t.Fatalf("%s: %v", tt.target, err)
+			}
+		}
+		// Host listings come in map order, so compare them as sets
+		if !sameJSON(t, body, plain.Body.Bytes()) {
+			t.Errorf("%s decoded body differs from the uncompressed response:\n%s\n%s", tt.target, body, plain.Body)
+		}
+	}
+}
+
+// sameJSON reports whether two JSON documents hold the same values, ignoring
+// the order of a "hosts" array
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var docs [2]map[string]interface{}
+	for i, data := range [][]byte{a, b} {
+		if err := json.Unmarshal(data, &docs[i]); err != nil {
+			t.Fatalf("invalid JSON %s: %v", data, err)
+		}
+		if hosts, ok := docs[i]["hosts"].([]interface{}); ok {
+			sort.Slice(hosts, func(x, y int) bool {
+				return hosts[x].(map[string]interface{})["instance_name"].(string) < hosts[y].(map[string]interface{})["instance_name"].(string)
+			})
+		}
+	}
+	return reflect.DeepEqual(docs[0], docs[1])
+}
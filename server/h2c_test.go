@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// protocolClient speaks only the given protocols in cleartext
+func protocolClient(http1, h2c bool) *http.Client {
+	var protocols http.Protocols
+	protocols.SetHTTP1(http1)
+	protocols.SetUnencryptedHTTP2(h2c)
+	return &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+}
+
+func TestHealthServerH2C(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		ds := newTestServer(t, func(config *Config) {
+			config.BindAddress = "127.0.0.1"
+			config.ServerPort, config.HealthPort = freePort(t), freePort(t)
+			config.HealthH2C = enabled
+			config.DrainPeriod = 0
+		})
+		health := "http://127.0.0.1:" + ds.config.HealthPort + "/health"
+		stop := startServer(t, ds, "http://127.0.0.1:"+ds.config.HealthPort+"/readyz")
+
+		// HTTP/1.1 keeps working either way
+		resp, err := protocolClient(true, false).Get(health)
+		if err != nil {
+			t.Errorf("h2c %v: HTTP/1.1 request: %v", enabled, err)
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+				t.Errorf("h2c %v: HTTP/1.1 request = %d over %s", enabled, resp.StatusCode, resp.Proto)
+			}
+		}
+
+		resp, err = protocolClient(false, true).Get(health)
+		switch {
+		case enabled && err != nil:
+			t.Errorf("h2c request: %v", err)
+		case enabled:
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+				t.Errorf("h2c request = %d over %s, want 200 over HTTP/2.0", resp.StatusCode, resp.Proto)
+			}
+		case err == nil:
+			resp.Body.Close()
+			t.Errorf("h2c request answered over %s with HEALTH_H2C off", resp.Proto)
+		}
+		stop()
+	}
+}
+
+func TestMTLSNegotiatesHTTP2(t *testing.T) {
+	ca := newTestCA(t, "s01 test CA")
+	certFile, keyFile := ca.issue(t, "server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "s01-server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	})
+	ds := newTestServer(t, func(config *Config) {
+		config.EnableTLS = true
+		config.CertFile, config.KeyFile, config.CACertFile = certFile, keyFile, ca.file()
+		config.BindAddress = "127.0.0.1"
+		config.ServerPort, config.HealthPort = freePort(t), freePort(t)
+		config.DrainPeriod = 0
+	})
+	stop := startServer(t, ds, "http://127.0.0.1:"+ds.config.HealthPort+"/readyz")
+	defer stop()
+
+	client := ca.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "web-w1"}})
+	transport := client.Transport.(*http.Transport)
+	transport.ForceAttemptHTTP2 = true
+	defer transport.CloseIdleConnections()
+
+	resp, err := client.Get("https://127.0.0.1:" + ds.config.ServerPort + "/api/v1/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 || resp.TLS.NegotiatedProtocol != "h2" {
+		t.Errorf("mTLS request = %d over %s (ALPN %q), want 200 over HTTP/2.0", resp.StatusCode, resp.Proto, resp.TLS.NegotiatedProtocol)
+	}
+}
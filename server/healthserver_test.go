@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestHealthServerEnabled(t *testing.T) {
+	tests := []struct {
+		enable, port string
+		want         bool
+	}{
+		{"", "8080", true},
+		{"true", "8080", true},
+		{"false", "8080", false},
+		{"true", "", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("ENABLE_HEALTH_SERVER", tt.enable)
+		ds := newTestServer(t, func(config *Config) { config.HealthPort = tt.port })
+		if got := ds.healthServerEnabled(); got != tt.want {
+			t.Errorf("ENABLE_HEALTH_SERVER=%q, HealthPort %q: enabled %v, want %v", tt.enable, tt.port, got, tt.want)
+		}
+
+		// The API port carries the probes exactly when the health server does not
+		wantProbe := http.StatusNotFound
+		if !tt.want {
+			wantProbe = http.StatusOK
+		}
+		if code := serve(ds, http.MethodGet, "/livez").Code; code != wantProbe {
+			t.Errorf("ENABLE_HEALTH_SERVER=%q, HealthPort %q: /livez on the API port = %d, want %d", tt.enable, tt.port, code, wantProbe)
+		}
+		if code := serve(ds, http.MethodGet, "/health").Code; code != http.StatusOK {
+			t.Errorf("ENABLE_HEALTH_SERVER=%q: /health on the API port = %d, want 200", tt.enable, code)
+		}
+	}
+}
+
+func TestStartWithoutHealthServer(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.BindAddress = "127.0.0.1"
+		config.ServerPort, config.HealthPort = freePort(t), freePort(t)
+		config.EnableHealthServer = false
+		config.DrainPeriod = 0
+	})
+	// startServer fails the test unless the API port answers the probe
+	stop := startServer(t, ds, "http://127.0.0.1:"+ds.config.ServerPort+"/readyz")
+
+	if conn, err := net.Dial("tcp", "127.0.0.1:"+ds.config.HealthPort); err == nil {
+		conn.Close()
+		t.Errorf("health port %s is open with the health server disabled", ds.config.HealthPort)
+	}
+	resp, err := http.Get("http://127.0.0.1:" + ds.config.ServerPort + "/livez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/livez on the API port = %d, want 200", resp.StatusCode)
+	}
+
+	// Shutdown with one server fewer still returns cleanly
+	stop()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Host eviction policies, applied once MaxHosts hosts are known
+const (
+	hostEvictionReject = "reject" // turn reports from new hosts away
+	hostEvictionLost   = "lost"   // drop the least recently seen lost host to make room, else reject
+	hostEvictionLRU    = "lru"    // drop the least recently seen host to make room
+)
+
+// errTooManyHosts rejects a report from a new host once the host limit is reached
+var errTooManyHosts = errors.New("host limit reached")
+
+// validateHostEviction rejects unknown host eviction policies
+func validateHostEviction(policy string) error {
+	switch policy {
+	case hostEvictionReject, hostEvictionLost, hostEvictionLRU:
+		return nil
+	default:
+		return fmt.Errorf("invalid host_eviction %q (expected %s, %s or %s)", policy, hostEvictionReject, hostEvictionLost, hostEvictionLRU)
+	}
+}
+
+// limitHosts caps the number of hosts held at maxHosts, 0 meaning no cap.
+// Statuses of known hosts are always accepted. What happens to a new host
+// beyond the cap depends on policy: under hostEvictionReject it is rejected
+// with errTooManyHosts, under hostEvictionLost it takes the place of the
+// least recently seen host whose current status is lostStatus, if any, and
+// under hostEvictionLRU that of the least recently seen host.
+func (s *InMemoryStorage) limitHosts(maxHosts int, policy, lostStatus string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.maxHosts = maxHosts
+	s.eviction = policy
+	s.lostStatus = lostStatus
+}
+
+// hostLimitReachedLocked reports whether status comes from a new host while
+// the cap is reached; the caller must hold s.mutex
+func (s *InMemoryStorage) hostLimitReachedLocked(status HostStatus) bool {
+	if s.maxHosts == 0 || len(s.hosts) < s.maxHosts {
+		return false
+	}
+	_, exists := s.hosts[hostKey(status.ServiceName, status.InstanceName)]
+	return !exists
+}
+
+// evictionsLocked picks the least recently seen hosts to evict to make room
+// for status's host under the host limit, or returns errTooManyHosts when
+// too few may be evicted. Nothing is evicted yet; the caller must hold
+// s.mutex.
+func (s *InMemoryStorage) evictionsLocked(status HostStatus) ([]*HostHistory, error) {
+	if !s.hostLimitReachedLocked(status) {
+		return nil, nil
+	}
+
+	hosts := make([]*HostHistory, 0, len(s.hosts))
+	for _, hostHistory := range s.hosts {
+		switch s.eviction {
+		case hostEvictionLRU:
+			hosts = append(hosts, hostHistory)
+		case hostEvictionLost:
+			if hostHistory.isLost(s.lostStatus) {
+				hosts = append(hosts, hostHistory)
+			}
+		}
+	}
+	needed := len(s.hosts) - s.maxHosts + 1
+	if len(hosts) < needed {
+		return nil, errTooManyHosts
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].lastSeen().Before(hosts[j].lastSeen())
+	})
+	return hosts[:needed], nil
+}
+
+// evictLocked drops hosts chosen by evictionsLocked; the caller must hold
+// s.mutex for writing
+func (s *InMemoryStorage) evictLocked(evicted []*HostHistory) {
+	for _, hostHistory := range evicted {
+		delete(s.hosts, hostKey(hostHistory.ServiceName, hostHistory.InstanceName))
+		s.forgetLocked(hostHistory.ServiceName, hostHistory.InstanceName)
+
+		s.logger.Warn("Host limit reached, evicted least recently seen host",
+			"max_hosts", s.maxHosts,
+			"service_name", hostHistory.ServiceName,
+			"instance_name", hostHistory.InstanceName,
+			"last_seen", hostHistory.lastSeen(),
+			"lost", hostHistory.isLost(s.lostStatus),
+		)
+	}
+}
+
+// lastSeen returns the host's LastSeen under its lock
+func (h *HostHistory) lastSeen() time.Time {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return h.LastSeen
+}
+
+// isLost reports whether the host's current status is lostStatus, which is
+// never the case when lostStatus is empty
+func (h *HostHistory) isLost(lostStatus string) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return lostStatus != "" && h.CurrentStatus == lostStatus
+}
+
+// hostLimitError logs and describes a report from a new host turned away by MaxHosts
+func hostLimitError(logger *slog.Logger, req StatusRequest) *reportError {
+	logger.Warn("Rejected status report from new host, host limit reached",
+		"service_name", req.ServiceName,
+		"instance_name", req.InstanceName,
+	)
+	return &reportError{http.StatusInsufficientStorage, errCodeHostLimit, "Host limit reached; reports from new hosts are not accepted"}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostLimitLostMakesRoomForLostHosts(t *testing.T) {
+	url, _ := webhookEvents(t)
+	ds := newTestServer(t, func(config *Config) {
+		config.MaxHosts = 2
+		config.HostEviction = hostEvictionLost
+		config.StaleGracePeriod = 0
+		config.WebhookURL = url
+	})
+	w1 := hostKey("web", "w1")
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+	ds.limiter.allow(w1, time.Now())
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w2", Status: "healthy"})
+
+	if rerr := ds.processReport(ds.logger, StatusRequest{ServiceName: "web", InstanceName: "w3", Status: "healthy"}, "192.0.2.1", "", ""); rerr == nil || rerr.status != http.StatusInsufficientStorage {
+		t.Fatalf("report past the cap with both hosts reporting = %+v, want 507", rerr)
+	}
+
+	// w1 goes quiet and is marked lost; w2 keeps reporting
+	later := time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second)
+	if _, err := ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w2", Status: "healthy", Timestamp: later}); err != nil {
+		t.Fatal(err)
+	}
+	if lost := ds.sweepStaleHosts(later); lost != 1 {
+		t.Fatalf("%d hosts marked lost, want w1", lost)
+	}
+
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w3", Status: "healthy"})
+	if _, found, _ := ds.storage.GetHostSnapshot("web", "w1"); found {
+		t.Error("lost host w1 still held after a new host took its place")
+	}
+	if hosts, _ := ds.storage.Count(); hosts != 2 {
+		t.Errorf("%d hosts held, want the cap of 2", hosts)
+	}
+
+	for transition := range ds.webhook.lastSent {
+		if strings.HasPrefix(transition, w1+"|") {
+			t.Errorf("webhook still holds the evicted host's transition %s", transition)
+		}
+	}
+	if _, ok := ds.limiter.buckets[w1]; ok {
+		t.Error("rate limiter still holds the evicted host's bucket")
+	}
+}
+
+func TestEvictionsLockedPolicies(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		policy  string
+		lost    []string // instances marked lost before the new host reports
+		want    string   // instance evicted, empty when none
+		wantErr error
+	}{
+		{"reject with none lost", hostEvictionReject, nil, "", errTooManyHosts},
+		{"reject keeps lost hosts", hostEvictionReject, []string{"b"}, "", errTooManyHosts},
+		{"lost with none lost", hostEvictionLost, nil, "", errTooManyHosts},
+		{"lost evicts the lost host", hostEvictionLost, []string{"b"}, "b", nil},
+		{"lost evicts the oldest lost host", hostEvictionLost, []string{"c", "b"}, "b", nil},
+		{"lru evicts the oldest host", hostEvictionLRU, nil, "a", nil},
+		{"lru ignores lost status", hostEvictionLRU, []string{"c"}, "a", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, _ := NewInMemoryStorage(10, 0, nil, "", discardLogger)
+			storage.limitHosts(3, tt.policy, "lost")
+			for i, instance := range []string{"a", "b", "c"} {
+				storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: instance, Status: "healthy", Timestamp: start.Add(time.Duration(i) * time.Minute)})
+			}
+			for _, instance := range tt.lost {
+				storage.MarkLost("web", instance, start.Add(time.Hour), "lost")
+			}
+
+			storage.mutex.Lock()
+			evicted, err := storage.evictionsLocked(HostStatus{ServiceName: "web", InstanceName: "d"})
+			storage.mutex.Unlock()
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			var got string
+			if len(evicted) == 1 {
+				got = evicted[0].InstanceName
+			}
+			if len(evicted) > 1 || got != tt.want {
+				t.Errorf("evicted %d hosts, first %q; want %q", len(evicted), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostLimitNewVersusExistingHosts(t *testing.T) {
+	report := func(instance, status string) string {
+		return `{"service_name":"web","instance_name":"` + instance + `","status":"` + status + `"}`
+	}
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			var logs bytes.Buffer
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+				config.MaxHosts = 2
+			})
+			ds.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w2", Status: "healthy"})
+
+			tests := []struct {
+				target, body string
+				want         int
+			}{
+				{"/api/v1/report", report("w3", "healthy"), http.StatusInsufficientStorage},
+				{"/api/v1/report", report("w1", "degraded"), http.StatusOK},
+				{"/api/v1/report", report("w2", "healthy"), http.StatusOK},
+				// Within a batch only the new host is turned away
+				{"/api/v1/report/batch", "[" + report("w1", "healthy") + "," + report("w4", "healthy") + "]", http.StatusMultiStatus},
+			}
+			for _, tt := range tests {
+				if recorder := post(ds, tt.target, tt.body); recorder.Code != tt.want {
+					t.Errorf("POST %s %s = %d, want %d", tt.target, tt.body, recorder.Code, tt.want)
+				}
+			}
+			if recorder := post(ds, "/api/v1/report", report("w5", "healthy")); !strings.Contains(recorder.Body.String(), errCodeHostLimit) {
+				t.Errorf("rejection body %s, want %s", recorder.Body, errCodeHostLimit)
+			}
+
+			hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"))
+			if hosts.Total != 2 {
+				t.Errorf("%d hosts held, want the cap of 2", hosts.Total)
+			}
+			if !strings.Contains(logs.String(), `"instance_name":"w3"`) {
+				t.Errorf("rejection of w3 not logged: %s", logs.String())
+			}
+		})
+	}
+}
+
+func TestHostLimitLRUEvictsLeastRecentlySeen(t *testing.T) {
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+				config.MaxHosts = 3
+				config.HostEviction = hostEvictionLRU
+			})
+			start := time.Now().Add(-time.Hour)
+			for i, instance := range []string{"w1", "w2", "w3"} {
+				ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: instance, Status: "healthy", Timestamp: start.Add(time.Duration(i) * time.Minute)})
+			}
+			// w1 reports again, so w2 is now the least recently seen
+			ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: start.Add(10 * time.Minute)})
+
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w4", Status: "healthy"})
+			var held []string
+			for _, host := range decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts")).Hosts {
+				held = append(held, host.InstanceName)
+			}
+			sort.Strings(held)
+			if strings.Join(held, ",") != "w1,w3,w4" {
+				t.Errorf("hosts held %v, want w2 evicted", held)
+			}
+		})
+	}
+}
+
+func TestLoadConfigHostLimit(t *testing.T) {
+	tests := []struct {
+		maxHosts, eviction string
+		wantErr            bool
+	}{
+		{"", "", false},
+		{"1000", "lru", false},
+		{"1000", "lost", false},
+		{"1000", "reject", false},
+		{"-1", "", true},
+		{"1000", "random", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("ENABLE_TLS", "false")
+		t.Setenv("MAX_HOSTS", tt.maxHosts)
+		t.Setenv("HOST_EVICTION", tt.eviction)
+		if _, err := loadConfig(); (err != nil) != tt.wantErr {
+			t.Errorf("MAX_HOSTS=%q HOST_EVICTION=%q: err = %v, want error %v", tt.maxHosts, tt.eviction, err, tt.wantErr)
+		}
+	}
+}
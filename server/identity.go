@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// Client identity sources, selecting what is recorded as a report's ClientID
+const (
+	clientIDSourceAuto   = "auto"   // SPIFFE URI SAN when the certificate has one, otherwise the CN
+	clientIDSourceSPIFFE = "spiffe" // SPIFFE URI SAN only
+	clientIDSourceCN     = "cn"     // Subject Common Name only
+)
+
+// validateClientIDSource checks a CLIENT_ID_SOURCE value
+func validateClientIDSource(source string) error {
+	switch source {
+	case clientIDSourceAuto, clientIDSourceSPIFFE, clientIDSourceCN:
+		return nil
+	default:
+		return fmt.Errorf("unknown client_id_source %q (expected %s, %s or %s)",
+			source, clientIDSourceAuto, clientIDSourceSPIFFE, clientIDSourceCN)
+	}
+}
+
+// getClientID returns the identity of the client certificate according to
+// source, or "" when the request carries no certificate or the chosen source
+// is absent from it
+func getClientID(r *http.Request, source string) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	cert := r.TLS.PeerCertificates[0]
+
+	switch source {
+	case clientIDSourceCN:
+		return cert.Subject.CommonName
+	case clientIDSourceSPIFFE:
+		return spiffeID(cert)
+	default:
+		if id := spiffeID(cert); id != "" {
+			return id
+		}
+		return cert.Subject.CommonName
+	}
+}
+
+// spiffeID returns the first spiffe:// URI SAN of cert, or ""
+func spiffeID(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" && uri.Host != "" {
+			return uri.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGetClientID(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://prod.example/ns/web/sa/w1")
+	https, _ := url.Parse("https://web-w1.example")
+	bare, _ := url.Parse("spiffe:///no-trust-domain")
+
+	certs := map[string]*x509.Certificate{
+		"spiffe":   {Subject: pkix.Name{CommonName: "web-w1"}, URIs: []*url.URL{https, spiffe}},
+		"cn only":  {Subject: pkix.Name{CommonName: "web-w1"}},
+		"other":    {Subject: pkix.Name{CommonName: "web-w1"}, URIs: []*url.URL{https, bare}},
+		"uri only": {URIs: []*url.URL{spiffe}},
+	}
+	tests := []struct {
+		cert, source, want string
+	}{
+		{"spiffe", clientIDSourceAuto, spiffe.String()},
+		{"spiffe", clientIDSourceSPIFFE, spiffe.String()},
+		{"spiffe", clientIDSourceCN, "web-w1"},
+		{"cn only", clientIDSourceAuto, "web-w1"},
+		{"cn only", clientIDSourceSPIFFE, ""},
+		{"cn only", clientIDSourceCN, "web-w1"},
+		{"other", clientIDSourceAuto, "web-w1"},
+		{"other", clientIDSourceSPIFFE, ""},
+		{"uri only", clientIDSourceAuto, spiffe.String()},
+		{"uri only", clientIDSourceCN, ""},
+	}
+	for _, tt := range tests {
+		r := &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{certs[tt.cert]}}}
+		if got := getClientID(r, tt.source); got != tt.want {
+			t.Errorf("%s cert, source %s: client ID = %q, want %q", tt.cert, tt.source, got, tt.want)
+		}
+	}
+
+	if got := getClientID(&http.Request{}, clientIDSourceAuto); got != "" {
+		t.Errorf("plain HTTP request has client ID %q", got)
+	}
+}
+
+func TestLoadConfigClientIDSource(t *testing.T) {
+	t.Setenv("ENABLE_TLS", "false")
+	config, err := loadConfig()
+	if err != nil || config.ClientIDSource != clientIDSourceAuto {
+		t.Fatalf("default client_id_source = %v, %v; want auto", config, err)
+	}
+	t.Setenv("CLIENT_ID_SOURCE", "san")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "client_id_source") {
+		t.Errorf("CLIENT_ID_SOURCE=san = %v, want it refused", err)
+	}
+}
+
+func TestReportRecordsClientID(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://prod.example/ns/web/sa/w1")
+	tests := []struct {
+		name   string
+		source string
+		uris   []*url.URL
+		want   string
+	}{
+		{"SVID", clientIDSourceAuto, []*url.URL{spiffe}, spiffe.String()},
+		{"CN only", clientIDSourceAuto, nil, "web-w1"},
+		{"CN authoritative", clientIDSourceCN, []*url.URL{spiffe}, "web-w1"},
+	}
+	for _, tt := range tests {
+		ca := newTestCA(t, "s01 test CA")
+		_, baseURL := newTLSTestServer(t, ca, func(config *Config) { config.ClientIDSource = tt.source })
+		client := ca.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "web-w1"}, URIs: tt.uris})
+
+		resp, err := client.Post(baseURL+"/api/v1/report", "application/json",
+			strings.NewReader(`{"service_name":"web","instance_name":"w1","status":"healthy"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: report status = %d", tt.name, resp.StatusCode)
+		}
+
+		resp, err = client.Get(baseURL + "/api/v1/hosts")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hosts DiscoveryResponse
+		err = json.NewDecoder(resp.Body).Decode(&hosts)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hosts.Hosts) != 1 || hosts.Hosts[0].ClientID != tt.want || hosts.Hosts[0].ClientCN != "web-w1" {
+			t.Errorf("%s: hosts = %+v, want client_id %q beside client_cn web-w1", tt.name, hosts.Hosts, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+// advisedInterval is the report interval, in seconds, the server asks clients
+// to use: the larger of AdvisedInterval and the interval that keeps the whole
+// fleet within TargetReportRate reports per second. 0 gives no advice, so
+// clients keep their configured interval.
+func (ds *S01Server) advisedInterval() int {
+	interval := ds.config.AdvisedInterval
+	if ds.config.TargetReportRate > 0 {
+		if hosts, err := ds.storage.Count(); err == nil {
+			// Round up so the fleet stays at or below the target rate
+			interval = max(interval, (hosts+ds.config.TargetReportRate-1)/ds.config.TargetReportRate)
+		}
+	}
+	return interval
+}
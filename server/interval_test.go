@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAdvisedInterval(t *testing.T) {
+	tests := []struct {
+		advised, targetRate, hosts int
+		want                       int
+	}{
+		{0, 0, 50, 0},
+		{30, 0, 50, 30},
+		{0, 10, 50, 5},
+		{0, 10, 51, 6}, // rounded up to stay within the rate
+		{30, 10, 50, 30},
+		{30, 1, 50, 50},
+		{0, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		ds := newTestServer(t, func(config *Config) {
+			config.AdvisedInterval, config.TargetReportRate = tt.advised, tt.targetRate
+		})
+		for i := 0; i < tt.hosts; i++ {
+			ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: fmt.Sprintf("w%d", i), Status: "healthy"})
+		}
+		if got := ds.advisedInterval(); got != tt.want {
+			t.Errorf("advised %d, target %d/s, %d hosts: interval %d, want %d", tt.advised, tt.targetRate, tt.hosts, got, tt.want)
+		}
+	}
+}
+
+func TestRepliesAdviseInterval(t *testing.T) {
+	report := `{"service_name":"web","instance_name":"w1","status":"healthy"}`
+	for _, advised := range []int{0, 90} {
+		ds := newTestServer(t, func(config *Config) { config.AdvisedInterval = advised })
+
+		single := post(ds, "/api/v1/report", report).Body.String()
+		var resp StatusResponse
+		if err := json.Unmarshal([]byte(single), &resp); err != nil || resp.NextIntervalSeconds != advised {
+			t.Errorf("ADVISED_INTERVAL=%d: report reply %+v, %v", advised, resp, err)
+		}
+		batch := post(ds, "/api/v1/report/batch", "["+report+"]")
+		var batchResp BatchResponse
+		if err := json.NewDecoder(batch.Body).Decode(&batchResp); err != nil || batchResp.NextIntervalSeconds != advised {
+			t.Errorf("ADVISED_INTERVAL=%d: batch reply %+v, %v", advised, batchResp, err)
+		}
+
+		// No advice leaves the field out, as older servers replied
+		if advised == 0 && strings.Contains(single, "next_interval_seconds") {
+			t.Errorf("reply without advice %s mentions next_interval_seconds", single)
+		}
+	}
+}
+
+func TestLoadConfigRejectsNegativeAdvice(t *testing.T) {
+	for _, env := range []string{"ADVISED_INTERVAL", "TARGET_REPORT_RATE"} {
+		t.Setenv("ENABLE_TLS", "false")
+		t.Setenv(env, "-1")
+		if _, err := loadConfig(); err == nil {
+			t.Errorf("%s=-1 accepted", env)
+		}
+		t.Setenv(env, "")
+	}
+}
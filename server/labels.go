@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bounds on the labels a client may attach to its reports
+const (
+	maxLabels        = 32
+	maxLabelKeyLen   = 64
+	maxLabelValueLen = 256
+)
+
+// validateLabels checks labels against the size bounds
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("too many labels: %d (max %d)", len(labels), maxLabels)
+	}
+	for key, value := range labels {
+		if key == "" {
+			return fmt.Errorf("label keys must not be empty")
+		}
+		if len(key) > maxLabelKeyLen {
+			return fmt.Errorf("label key exceeds %d bytes", maxLabelKeyLen)
+		}
+		if len(value) > maxLabelValueLen {
+			return fmt.Errorf("value of label %q exceeds %d bytes", key, maxLabelValueLen)
+		}
+	}
+	return nil
+}
+
+// labelFilter is one ?label= or ?match= query parameter: "key=value"
+// requires that value, "key!=value" requires the label to be absent or hold
+// another value, and a bare "key" only requires the label to be present
+type labelFilter struct {
+	key      string
+	value    string
+	hasValue bool
+	negate   bool
+}
+
+// parseLabelFilters parses the values of repeated ?label= or ?match=
+// parameters, rejecting a predicate without a key such as "=a" or ""
+func parseLabelFilters(values []string) ([]labelFilter, error) {
+	var filters []labelFilter
+	for _, value := range values {
+		key, want, negate := strings.Cut(value, "!=")
+		hasValue := negate
+		if !negate {
+			key, want, hasValue = strings.Cut(value, "=")
+		}
+		if key = strings.TrimSpace(key); key == "" {
+			return nil, fmt.Errorf("label predicate %q has no key", value)
+		}
+		filters = append(filters, labelFilter{key: key, value: strings.TrimSpace(want), hasValue: hasValue, negate: negate})
+	}
+	return filters, nil
+}
+
+// matchesLabels reports whether labels satisfy every filter
+func matchesLabels(labels map[string]string, filters []labelFilter) bool {
+	for _, filter := range filters {
+		value, ok := labels[filter.key]
+		if filter.negate {
+			if ok && value == filter.value {
+				return false
+			}
+			continue
+		}
+		if !ok || (filter.hasValue && value != filter.value) {
+			return false
+		}
+	}
+	return true
+}
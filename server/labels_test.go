@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLabelFilters(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    []labelFilter
+		wantErr bool
+	}{
+		{values: nil},
+		{values: []string{"zone=a"}, want: []labelFilter{{key: "zone", value: "a", hasValue: true}}},
+		{values: []string{"gpu"}, want: []labelFilter{{key: "gpu"}}},
+		{values: []string{"zone!=a"}, want: []labelFilter{{key: "zone", value: "a", hasValue: true, negate: true}}},
+		{values: []string{" zone = a "}, want: []labelFilter{{key: "zone", value: "a", hasValue: true}}},
+		{values: []string{"zone="}, want: []labelFilter{{key: "zone", hasValue: true}}},
+		{values: []string{"expr=a=b"}, want: []labelFilter{{key: "expr", value: "a=b", hasValue: true}}},
+		{values: []string{"=a"}, wantErr: true},
+		{values: []string{""}, wantErr: true},
+		{values: []string{"!=b"}, wantErr: true},
+		{values: []string{"zone=a", " = a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLabelFilters(tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLabelFilters(%q) error = %v, wantErr %v", tt.values, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLabelFilters(%q) = %+v, want %+v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesLabels(t *testing.T) {
+	labels := map[string]string{"zone": "a", "gpu": "true", "empty": ""}
+	tests := []struct {
+		filters string
+		want    bool
+	}{
+		{"", true},
+		{"zone=a", true},
+		{"zone=b", false},
+		{"gpu", true},
+		{"missing", false},
+		{"zone!=b", true},
+		{"zone!=a", false},
+		{"missing!=a", true},
+		{"empty=", true},
+		{"zone=a,gpu=true", true},
+		{"zone=a,gpu=false", false},
+	}
+	for _, tt := range tests {
+		var values []string
+		if tt.filters != "" {
+			values = strings.Split(tt.filters, ",")
+		}
+		filters, err := parseLabelFilters(values)
+		if err != nil {
+			t.Fatalf("parseLabelFilters(%q): %v", values, err)
+		}
+		if got := matchesLabels(labels, filters); got != tt.want {
+			t.Errorf("matchesLabels(%q) = %v, want %v", tt.filters, got, tt.want)
+		}
+	}
+	if !matchesLabels(nil, nil) || matchesLabels(nil, []labelFilter{{key: "zone"}}) {
+		t.Error("a host without labels must only satisfy no predicates")
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	many := make(map[string]string)
+	for i := 0; i <= maxLabels; i++ {
+		many[strings.Repeat("k", i+1)] = "v"
+	}
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"some", map[string]string{"zone": "a"}, false},
+		{"too many", many, true},
+		{"empty key", map[string]string{"": "a"}, true},
+		{"long key", map[string]string{strings.Repeat("k", maxLabelKeyLen+1): "a"}, true},
+		{"long value", map[string]string{"zone": strings.Repeat("v", maxLabelValueLen+1)}, true},
+	}
+	for _, tt := range tests {
+		if err := validateLabels(tt.labels); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateLabels error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReportLabelsRoundTrip(t *testing.T) {
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+			})
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy", Labels: map[string]string{"region": "us-east", "zone": "a"}})
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w2", Status: "healthy", Labels: map[string]string{"region": "eu-west"}})
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w3", Status: "healthy"})
+
+			hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts?service=web&label=region=us-east"))
+			if len(hosts.Hosts) != 1 || hosts.Hosts[0].InstanceName != "w1" {
+				t.Fatalf("region=us-east matched %+v, want w1 alone", hosts.Hosts)
+			}
+			if want := map[string]string{"region": "us-east", "zone": "a"}; !reflect.DeepEqual(hosts.Hosts[0].Labels, want) {
+				t.Errorf("labels = %v, want %v", hosts.Hosts[0].Labels, want)
+			}
+
+			tests := []struct {
+				query string
+				want  int
+			}{
+				{"", 3},
+				{"&label=region", 2},
+				{"&label=region=us-east&label=zone=b", 0},
+				{"&label=region=ap-south", 0},
+			}
+			for _, tt := range tests {
+				if hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts?service=web"+tt.query)); len(hosts.Hosts) != tt.want {
+					t.Errorf("%q matched %d hosts, want %d", tt.query, len(hosts.Hosts), tt.want)
+				}
+			}
+
+			// A later report replaces the labels rather than merging them
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy", Labels: map[string]string{"region": "eu-west"}})
+			hosts = decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts?service=web&label=region=eu-west"))
+			if len(hosts.Hosts) != 2 {
+				t.Errorf("after relabelling w1, region=eu-west matched %+v, want w1 and w2", hosts.Hosts)
+			}
+		})
+	}
+}
+
+func TestReportRejectsOversizedLabels(t *testing.T) {
+	ds := newTestServer(t, nil)
+	recorder := post(ds, "/api/v1/report", `{"service_name":"web","instance_name":"w1","status":"healthy","labels":{"region":"`+strings.Repeat("x", maxLabelValueLen+1)+`"}}`)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "region") {
+		t.Errorf("oversized label = %d %s, want 400 naming the label", recorder.Code, recorder.Body)
+	}
+	if hosts, _ := ds.storage.Count(); hosts != 0 {
+		t.Errorf("%d hosts stored from a rejected report", hosts)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatestMatchesEndOfHistory(t *testing.T) {
+	reports := []StatusRequest{
+		{Status: "healthy", HealthMetrics: &HealthMetrics{CPUUsage: 10, OverallScore: 95}, Labels: map[string]string{"zone": "a"}},
+		{Status: "degraded", HealthMetrics: &HealthMetrics{CPUUsage: 85, OverallScore: 70,
+			Checks: []HealthCheck{{Name: "CPU Usage", Status: "degraded", Value: "85.0%"}}}, KernelVersion: "6.8.0"},
+		{Status: "healthy", HealthMetrics: &HealthMetrics{CPUUsage: 12, OverallScore: 92}, Arch: "arm64"},
+	}
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+			})
+			compare := func(step string) HostResponse {
+				t.Helper()
+				var detail HostHistoryResponse
+				if err := json.NewDecoder(serve(ds, http.MethodGet, "/api/v1/hosts/web/w1").Body).Decode(&detail); err != nil {
+					t.Fatal(err)
+				}
+				recorder := serve(ds, http.MethodGet, "/api/v1/hosts/web/w1/latest")
+				if strings.Contains(recorder.Body.String(), `"statuses"`) {
+					t.Errorf("%s: /latest carries the history", step)
+				}
+				var latest HostResponse
+				if err := json.NewDecoder(recorder.Body).Decode(&latest); err != nil {
+					t.Fatal(err)
+				}
+
+				last := detail.Statuses[len(detail.Statuses)-1]
+				want := HostResponse{
+					ServiceName:   "web",
+					InstanceName:  "w1",
+					Status:        detail.CurrentStatus,
+					IPAddress:     last.IPAddress,
+					LastSeen:      detail.LastSeen,
+					HealthMetrics: last.HealthMetrics,
+					Labels:        last.Labels,
+					StableSince:   detail.StableSince,
+					FlapCount:     detail.FlapCount,
+					KernelVersion: last.KernelVersion,
+					Arch:          last.Arch,
+				}
+				if !reflect.DeepEqual(latest, want) {
+					t.Errorf("%s: /latest = %+v\nwant the end of the history %+v", step, latest, want)
+				}
+				return latest
+			}
+
+			for i, req := range reports {
+				req.ServiceName, req.InstanceName = "web", "w1"
+				mustReport(t, ds, req)
+				compare(fmt.Sprintf("report %d", i+1))
+			}
+			// Once lost, the status changes while the metrics stay the last reported
+			ds.sweepStaleHosts(time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second))
+			if latest := compare("after going stale"); latest.Status != "lost" || latest.HealthMetrics.OverallScore != 92 {
+				t.Errorf("stale host latest = %s with score %d, want lost with the last reported 92", latest.Status, latest.HealthMetrics.OverallScore)
+			}
+
+			unknown := serve(ds, http.MethodGet, "/api/v1/hosts/web/nobody/latest")
+			if unknown.Code != http.StatusNotFound || !strings.Contains(unknown.Body.String(), errCodeNotFound) {
+				t.Errorf("unknown host = %d %s, want 404", unknown.Code, unknown.Body)
+			}
+		})
+	}
+}
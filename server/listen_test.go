@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestHealthBindAddress(t *testing.T) {
+	tests := []struct {
+		bind, healthBind string
+		want             string
+	}{
+		{"", "", ""},
+		{"10.0.0.5", "", "10.0.0.5"},
+		{"10.0.0.5", "127.0.0.1", "127.0.0.1"},
+		{"", "::1", "::1"},
+	}
+	for _, tt := range tests {
+		t.Setenv("BIND_ADDRESS", tt.bind)
+		t.Setenv("HEALTH_BIND_ADDRESS", tt.healthBind)
+		ds := newTestServer(t, nil)
+		if ds.config.BindAddress != tt.bind {
+			t.Errorf("BIND_ADDRESS=%q loaded as %q", tt.bind, ds.config.BindAddress)
+		}
+		if got := ds.healthBindAddress(); got != tt.want {
+			t.Errorf("bind %q, health bind %q: health server binds %q, want %q", tt.bind, tt.healthBind, got, tt.want)
+		}
+	}
+}
+
+func TestStartBindsConfiguredAddresses(t *testing.T) {
+	// All of 127.0.0.0/8 is loopback on Linux, so the two addresses tell
+	// apart a listener bound to one interface from one bound to all
+	probe, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 unavailable: %v", err)
+	}
+	probe.Close()
+
+	ds := newTestServer(t, func(config *Config) {
+		config.BindAddress, config.HealthBindAddress = "127.0.0.1", "127.0.0.2"
+		config.ServerPort, config.HealthPort = freePort(t), freePort(t)
+		config.DrainPeriod = 0
+	})
+	stop := startServer(t, ds, "http://127.0.0.2:"+ds.config.HealthPort+"/readyz")
+	defer stop()
+
+	tests := []struct {
+		host, port string
+		wantOpen   bool
+	}{
+		{"127.0.0.1", ds.config.ServerPort, true},
+		{"127.0.0.2", ds.config.ServerPort, false},
+		{"127.0.0.2", ds.config.HealthPort, true},
+		{"127.0.0.1", ds.config.HealthPort, false},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", net.JoinHostPort(tt.host, tt.port))
+		if err == nil {
+			conn.Close()
+		}
+		if open := err == nil; open != tt.wantOpen {
+			t.Errorf("%s:%s accepting = %v, want %v", tt.host, tt.port, open, tt.wantOpen)
+		}
+	}
+}
@@ -3,50 +3,59 @@ package main
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/management/s01-shared"
 )
 
-// HealthCheck represents a single health check result
-type HealthCheck struct {
-	Name    string `json:"name"`
-	Status  string `json:"status"`
-	Message string `json:"message,omitempty"`
-	Value   string `json:"value,omitempty"`
-}
+// Build information, set at link time with
+// -ldflags "-X main.version=... -X main.gitCommit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	gitCommit = "dev"
+	buildDate = "dev"
+)
 
-// HealthMetrics contains system health metrics
-type HealthMetrics struct {
-	CPUUsage     float64       `json:"cpu_usage"`
-	MemoryUsage  float64       `json:"memory_usage"`
-	DiskUsage    float64       `json:"disk_usage"`
-	NetworkOk    bool          `json:"network_ok"`
-	Checks       []HealthCheck `json:"checks"`
-	OverallScore int           `json:"overall_score"`
-}
+const (
+	maxHealthChecks         = 64
+	maxRecentErrorSamples   = 5
+	maxRecentErrorSampleLen = 256
+)
 
 // HostStatus represents the status report from a host
 type HostStatus struct {
-	ServiceName   string         `json:"service_name"`
-	InstanceName  string         `json:"instance_name"`
-	IPAddress     string         `json:"ip_address"`
-	Status        string         `json:"status"`
-	Timestamp     time.Time      `json:"timestamp"`
-	ClientCN      string         `json:"client_cn,omitempty"` // Certificate Common Name
-	HealthMetrics *HealthMetrics `json:"health_metrics,omitempty"`
+	ServiceName   string            `json:"service_name"`
+	InstanceName  string            `json:"instance_name"`
+	IPAddress     string            `json:"ip_address"`
+	Status        string            `json:"status"`
+	Timestamp     time.Time         `json:"timestamp"`                  // when the server received the report
+	ClientTime    *time.Time        `json:"client_timestamp,omitempty"` // when the client took the report
+	Sequence      uint64            `json:"sequence,omitempty"`         // client-assigned, increasing per host
+	ClientCN      string            `json:"client_cn,omitempty"`        // Certificate Common Name
+	ClientID      string            `json:"client_id,omitempty"`        // certificate identity chosen by ClientIDSource
+	Labels        map[string]string `json:"labels,omitempty"`           // operator-defined tags, e.g. region or zone
+	HealthMetrics *HealthMetrics    `json:"health_metrics,omitempty"`
+	RecentErrors  *RecentErrors     `json:"recent_errors,omitempty"`
+	KernelVersion string            `json:"kernel_version,omitempty"`
+	OSRelease     string            `json:"os_release,omitempty"`
+	Arch          string            `json:"arch,omitempty"`
 }
 
 // HostHistory holds the history of statuses for a specific host
@@ -55,124 +64,255 @@ type HostHistory struct {
 	InstanceName string       `json:"instance_name"`
 	Statuses     []HostStatus `json:"statuses"`
 	LastSeen     time.Time    `json:"last_seen"`
-	mutex        sync.RWMutex `json:"-"`
+	LastSequence uint64       `json:"last_sequence"` // highest client sequence accepted
+	FirstSeen    time.Time    `json:"first_seen"`    // timestamp of the first status held; after a restart, the first one restored
+	Reports      int          `json:"reports"`       // statuses accepted, unchanged ones that only refreshed LastSeen included
+	// CurrentStatus is the latest reported status, or StaleStatus ("lost" by
+	// default) once the stale sweeper finds the host silent for longer than
+	// StaleTimeout
+	CurrentStatus string       `json:"current_status"`
+	mutex         sync.RWMutex `json:"-"`
 }
 
 // HostHistoryResponse is used for JSON responses to avoid mutex copying
 type HostHistoryResponse struct {
-	ServiceName  string       `json:"service_name"`
-	InstanceName string       `json:"instance_name"`
-	Statuses     []HostStatus `json:"statuses"`
-	LastSeen     time.Time    `json:"last_seen"`
+	ServiceName   string       `json:"service_name"`
+	InstanceName  string       `json:"instance_name"`
+	Statuses      []HostStatus `json:"statuses"`
+	LastSeen      time.Time    `json:"last_seen"`
+	CurrentStatus string       `json:"current_status"`
+	StableSince   *time.Time   `json:"stable_since,omitempty"` // start of the latest reported status's unbroken run
+	FlapCount     int          `json:"flap_count"`             // status changes within the retained history
 }
 
 // HostResponse represents a simplified host for public API responses
 type HostResponse struct {
-	ServiceName   string         `json:"service_name"`
-	InstanceName  string         `json:"instance_name"`
-	Status        string         `json:"status"`
-	IPAddress     string         `json:"ip_address"`
-	LastSeen      time.Time      `json:"last_seen"`
-	HealthMetrics *HealthMetrics `json:"health_metrics,omitempty"`
-	ClientCN      string         `json:"client_cn,omitempty"`
+	ServiceName   string            `json:"service_name"`
+	InstanceName  string            `json:"instance_name"`
+	Status        string            `json:"status"`
+	IPAddress     string            `json:"ip_address"`
+	LastSeen      time.Time         `json:"last_seen"`
+	HealthMetrics *HealthMetrics    `json:"health_metrics,omitempty"`
+	ClientCN      string            `json:"client_cn,omitempty"`
+	ClientID      string            `json:"client_id,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	ClockSkew     *float64          `json:"clock_skew_seconds,omitempty"` // server receive time minus client timestamp of the latest report
+	StableSince   *time.Time        `json:"stable_since,omitempty"`       // start of the latest reported status's unbroken run
+	FlapCount     int               `json:"flap_count"`                   // status changes within the retained history
+	KernelVersion string            `json:"kernel_version,omitempty"`
+	OSRelease     string            `json:"os_release,omitempty"`
+	Arch          string            `json:"arch,omitempty"`
 }
 
 type S01Server struct {
-	hosts      map[string]*HostHistory // key: service_name:instance_name
-	maxHistory int
-	mutex      sync.RWMutex
-	logger     *slog.Logger
-	config     *Config
-	tlsConfig  *tls.Config
+	storage   Storage
+	logger    *slog.Logger
+	config    *Config
+	tlsConfig *tls.Config
+	certs     *certStore    // nil when TLS is disabled
+	draining  atomic.Bool   // set on shutdown; new reports are rejected
+	ready     atomic.Bool   // set once the main listener is accepting
+	revision  atomic.Uint64 // counts changes to stored host state; see hostsETag
+	webhook   *webhookNotifier
+	tracer    *shared.Tracer // nil when tracing is disabled
+	ipLog     *ipRedactor
+	limiter   *reportLimiter // nil when ReportRateLimit is 0
+	cors      *corsPolicy    // nil when CORSAllowedOrigins is empty
+	audit     *auditLog      // nil when AuditLogSize is 0
+	self      selfHealth     // failures of the server itself, reported by /health
+	startedAt time.Time      // set by Start; reported as uptime in /health
 }
 
-// Config holds server configuration
+// Config holds server configuration. Config file keys are the lowercased
+// environment variable names.
 type Config struct {
-	ServerPort     string
-	HealthPort     string
-	MaxHistory     int
-	StaleTimeout   int // seconds after which a host is considered lost
-	CertFile       string
-	KeyFile        string
-	CACertFile     string
-	LogLevel       string
-	ReadTimeout    int
-	WriteTimeout   int
-	RequestTimeout int
-	EnableTLS      bool
-}
-
-// StatusRequest represents the incoming status report
-type StatusRequest struct {
-	ServiceName   string         `json:"service_name"`
-	InstanceName  string         `json:"instance_name"`
-	Status        string         `json:"status"`
-	HealthMetrics *HealthMetrics `json:"health_metrics,omitempty"`
+	ServerPort         string `json:"server_port"`
+	HealthPort         string `json:"health_port"`
+	BindAddress        string `json:"bind_address"`        // interface for the API listener; empty binds all
+	HealthBindAddress  string `json:"health_bind_address"` // interface for the health listener; empty uses BindAddress
+	UnixSocket         string `json:"unix_socket"`         // path of an additional plain HTTP API listener for local sidecars; empty disables
+	MaxHistory         int    `json:"max_history"`
+	HistoryRetention   int    `json:"history_retention"` // seconds of history kept per host, applied before MaxHistory; 0 disables
+	StaleTimeout       int    `json:"stale_timeout"`     // seconds after which a host is considered lost
+	StaleStatus        string `json:"stale_status"`      // current status given to lost hosts, e.g. "offline"
+	SweepInterval      int    `json:"sweep_interval"`    // seconds between background scans for lost hosts; 0 disables
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	CACertFile         string `json:"ca_cert_file"`
+	LogLevel           string `json:"log_level"`
+	LogFormat          string `json:"log_format"` // json or text
+	LogOutput          string `json:"log_output"` // stdout, stderr or a file path
+	ReadTimeout        int    `json:"read_timeout"`
+	WriteTimeout       int    `json:"write_timeout"`
+	RequestTimeout     int    `json:"request_timeout"`
+	MaxRequestBytes    int    `json:"max_request_bytes"` // largest accepted report body; 0 disables the limit
+	DrainPeriod        int    `json:"drain_period"`      // seconds /readyz fails on shutdown before the listeners close
+	EnableTLS          bool   `json:"enable_tls"`
+	EnableHealthServer bool   `json:"enable_health_server"`  // serve /health, /livez and /readyz without TLS on HealthPort; an empty HealthPort also disables it
+	HealthH2C          bool   `json:"health_h2c"`            // also accept HTTP/2 without TLS (h2c, prior knowledge) on the health server
+	CNPolicy           string `json:"cn_policy"`             // how a client certificate CN must match the reported host; cnPolicyOff disables
+	CertExpiryWarnDays int    `json:"cert_expiry_warn_days"` // warn when the certificate expires within this many days
+	RejectExpiredCert  bool   `json:"reject_expired_cert"`   // refuse to start or reload with an expired certificate
+	MaxReportAge       int    `json:"max_report_age"`        // seconds; reports with an older client timestamp are rejected, 0 disables
+	ClockSkewWarn      int    `json:"clock_skew_warn"`       // seconds of client clock skew beyond which reports are logged; 0 disables
+	PersistPath        string `json:"persist_path"`          // JSON-lines file host history is persisted to; empty disables
+	StorageBackend     string `json:"storage_backend"`       // "memory" (default) or "sqlite"
+	StoragePath        string `json:"storage_path"`          // sqlite database DSN
+	WebhookURL         string `json:"webhook_url"`           // URL notified of transitions into or out of unhealthy/lost; empty disables
+	WebhookDebounce    int    `json:"webhook_debounce"`      // seconds during which a repeated identical transition is not re-sent
+	AuditLogSize       int    `json:"audit_log_size"`        // status transitions kept in memory for /api/v1/audit; 0 disables the audit log
+	AuditLogFile       string `json:"audit_log_file"`        // JSON-lines file every transition is appended to and restored from; empty disables
+	MaxHosts           int    `json:"max_hosts"`             // distinct hosts held at most; 0 disables the cap
+	HostEviction       string `json:"host_eviction"`         // what a new host past MaxHosts gets: hostEvictionReject, hostEvictionLost or hostEvictionLRU
+	StatusSmoothing    string `json:"status_smoothing"`      // how current status is derived from recent reports; smoothingOff uses the latest
+	SmoothingWindow    int    `json:"smoothing_window"`      // number of recent reports considered when smoothing
+	TLSMinVersion      string `json:"tls_min_version"`       // "1.2" or "1.3"
+	CipherSuites       string `json:"cipher_suites"`         // comma-separated TLS 1.2 suite names; empty uses shared.DefaultCipherSuites
+	ClientIDSource     string `json:"client_id_source"`      // which certificate identity is recorded as ClientID: auto, spiffe or cn
+	OTLPEndpoint       string `json:"otlp_endpoint"`         // OpenTelemetry collector base URL spans are exported to; empty disables tracing
+	PrivacyMode        string `json:"privacy_mode"`          // how client IPs appear in logs: off, hash or omit
+	PrivacySalt        string `json:"privacy_salt"`          // key for PrivacyMode hash; empty uses a random per-process key
+	ReportRateLimit    int    `json:"report_rate_limit"`     // reports per minute allowed per host; 0 disables
+	ReportRateBurst    int    `json:"report_rate_burst"`     // reports a host may send at once before ReportRateLimit applies
+	CORSAllowedOrigins string `json:"cors_allowed_origins"`  // comma-separated origins, or "*", allowed to read the health endpoints from a browser; empty disables
+	AdvisedInterval    int    `json:"advised_interval"`      // report interval in seconds clients are asked to use; 0 leaves theirs unless TargetReportRate applies
+	TargetReportRate   int    `json:"target_report_rate"`    // fleet-wide reports per second; clients are asked to slow down to stay within it, 0 disables
+	StaleGracePeriod   int    `json:"stale_grace_period"`    // seconds a new host is spared from being marked lost; 0 disables
+	StaleGraceReports  int    `json:"stale_grace_reports"`   // reports after which a host is no longer spared, even within StaleGracePeriod; 0 waits out the period
+	MaxGoroutines      int    `json:"max_goroutines"`        // goroutine count above which /health reports the server unhealthy; 0 disables
+
+	// STATUS_SMOOTHING=ewma derives status from a smoothed OverallScore
+	SmoothingAlpha         float64 `json:"smoothing_alpha"`          // weight of the newest score, in (0, 1]
+	SmoothingHealthyScore  int     `json:"smoothing_healthy_score"`  // smoothed score at or above which a host is healthy
+	SmoothingDegradedScore int     `json:"smoothing_degraded_score"` // smoothed score at or above which a host is degraded
+
+	// Per-service score thresholds keyed by ServiceName. A listed service's
+	// status is derived from its reports' OverallScore, and they replace the
+	// smoothing scores under STATUS_SMOOTHING=ewma; other services keep the
+	// global behaviour.
+	ServiceThresholds map[string]ScoreThresholds `json:"service_thresholds"`
 }
 
+// reportableStatuses are the statuses a client may report
+var reportableStatuses = map[string]bool{
+	"healthy":   true,
+	"degraded":  true,
+	"unhealthy": true,
+}
+
+// Report payload types shared with the client, so both sides serialize them
+// identically
+type (
+	StatusRequest     = shared.StatusRequest
+	HealthCheck       = shared.HealthCheck
+	HealthMetrics     = shared.HealthMetrics
+	ScoreContribution = shared.ScoreContribution
+	RecentErrors      = shared.RecentErrors
+	StatusResponse    = shared.StatusResponse
+)
+
+// Report detail levels
+const (
+	reportDetailFull      = shared.ReportDetailFull
+	reportDetailHeartbeat = shared.ReportDetailHeartbeat
+)
+
 // DiscoveryResponse represents the response from discovery queries
 type DiscoveryResponse struct {
 	Hosts []HostResponse `json:"hosts"`
 	Total int            `json:"total"`
 }
 
+// StatsResponse summarizes the fleet
+type StatsResponse struct {
+	TotalHosts         int            `json:"total_hosts"`
+	ByStatus           map[string]int `json:"by_status"`
+	ByService          map[string]int `json:"by_service"`
+	HostsWithMetrics   int            `json:"hosts_with_metrics"`
+	AverageCPUUsage    float64        `json:"average_cpu_usage"`
+	AverageMemoryUsage float64        `json:"average_memory_usage"`
+	AverageDiskUsage   float64        `json:"average_disk_usage"`
+}
+
 // NewS01Server creates a new s01 server instance
 func NewS01Server(config *Config, logger *slog.Logger) (*S01Server, error) {
 	var tlsConfig *tls.Config
+	var certs *certStore
 	var err error
 	if config.EnableTLS {
-		tlsConfig, err = setupTLSConfig(config)
+		tlsConfig, certs, err = setupTLSConfig(config, logger)
 		if err != nil {
 			return nil, fmt.Errorf("failed to setup TLS: %v", err)
 		}
 	}
 
-	return &S01Server{
-		hosts:      make(map[string]*HostHistory),
-		maxHistory: config.MaxHistory,
-		logger:     logger,
-		config:     config,
-		tlsConfig:  tlsConfig,
-	}, nil
-}
+	audit, err := newAuditLog(config.AuditLogSize, config.AuditLogFile, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+
+	ds := &S01Server{
+		logger:    logger,
+		config:    config,
+		tlsConfig: tlsConfig,
+		certs:     certs,
+		webhook:   newWebhookNotifier(config.WebhookURL, time.Duration(config.WebhookDebounce)*time.Second, config.StaleStatus, logger),
+		audit:     audit,
+		tracer:    shared.NewTracer(config.OTLPEndpoint, "s01-server", logger),
+		ipLog:     newIPRedactor(config.PrivacyMode, config.PrivacySalt),
+		limiter:   newReportLimiter(config.ReportRateLimit, config.ReportRateBurst),
+		cors:      newCORSPolicy(config.CORSAllowedOrigins),
+	}
 
-// setupTLSConfig configures mTLS for the server
-func setupTLSConfig(config *Config) (*tls.Config, error) {
-	// Load server certificate and key
-	serverCert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	ds.storage, err = newStorage(config, logger, ds.forgetHost)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load server certificate: %v", err)
+		audit.Close()
+		return nil, fmt.Errorf("failed to setup storage: %v", err)
 	}
+	return ds, nil
+}
 
-	// Load CA certificate
-	caCertPEM, err := os.ReadFile(config.CACertFile)
+// forgetHost drops what the server keeps per host outside storage once
+// storage evicts or prunes the host, so the maps do not outgrow MaxHosts. A
+// host that comes back is then treated as new.
+func (ds *S01Server) forgetHost(serviceName, instanceName string) {
+	ds.webhook.forget(serviceName, instanceName)
+	ds.limiter.forget(hostKey(serviceName, instanceName))
+}
+
+// setupTLSConfig configures mTLS for the server. The certificate and client
+// CA pool are read from the returned store on every handshake, so reloading
+// the store takes effect for new connections.
+func setupTLSConfig(config *Config, logger *slog.Logger) (*tls.Config, *certStore, error) {
+	minVersion, cipherSuites, err := parseTLSOptions(config.TLSMinVersion, config.CipherSuites)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		return nil, nil, err
 	}
 
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCertPEM) {
-		return nil, fmt.Errorf("failed to parse CA certificate")
+	// Load server certificate, key, and CA certificate
+	certs, err := newCertStore(config, logger)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{serverCert},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		ClientCAs:    caCertPool,
-		MinVersion:   tls.VersionTLS12,
-		CipherSuites: []uint16{
-			// HTTP/2 required cipher suites
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			// Additional secure cipher suites
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-		},
+		GetCertificate: certs.getCertificate,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+		ClientCAs:      certs.caPool.Load(),
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
 	}
 
-	return tlsConfig, nil
+	// Verify clients against the current CA pool. The per-connection config
+	// is a clone of this one, so ALPN is listed here for it to keep HTTP/2.
+	tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		perConn := tlsConfig.Clone()
+		perConn.ClientCAs = certs.caPool.Load()
+		return perConn, nil
+	}
+
+	return tlsConfig, certs, nil
 }
 
 // getClientIP extracts the real client IP address
@@ -203,101 +343,304 @@ func getClientCN(r *http.Request) string {
 	return ""
 }
 
-// parsePathParams extracts path parameters from URL path
-func parsePathParams(path, pattern string) map[string]string {
-	params := make(map[string]string)
+// Client certificate CN policies for status reports
+const (
+	cnPolicyOff     = "off"     // any valid certificate may report for any host
+	cnPolicyExact   = "exact"   // CN must be "<service_name>-<instance_name>"
+	cnPolicyService = "service" // CN must equal service_name
+	cnPolicyPrefix  = "prefix"  // CN must be service_name or start with "<service_name>-"
+)
 
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
-	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+// cnMatchesHost reports whether a certificate CN may report for the given host under policy
+func cnMatchesHost(policy, cn, serviceName, instanceName string) bool {
+	switch policy {
+	case cnPolicyOff, "":
+		return true
+	case cnPolicyExact:
+		return cn == serviceName+"-"+instanceName
+	case cnPolicyService:
+		return cn == serviceName
+	case cnPolicyPrefix:
+		return cn == serviceName || strings.HasPrefix(cn, serviceName+"-")
+	default:
+		return false
+	}
+}
 
-	if len(pathParts) != len(patternParts) {
-		return params
+// reportStatus handles incoming status reports from hosts
+func (ds *S01Server) reportStatus(w http.ResponseWriter, r *http.Request) {
+	logger := ds.requestLogger(r)
+
+	body, ok := ds.readReportBody(w, r)
+	if !ok {
+		return
 	}
 
-	for i, part := range patternParts {
-		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
-			key := part[1 : len(part)-1]
-			if i < len(pathParts) {
-				params[key] = pathParts[i]
-			}
-		} else if part != pathParts[i] {
-			return make(map[string]string) // Pattern doesn't match
-		}
+	var req StatusRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		logger.Error("Failed to decode status request", "error", err)
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON")
+		return
 	}
 
-	return params
-}
+	span := requestSpan(r)
+	span.SetAttribute("service_name", req.ServiceName)
+	span.SetAttribute("instance_name", req.InstanceName)
 
-// matchesPattern checks if a path matches a pattern
-func matchesPattern(path, pattern string) bool {
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
-	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	if !ds.allowReport(logger, req) {
+		span.SetError("rate limited")
+		w.Header().Set("Retry-After", strconv.Itoa(ds.limiter.retryAfter()))
+		writeJSONError(w, http.StatusTooManyRequests, errCodeRateLimited, "Too many reports for this host")
+		return
+	}
 
-	if len(pathParts) != len(patternParts) {
-		return false
+	if rerr := ds.processReport(logger, req, getClientIP(r), getClientCN(r), getClientID(r, ds.config.ClientIDSource)); rerr != nil {
+		span.SetError(rerr.message)
+		writeJSONError(w, rerr.status, rerr.code, rerr.message)
+		return
 	}
 
-	for i, part := range patternParts {
-		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
-			continue // Parameter - matches anything
-		} else if i >= len(pathParts) || part != pathParts[i] {
-			return false
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(StatusResponse{Status: "ok", NextIntervalSeconds: ds.advisedInterval()})
+}
+
+// readReportBody reads a report body within MaxRequestBytes. On failure the
+// error response has already been written and ok is false.
+func (ds *S01Server) readReportBody(w http.ResponseWriter, r *http.Request) (body []byte, ok bool) {
+	logger := ds.requestLogger(r)
+	if ds.config.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(ds.config.MaxRequestBytes))
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			logger.Warn("Rejected oversized status report",
+				ds.ipLog.attr(getClientIP(r)),
+				"limit_bytes", maxBytesErr.Limit,
+			)
+			writeJSONError(w, http.StatusRequestEntityTooLarge, errCodeRequestTooLarge, "Request body too large")
+			return nil, false
+		}
+		// A client that hangs up or stalls mid-body is routine, not a server
+		// error, and must not be mistaken for a malformed report
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			logger.Warn("Timed out reading status report body",
+				ds.ipLog.attr(getClientIP(r)),
+				"bytes_read", len(body),
+			)
+			writeJSONError(w, http.StatusRequestTimeout, errCodeRequestTimeout, "Timed out reading request body")
+			return nil, false
 		}
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.Canceled) {
+			logger.Info("Client disconnected before sending the full status report",
+				ds.ipLog.attr(getClientIP(r)),
+				"bytes_read", len(body),
+			)
+			writeJSONError(w, statusClientClosedRequest, errCodeClientClosed, "Request body incomplete")
+			return nil, false
+		}
+		logger.Error("Failed to read request body", "error", err)
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Failed to read request")
+		return nil, false
 	}
+	return body, true
+}
 
-	return true
+// allowReport applies the per-host rate limit, which keeps a client stuck in
+// a loop from flooding storage and logs
+func (ds *S01Server) allowReport(logger *slog.Logger, req StatusRequest) bool {
+	if ds.limiter.allow(hostKey(req.ServiceName, req.InstanceName), time.Now()) {
+		return true
+	}
+	logger.Warn("Rate limited status report",
+		"service_name", req.ServiceName,
+		"instance_name", req.InstanceName,
+		"rate_limit_per_minute", ds.config.ReportRateLimit,
+	)
+	return false
 }
 
-// reportStatus handles incoming status reports from hosts
-func (ds *S01Server) reportStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// reportError is why a single status report was rejected
+type reportError struct {
+	status  int
+	code    string
+	message string
+}
+
+// processReport validates one status report from the client at clientIP
+// presenting a certificate with clientCN and clientID and stores it, logging
+// through logger
+func (ds *S01Server) processReport(logger *slog.Logger, req StatusRequest, clientIP, clientCN, clientID string) *reportError {
+	// Validate required fields; a metrics-only report carries no status
+	if req.ServiceName == "" || req.InstanceName == "" || (req.Status == "" && !req.MetricsOnly) {
+		logger.Error("Missing required fields in status request")
+		return &reportError{http.StatusBadRequest, errCodeInvalidRequest, "Missing required fields: service_name, instance_name, status"}
+	}
+	if req.MetricsOnly {
+		if req.Status != "" {
+			return &reportError{http.StatusBadRequest, errCodeInvalidRequest, "Invalid metrics-only report: status must be omitted"}
+		}
+		if req.HealthMetrics == nil {
+			return &reportError{http.StatusBadRequest, errCodeInvalidRequest, "Invalid metrics-only report: health_metrics is required"}
+		}
+		if req.Detail == reportDetailHeartbeat {
+			return &reportError{http.StatusBadRequest, errCodeInvalidRequest, "Invalid metrics-only report: detail cannot be heartbeat"}
+		}
+	} else {
+		// Clients may only report the statuses they compute; the stale status is derived by the server
+		req.Status = strings.ToLower(req.Status)
+		if !reportableStatuses[req.Status] {
+			return &reportError{http.StatusBadRequest, errCodeInvalidStatus, "Invalid status: must be healthy, degraded or unhealthy"}
+		}
+	}
+	if req.Detail != "" && req.Detail != reportDetailFull && req.Detail != reportDetailHeartbeat {
+		return &reportError{http.StatusBadRequest, errCodeInvalidRequest, "Invalid detail: must be full or heartbeat"}
+	}
+	if err := validateLabels(req.Labels); err != nil {
+		return &reportError{http.StatusBadRequest, errCodeInvalidRequest, "Invalid labels: " + err.Error()}
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		ds.logger.Error("Failed to read request body", "error", err)
-		http.Error(w, "Failed to read request", http.StatusBadRequest)
-		return
+	// Keep one host's certificate from reporting on behalf of another
+	if !cnMatchesHost(ds.config.CNPolicy, clientCN, req.ServiceName, req.InstanceName) {
+		logger.Warn("Rejected status report: certificate CN does not match host",
+			"service_name", req.ServiceName,
+			"instance_name", req.InstanceName,
+			"client_cn", clientCN,
+			ds.ipLog.attr(clientIP),
+			"cn_policy", ds.config.CNPolicy,
+		)
+		return &reportError{http.StatusForbidden, errCodeForbidden, "Client certificate not authorized for this host"}
 	}
 
-	var req StatusRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		ds.logger.Error("Failed to decode status request", "error", err)
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
+	// Keep backfilled reports from masquerading as current state
+	if req.Timestamp != nil && ds.config.MaxReportAge > 0 {
+		maxAge := time.Duration(ds.config.MaxReportAge) * time.Second
+		if age := time.Since(*req.Timestamp); age > maxAge {
+			logger.Warn("Rejected stale status report",
+				"service_name", req.ServiceName,
+				"instance_name", req.InstanceName,
+				"report_age", age.Round(time.Second).String(),
+				"max_report_age", maxAge.String(),
+			)
+			return &reportError{http.StatusBadRequest, errCodeStaleReport, "Report timestamp older than max report age"}
+		}
 	}
 
-	// Validate required fields
-	if req.ServiceName == "" || req.InstanceName == "" || req.Status == "" {
-		ds.logger.Error("Missing required fields in status request")
-		http.Error(w, "Missing required fields: service_name, instance_name, status", http.StatusBadRequest)
-		return
+	// A skewed client clock makes its timestamps, and anything keyed on them, misleading
+	receivedAt := time.Now()
+	if skew, ok := clockSkew(receivedAt, req.Timestamp); ok && ds.config.ClockSkewWarn > 0 {
+		if skew.Abs() > time.Duration(ds.config.ClockSkewWarn)*time.Second {
+			logger.Warn("Client clock skew exceeds threshold",
+				"service_name", req.ServiceName,
+				"instance_name", req.InstanceName,
+				"clock_skew_seconds", skew.Round(time.Millisecond).Seconds(),
+				"clock_skew_warn", ds.config.ClockSkewWarn,
+			)
+		}
 	}
 
-	clientIP := getClientIP(r)
-	clientCN := getClientCN(r)
+	// Bound what a client can make us store for its metrics and error summary
+	if req.HealthMetrics != nil && len(req.HealthMetrics.Checks) > maxHealthChecks {
+		req.HealthMetrics.Checks = req.HealthMetrics.Checks[:maxHealthChecks]
+	}
+	if req.HealthMetrics != nil && len(req.HealthMetrics.ScoreBreakdown) > maxHealthChecks {
+		req.HealthMetrics.ScoreBreakdown = req.HealthMetrics.ScoreBreakdown[:maxHealthChecks]
+	}
+	if req.RecentErrors != nil {
+		if len(req.RecentErrors.Samples) > maxRecentErrorSamples {
+			req.RecentErrors.Samples = req.RecentErrors.Samples[:maxRecentErrorSamples]
+		}
+		for i, sample := range req.RecentErrors.Samples {
+			if len(sample) > maxRecentErrorSampleLen {
+				req.RecentErrors.Samples[i] = sample[:maxRecentErrorSampleLen]
+			}
+		}
+	}
 
 	status := HostStatus{
 		ServiceName:   req.ServiceName,
 		InstanceName:  req.InstanceName,
 		IPAddress:     clientIP,
 		Status:        req.Status,
-		Timestamp:     time.Now(),
+		Timestamp:     receivedAt,
+		ClientTime:    req.Timestamp,
+		Sequence:      req.Sequence,
 		ClientCN:      clientCN,
+		ClientID:      clientID,
+		Labels:        req.Labels,
 		HealthMetrics: req.HealthMetrics,
+		RecentErrors:  req.RecentErrors,
+		KernelVersion: req.KernelVersion,
+		OSRelease:     req.OSRelease,
+		Arch:          req.Arch,
+	}
+	if !req.MetricsOnly {
+		ds.applyServiceThresholds(logger, &status)
 	}
 
-	ds.addHostStatus(status)
+	if req.MetricsOnly {
+		current, err := ds.recordMetrics(status)
+		if err != nil {
+			if errors.Is(err, errStaleSequence) {
+				return staleSequenceError(logger, req)
+			}
+			if errors.Is(err, errNoPriorStatus) {
+				return &reportError{http.StatusConflict, errCodeNoPriorStatus, "No status recorded for this host; send a full report first"}
+			}
+			logger.Error("Failed to store health metrics", "error", err)
+			return &reportError{http.StatusInternalServerError, errCodeInternal, "Failed to store status"}
+		}
+		logger.Debug("Host metrics updated",
+			"service_name", req.ServiceName,
+			"instance_name", req.InstanceName,
+			"status", current,
+			"health_score", req.HealthMetrics.OverallScore,
+		)
+		return nil
+	}
+
+	if req.Detail == reportDetailHeartbeat {
+		ds.keepThresholdStatus(&status)
+		if err := ds.recordHeartbeat(status); err != nil {
+			if errors.Is(err, errStaleSequence) {
+				return staleSequenceError(logger, req)
+			}
+			if errors.Is(err, errTooManyHosts) {
+				return hostLimitError(logger, req)
+			}
+			logger.Error("Failed to store heartbeat", "error", err)
+			return &reportError{http.StatusInternalServerError, errCodeInternal, "Failed to store status"}
+		}
+		logger.Debug("Host heartbeat",
+			"service_name", req.ServiceName,
+			"instance_name", req.InstanceName,
+			"status", status.Status,
+		)
+		return nil
+	}
+
+	if err := ds.addHostStatus(status); err != nil {
+		if errors.Is(err, errStaleSequence) {
+			return staleSequenceError(logger, req)
+		}
+		if errors.Is(err, errTooManyHosts) {
+			return hostLimitError(logger, req)
+		}
+		logger.Error("Failed to store host status", "error", err)
+		return &reportError{http.StatusInternalServerError, errCodeInternal, "Failed to store status"}
+	}
 
 	// Enhanced logging with health metrics
 	logFields := []any{
 		"service_name", req.ServiceName,
 		"instance_name", req.InstanceName,
-		"ip_address", clientIP,
-		"status", req.Status,
+		ds.ipLog.attr(clientIP),
+		"status", status.Status,
 		"client_cn", clientCN,
+		"client_id", clientID,
 	}
 
 	// Add health metrics to logs if available
@@ -311,145 +654,380 @@ func (ds *S01Server) reportStatus(w http.ResponseWriter, r *http.Request) {
 			"health_checks_count", len(req.HealthMetrics.Checks),
 		)
 	}
+	if req.RecentErrors != nil {
+		logFields = append(logFields, "recent_errors", req.RecentErrors.Count)
+	}
 
-	ds.logger.Info("Host status reported", logFields...)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	logger.Info("Host status reported", logFields...)
+	return nil
 }
 
-// addHostStatus adds a new status report to the host history
-func (ds *S01Server) addHostStatus(status HostStatus) {
-	key := fmt.Sprintf("%s:%s", status.ServiceName, status.InstanceName)
-
-	ds.mutex.Lock()
-	defer ds.mutex.Unlock()
+// staleSequenceError logs and describes a report rejected as out of order or replayed
+func staleSequenceError(logger *slog.Logger, req StatusRequest) *reportError {
+	logger.Warn("Rejected out-of-order or replayed status report",
+		"service_name", req.ServiceName,
+		"instance_name", req.InstanceName,
+		"sequence", req.Sequence,
+	)
+	return &reportError{http.StatusConflict, errCodeStaleSequence, "Report sequence is not newer than the last accepted report"}
+}
 
-	hostHistory, exists := ds.hosts[key]
-	if !exists {
-		hostHistory = &HostHistory{
-			ServiceName:  status.ServiceName,
-			InstanceName: status.InstanceName,
-			Statuses:     make([]HostStatus, 0, ds.maxHistory),
-		}
-		ds.hosts[key] = hostHistory
+// addHostStatus adds a new status report to the host history. Webhooks and
+// the audit log see the host's current status, which smoothing may keep from
+// following a single report.
+func (ds *S01Server) addHostStatus(status HostStatus) error {
+	change, err := ds.storage.AddStatus(status)
+	ds.self.observeStorage(err, status.Timestamp)
+	if err != nil {
+		return err
 	}
+	ds.hostsChanged()
+	ds.webhook.observe(status.ServiceName, status.InstanceName, change, status.Timestamp)
+	ds.audit.observe(status.ServiceName, status.InstanceName, change, status.ClientCN, status.Timestamp)
+	return nil
+}
 
-	hostHistory.mutex.Lock()
-	defer hostHistory.mutex.Unlock()
+// errNoPriorStatus rejects a metrics-only report from a host with no stored
+// status to keep
+var errNoPriorStatus = errors.New("no prior status")
 
-	// Add new status
-	hostHistory.Statuses = append(hostHistory.Statuses, status)
-	hostHistory.LastSeen = status.Timestamp
+// recordMetrics stores the health metrics of a metrics-only report on the
+// host's latest status, returning the host's current status
+func (ds *S01Server) recordMetrics(status HostStatus) (string, error) {
+	change, ok, err := ds.storage.UpdateMetrics(status)
+	ds.self.observeStorage(err, status.Timestamp)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errNoPriorStatus
+	}
+	ds.hostsChanged()
+	// A lost host that pushes metrics is back with the status it last reported
+	ds.webhook.observe(status.ServiceName, status.InstanceName, change, status.Timestamp)
+	ds.audit.observe(status.ServiceName, status.InstanceName, change, status.ClientCN, status.Timestamp)
+	return change.Current, nil
+}
 
-	// Trim history if needed
-	if len(hostHistory.Statuses) > ds.maxHistory {
-		copy(hostHistory.Statuses, hostHistory.Statuses[1:])
-		hostHistory.Statuses = hostHistory.Statuses[:ds.maxHistory]
+// recordHeartbeat refreshes a host's liveness without growing its history. A
+// heartbeat is only stored as a history entry when the host is new or its
+// status changed since the last stored report.
+func (ds *S01Server) recordHeartbeat(status HostStatus) error {
+	change, touched, err := ds.storage.Touch(status)
+	ds.self.observeStorage(err, status.Timestamp)
+	if err != nil {
+		return err
+	}
+	if touched {
+		ds.hostsChanged()
+		ds.webhook.observe(status.ServiceName, status.InstanceName, change, status.Timestamp)
+		ds.audit.observe(status.ServiceName, status.InstanceName, change, status.ClientCN, status.Timestamp)
+		return nil
 	}
+	return ds.addHostStatus(status)
 }
 
 // getHosts returns all known hosts
 func (ds *S01Server) getHosts(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	logger := ds.requestLogger(r)
+
+	labelFilters, err := parseLabelFilters(r.URL.Query()["label"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid label filter: "+err.Error())
 		return
 	}
 
-	ds.mutex.RLock()
-	defer ds.mutex.RUnlock()
+	// Tag before reading so a change racing the read yields a stale tag,
+	// costing the next poll a full response, rather than a tag that hides it
+	if notModified(w, r, ds.hostsETag()) {
+		return
+	}
 
-	hosts := make([]HostResponse, 0, len(ds.hosts))
-	now := time.Now()
-	staleThreshold := time.Duration(ds.config.StaleTimeout) * time.Second
+	hosts, err := ds.filterHosts(r.URL.Query(), labelFilters)
+	if err != nil {
+		logger.Error("Failed to load hosts", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load hosts")
+		return
+	}
 
-	for _, hostHistory := range ds.hosts {
-		hostHistory.mutex.RLock()
+	response := DiscoveryResponse{
+		Hosts: hosts,
+		Total: len(hosts),
+	}
+
+	clientCN := getClientCN(r)
+	logger.Info("Hosts discovery request",
+		"total_hosts", len(hosts),
+		"client_cn", clientCN,
+	)
 
-		// Get the latest status (most recent)
-		var latestStatus HostStatus
-		currentStatus := "unknown"
-		if len(hostHistory.Statuses) > 0 {
-			latestStatus = hostHistory.Statuses[len(hostHistory.Statuses)-1]
-			currentStatus = latestStatus.Status
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// filterHosts returns the current host list narrowed by the optional
+// ?kernel= prefix, ?service= and ?status= filters in query and by the
+// already parsed ?label= predicates
+func (ds *S01Server) filterHosts(query url.Values, labelFilters []labelFilter) ([]HostResponse, error) {
+	kernelPrefix := query.Get("kernel")
+	serviceFilter := query.Get("service")
+	statusFilter := parseStatusFilter(query.Get("status"))
+
+	snapshots, err := ds.storage.GetHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]HostResponse, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		hostResponse := newHostResponse(snapshot)
+
+		if kernelPrefix != "" && !strings.HasPrefix(hostResponse.KernelVersion, kernelPrefix) {
+			continue
 		}
+		if serviceFilter != "" && hostResponse.ServiceName != serviceFilter {
+			continue
+		}
+		if len(statusFilter) > 0 && !statusFilter[hostResponse.Status] {
+			continue
+		}
+		if !matchesLabels(hostResponse.Labels, labelFilters) {
+			continue
+		}
+		hosts = append(hosts, hostResponse)
+	}
+	return hosts, nil
+}
+
+// clockSkew is how far the client clock trails the server's when a report is
+// received: positive when the client timestamp lies in the past. It includes
+// transit time, and reports replayed from a client buffer look skewed into
+// the past. ok is false when the client sent no timestamp.
+func clockSkew(receivedAt time.Time, clientTime *time.Time) (skew time.Duration, ok bool) {
+	if clientTime == nil {
+		return 0, false
+	}
+	return receivedAt.Sub(*clientTime), true
+}
+
+// clockSkewSeconds is the skew of status rounded to milliseconds, or nil when
+// its client sent no timestamp
+func clockSkewSeconds(status HostStatus) *float64 {
+	skew, ok := clockSkew(status.Timestamp, status.ClientTime)
+	if !ok {
+		return nil
+	}
+	seconds := skew.Round(time.Millisecond).Seconds()
+	return &seconds
+}
+
+// newHostResponse creates a simplified response with just the current status
+// and the details of the latest report
+func newHostResponse(snapshot HostSnapshot) HostResponse {
+	var latestStatus HostStatus
+	var stableSince *time.Time
+	if snapshot.Latest != nil {
+		latestStatus = *snapshot.Latest
+		stableSince = &snapshot.StableSince
+	}
+
+	return HostResponse{
+		ServiceName:   snapshot.ServiceName,
+		InstanceName:  snapshot.InstanceName,
+		Status:        snapshot.CurrentStatus,
+		IPAddress:     latestStatus.IPAddress,
+		LastSeen:      snapshot.LastSeen,
+		HealthMetrics: latestStatus.HealthMetrics,
+		ClientCN:      latestStatus.ClientCN,
+		ClientID:      latestStatus.ClientID,
+		Labels:        latestStatus.Labels,
+		ClockSkew:     clockSkewSeconds(latestStatus),
+		StableSince:   stableSince,
+		FlapCount:     snapshot.FlapCount,
+		KernelVersion: latestStatus.KernelVersion,
+		OSRelease:     latestStatus.OSRelease,
+		Arch:          latestStatus.Arch,
+	}
+}
 
-		// Check if host is stale (hasn't reported in staleTimeout seconds)
-		if now.Sub(hostHistory.LastSeen) > staleThreshold {
-			currentStatus = "lost"
+// getServiceInstances returns the live instances of a service: healthy ones,
+// plus degraded ones with ?include_degraded=true. Repeated ?match= label
+// predicates narrow them for topology-aware balancing, e.g.
+// ?match=zone=us-east-1a&match=gpu=true.
+func (ds *S01Server) getServiceInstances(w http.ResponseWriter, r *http.Request) {
+	logger := ds.requestLogger(r)
+
+	serviceName := r.PathValue("service_name")
+	if serviceName == "" {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Missing service_name")
+		return
+	}
+
+	includeDegraded := false
+	if value := r.URL.Query().Get("include_degraded"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid include_degraded value")
+			return
 		}
+		includeDegraded = parsed
+	}
+	matchFilters, err := parseLabelFilters(r.URL.Query()["match"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Invalid match predicate: "+err.Error())
+		return
+	}
+
+	snapshots, err := ds.storage.GetHosts()
+	if err != nil {
+		logger.Error("Failed to load hosts", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load hosts")
+		return
+	}
+
+	known := false
+	hosts := make([]HostResponse, 0)
 
-		// Create simplified response with just current status
-		hostResponse := HostResponse{
-			ServiceName:   hostHistory.ServiceName,
-			InstanceName:  hostHistory.InstanceName,
-			Status:        currentStatus,
-			IPAddress:     latestStatus.IPAddress,
-			LastSeen:      hostHistory.LastSeen,
-			HealthMetrics: latestStatus.HealthMetrics,
-			ClientCN:      latestStatus.ClientCN,
+	for _, snapshot := range snapshots {
+		if snapshot.ServiceName != serviceName {
+			continue
 		}
+		known = true
 
-		hostHistory.mutex.RUnlock()
+		if snapshot.CurrentStatus != "healthy" && !(includeDegraded && snapshot.CurrentStatus == "degraded") {
+			continue
+		}
+		hostResponse := newHostResponse(snapshot)
+		if !matchesLabels(hostResponse.Labels, matchFilters) {
+			continue
+		}
 		hosts = append(hosts, hostResponse)
 	}
 
-	response := DiscoveryResponse{
+	if !known {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Service not found")
+		return
+	}
+
+	logger.Info("Service instances request",
+		"service_name", serviceName,
+		"instances", len(hosts),
+		"match", len(matchFilters),
+		"client_cn", getClientCN(r),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DiscoveryResponse{
 		Hosts: hosts,
 		Total: len(hosts),
+	})
+}
+
+// getStats returns fleet-wide counts and average resource usage
+func (ds *S01Server) getStats(w http.ResponseWriter, r *http.Request) {
+	logger := ds.requestLogger(r)
+
+	snapshots, err := ds.storage.GetHosts()
+	if err != nil {
+		logger.Error("Failed to load hosts", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load hosts")
+		return
 	}
 
-	clientCN := getClientCN(r)
-	ds.logger.Info("Hosts discovery request",
-		"total_hosts", len(hosts),
-		"client_cn", clientCN,
+	stats := computeStats(snapshots, ds.config.StaleStatus)
+
+	logger.Info("Stats request",
+		"total_hosts", stats.TotalHosts,
+		"client_cn", getClientCN(r),
 	)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// computeStats aggregates host snapshots. Averages cover hosts that are not
+// lost, i.e. not in staleStatus, and whose latest report carried health metrics.
+func computeStats(snapshots []HostSnapshot, staleStatus string) StatsResponse {
+	stats := StatsResponse{
+		TotalHosts: len(snapshots),
+		ByStatus: map[string]int{
+			"healthy":   0,
+			"degraded":  0,
+			"unhealthy": 0,
+			staleStatus: 0,
+			"pending":   0,
+		},
+		ByService: make(map[string]int),
+	}
+
+	var cpu, memory, disk float64
+	for _, snapshot := range snapshots {
+		status := snapshot.CurrentStatus
+		stats.ByStatus[status]++
+		stats.ByService[snapshot.ServiceName]++
+
+		if status == staleStatus || snapshot.Latest == nil || snapshot.Latest.HealthMetrics == nil {
+			continue
+		}
+		metrics := snapshot.Latest.HealthMetrics
+		cpu += metrics.CPUUsage
+		memory += metrics.MemoryUsage
+		disk += metrics.DiskUsage
+		stats.HostsWithMetrics++
+	}
+
+	if stats.HostsWithMetrics > 0 {
+		n := float64(stats.HostsWithMetrics)
+		stats.AverageCPUUsage = cpu / n
+		stats.AverageMemoryUsage = memory / n
+		stats.AverageDiskUsage = disk / n
+	}
+	return stats
+}
+
+// parseStatusFilter parses a comma-separated list of statuses to OR together
+func parseStatusFilter(value string) map[string]bool {
+	if value == "" {
+		return nil
+	}
+	statuses := make(map[string]bool)
+	for _, status := range strings.Split(value, ",") {
+		if status = strings.ToLower(strings.TrimSpace(status)); status != "" {
+			statuses[status] = true
+		}
+	}
+	return statuses
 }
 
 // getHostByName returns a specific host by service_name and instance_name
 func (ds *S01Server) getHostByName(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	logger := ds.requestLogger(r)
 
-	// Parse path parameters manually
-	params := parsePathParams(r.URL.Path, "/api/v1/hosts/{service_name}/{instance_name}")
-	serviceName := params["service_name"]
-	instanceName := params["instance_name"]
+	serviceName := r.PathValue("service_name")
+	instanceName := r.PathValue("instance_name")
 
 	if serviceName == "" || instanceName == "" {
-		http.Error(w, "Missing service_name or instance_name", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Missing service_name or instance_name")
 		return
 	}
 
-	key := fmt.Sprintf("%s:%s", serviceName, instanceName)
-
-	ds.mutex.RLock()
-	hostHistory, exists := ds.hosts[key]
-	ds.mutex.RUnlock()
-
+	historyCopy, exists, err := ds.storage.GetHost(serviceName, instanceName)
+	if err != nil {
+		logger.Error("Failed to load host", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load host")
+		return
+	}
 	if !exists {
-		http.Error(w, "Host not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Host not found")
 		return
 	}
-
-	hostHistory.mutex.RLock()
-	historyCopy := HostHistoryResponse{
-		ServiceName:  hostHistory.ServiceName,
-		InstanceName: hostHistory.InstanceName,
-		LastSeen:     hostHistory.LastSeen,
-		Statuses:     make([]HostStatus, len(hostHistory.Statuses)),
+	if len(historyCopy.Statuses) > 0 {
+		stableSince, flaps := statusStability(historyCopy.Statuses)
+		historyCopy.StableSince, historyCopy.FlapCount = &stableSince, flaps
 	}
-	copy(historyCopy.Statuses, hostHistory.Statuses)
-	hostHistory.mutex.RUnlock()
 
 	clientCN := getClientCN(r)
-	ds.logger.Info("Host detail request",
+	logger.Info("Host detail request",
 		"service_name", serviceName,
 		"instance_name", instanceName,
 		"client_cn", clientCN,
@@ -459,117 +1037,304 @@ func (ds *S01Server) getHostByName(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(historyCopy)
 }
 
+// getLatestHost returns only the current state of one host, as listed by
+// getHosts, without its status history
+func (ds *S01Server) getLatestHost(w http.ResponseWriter, r *http.Request) {
+	logger := ds.requestLogger(r)
+
+	serviceName := r.PathValue("service_name")
+	instanceName := r.PathValue("instance_name")
+
+	if serviceName == "" || instanceName == "" {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Missing service_name or instance_name")
+		return
+	}
+
+	snapshot, exists, err := ds.storage.GetHostSnapshot(serviceName, instanceName)
+	if err != nil {
+		logger.Error("Failed to load host", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "Failed to load host")
+		return
+	}
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Host not found")
+		return
+	}
+
+	logger.Info("Latest host status request",
+		"service_name", serviceName,
+		"instance_name", instanceName,
+		"client_cn", getClientCN(r),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newHostResponse(snapshot))
+}
+
 // health provides a health check endpoint
 func (ds *S01Server) health(w http.ResponseWriter, r *http.Request) {
-	ds.mutex.RLock()
-	totalHosts := len(ds.hosts)
-	ds.mutex.RUnlock()
+	logger := ds.requestLogger(r)
+	totalHosts, err := ds.storage.Count()
+	if err != nil {
+		logger.Error("Failed to count hosts", "error", err)
+	}
+
+	now := time.Now()
+	problems := ds.self.problems(now, err, ds.config.MaxGoroutines)
 
 	health := map[string]interface{}{
 		"status":      "ok",
-		"timestamp":   time.Now(),
+		"timestamp":   now,
 		"total_hosts": totalHosts,
-		"version":     "1.0.0",
+		"version":     version,
+		"git_commit":  gitCommit,
+		"build_date":  buildDate,
+	}
+	addRuntimeStats(health, ds.startedAt)
+	if ds.certs != nil {
+		health["certificate_expires_at"] = ds.certs.expiresAt()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if len(problems) > 0 {
+		logger.Warn("Health check failing", "problems", problems)
+		health["status"] = "unhealthy"
+		health["problems"] = problems
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	json.NewEncoder(w).Encode(health)
 }
 
-// router handles HTTP routing manually
-func (ds *S01Server) router(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
+// livez reports that the process is running; it never consults storage
+func (ds *S01Server) livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
 
-	switch {
-	case path == "/health":
-		ds.health(w, r)
-	case path == "/api/v1/report":
-		ds.reportStatus(w, r)
-	case path == "/api/v1/hosts":
-		ds.getHosts(w, r)
-	case matchesPattern(path, "/api/v1/hosts/{service_name}/{instance_name}"):
-		ds.getHostByName(w, r)
-	default:
-		http.Error(w, "Not found", http.StatusNotFound)
+// readyz reports whether the main listener is accepting reports. It fails
+// until Start has bound the listener and again once shutdown begins, so load
+// balancers stop routing to a draining instance.
+func (ds *S01Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if !ds.ready.Load() {
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeUnavailable, "Server is not ready")
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-// healthRouter handles health check requests without requiring client certificates
-func (ds *S01Server) healthRouter(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/health" {
-		ds.health(w, r)
-	} else {
-		http.NotFound(w, r)
+// addRuntimeStats adds goroutine, heap, GC, and uptime figures to a health payload
+func addRuntimeStats(health map[string]interface{}, startedAt time.Time) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	health["goroutines"] = runtime.NumGoroutine()
+	health["heap_alloc_bytes"] = memStats.HeapAlloc
+	health["heap_sys_bytes"] = memStats.HeapSys
+	health["gc_count"] = memStats.NumGC
+
+	uptime := 0.0
+	if !startedAt.IsZero() {
+		uptime = time.Since(startedAt).Seconds()
+	}
+	health["uptime_seconds"] = uptime
+}
+
+// healthServerEnabled reports whether the plain HTTP health server runs. When
+// it does not, the main listener also serves the probes.
+func (ds *S01Server) healthServerEnabled() bool {
+	return ds.config.EnableHealthServer && ds.config.HealthPort != ""
+}
+
+// healthBindAddress is the interface the health server listens on, which
+// follows BindAddress unless HealthBindAddress is set
+func (ds *S01Server) healthBindAddress() string {
+	if ds.config.HealthBindAddress != "" {
+		return ds.config.HealthBindAddress
+	}
+	return ds.config.BindAddress
+}
+
+// drain takes the server out of rotation ahead of shutdown: /readyz fails
+// for DrainPeriod while reads and in-flight reports are still served, so load
+// balancers polling it stop routing here before the listeners close. Another
+// signal on stop cuts the wait short.
+func (ds *S01Server) drain(stop <-chan os.Signal) {
+	ds.ready.Store(false)
+	if ds.config.DrainPeriod <= 0 {
+		return
+	}
+
+	ds.logger.Info("Draining before shutdown", "drain_period", ds.config.DrainPeriod)
+	timer := time.NewTimer(time.Duration(ds.config.DrainPeriod) * time.Second)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-stop:
+		ds.logger.Warn("Shutdown signal received again, ending drain early")
 	}
 }
 
+// healthProtocols is what the plain HTTP health server speaks: HTTP/1.1,
+// plus HTTP/2 cleartext with prior knowledge when h2c is set. The mTLS
+// server negotiates HTTP/2 through ALPN instead.
+func healthProtocols(h2c bool) *http.Protocols {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(h2c)
+	return &protocols
+}
+
 // Start starts the s01 server
 func (ds *S01Server) Start() error {
+	ds.startedAt = time.Now()
+
 	// Main server config, TLS optional based on EnableTLS flag
 	server := &http.Server{
-		Addr:         ":" + ds.config.ServerPort,
-		Handler:      http.HandlerFunc(ds.router),
+		Addr:         net.JoinHostPort(ds.config.BindAddress, ds.config.ServerPort),
+		Handler:      ds.withRequestID(ds.withRecovery(ds.routes().ServeHTTP)),
 		ReadTimeout:  time.Duration(ds.config.ReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(ds.config.WriteTimeout) * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
 
-	// Health check server (no TLS, no client certs required)
-	healthServer := &http.Server{
-		Addr:         ":" + ds.config.HealthPort,
-		Handler:      http.HandlerFunc(ds.healthRouter),
-		ReadTimeout:  time.Duration(ds.config.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(ds.config.WriteTimeout) * time.Second,
-		IdleTimeout:  120 * time.Second,
+	// Health check server (no TLS, no client certs required), unless disabled
+	var healthServer *http.Server
+	if ds.healthServerEnabled() {
+		healthServer = &http.Server{
+			Addr:         net.JoinHostPort(ds.healthBindAddress(), ds.config.HealthPort),
+			Handler:      ds.withRequestID(ds.withRecovery(ds.cors.withCORS(ds.healthRoutes().ServeHTTP))),
+			ReadTimeout:  time.Duration(ds.config.ReadTimeout) * time.Second,
+			WriteTimeout: time.Duration(ds.config.WriteTimeout) * time.Second,
+			IdleTimeout:  120 * time.Second,
+			Protocols:    healthProtocols(ds.config.HealthH2C),
+		}
+	}
+
+	// Bind the main listener up front so readiness means it is accepting
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", server.Addr, err)
 	}
 
 	if ds.config.EnableTLS {
 		server.TLSConfig = ds.tlsConfig
-		ds.logger.Info("Starting s01 server with mTLS", "port", ds.config.ServerPort)
+		ds.logger.Info("Starting s01 server with mTLS", "address", server.Addr)
 		go func() {
-			if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			if err := server.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
 				ds.logger.Error("Failed to start main server", "error", err)
 				os.Exit(1)
 			}
 		}()
 	} else {
-		ds.logger.Info("Starting s01 server without TLS termination (plain HTTP)", "port", ds.config.ServerPort)
+		ds.logger.Info("Starting s01 server without TLS termination (plain HTTP)", "address", server.Addr)
 		go func() {
-			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 				ds.logger.Error("Failed to start main server", "error", err)
 				os.Exit(1)
 			}
 		}()
 	}
 
-	ds.logger.Info("Starting health check server", "port", ds.config.HealthPort)
-
-	// Start health server in goroutine
-	go func() {
-		if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			ds.logger.Error("Failed to start health server", "error", err)
-			os.Exit(1)
+	// Local sidecars may report over a Unix socket instead. Connections carry
+	// no client certificate, so access is governed by the socket's file
+	// permissions.
+	var unixServer *http.Server
+	if ds.config.UnixSocket != "" {
+		unixListener, err := listenUnixSocket(ds.config.UnixSocket)
+		if err != nil {
+			server.Close()
+			return err
+		}
+		unixServer = &http.Server{
+			Handler:      server.Handler,
+			ReadTimeout:  server.ReadTimeout,
+			WriteTimeout: server.WriteTimeout,
+			IdleTimeout:  server.IdleTimeout,
 		}
-	}()
+		ds.logger.Info("Starting s01 server on Unix socket (plain HTTP)", "socket", ds.config.UnixSocket)
+		go func() {
+			if err := unixServer.Serve(unixListener); err != nil && err != http.ErrServerClosed {
+				ds.logger.Error("Failed to start Unix socket server", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+	ds.ready.Store(true)
+
+	if healthServer != nil {
+		ds.logger.Info("Starting health check server", "address", healthServer.Addr)
+
+		// Start health server in goroutine
+		go func() {
+			if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				ds.logger.Error("Failed to start health server", "error", err)
+				os.Exit(1)
+			}
+		}()
+	} else {
+		ds.logger.Info("Health check server disabled; probes are served on the main listener")
+	}
+
+	// Mark hosts lost in the background rather than only when queried
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	if ds.config.SweepInterval > 0 {
+		go ds.runStaleSweeper(sweepCtx, time.Duration(ds.config.SweepInterval)*time.Second)
+	} else {
+		ds.logger.Warn("SWEEP_INTERVAL is 0; hosts will never be marked lost")
+	}
+
+	// Export trace spans in the background when OTLP_ENDPOINT is set
+	traceCtx, stopTracer := context.WithCancel(context.Background())
+	defer stopTracer()
+	go ds.tracer.Run(traceCtx)
+
+	// Reload certificates on SIGHUP, e.g. after rotation
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	defer signal.Stop(reloadChan)
 
 	// Wait for interrupt signal
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
+	for waiting := true; waiting; {
+		select {
+		case <-reloadChan:
+			ds.reloadCertificates()
+		case <-c:
+			waiting = false
+		}
+	}
 
+	// Stop accepting new reports at once; in-flight ones complete during the
+	// drain and Shutdown
 	ds.logger.Info("Shutting down servers...")
+	ds.draining.Store(true)
+	ds.drain(c)
+	stopSweeper()
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Shutdown both servers
-	var err1, err2 error
-	go func() { err1 = server.Shutdown(ctx) }()
-	go func() { err2 = healthServer.Shutdown(ctx) }()
+	// Shutdown all servers
+	var err1, err2, err3 error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() { defer wg.Done(); err1 = server.Shutdown(ctx) }()
+	if healthServer != nil {
+		wg.Add(1)
+		go func() { defer wg.Done(); err2 = healthServer.Shutdown(ctx) }()
+	}
+	if unixServer != nil {
+		wg.Add(1)
+		go func() { defer wg.Done(); err3 = unixServer.Shutdown(ctx) }()
+	}
 
-	// Wait for both shutdowns to complete
-	time.Sleep(1 * time.Second)
+	// Wait for all shutdowns to complete
+	wg.Wait()
 
 	if err1 != nil {
 		ds.logger.Error("Main server shutdown error", "error", err1)
@@ -579,11 +1344,42 @@ func (ds *S01Server) Start() error {
 		ds.logger.Error("Health server shutdown error", "error", err2)
 		return err2
 	}
+	if err3 != nil {
+		ds.logger.Error("Unix socket server shutdown error", "error", err3)
+		return err3
+	}
+
+	stopTracer()
+	ds.tracer.Flush()
+
+	if err := ds.storage.Close(); err != nil {
+		ds.logger.Error("Failed to close storage", "error", err)
+	}
+	if err := ds.audit.Close(); err != nil {
+		ds.logger.Error("Failed to close audit log", "error", err)
+	}
 
 	ds.logger.Info("Servers stopped")
 	return nil
 }
 
+// reloadCertificates swaps in the certificate files from disk, keeping the
+// current ones if they fail to load
+func (ds *S01Server) reloadCertificates() {
+	if ds.certs == nil {
+		ds.logger.Info("Ignoring certificate reload, TLS is disabled")
+		return
+	}
+	if err := ds.certs.reload(); err != nil {
+		ds.logger.Error("Certificate reload failed, keeping current certificates", "error", err)
+		return
+	}
+	ds.logger.Info("Certificates reloaded",
+		"cert_file", filepath.Base(ds.config.CertFile),
+		"ca_cert", filepath.Base(ds.config.CACertFile),
+	)
+}
+
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -602,50 +1398,202 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
-// loadConfig loads configuration from environment variables
+// getEnvFloat gets an environment variable as float with a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvBool gets an environment variable as boolean with a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// loadConfig loads configuration from defaults, then the first config file
+// found, then environment variables, each layer overriding the previous one
 func loadConfig() (*Config, error) {
 	config := &Config{
-		ServerPort:     getEnv("SERVER_PORT", "8443"),
-		HealthPort:     getEnv("HEALTH_PORT", "8080"),
-		MaxHistory:     getEnvInt("MAX_HISTORY", 100),
-		StaleTimeout:   getEnvInt("STALE_TIMEOUT", 300), // 5 minutes default
-		CertFile:       getEnv("CERT_FILE", "/etc/ssl/certs/server.crt"),
-		KeyFile:        getEnv("KEY_FILE", "/etc/ssl/certs/server.key"),
-		CACertFile:     getEnv("CA_CERT_FILE", "/etc/ssl/certs/root_ca.crt"),
-		LogLevel:       getEnv("LOG_LEVEL", "info"),
-		ReadTimeout:    getEnvInt("READ_TIMEOUT", 30),
-		WriteTimeout:   getEnvInt("WRITE_TIMEOUT", 30),
-		RequestTimeout: getEnvInt("REQUEST_TIMEOUT", 30),
-		EnableTLS:      getEnv("ENABLE_TLS", "true") == "true",
+		ServerPort:         "8443",
+		HealthPort:         "8080",
+		EnableHealthServer: true,
+		MaxHistory:         100,
+		StaleTimeout:       300, // 5 minutes default
+		StaleStatus:        "lost",
+		SweepInterval:      30,
+		CertFile:           "/etc/ssl/certs/server.crt",
+		KeyFile:            "/etc/ssl/certs/server.key",
+		CACertFile:         "/etc/ssl/certs/root_ca.crt",
+		LogLevel:           "info",
+		LogFormat:          "json",
+		LogOutput:          "stdout",
+		ReadTimeout:        30,
+		WriteTimeout:       30,
+		RequestTimeout:     30,
+		MaxRequestBytes:    64 * 1024,
+		DrainPeriod:        5,
+		EnableTLS:          true,
+		CNPolicy:           cnPolicyOff,
+		ClientIDSource:     clientIDSourceAuto,
+		PrivacyMode:        privacyOff,
+		ReportRateLimit:    60,
+		ReportRateBurst:    10,
+		CertExpiryWarnDays: 14,
+		ClockSkewWarn:      30,
+		StorageBackend:     storageMemory,
+		StoragePath:        "s01.db",
+		WebhookDebounce:    300,
+		AuditLogSize:       1000,
+		HostEviction:       hostEvictionReject,
+		StatusSmoothing:    smoothingOff,
+		SmoothingWindow:    5,
+		TLSMinVersion:      "1.2",
+		MaxGoroutines:      10000,
+
+		SmoothingAlpha:         0.2,
+		SmoothingHealthyScore:  80,
+		SmoothingDegradedScore: 60,
 	}
 
 	// Try to read config file if it exists
-	configPaths := []string{
-		"/etc/s01/config.json",
-		"./config/config.json",
-		"./config.json",
+	// JSON is preferred when both formats exist in the same directory
+	var configPaths []string
+	for _, dir := range []string{"/etc/s01", "./config", "."} {
+		for _, ext := range []string{".json", ".yaml", ".yml"} {
+			configPaths = append(configPaths, filepath.Join(dir, "config"+ext))
+		}
 	}
 
 	for _, configPath := range configPaths {
 		if data, err := os.ReadFile(configPath); err == nil {
-			var fileConfig Config
-			if err := json.Unmarshal(data, &fileConfig); err == nil {
-				// Override defaults with file config, but let env vars take precedence
-				if config.ServerPort == "8443" && fileConfig.ServerPort != "" {
-					config.ServerPort = fileConfig.ServerPort
-				}
-				if config.MaxHistory == 100 && fileConfig.MaxHistory != 0 {
-					config.MaxHistory = fileConfig.MaxHistory
-				}
-				// ... continue for other fields as needed
+			// Fields missing from the file keep their defaults
+			if err := shared.UnmarshalConfig(configPath, data, config); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %v", configPath, err)
 			}
 			break
 		}
 	}
 
+	// Override with environment variables (higher priority than config file)
+	config.ServerPort = getEnv("SERVER_PORT", config.ServerPort)
+	config.HealthPort = getEnv("HEALTH_PORT", config.HealthPort)
+	config.EnableHealthServer = getEnvBool("ENABLE_HEALTH_SERVER", config.EnableHealthServer)
+	config.HealthH2C = getEnvBool("HEALTH_H2C", config.HealthH2C)
+	config.BindAddress = getEnv("BIND_ADDRESS", config.BindAddress)
+	config.HealthBindAddress = getEnv("HEALTH_BIND_ADDRESS", config.HealthBindAddress)
+	config.UnixSocket = getEnv("UNIX_SOCKET", config.UnixSocket)
+	config.MaxHistory = getEnvInt("MAX_HISTORY", config.MaxHistory)
+	config.HistoryRetention = getEnvInt("HISTORY_RETENTION", config.HistoryRetention)
+	config.StaleTimeout = getEnvInt("STALE_TIMEOUT", config.StaleTimeout)
+	config.StaleStatus = strings.ToLower(strings.TrimSpace(getEnv("STALE_STATUS", config.StaleStatus)))
+	config.SweepInterval = getEnvInt("SWEEP_INTERVAL", config.SweepInterval)
+	config.CertFile = getEnv("CERT_FILE", config.CertFile)
+	config.KeyFile = getEnv("KEY_FILE", config.KeyFile)
+	config.CACertFile = getEnv("CA_CERT_FILE", config.CACertFile)
+	config.LogLevel = getEnv("LOG_LEVEL", config.LogLevel)
+	config.LogFormat = getEnv("LOG_FORMAT", config.LogFormat)
+	config.LogOutput = getEnv("LOG_OUTPUT", config.LogOutput)
+	config.ReadTimeout = getEnvInt("READ_TIMEOUT", config.ReadTimeout)
+	config.WriteTimeout = getEnvInt("WRITE_TIMEOUT", config.WriteTimeout)
+	config.RequestTimeout = getEnvInt("REQUEST_TIMEOUT", config.RequestTimeout)
+	config.MaxRequestBytes = getEnvInt("MAX_REQUEST_BYTES", config.MaxRequestBytes)
+	config.DrainPeriod = getEnvInt("DRAIN_PERIOD", config.DrainPeriod)
+	config.EnableTLS = getEnvBool("ENABLE_TLS", config.EnableTLS)
+	config.CNPolicy = getEnv("CN_POLICY", config.CNPolicy)
+	config.CertExpiryWarnDays = getEnvInt("CERT_EXPIRY_WARN_DAYS", config.CertExpiryWarnDays)
+	config.RejectExpiredCert = getEnvBool("REJECT_EXPIRED_CERT", config.RejectExpiredCert)
+	config.MaxReportAge = getEnvInt("MAX_REPORT_AGE", config.MaxReportAge)
+	config.ClockSkewWarn = getEnvInt("CLOCK_SKEW_WARN", config.ClockSkewWarn)
+	config.PersistPath = getEnv("PERSIST_PATH", config.PersistPath)
+	config.StorageBackend = getEnv("STORAGE_BACKEND", config.StorageBackend)
+	config.StoragePath = getEnv("STORAGE_PATH", config.StoragePath)
+	config.WebhookURL = getEnv("WEBHOOK_URL", config.WebhookURL)
+	config.WebhookDebounce = getEnvInt("WEBHOOK_DEBOUNCE", config.WebhookDebounce)
+	config.AuditLogSize = getEnvInt("AUDIT_LOG_SIZE", config.AuditLogSize)
+	config.AuditLogFile = getEnv("AUDIT_LOG_FILE", config.AuditLogFile)
+	config.MaxHosts = getEnvInt("MAX_HOSTS", config.MaxHosts)
+	config.HostEviction = getEnv("HOST_EVICTION", config.HostEviction)
+	config.StatusSmoothing = getEnv("STATUS_SMOOTHING", config.StatusSmoothing)
+	config.SmoothingWindow = getEnvInt("SMOOTHING_WINDOW", config.SmoothingWindow)
+	config.SmoothingAlpha = getEnvFloat("SMOOTHING_ALPHA", config.SmoothingAlpha)
+	config.SmoothingHealthyScore = getEnvInt("SMOOTHING_HEALTHY_SCORE", config.SmoothingHealthyScore)
+	config.SmoothingDegradedScore = getEnvInt("SMOOTHING_DEGRADED_SCORE", config.SmoothingDegradedScore)
+	if value := os.Getenv("SERVICE_THRESHOLDS"); value != "" {
+		config.ServiceThresholds = nil
+		if err := json.Unmarshal([]byte(value), &config.ServiceThresholds); err != nil {
+			return nil, fmt.Errorf("invalid SERVICE_THRESHOLDS: %v", err)
+		}
+	}
+	config.TLSMinVersion = getEnv("TLS_MIN_VERSION", config.TLSMinVersion)
+	config.CipherSuites = getEnv("CIPHER_SUITES", config.CipherSuites)
+	config.ClientIDSource = getEnv("CLIENT_ID_SOURCE", config.ClientIDSource)
+	config.OTLPEndpoint = getEnv("OTLP_ENDPOINT", config.OTLPEndpoint)
+	config.PrivacyMode = getEnv("PRIVACY_MODE", config.PrivacyMode)
+	config.PrivacySalt = getEnv("PRIVACY_SALT", config.PrivacySalt)
+	config.ReportRateLimit = getEnvInt("REPORT_RATE_LIMIT", config.ReportRateLimit)
+	config.ReportRateBurst = getEnvInt("REPORT_RATE_BURST", config.ReportRateBurst)
+	config.CORSAllowedOrigins = getEnv("CORS_ALLOWED_ORIGINS", config.CORSAllowedOrigins)
+	config.AdvisedInterval = getEnvInt("ADVISED_INTERVAL", config.AdvisedInterval)
+	config.TargetReportRate = getEnvInt("TARGET_REPORT_RATE", config.TargetReportRate)
+	config.StaleGracePeriod = getEnvInt("STALE_GRACE_PERIOD", config.StaleGracePeriod)
+	config.StaleGraceReports = getEnvInt("STALE_GRACE_REPORTS", config.StaleGraceReports)
+	config.MaxGoroutines = getEnvInt("MAX_GOROUTINES", config.MaxGoroutines)
+
+	switch config.CNPolicy {
+	case cnPolicyOff, cnPolicyExact, cnPolicyService, cnPolicyPrefix:
+	default:
+		return nil, fmt.Errorf("unknown cn_policy %q (expected %s, %s, %s or %s)",
+			config.CNPolicy, cnPolicyOff, cnPolicyExact, cnPolicyService, cnPolicyPrefix)
+	}
+	if err := validateClientIDSource(config.ClientIDSource); err != nil {
+		return nil, err
+	}
+	// The stale status must not collide with a status a host can hold otherwise
+	if config.StaleStatus == "" || reportableStatuses[config.StaleStatus] || config.StaleStatus == "pending" {
+		return nil, fmt.Errorf("invalid STALE_STATUS %q: must be non-empty and not healthy, degraded, unhealthy or pending", config.StaleStatus)
+	}
+	if err := validatePrivacyMode(config.PrivacyMode); err != nil {
+		return nil, err
+	}
+	if config.MaxHosts < 0 {
+		return nil, fmt.Errorf("max_hosts must not be negative")
+	}
+	if err := validateHostEviction(config.HostEviction); err != nil {
+		return nil, err
+	}
+	if config.AuditLogSize < 0 {
+		return nil, fmt.Errorf("audit_log_size must not be negative")
+	}
+	if config.AdvisedInterval < 0 || config.TargetReportRate < 0 {
+		return nil, fmt.Errorf("advised_interval and target_report_rate must not be negative")
+	}
+	if config.StaleGracePeriod < 0 || config.StaleGraceReports < 0 {
+		return nil, fmt.Errorf("stale_grace_period and stale_grace_reports must not be negative")
+	}
+	if config.MaxGoroutines < 0 {
+		return nil, fmt.Errorf("max_goroutines must not be negative")
+	}
+	if err := validateServiceThresholds(config.ServiceThresholds); err != nil {
+		return nil, err
+	}
+	if _, err := newStatusDeriver(config); err != nil {
+		return nil, err
+	}
+	if _, _, err := parseTLSOptions(config.TLSMinVersion, config.CipherSuites); err != nil {
+		return nil, err
+	}
+
 	// Validate required files exist only if TLS is enabled
 	if config.EnableTLS {
-		for _, file := range []string{config.CertFile, config.KeyFile, config.CACertFile} {
+		for _, file := range append([]string{config.CertFile, config.KeyFile}, caCertPaths(config.CACertFile)...) {
 			if _, err := os.Stat(file); os.IsNotExist(err) {
 				return nil, fmt.Errorf("required file not found: %s", file)
 			}
@@ -655,8 +1603,10 @@ func loadConfig() (*Config, error) {
 	return config, nil
 }
 
-// setupLogger configures the structured logger
-func setupLogger(level string) *slog.Logger {
+// setupLogger configures the structured logger. format is json or text
+// (logfmt); output is stdout, stderr or a file path appended to. An unknown
+// format falls back to json and an unopenable file to stdout, with a warning.
+func setupLogger(level, format, output string) *slog.Logger {
 	var logLevel slog.Level
 	switch strings.ToLower(level) {
 	case "debug":
@@ -675,9 +1625,40 @@ func setupLogger(level string) *slog.Logger {
 		Level: logLevel,
 	}
 
-	// Use JSON handler for structured logging
-	handler := slog.NewJSONHandler(os.Stdout, opts)
-	return slog.New(handler)
+	var writer io.Writer = os.Stdout
+	var outputErr error
+	switch output {
+	case "stdout", "":
+	case "stderr":
+		writer = os.Stderr
+	default:
+		file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			outputErr = err
+		} else {
+			writer = file
+		}
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text", "logfmt":
+		handler = slog.NewTextHandler(writer, opts)
+	default:
+		handler = slog.NewJSONHandler(writer, opts)
+	}
+	logger := slog.New(handler)
+
+	// Report fallbacks through the logger we ended up with
+	if outputErr != nil {
+		logger.Warn("Failed to open log output, logging to stdout", "log_output", output, "error", outputErr)
+	}
+	switch strings.ToLower(format) {
+	case "json", "text", "logfmt", "":
+	default:
+		logger.Warn("Unknown log format, using json", "log_format", format)
+	}
+	return logger
 }
 
 func main() {
@@ -687,7 +1668,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger := setupLogger(config.LogLevel)
+	logger := setupLogger(config.LogLevel, config.LogFormat, config.LogOutput)
 
 	server, err := NewS01Server(config, logger)
 	if err != nil {
@@ -696,12 +1677,31 @@ func main() {
 	}
 
 	logger.Info("S01 server configuration loaded",
+		"version", version,
+		"git_commit", gitCommit,
+		"build_date", buildDate,
 		"port", config.ServerPort,
 		"max_history", config.MaxHistory,
+		"storage_backend", config.StorageBackend,
 		"cert_file", filepath.Base(config.CertFile),
 		"ca_cert", filepath.Base(config.CACertFile),
+		"cn_policy", config.CNPolicy,
+		"client_id_source", config.ClientIDSource,
+		"status_smoothing", config.StatusSmoothing,
+		"tls_min_version", config.TLSMinVersion,
+		"privacy_mode", config.PrivacyMode,
 	)
 
+	if config.CNPolicy != cnPolicyOff && !config.EnableTLS {
+		logger.Warn("CN_POLICY is set but TLS is disabled; reports carry no client certificate and will be rejected")
+	}
+	if config.CNPolicy != cnPolicyOff && config.UnixSocket != "" {
+		logger.Warn("CN_POLICY is set; reports over UNIX_SOCKET carry no client certificate and will be rejected")
+	}
+	if config.PrivacyMode == privacyHash && config.PrivacySalt == "" {
+		logger.Warn("PRIVACY_SALT is empty; IP hashes in logs will change on every restart")
+	}
+
 	if err := server.Start(); err != nil {
 		logger.Error("Server failed to start", "error", err)
 		os.Exit(1)
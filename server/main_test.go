@@ -0,0 +1,929 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer builds a server with TLS off and logs discarded from the
+// default configuration, adjusted by configure when it is not nil
+func newTestServer(t *testing.T, configure func(*Config)) *S01Server {
+	t.Helper()
+	t.Setenv("ENABLE_TLS", "false")
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if configure != nil {
+		configure(config)
+	}
+
+	ds, err := NewS01Server(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewS01Server: %v", err)
+	}
+	t.Cleanup(func() { ds.storage.Close() })
+	return ds
+}
+
+// mustReport stores req as if it came from 192.0.2.1 without a client certificate
+func mustReport(t *testing.T, ds *S01Server, req StatusRequest) {
+	t.Helper()
+	if rerr := ds.processReport(ds.logger, req, "192.0.2.1", "", ""); rerr != nil {
+		t.Fatalf("report %s/%s: %d %s", req.ServiceName, req.InstanceName, rerr.status, rerr.message)
+	}
+}
+
+// serve sends a request through the API routes and returns the recorded response
+func serve(ds *S01Server, method, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	ds.routes().ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder
+}
+
+// post sends a JSON body through the API routes and returns the recorded response
+func post(ds *S01Server, target, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	ds.routes().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+	return recorder
+}
+
+// decodeDiscovery decodes a host listing, failing the test on a non-200 response
+func decodeDiscovery(t *testing.T, recorder *httptest.ResponseRecorder) DiscoveryResponse {
+	t.Helper()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	var response DiscoveryResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return response
+}
+
+func TestGetServiceInstancesMatch(t *testing.T) {
+	ds := newTestServer(t, nil)
+	for _, host := range []struct {
+		instance string
+		status   string
+		labels   map[string]string
+	}{
+		{"a1", "healthy", map[string]string{"zone": "us-east-1a", "gpu": "true"}},
+		{"a2", "healthy", map[string]string{"zone": "us-east-1a"}},
+		{"b1", "healthy", map[string]string{"zone": "us-east-1b", "gpu": "true"}},
+		{"b2", "degraded", map[string]string{"zone": "us-east-1b", "gpu": "true"}},
+		{"c1", "unhealthy", map[string]string{"zone": "us-east-1a", "gpu": "true"}},
+		{"n1", "healthy", nil},
+	} {
+		mustReport(t, ds, StatusRequest{ServiceName: "render", InstanceName: host.instance, Status: host.status, Labels: host.labels})
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"no predicates", "", []string{"a1", "a2", "b1", "n1"}},
+		{"same zone", "?match=zone=us-east-1a", []string{"a1", "a2"}},
+		{"zone and gpu", "?match=zone=us-east-1a&match=gpu=true", []string{"a1"}},
+		{"label present", "?match=gpu", []string{"a1", "b1"}},
+		{"anti-affinity", "?match=zone!=us-east-1a", []string{"b1", "n1"}},
+		{"with degraded", "?match=zone=us-east-1b&include_degraded=true", []string{"b1", "b2"}},
+		{"no match", "?match=zone=eu-west-1a", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/services/render/instances"+tt.query))
+			got := make(map[string]bool)
+			for _, host := range response.Hosts {
+				got[host.InstanceName] = true
+			}
+			if len(got) != len(tt.want) || response.Total != len(tt.want) {
+				t.Fatalf("instances = %v, want %v", got, tt.want)
+			}
+			for _, instance := range tt.want {
+				if !got[instance] {
+					t.Fatalf("instances = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReportBoundsRecentErrors(t *testing.T) {
+	ds := newTestServer(t, nil)
+	samples := []string{strings.Repeat("e", maxRecentErrorSampleLen+44)}
+	for i := 0; i < maxRecentErrorSamples+2; i++ {
+		samples = append(samples, fmt.Sprintf("error %d", i))
+	}
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "degraded",
+		RecentErrors: &RecentErrors{Count: 42, Samples: samples}})
+
+	snapshot, _, _ := ds.storage.GetHostSnapshot("web", "w1")
+	got := snapshot.Latest.RecentErrors
+	if got == nil || got.Count != 42 {
+		t.Fatalf("recent errors = %+v, want the reported count of 42 kept", got)
+	}
+	if len(got.Samples) != maxRecentErrorSamples || len(got.Samples[0]) != maxRecentErrorSampleLen {
+		t.Errorf("%d samples, first %d bytes; want %d samples of at most %d bytes", len(got.Samples), len(got.Samples[0]), maxRecentErrorSamples, maxRecentErrorSampleLen)
+	}
+}
+
+func TestGetHostsReportsInventory(t *testing.T) {
+	ds := newTestServer(t, nil)
+	for _, host := range []struct{ instance, kernel, arch string }{
+		{"old", "5.15.0-1051-aws", "amd64"},
+		{"new", "6.8.0-31-generic", "arm64"},
+		{"unknown", "", ""},
+	} {
+		mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: host.instance, Status: "healthy",
+			KernelVersion: host.kernel, OSRelease: "Ubuntu 24.04 LTS", Arch: host.arch})
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"?kernel=5.", "old"},
+		{"?kernel=6.8.0-31-generic", "new"},
+		{"?kernel=4.", ""},
+	}
+	for _, tt := range tests {
+		response := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"+tt.query))
+		var got []string
+		for _, host := range response.Hosts {
+			got = append(got, host.InstanceName)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s matched %v, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	response := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts?kernel=6."))
+	if host := response.Hosts[0]; host.Arch != "arm64" || host.OSRelease != "Ubuntu 24.04 LTS" {
+		t.Errorf("host = arch %q, os %q; want the reported inventory", host.Arch, host.OSRelease)
+	}
+}
+
+func TestMaxReportAge(t *testing.T) {
+	ago := func(d time.Duration) *time.Time {
+		at := time.Now().Add(-d)
+		return &at
+	}
+	tests := []struct {
+		name      string
+		maxAge    int
+		timestamp *time.Time
+		wantCode  string // empty when the report is accepted
+	}{
+		{"no client timestamp", 300, nil, ""},
+		{"recent", 300, ago(10 * time.Second), ""},
+		{"backfilled", 300, ago(10 * time.Minute), errCodeStaleReport},
+		{"client clock ahead", 300, ago(-time.Hour), ""},
+		{"check disabled", 0, ago(24 * time.Hour), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) { config.MaxReportAge = tt.maxAge })
+			rerr := ds.processReport(ds.logger, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: tt.timestamp}, "192.0.2.1", "", "")
+			var code string
+			if rerr != nil {
+				code = rerr.code
+			}
+			if code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q", code, tt.wantCode)
+			}
+			if _, stored, _ := ds.storage.GetHostSnapshot("web", "w1"); stored != (tt.wantCode == "") {
+				t.Errorf("report stored = %v, want %v", stored, tt.wantCode == "")
+			}
+		})
+	}
+}
+
+func TestLatestHostCarriesScoreBreakdown(t *testing.T) {
+	ds := newTestServer(t, nil)
+	breakdown := make([]ScoreContribution, maxHealthChecks+5)
+	for i := range breakdown {
+		breakdown[i] = ScoreContribution{Check: fmt.Sprintf("check-%d", i), Points: i % 10, MaxPoints: 10}
+	}
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy",
+		HealthMetrics: &HealthMetrics{OverallScore: 80, ScoreBreakdown: breakdown}})
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w2", Status: "healthy",
+		HealthMetrics: &HealthMetrics{OverallScore: 80}})
+
+	tests := []struct {
+		instance string
+		want     int // breakdown entries returned
+		field    bool
+	}{
+		{"w1", maxHealthChecks, true},
+		{"w2", 0, false},
+	}
+	for _, tt := range tests {
+		recorder := serve(ds, http.MethodGet, "/api/v1/hosts/web/"+tt.instance+"/latest")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", tt.instance, recorder.Code, recorder.Body)
+		}
+		if got := strings.Contains(recorder.Body.String(), `"score_breakdown"`); got != tt.field {
+			t.Errorf("%s: score_breakdown in body = %v, want %v", tt.instance, got, tt.field)
+		}
+		var host HostResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&host); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(host.HealthMetrics.ScoreBreakdown); got != tt.want {
+			t.Errorf("%s: %d breakdown entries, want %d", tt.instance, got, tt.want)
+		}
+	}
+}
+
+func TestHeartbeatsStayOutOfHistory(t *testing.T) {
+	type report struct{ detail, status string }
+	tests := []struct {
+		name    string
+		reports []report
+		want    []string // stored history
+		lastHB  bool     // whether the last report moved LastSeen past the last stored status
+	}{
+		{"heartbeats repeat the status", []report{{"full", "healthy"}, {"heartbeat", "healthy"}, {"heartbeat", "healthy"}}, []string{"healthy"}, true},
+		{"heartbeat from a new host", []report{{"heartbeat", "degraded"}}, []string{"degraded"}, false},
+		{"heartbeat with a new status", []report{{"full", "healthy"}, {"heartbeat", "unhealthy"}}, []string{"healthy", "unhealthy"}, false},
+		{"omitted detail is a full report", []report{{"", "healthy"}, {"", "healthy"}}, []string{"healthy", "healthy"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestServer(t, nil)
+			for _, r := range tt.reports {
+				mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: r.status, Detail: r.detail})
+			}
+			history, _, err := ds.storage.GetHost("web", "w1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, status := range history.Statuses {
+				got = append(got, status.Status)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("history = %v, want %v", got, tt.want)
+			}
+			last := history.Statuses[len(history.Statuses)-1].Timestamp
+			if moved := history.LastSeen.After(last); moved != tt.lastHB {
+				t.Errorf("LastSeen after the last stored status = %v, want %v", moved, tt.lastHB)
+			}
+		})
+	}
+
+	ds := newTestServer(t, nil)
+	if rerr := ds.processReport(ds.logger, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy", Detail: "verbose"}, "192.0.2.1", "", ""); rerr == nil || rerr.status != http.StatusBadRequest {
+		t.Errorf("report with detail verbose = %+v, want 400", rerr)
+	}
+}
+
+func TestGetHostsFilters(t *testing.T) {
+	ds := newTestServer(t, nil)
+	for _, host := range []struct{ service, instance, status string }{
+		{"web", "w1", "healthy"},
+		{"web", "w2", "unhealthy"},
+		{"payment", "p1", "unhealthy"},
+		{"payment", "p2", "degraded"},
+		{"payment", "p3", "healthy"},
+	} {
+		mustReport(t, ds, StatusRequest{ServiceName: host.service, InstanceName: host.instance, Status: host.status})
+	}
+	// p3 went quiet; its derived status is lost though it last reported healthy
+	ds.storage.MarkLost("payment", "p3", time.Now().Add(time.Minute), ds.config.StaleStatus)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"p1", "p2", "p3", "w1", "w2"}},
+		{"?status=unhealthy", []string{"p1", "w2"}},
+		{"?status=unhealthy,%20Degraded", []string{"p1", "p2", "w2"}},
+		{"?status=lost", []string{"p3"}},
+		{"?status=healthy", []string{"w1"}},
+		{"?service=payment", []string{"p1", "p2", "p3"}},
+		{"?service=payment&status=unhealthy,lost", []string{"p1", "p3"}},
+		{"?service=web&status=degraded", nil},
+		{"?service=unknown", nil},
+	}
+	for _, tt := range tests {
+		response := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"+tt.query))
+		var got []string
+		for _, host := range response.Hosts {
+			got = append(got, host.InstanceName)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q matched %v, want %v", tt.query, got, tt.want)
+		}
+		if response.Total != len(tt.want) {
+			t.Errorf("%q: total = %d, want the filtered %d", tt.query, response.Total, len(tt.want))
+		}
+	}
+}
+
+func TestLoadConfigFileThenEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("ENABLE_TLS", "false")
+	defaults, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// From here on only the file turns TLS off
+	t.Setenv("ENABLE_TLS", "")
+
+	// Every field set to something other than its default
+	file := Config{
+		ServerPort: "9443", HealthPort: "9080", BindAddress: "127.0.0.1", HealthBindAddress: "127.0.0.2",
+		UnixSocket: "/run/s01.sock", MaxHistory: 7, HistoryRetention: 3600, StaleTimeout: 90,
+		StaleStatus: "offline", SweepInterval: 5, CertFile: "server.crt", KeyFile: "server.key",
+		CACertFile: "ca.crt", LogLevel: "debug", LogFormat: "text", LogOutput: "stderr",
+		ReadTimeout: 11, WriteTimeout: 12, RequestTimeout: 13, MaxRequestBytes: 4096, DrainPeriod: 2,
+		EnableTLS: false, EnableHealthServer: false, HealthH2C: true, CNPolicy: cnPolicyService,
+		CertExpiryWarnDays: 30, RejectExpiredCert: true, MaxReportAge: 600, ClockSkewWarn: 5,
+		PersistPath: "history.jsonl", StorageBackend: storageSQLite, StoragePath: "hosts.db",
+		WebhookURL: "http://alerts.example/hook", WebhookDebounce: 60, AuditLogSize: 50,
+		AuditLogFile: "audit.jsonl", MaxHosts: 500, HostEviction: hostEvictionLRU,
+		StatusSmoothing: smoothingEWMA, SmoothingWindow: 9, TLSMinVersion: "1.3",
+		CipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", ClientIDSource: clientIDSourceSPIFFE,
+		OTLPEndpoint: "http://otel:4318", PrivacyMode: privacyHash, PrivacySalt: "pepper",
+		ReportRateLimit: 30, ReportRateBurst: 3, CORSAllowedOrigins: "https://ops.example",
+		AdvisedInterval: 45, TargetReportRate: 200, StaleGracePeriod: 120, StaleGraceReports: 4,
+		MaxGoroutines: 500, SmoothingAlpha: 0.5, SmoothingHealthyScore: 85, SmoothingDegradedScore: 50,
+		ServiceThresholds: map[string]ScoreThresholds{"db": {HealthyScore: 90, DegradedScore: 70}},
+	}
+	fields := reflect.ValueOf(file)
+	for i := 0; i < fields.NumField(); i++ {
+		name := fields.Type().Field(i).Name
+		if name != "EnableTLS" && reflect.DeepEqual(fields.Field(i).Interface(), reflect.ValueOf(*defaults).Field(i).Interface()) {
+			t.Fatalf("test config leaves %s at its default", name)
+		}
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("config.json", data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if !reflect.DeepEqual(*loaded, file) {
+		t.Errorf("loaded %+v\nwant the file's %+v", *loaded, file)
+	}
+
+	// Environment variables override the file
+	t.Setenv("MAX_HISTORY", "3")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("HEALTH_H2C", "false")
+	t.Setenv("SMOOTHING_ALPHA", "0.9")
+	loaded, err = loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig with env overrides: %v", err)
+	}
+	if loaded.MaxHistory != 3 || loaded.LogLevel != "warn" || loaded.HealthH2C || loaded.SmoothingAlpha != 0.9 {
+		t.Errorf("env overrides ignored: max_history %d, log_level %s, health_h2c %v, smoothing_alpha %v",
+			loaded.MaxHistory, loaded.LogLevel, loaded.HealthH2C, loaded.SmoothingAlpha)
+	}
+	if loaded.StaleTimeout != 90 || loaded.WebhookURL != file.WebhookURL {
+		t.Errorf("fields without env overrides lost the file's values: stale_timeout %d, webhook_url %s", loaded.StaleTimeout, loaded.WebhookURL)
+	}
+
+	if err := os.WriteFile("config.json", []byte(`{"max_history": "lots"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "config.json") {
+		t.Errorf("malformed config file = %v, want an error naming it", err)
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	withMetrics := func(status string, cpu, memory, disk float64) *HostStatus {
+		return &HostStatus{Status: status, HealthMetrics: &HealthMetrics{CPUUsage: cpu, MemoryUsage: memory, DiskUsage: disk}}
+	}
+	tests := []struct {
+		name        string
+		snapshots   []HostSnapshot
+		wantStatus  map[string]int
+		wantService map[string]int
+		wantMetrics int
+		wantCPU     float64
+		wantDisk    float64
+	}{
+		{
+			name:        "empty fleet",
+			wantStatus:  map[string]int{"healthy": 0, "degraded": 0, "unhealthy": 0, "lost": 0, "pending": 0},
+			wantService: map[string]int{},
+		},
+		{
+			name: "lost and metric-less hosts stay out of the averages",
+			snapshots: []HostSnapshot{
+				{ServiceName: "web", CurrentStatus: "healthy", Latest: withMetrics("healthy", 10, 40, 50)},
+				{ServiceName: "web", CurrentStatus: "degraded", Latest: withMetrics("degraded", 30, 60, 70)},
+				{ServiceName: "db", CurrentStatus: "lost", Latest: withMetrics("healthy", 99, 99, 99)},
+				{ServiceName: "db", CurrentStatus: "unhealthy", Latest: &HostStatus{Status: "unhealthy"}},
+				{ServiceName: "cache", CurrentStatus: "pending"},
+			},
+			wantStatus:  map[string]int{"healthy": 1, "degraded": 1, "unhealthy": 1, "lost": 1, "pending": 1},
+			wantService: map[string]int{"web": 2, "db": 2, "cache": 1},
+			wantMetrics: 2,
+			wantCPU:     20,
+			wantDisk:    60,
+		},
+	}
+	for _, tt := range tests {
+		stats := computeStats(tt.snapshots, "lost")
+		if stats.TotalHosts != len(tt.snapshots) || !reflect.DeepEqual(stats.ByStatus, tt.wantStatus) || !reflect.DeepEqual(stats.ByService, tt.wantService) {
+			t.Errorf("%s: counts %d %v %v, want %d %v %v", tt.name, stats.TotalHosts, stats.ByStatus, stats.ByService, len(tt.snapshots), tt.wantStatus, tt.wantService)
+		}
+		if stats.HostsWithMetrics != tt.wantMetrics || stats.AverageCPUUsage != tt.wantCPU || stats.AverageDiskUsage != tt.wantDisk {
+			t.Errorf("%s: %d hosts averaging cpu %v disk %v, want %d averaging %v %v", tt.name, stats.HostsWithMetrics, stats.AverageCPUUsage, stats.AverageDiskUsage, tt.wantMetrics, tt.wantCPU, tt.wantDisk)
+		}
+	}
+}
+
+func TestGetStatsCountsStaleHostsAsLost(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) { config.StaleStatus = "offline" })
+	for i, status := range []string{"healthy", "healthy", "unhealthy"} {
+		mustReport(t, ds, StatusRequest{
+			ServiceName:   "api",
+			InstanceName:  fmt.Sprintf("api-%d", i),
+			Status:        status,
+			HealthMetrics: &HealthMetrics{CPUUsage: float64(10 * (i + 1)), MemoryUsage: 50},
+		})
+	}
+	later := time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second)
+	if _, err := ds.storage.AddStatus(HostStatus{ServiceName: "api", InstanceName: "api-0", Status: "healthy", Timestamp: later, HealthMetrics: &HealthMetrics{CPUUsage: 10, MemoryUsage: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	ds.sweepStaleHosts(later)
+
+	recorder := serve(ds, http.MethodGet, "/api/v1/stats")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	var stats StatsResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.ByStatus["offline"] != 2 || stats.ByStatus["healthy"] != 1 || stats.ByService["api"] != 3 {
+		t.Errorf("counts = %v %v, want api-1 and api-2 offline", stats.ByStatus, stats.ByService)
+	}
+	if stats.HostsWithMetrics != 1 || stats.AverageCPUUsage != 10 || stats.AverageMemoryUsage != 50 {
+		t.Errorf("averages over %d hosts: cpu %v memory %v, want only api-0's", stats.HostsWithMetrics, stats.AverageCPUUsage, stats.AverageMemoryUsage)
+	}
+}
+
+func TestGetServiceInstancesOnlyLive(t *testing.T) {
+	ds := newTestServer(t, nil)
+	reports := map[string][]string{
+		"api":   {"healthy", "degraded", "unhealthy", "healthy"},
+		"queue": {"unhealthy"},
+	}
+	for service, statuses := range reports {
+		for i, status := range statuses {
+			mustReport(t, ds, StatusRequest{ServiceName: service, InstanceName: fmt.Sprintf("%s-%d", service, i), Status: status})
+		}
+	}
+	// api-3 stops reporting and is marked lost
+	later := time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second)
+	for i, status := range reports["api"][:3] {
+		ds.storage.AddStatus(HostStatus{ServiceName: "api", InstanceName: fmt.Sprintf("api-%d", i), Status: status, Timestamp: later})
+	}
+	ds.sweepStaleHosts(later)
+
+	tests := []struct {
+		target   string
+		wantCode int
+		want     []string
+	}{
+		{"/api/v1/services/api/instances", http.StatusOK, []string{"api-0"}},
+		{"/api/v1/services/api/instances?include_degraded=true", http.StatusOK, []string{"api-0", "api-1"}},
+		{"/api/v1/services/api/instances?include_degraded=false", http.StatusOK, []string{"api-0"}},
+		{"/api/v1/services/queue/instances", http.StatusOK, nil},
+		{"/api/v1/services/search/instances", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		recorder := serve(ds, http.MethodGet, tt.target)
+		if recorder.Code != tt.wantCode {
+			t.Errorf("GET %s = %d, want %d", tt.target, recorder.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+		var got []string
+		for _, host := range decodeDiscovery(t, recorder).Hosts {
+			got = append(got, host.InstanceName)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GET %s = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestCNMatchesHost(t *testing.T) {
+	tests := []struct {
+		policy string
+		cn     string
+		want   bool
+	}{
+		{cnPolicyOff, "", true},
+		{cnPolicyOff, "db-d1", true},
+		{cnPolicyExact, "web-w1", true},
+		{cnPolicyExact, "web", false},
+		{cnPolicyExact, "web-w2", false},
+		{cnPolicyService, "web", true},
+		{cnPolicyService, "web-w1", false},
+		{cnPolicyPrefix, "web", true},
+		{cnPolicyPrefix, "web-anything", true},
+		{cnPolicyPrefix, "webhook", false},
+		{cnPolicyPrefix, "", false},
+		{"bogus", "web", false},
+	}
+	for _, tt := range tests {
+		if got := cnMatchesHost(tt.policy, tt.cn, "web", "w1"); got != tt.want {
+			t.Errorf("%s policy, CN %q reporting web/w1 = %v, want %v", tt.policy, tt.cn, got, tt.want)
+		}
+	}
+}
+
+func TestReportRejectsMismatchedCN(t *testing.T) {
+	ca := newTestCA(t, "s01 test CA")
+	ds, url := newTLSTestServer(t, ca, func(config *Config) { config.CNPolicy = cnPolicyExact })
+	client := ca.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "web-w1"}})
+
+	report := func(service, instance string) int {
+		t.Helper()
+		body := fmt.Sprintf(`{"service_name": %q, "instance_name": %q, "status": "healthy"}`, service, instance)
+		resp, err := client.Post(url+"/api/v1/report", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := report("web", "w1"); code != http.StatusOK {
+		t.Errorf("report as web/w1 with CN web-w1 = %d, want 200", code)
+	}
+	for _, host := range [][2]string{{"web", "w2"}, {"db", "w1"}} {
+		if code := report(host[0], host[1]); code != http.StatusForbidden {
+			t.Errorf("report as %s/%s with CN web-w1 = %d, want 403", host[0], host[1], code)
+		}
+		if _, found, _ := ds.storage.GetHostSnapshot(host[0], host[1]); found {
+			t.Errorf("rejected report for %s/%s was stored", host[0], host[1])
+		}
+	}
+}
+
+func TestReportStatusValidation(t *testing.T) {
+	ds := newTestServer(t, nil)
+	tests := []struct {
+		status   string
+		wantCode int
+		stored   string // status recorded for the host when accepted
+	}{
+		{"healthy", http.StatusOK, "healthy"},
+		{"degraded", http.StatusOK, "degraded"},
+		{"unhealthy", http.StatusOK, "unhealthy"},
+		{"UnHealthy", http.StatusOK, "unhealthy"},
+		{"lost", http.StatusBadRequest, ""},
+		{"HEALTHY!!", http.StatusBadRequest, ""},
+		{"helthy", http.StatusBadRequest, ""},
+	}
+	for i, tt := range tests {
+		instance := fmt.Sprintf("v%d", i)
+		body := fmt.Sprintf(`{"service_name": "web", "instance_name": %q, "status": %q}`, instance, tt.status)
+		recorder := post(ds, "/api/v1/report", body)
+		if recorder.Code != tt.wantCode {
+			t.Errorf("status %q = %d, want %d", tt.status, recorder.Code, tt.wantCode)
+			continue
+		}
+
+		snapshot, found, _ := ds.storage.GetHostSnapshot("web", instance)
+		if tt.stored == "" {
+			var response ErrorResponse
+			json.NewDecoder(recorder.Body).Decode(&response)
+			if found || response.Error.Code != errCodeInvalidStatus {
+				t.Errorf("status %q: stored %v with error code %q, want nothing stored and %s", tt.status, found, response.Error.Code, errCodeInvalidStatus)
+			}
+		} else if !found || snapshot.CurrentStatus != tt.stored {
+			t.Errorf("status %q stored as %q, want %q", tt.status, snapshot.CurrentStatus, tt.stored)
+		}
+	}
+}
+
+func TestReportBodyLimit(t *testing.T) {
+	// padded returns a valid report padded to n bytes through its os_release
+	padded := func(n int) string {
+		report := `{"service_name": "web", "instance_name": "w1", "status": "healthy", "os_release": ""}`
+		return strings.Replace(report, `""`, `"`+strings.Repeat("x", n-len(report))+`"`, 1)
+	}
+	tests := []struct {
+		name   string
+		limit  int
+		target string
+		body   string
+		want   int
+	}{
+		{"at the limit", 1024, "/api/v1/report", padded(1024), http.StatusOK},
+		{"one byte over", 1024, "/api/v1/report", padded(1025), http.StatusRequestEntityTooLarge},
+		{"far over", 1024, "/api/v1/report", padded(10 << 20), http.StatusRequestEntityTooLarge},
+		{"batch over", 1024, "/api/v1/report/batch", "[" + padded(2048) + "]", http.StatusRequestEntityTooLarge},
+		{"limit disabled", 0, "/api/v1/report", padded(1 << 20), http.StatusOK},
+	}
+	for _, tt := range tests {
+		ds := newTestServer(t, func(config *Config) { config.MaxRequestBytes = tt.limit })
+		recorder := post(ds, tt.target, tt.body)
+		if recorder.Code != tt.want {
+			t.Errorf("%s: %d-byte body = %d, want %d", tt.name, len(tt.body), recorder.Code, tt.want)
+			continue
+		}
+		if tt.want == http.StatusRequestEntityTooLarge {
+			if hosts, _ := ds.storage.Count(); hosts != 0 {
+				t.Errorf("%s: oversized report stored", tt.name)
+			}
+		}
+	}
+}
+
+func TestReportBoundsChecks(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) { config.MaxRequestBytes = 0 })
+	metrics := HealthMetrics{OverallScore: 90}
+	for i := 0; i < 5000; i++ {
+		metrics.Checks = append(metrics.Checks, HealthCheck{Name: fmt.Sprintf("check %d", i), Status: "healthy"})
+		metrics.ScoreBreakdown = append(metrics.ScoreBreakdown, ScoreContribution{Check: fmt.Sprintf("check %d", i), Points: 1, MaxPoints: 1})
+	}
+	body, _ := json.Marshal(StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy", HealthMetrics: &metrics})
+
+	if recorder := post(ds, "/api/v1/report", string(body)); recorder.Code != http.StatusOK {
+		t.Fatalf("report with 5000 checks = %d, want it accepted and trimmed", recorder.Code)
+	}
+	snapshot, _, _ := ds.storage.GetHostSnapshot("web", "w1")
+	stored := snapshot.Latest.HealthMetrics
+	if len(stored.Checks) != maxHealthChecks || len(stored.ScoreBreakdown) != maxHealthChecks {
+		t.Errorf("stored %d checks and %d contributions, want %d of each", len(stored.Checks), len(stored.ScoreBreakdown), maxHealthChecks)
+	}
+	if stored.Checks[0].Name != "check 0" {
+		t.Errorf("first stored check %q, want the first reported", stored.Checks[0].Name)
+	}
+}
+
+func TestHealthRuntimeStats(t *testing.T) {
+	ds := newTestServer(t, nil)
+
+	// health fetches /health decoded into a generic map
+	health := func() map[string]interface{} {
+		t.Helper()
+		var body map[string]interface{}
+		if err := json.NewDecoder(serve(ds, http.MethodGet, "/health").Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	// Before Start there is no uptime yet
+	if uptime := health()["uptime_seconds"]; uptime != 0.0 {
+		t.Errorf("uptime before Start = %v, want 0", uptime)
+	}
+
+	ds.startedAt = time.Now().Add(-time.Minute)
+	first := health()
+	for _, key := range []string{"status", "timestamp", "total_hosts", "version", "goroutines", "heap_alloc_bytes", "heap_sys_bytes", "gc_count", "uptime_seconds"} {
+		if _, ok := first[key]; !ok {
+			t.Errorf("/health lacks %s", key)
+		}
+	}
+	if goroutines, _ := first["goroutines"].(float64); goroutines < 1 {
+		t.Errorf("goroutines = %v", first["goroutines"])
+	}
+	if alloc, sys := first["heap_alloc_bytes"].(float64), first["heap_sys_bytes"].(float64); alloc <= 0 || sys < alloc {
+		t.Errorf("heap alloc %v of sys %v", alloc, sys)
+	}
+
+	runtime.GC()
+	time.Sleep(10 * time.Millisecond)
+	second := health()
+	if uptime := first["uptime_seconds"].(float64); uptime < 60 || second["uptime_seconds"].(float64) <= uptime {
+		t.Errorf("uptime went from %v to %v, want at least 60 and increasing", uptime, second["uptime_seconds"])
+	}
+	if second["gc_count"].(float64) <= first["gc_count"].(float64) {
+		t.Errorf("gc_count stayed at %v across a collection", second["gc_count"])
+	}
+}
+
+func TestHealthBuildInfo(t *testing.T) {
+	ds := newTestServer(t, nil)
+	defer func(v, c, d string) { version, gitCommit, buildDate = v, c, d }(version, gitCommit, buildDate)
+
+	tests := []struct {
+		name                     string
+		version, commit, builtAt string
+	}{
+		{"unset", "dev", "dev", "dev"},
+		{"release build", "v1.4.2", "3f9c2ab", "2026-10-01T12:00:00Z"},
+	}
+	for _, tt := range tests {
+		if tt.name != "unset" {
+			version, gitCommit, buildDate = tt.version, tt.commit, tt.builtAt
+		}
+		var health struct {
+			Version   string `json:"version"`
+			GitCommit string `json:"git_commit"`
+			BuildDate string `json:"build_date"`
+		}
+		if err := json.NewDecoder(serve(ds, http.MethodGet, "/health").Body).Decode(&health); err != nil {
+			t.Fatal(err)
+		}
+		if health.Version != tt.version || health.GitCommit != tt.commit || health.BuildDate != tt.builtAt {
+			t.Errorf("%s: /health reports %+v, want %s %s %s", tt.name, health, tt.version, tt.commit, tt.builtAt)
+		}
+	}
+}
+
+func TestHostWithoutStatusesIsPending(t *testing.T) {
+	ds := newTestServer(t, nil)
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+	// As replay can leave it: a history with nothing in it
+	memory := ds.storage.(*InMemoryStorage)
+	memory.hosts[hostKey("web", "w2")] = &HostHistory{
+		ServiceName:   "web",
+		InstanceName:  "w2",
+		LastSeen:      time.Now().Add(-time.Hour),
+		CurrentStatus: "healthy",
+	}
+
+	// Never seen, so never stale
+	later := time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second)
+	if _, err := ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", IPAddress: "10.0.0.1", Timestamp: later}); err != nil {
+		t.Fatal(err)
+	}
+	if lost := ds.sweepStaleHosts(later); lost != 0 {
+		t.Errorf("sweep marked %d hosts lost, want the pending host left alone", lost)
+	}
+
+	hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"))
+	byInstance := make(map[string]HostResponse)
+	for _, host := range hosts.Hosts {
+		byInstance[host.InstanceName] = host
+	}
+	if w1 := byInstance["w1"]; w1.Status != "healthy" || w1.IPAddress != "10.0.0.1" {
+		t.Errorf("reporting host = %+v", w1)
+	}
+	if w2 := byInstance["w2"]; w2.Status != "pending" || !w2.LastSeen.IsZero() || w2.IPAddress != "" {
+		t.Errorf("host without statuses = %+v, want pending with a zero last_seen", w2)
+	}
+
+	var detail HostHistoryResponse
+	if err := json.NewDecoder(serve(ds, http.MethodGet, "/api/v1/hosts/web/w2").Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.CurrentStatus != "pending" || !detail.LastSeen.IsZero() || len(detail.Statuses) != 0 {
+		t.Errorf("detail = %+v, want pending with no statuses", detail)
+	}
+
+	var stats StatsResponse
+	if err := json.NewDecoder(serve(ds, http.MethodGet, "/api/v1/stats").Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.ByStatus["pending"] != 1 || stats.ByStatus["lost"] != 0 || stats.ByStatus["healthy"] != 1 {
+		t.Errorf("stats by status = %v, want one pending and one healthy", stats.ByStatus)
+	}
+}
+
+func TestReportSequence(t *testing.T) {
+	tests := []struct {
+		name   string
+		detail string
+		seq    uint64
+		want   int
+	}{
+		{"first", reportDetailFull, 10, http.StatusOK},
+		{"in order", reportDetailFull, 11, http.StatusOK},
+		{"gap", reportDetailFull, 15, http.StatusOK},
+		{"duplicate", reportDetailFull, 15, http.StatusConflict},
+		{"out of order", reportDetailFull, 12, http.StatusConflict},
+		{"replayed heartbeat", reportDetailHeartbeat, 14, http.StatusConflict},
+		{"heartbeat", reportDetailHeartbeat, 16, http.StatusOK},
+		{"unnumbered", reportDetailFull, 0, http.StatusOK},
+		{"after an unnumbered report", reportDetailFull, 16, http.StatusConflict},
+		{"newer", reportDetailFull, 17, http.StatusOK},
+	}
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+			})
+			taken := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+			for _, tt := range tests {
+				body := fmt.Sprintf(`{"service_name":"web","instance_name":"w1","status":"healthy","detail":%q,"sequence":%d,"timestamp":%q}`,
+					tt.detail, tt.seq, taken.Format(time.RFC3339))
+				recorder := post(ds, "/api/v1/report", body)
+				if recorder.Code != tt.want {
+					t.Errorf("%s (sequence %d): status = %d, want %d; body %s", tt.name, tt.seq, recorder.Code, tt.want, recorder.Body)
+				}
+				if tt.want == http.StatusConflict && !strings.Contains(recorder.Body.String(), errCodeStaleSequence) {
+					t.Errorf("%s: body %s, want the %s code", tt.name, recorder.Body, errCodeStaleSequence)
+				}
+			}
+
+			var detail HostHistoryResponse
+			if err := json.NewDecoder(serve(ds, http.MethodGet, "/api/v1/hosts/web/w1").Body).Decode(&detail); err != nil {
+				t.Fatal(err)
+			}
+			latest := detail.Statuses[len(detail.Statuses)-1]
+			if latest.Sequence != 17 || latest.ClientTime == nil || !latest.ClientTime.Equal(taken) || latest.Timestamp.Equal(taken) {
+				t.Errorf("latest stored status = %+v, want sequence 17 with the client time beside the receive time", latest)
+			}
+		})
+	}
+}
+
+func TestSetupLoggerFormats(t *testing.T) {
+	tests := []struct {
+		format  string
+		isJSON  bool
+		warning string
+	}{
+		{"json", true, ""},
+		{"", true, ""},
+		{"text", false, ""},
+		{"LOGFMT", false, ""},
+		{"yaml", true, "Unknown log format"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "server.log")
+		// An existing log is appended to, not truncated
+		if err := os.WriteFile(path, []byte("earlier line\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		logger := setupLogger("info", tt.format, path)
+		logger.Debug("Hidden below info")
+		logger.Info("Server started", "port", 8443)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if lines[0] != "earlier line" {
+			t.Errorf("%q: log file starts %q, want the earlier content kept", tt.format, lines[0])
+		}
+		last := lines[len(lines)-1]
+		var entry map[string]interface{}
+		if isJSON := json.Unmarshal([]byte(last), &entry) == nil; isJSON != tt.isJSON {
+			t.Errorf("%q: wrote %q, want JSON %v", tt.format, last, tt.isJSON)
+		}
+		if !strings.Contains(last, "Server started") || !strings.Contains(last, "8443") {
+			t.Errorf("%q: last line %q, want the message and its attribute", tt.format, last)
+		}
+		if strings.Contains(string(content), "Hidden") {
+			t.Errorf("%q: debug line written at info level", tt.format)
+		}
+		if got := strings.Contains(string(content), "Unknown log format"); got != (tt.warning != "") {
+			t.Errorf("%q: fallback warning logged = %v", tt.format, got)
+		}
+	}
+}
+
+func TestSetupLoggerOutputFallback(t *testing.T) {
+	// Capture what lands on stdout
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+	os.Stdout = write
+
+	logger := setupLogger("info", "json", filepath.Join(t.TempDir(), "missing", "server.log"))
+	logger.Info("Still logged")
+	write.Close()
+	captured, _ := io.ReadAll(read)
+
+	if !strings.Contains(string(captured), "Failed to open log output") || !strings.Contains(string(captured), "Still logged") {
+		t.Errorf("stdout = %s, want the warning and later lines", captured)
+	}
+}
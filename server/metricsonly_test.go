@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// hostDetail fetches a host's history through the API
+func hostDetail(t *testing.T, ds *S01Server, serviceName, instanceName string) HostHistoryResponse {
+	t.Helper()
+	recorder := serve(ds, http.MethodGet, "/api/v1/hosts/"+serviceName+"/"+instanceName)
+	var detail HostHistoryResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&detail); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("host detail = %d, %v", recorder.Code, err)
+	}
+	return detail
+}
+
+func TestMetricsOnlyValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"metrics only", `{"service_name":"web","instance_name":"w1","metrics_only":true,"health_metrics":{"overall_score":90}}`, http.StatusOK, ""},
+		{"status alongside", `{"service_name":"web","instance_name":"w1","metrics_only":true,"status":"healthy","health_metrics":{}}`, http.StatusBadRequest, errCodeInvalidRequest},
+		{"no metrics", `{"service_name":"web","instance_name":"w1","metrics_only":true}`, http.StatusBadRequest, errCodeInvalidRequest},
+		{"as a heartbeat", `{"service_name":"web","instance_name":"w1","metrics_only":true,"detail":"heartbeat","health_metrics":{}}`, http.StatusBadRequest, errCodeInvalidRequest},
+		{"unknown host", `{"service_name":"web","instance_name":"w9","metrics_only":true,"health_metrics":{}}`, http.StatusConflict, errCodeNoPriorStatus},
+		// Without the flag an empty status is still refused
+		{"empty status", `{"service_name":"web","instance_name":"w1","status":"","health_metrics":{}}`, http.StatusBadRequest, errCodeInvalidRequest},
+		{"flag off", `{"service_name":"web","instance_name":"w1","metrics_only":false,"health_metrics":{}}`, http.StatusBadRequest, errCodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		ds := newTestServer(t, nil)
+		mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+
+		recorder := post(ds, "/api/v1/report", tt.body)
+		var response ErrorResponse
+		json.NewDecoder(recorder.Body).Decode(&response)
+		if recorder.Code != tt.wantStatus || response.Error.Code != tt.wantCode {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, recorder.Code, response.Error.Code, tt.wantStatus, tt.wantCode)
+		}
+	}
+}
+
+func TestMetricsOnlyKeepsPriorStatus(t *testing.T) {
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+			})
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "degraded", Sequence: 1,
+				HealthMetrics: &HealthMetrics{CPUUsage: 92, OverallScore: 65}})
+			before := hostDetail(t, ds, "web", "w1")
+			etag := serve(ds, http.MethodGet, "/api/v1/hosts").Header().Get("ETag")
+
+			time.Sleep(10 * time.Millisecond) // so LastSeen visibly moves
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", MetricsOnly: true, Sequence: 2,
+				HealthMetrics: &HealthMetrics{CPUUsage: 40, OverallScore: 88},
+				RecentErrors:  &RecentErrors{Count: 2, Samples: []string{"disk slow"}}})
+
+			after := hostDetail(t, ds, "web", "w1")
+			if after.CurrentStatus != "degraded" || len(after.Statuses) != 1 {
+				t.Fatalf("after metrics: %s with %d statuses, want degraded with the one entry", after.CurrentStatus, len(after.Statuses))
+			}
+			latest := after.Statuses[0]
+			if latest.Status != "degraded" || latest.HealthMetrics.OverallScore != 88 || latest.HealthMetrics.CPUUsage != 40 {
+				t.Errorf("latest status %s with metrics %+v, want degraded with the pushed metrics", latest.Status, latest.HealthMetrics)
+			}
+			if latest.RecentErrors == nil || latest.RecentErrors.Count != 2 {
+				t.Errorf("recent errors = %+v, want the pushed ones", latest.RecentErrors)
+			}
+			if !after.LastSeen.After(before.LastSeen) {
+				t.Errorf("last seen %v, want it past %v", after.LastSeen, before.LastSeen)
+			}
+			if now := serve(ds, http.MethodGet, "/api/v1/hosts").Header().Get("ETag"); now == etag {
+				t.Error("host listing ETag unchanged by new metrics")
+			}
+
+			// Metrics without recent errors leave the stored ones alone, and
+			// replayed sequences are refused as for any report
+			mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", MetricsOnly: true, Sequence: 3,
+				HealthMetrics: &HealthMetrics{OverallScore: 90}})
+			if again := hostDetail(t, ds, "web", "w1"); again.Statuses[0].RecentErrors == nil {
+				t.Error("recent errors dropped by a report without them")
+			}
+			if err := ds.processReport(discardLogger, StatusRequest{ServiceName: "web", InstanceName: "w1", MetricsOnly: true, Sequence: 3,
+				HealthMetrics: &HealthMetrics{}}, "192.0.2.1", "", ""); err == nil || err.status != http.StatusConflict {
+				t.Errorf("replayed metrics-only report = %+v, want 409", err)
+			}
+		})
+	}
+}
+
+func TestMetricsOnlyRevivesLostHost(t *testing.T) {
+	ds := newTestServer(t, nil)
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "unhealthy"})
+	ds.sweepStaleHosts(time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second))
+	if host := hostDetail(t, ds, "web", "w1"); host.CurrentStatus != "lost" {
+		t.Fatalf("host %s after the sweep, want lost", host.CurrentStatus)
+	}
+
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", MetricsOnly: true, HealthMetrics: &HealthMetrics{OverallScore: 40}})
+	if host := hostDetail(t, ds, "web", "w1"); host.CurrentStatus != "unhealthy" {
+		t.Errorf("host %s after pushing metrics, want its last reported status unhealthy", host.CurrentStatus)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+)
+
+// Privacy modes, controlling how client IP addresses appear in logs. The API
+// and storage always keep the real address.
+const (
+	privacyOff  = "off"  // log ip_address as-is
+	privacyHash = "hash" // log a salted hash as ip_hash instead
+	privacyOmit = "omit" // leave client IPs out of logs
+)
+
+// validatePrivacyMode checks a PRIVACY_MODE value
+func validatePrivacyMode(mode string) error {
+	switch mode {
+	case privacyOff, privacyHash, privacyOmit:
+		return nil
+	default:
+		return fmt.Errorf("unknown privacy_mode %q (expected %s, %s or %s)", mode, privacyOff, privacyHash, privacyOmit)
+	}
+}
+
+// ipRedactor renders client IPs for log lines according to a privacy mode
+type ipRedactor struct {
+	mode string
+	salt []byte
+}
+
+// newIPRedactor creates a redactor for mode. Hashes are keyed with salt so
+// the same address always maps to the same hash; an empty salt is replaced
+// with a random one, making hashes stable only until restart.
+func newIPRedactor(mode, salt string) *ipRedactor {
+	ir := &ipRedactor{mode: mode, salt: []byte(salt)}
+	if mode == privacyHash && salt == "" {
+		ir.salt = make([]byte, 32)
+		rand.Read(ir.salt)
+	}
+	return ir
+}
+
+// attr returns the log attribute for ip: ip_address, ip_hash, or an empty
+// attribute that handlers skip
+func (ir *ipRedactor) attr(ip string) slog.Attr {
+	switch ir.mode {
+	case privacyHash:
+		mac := hmac.New(sha256.New, ir.salt)
+		mac.Write([]byte(ip))
+		return slog.String("ip_hash", hex.EncodeToString(mac.Sum(nil))[:16])
+	case privacyOmit:
+		return slog.Attr{}
+	default:
+		return slog.String("ip_address", ip)
+	}
+}
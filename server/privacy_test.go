@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// reportFrom posts a report from remoteAddr with ds's logger replaced by one
+// capturing JSON lines, and returns the "Host status reported" line's fields
+func reportFrom(t *testing.T, ds *S01Server, remoteAddr, body string) map[string]interface{} {
+	t.Helper()
+	var logs strings.Builder
+	ds.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/report", strings.NewReader(body))
+	req.RemoteAddr = remoteAddr
+	recorder := httptest.NewRecorder()
+	ds.routes().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("report = %d %s", recorder.Code, recorder.Body)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(logs.String()))
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if json.Unmarshal(scanner.Bytes(), &line) == nil && line["msg"] == "Host status reported" {
+			return line
+		}
+	}
+	t.Fatalf("no report line in %s", logs.String())
+	return nil
+}
+
+func TestPrivacyModeLogs(t *testing.T) {
+	const body = `{"service_name":"web","instance_name":"w1","status":"healthy"}`
+	tests := []struct {
+		mode, salt  string
+		wantAddress bool
+		wantHash    bool
+	}{
+		{privacyOff, "", true, false},
+		{privacyHash, "pepper", false, true},
+		{privacyHash, "", false, true},
+		{privacyOmit, "", false, false},
+	}
+	for _, tt := range tests {
+		ds := newTestServer(t, func(config *Config) {
+			config.PrivacyMode, config.PrivacySalt = tt.mode, tt.salt
+		})
+		line := reportFrom(t, ds, "203.0.113.7:40000", body)
+
+		raw, _ := json.Marshal(line)
+		if strings.Contains(string(raw), "203.0.113.7") != tt.wantAddress {
+			t.Errorf("%s: log line %s, want the raw IP %v", tt.mode, raw, tt.wantAddress)
+		}
+		hash, hashed := line["ip_hash"].(string)
+		if hashed != tt.wantHash || hashed && len(hash) != 16 {
+			t.Errorf("%s: ip_hash = %v, want a 16-digit hash %v", tt.mode, line["ip_hash"], tt.wantHash)
+		}
+
+		// The API is not redacted
+		hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"))
+		if hosts.Hosts[0].IPAddress != "203.0.113.7" {
+			t.Errorf("%s: API ip_address = %q, want the real address", tt.mode, hosts.Hosts[0].IPAddress)
+		}
+	}
+}
+
+func TestPrivacyHashIsStable(t *testing.T) {
+	// hashOf logs a report from ip on a fresh server salted with salt
+	hashOf := func(salt, ip string) string {
+		ds := newTestServer(t, func(config *Config) {
+			config.PrivacyMode, config.PrivacySalt = privacyHash, salt
+		})
+		line := reportFrom(t, ds, ip+":40000", `{"service_name":"web","instance_name":"w1","status":"healthy"}`)
+		return line["ip_hash"].(string)
+	}
+
+	first := hashOf("pepper", "203.0.113.7")
+	if again := hashOf("pepper", "203.0.113.7"); again != first {
+		t.Errorf("same salt and IP hashed as %s then %s", first, again)
+	}
+	if other := hashOf("pepper", "203.0.113.8"); other == first {
+		t.Error("different IPs share a hash")
+	}
+	if resalted := hashOf("paprika", "203.0.113.7"); resalted == first {
+		t.Error("hash unchanged by a different salt")
+	}
+}
+
+func TestLoadConfigPrivacyMode(t *testing.T) {
+	t.Setenv("ENABLE_TLS", "false")
+	t.Setenv("PRIVACY_MODE", "mask")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "privacy_mode") {
+		t.Errorf("PRIVACY_MODE=mask = %v, want it refused", err)
+	}
+}
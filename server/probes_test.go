@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// freePort returns a loopback port nothing is listening on
+func freePort(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+// startServer runs ds.Start until readyz answers 200 and returns a function
+// that shuts the server down with SIGTERM and waits for Start to return; it
+// does nothing on later calls
+func startServer(t *testing.T, ds *S01Server, readyz string) (stop func()) {
+	t.Helper()
+	// Keep SIGTERM from killing the test before Start is listening for it
+	caught := make(chan os.Signal, 1)
+	signal.Notify(caught, syscall.SIGTERM)
+
+	done := make(chan error, 1)
+	go func() { done <- ds.Start() }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(readyz)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s not 200 within 5s of Start: %v", readyz, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			defer signal.Stop(caught)
+			for {
+				syscall.Kill(os.Getpid(), syscall.SIGTERM)
+				select {
+				case err := <-done:
+					if err != nil {
+						t.Errorf("Start = %v", err)
+					}
+					return
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+		})
+	}
+}
+
+func TestReadyzFollowsStartAndShutdown(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.BindAddress = "127.0.0.1"
+		config.ServerPort, config.HealthPort = freePort(t), freePort(t)
+		config.DrainPeriod = 0
+	})
+	probe := func(path string) int {
+		recorder := httptest.NewRecorder()
+		ds.healthRoutes().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder.Code
+	}
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before Start = %d, want 503", code)
+	}
+	if code := probe("/livez"); code != http.StatusOK {
+		t.Errorf("/livez before Start = %d, want 200", code)
+	}
+
+	stop := startServer(t, ds, "http://127.0.0.1:"+ds.config.HealthPort+"/readyz")
+	// Readiness means the main listener already accepts connections
+	if conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", ds.config.ServerPort)); err != nil {
+		t.Errorf("main listener not accepting once ready: %v", err)
+	} else {
+		conn.Close()
+	}
+
+	stop()
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz after shutdown = %d, want 503", code)
+	}
+}
+
+func TestStartReportsBindFailure(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	_, port, _ := net.SplitHostPort(taken.Addr().String())
+
+	ds := newTestServer(t, func(config *Config) {
+		config.BindAddress = "127.0.0.1"
+		config.ServerPort, config.HealthPort = port, freePort(t)
+	})
+	if err := ds.Start(); err == nil || !strings.Contains(err.Error(), "failed to listen") {
+		t.Errorf("Start on a taken port = %v, want the bind error", err)
+	}
+	if ds.ready.Load() {
+		t.Error("server ready though its listener never bound")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// limiterPruneInterval is how often idle buckets are dropped from the limiter
+const limiterPruneInterval = time.Minute
+
+// reportLimiter is a token bucket per host: a host may send up to burst
+// reports at once, refilled at a steady rate. Hosts idle long enough for
+// their bucket to refill are pruned, so departed hosts do not accumulate.
+type reportLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+// tokenBucket is one host's remaining allowance as of updated
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newReportLimiter allows perMinute reports per minute per host with bursts
+// of up to burst; it returns nil, which allows everything, when perMinute is 0
+func newReportLimiter(perMinute, burst int) *reportLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &reportLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   math.Max(float64(burst), 1),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from key's bucket, reporting false when none is left
+func (rl *reportLimiter) allow(key string, now time.Time) bool {
+	if rl == nil {
+		return true
+	}
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if now.Sub(rl.lastPrune) >= limiterPruneInterval {
+		rl.prune(now)
+	}
+
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.burst, updated: now}
+		rl.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(rl.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*rl.rate)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// forget drops key's bucket
+func (rl *reportLimiter) forget(key string) {
+	if rl == nil {
+		return
+	}
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	delete(rl.buckets, key)
+}
+
+// retryAfter is the longest a limited host waits for its next token, in
+// whole seconds for the Retry-After header
+func (rl *reportLimiter) retryAfter() int {
+	return int(math.Ceil(1 / rl.rate))
+}
+
+// prune drops buckets that would have refilled completely by now, which are
+// indistinguishable from a new bucket; the caller must hold rl.mutex
+func (rl *reportLimiter) prune(now time.Time) {
+	refill := time.Duration(rl.burst / rl.rate * float64(time.Second))
+	for key, bucket := range rl.buckets {
+		if now.Sub(bucket.updated) >= refill {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastPrune = now
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReportLimiterBucket(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	rl := newReportLimiter(60, 3)
+	tests := []struct {
+		key    string
+		offset time.Duration
+		want   bool
+	}{
+		{"web/w1", 0, true},
+		{"web/w1", 0, true},
+		{"web/w1", 0, true},
+		{"web/w1", 0, false},
+		{"web/w2", 0, true}, // another host has its own bucket
+		{"web/w1", 500 * time.Millisecond, false},
+		{"web/w1", time.Second, true}, // one token refilled per second
+		{"web/w1", time.Second, false},
+		{"web/w1", time.Hour, true}, // refills cap at the burst
+		{"web/w1", time.Hour, true},
+		{"web/w1", time.Hour, true},
+		{"web/w1", time.Hour, false},
+	}
+	for i, tt := range tests {
+		if got := rl.allow(tt.key, start.Add(tt.offset)); got != tt.want {
+			t.Errorf("report %d from %s at +%v allowed = %v, want %v", i, tt.key, tt.offset, got, tt.want)
+		}
+	}
+	if rl.retryAfter() != 1 {
+		t.Errorf("retryAfter = %d, want 1s at one report per second", rl.retryAfter())
+	}
+	if slow := newReportLimiter(20, 1); slow.retryAfter() != 3 {
+		t.Errorf("retryAfter = %d, want 3s at 20 reports per minute", slow.retryAfter())
+	}
+
+	disabled := newReportLimiter(0, 10)
+	for i := 0; i < 100; i++ {
+		if !disabled.allow("web/w1", start) {
+			t.Fatal("a zero rate limit refused a report")
+		}
+	}
+	disabled.forget("web/w1")
+}
+
+func TestReportLimiterPrunesIdleBuckets(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	rl := newReportLimiter(60, 10) // a drained bucket refills in 10s
+	rl.allow("web/departed", start)
+	rl.allow("web/busy", start)
+
+	rl.allow("web/busy", start.Add(limiterPruneInterval-time.Second))
+	if len(rl.buckets) != 2 {
+		t.Fatalf("buckets pruned before the prune interval: %v", rl.buckets)
+	}
+	rl.allow("web/busy", start.Add(limiterPruneInterval))
+	if _, ok := rl.buckets["web/departed"]; ok {
+		t.Error("idle bucket kept past its refill time")
+	}
+	if _, ok := rl.buckets["web/busy"]; !ok {
+		t.Error("bucket of a reporting host pruned")
+	}
+}
+
+func TestReportRateLimited(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.ReportRateLimit, config.ReportRateBurst = 30, 3
+	})
+	const body = `{"service_name":"web","instance_name":"%s","status":"healthy"}`
+
+	var codes []int
+	for i := 0; i < 6; i++ {
+		recorder := post(ds, "/api/v1/report", fmt.Sprintf(body, "w1"))
+		codes = append(codes, recorder.Code)
+		if recorder.Code != http.StatusTooManyRequests {
+			continue
+		}
+		if retry := recorder.Header().Get("Retry-After"); retry != "2" {
+			t.Errorf("Retry-After = %q, want 2s at 30 reports per minute", retry)
+		}
+		var response ErrorResponse
+		if json.NewDecoder(recorder.Body).Decode(&response); response.Error.Code != errCodeRateLimited {
+			t.Errorf("429 body = %+v, want rate_limited", response)
+		}
+	}
+	if fmt.Sprint(codes) != "[200 200 200 429 429 429]" {
+		t.Errorf("codes = %v, want the burst of 3 then 429s", codes)
+	}
+	if history, _, _ := ds.storage.GetHost("web", "w1"); len(history.Statuses) != 3 {
+		t.Errorf("%d statuses stored, want only the 3 allowed", len(history.Statuses))
+	}
+	if recorder := post(ds, "/api/v1/report", fmt.Sprintf(body, "w2")); recorder.Code != http.StatusOK {
+		t.Errorf("another host limited with w1: %d", recorder.Code)
+	}
+}
+
+func TestBatchRateLimited(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.ReportRateLimit, config.ReportRateBurst = 60, 1
+	})
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+	ds.limiter.allow(hostKey("web", "w1"), time.Now())
+
+	// Both of w2's reports cost a single token; w1 has none left
+	recorder := post(ds, "/api/v1/report/batch", `[
+		{"service_name":"web","instance_name":"w1","status":"healthy"},
+		{"service_name":"web","instance_name":"w2","status":"healthy"},
+		{"service_name":"web","instance_name":"w2","status":"degraded"}]`)
+	var response BatchResponse
+	json.NewDecoder(recorder.Body).Decode(&response)
+	if response.Accepted != 2 || response.Rejected != 1 || response.Results[0].Error == nil || response.Results[0].Error.Code != errCodeRateLimited {
+		t.Errorf("batch = %d %+v, want w1 rate limited and both w2 reports stored", recorder.Code, response)
+	}
+
+	recorder = post(ds, "/api/v1/report/batch", `[{"service_name":"web","instance_name":"w1","status":"healthy"},{"service_name":"web","instance_name":"w2","status":"healthy"}]`)
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("batch with every host limited = %d, Retry-After %q; want 429 with Retry-After", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+}
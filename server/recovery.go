@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// recoveryResponseWriter notes whether the handler started its response, in
+// which case a 500 can no longer be sent
+type recoveryResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (rw *recoveryResponseWriter) WriteHeader(status int) {
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recoveryResponseWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *recoveryResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// withRecovery turns a panic in the handler into a logged stack trace and a
+// 500 JSON error, and marks the server unhealthy on /health for a while.
+// http.ErrAbortHandler is re-raised so net/http still aborts the response
+// quietly; fatal runtime errors and os.Exit cannot be recovered and still
+// end the process.
+func (ds *S01Server) withRecovery(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryResponseWriter{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			ds.self.recordPanic(time.Now())
+			ds.requestLogger(r).Error("Handler panicked",
+				"panic", recovered,
+				"method", r.Method,
+				"path", r.URL.Path,
+				ds.ipLog.attr(getClientIP(r)),
+				"client_cn", getClientCN(r),
+				"stack", string(debug.Stack()),
+			)
+
+			// Once the response has started, the client gets a truncated
+			// body instead; appending an error would corrupt it
+			if !rw.wroteHeader {
+				writeJSONError(rw, http.StatusInternalServerError, errCodeInternal, "Internal server error")
+			}
+		}()
+		next(rw, r)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryLogsRedactedClientIP(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantNot string
+	}{
+		{privacyOff, `"ip_address":"198.51.100.7"`, "192.0.2.1"},
+		{privacyHash, `"ip_hash":`, "198.51.100.7"},
+		{privacyOmit, `"Handler panicked"`, "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) { config.PrivacyMode = tt.mode })
+			var logs bytes.Buffer
+			ds.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+			req.RemoteAddr = "192.0.2.1:4711"
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+			recorder := httptest.NewRecorder()
+			ds.withRecovery(func(http.ResponseWriter, *http.Request) { panic("boom") })(recorder, req)
+
+			if recorder.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want 500", recorder.Code)
+			}
+			if !strings.Contains(logs.String(), tt.want) || strings.Contains(logs.String(), tt.wantNot) {
+				t.Errorf("log = %s; want %s and no %s", logs.String(), tt.want, tt.wantNot)
+			}
+		})
+	}
+}
+
+func TestRecoveryAnswers500WithStack(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantCode int
+		wantBody string
+		wantLog  bool
+	}{
+		{"no panic", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("fine")) }, http.StatusOK, "fine", false},
+		{"panic before responding", func(w http.ResponseWriter, r *http.Request) {
+			var hosts map[string]int
+			hosts["w1"]++ // nil map write
+		}, http.StatusInternalServerError, `"code":"internal_error"`, true},
+		{"panic with an error", func(w http.ResponseWriter, r *http.Request) { panic(errors.New("storage exploded")) },
+			http.StatusInternalServerError, `"code":"internal_error"`, true},
+		// The status line is gone, so the client gets the truncated body as is
+		{"panic mid-response", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"hosts":[`))
+			panic("boom")
+		}, http.StatusOK, `{"hosts":[`, true},
+	}
+	for _, tt := range tests {
+		ds := newTestServer(t, nil)
+		var logs bytes.Buffer
+		ds.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+		recorder := httptest.NewRecorder()
+		ds.withRecovery(tt.handler)(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/report", nil))
+
+		if recorder.Code != tt.wantCode || !strings.Contains(recorder.Body.String(), tt.wantBody) {
+			t.Errorf("%s: %d %s, want %d with %s", tt.name, recorder.Code, recorder.Body, tt.wantCode, tt.wantBody)
+		}
+		if tt.name == "panic mid-response" && recorder.Body.String() != `{"hosts":[` {
+			t.Errorf("%s: error appended to the started body: %s", tt.name, recorder.Body)
+		}
+		if !tt.wantLog {
+			if logs.Len() != 0 {
+				t.Errorf("%s: logged %s", tt.name, logs.String())
+			}
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: log %q: %v", tt.name, logs.String(), err)
+		}
+		stack, _ := entry["stack"].(string)
+		if entry["level"] != "ERROR" || entry["method"] != "POST" || entry["path"] != "/api/v1/report" ||
+			!strings.Contains(stack, "goroutine ") || !strings.Contains(stack, "recovery_test.go") {
+			t.Errorf("%s: log entry %v, want an error with the request and a stack through the handler", tt.name, entry)
+		}
+	}
+}
+
+func TestRecoveryLetsAbortHandlerThrough(t *testing.T) {
+	ds := newTestServer(t, nil)
+	var logs bytes.Buffer
+	ds.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler re-raised", recovered)
+		}
+		if logs.Len() != 0 || !ds.self.lastPanic.IsZero() {
+			t.Errorf("aborted handler logged %q or counted as a panic", logs.String())
+		}
+	}()
+	ds.withRecovery(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) })(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestServerKeepsServingAfterPanic(t *testing.T) {
+	ds := newTestServer(t, nil)
+	ds.logger = discardLogger
+	mux := http.NewServeMux()
+	mux.HandleFunc("/boom", func(http.ResponseWriter, *http.Request) { panic("boom") })
+	mux.Handle("/", ds.routes())
+	// The chain Start builds around the router
+	server := httptest.NewServer(ds.withRequestID(ds.withRecovery(mux.ServeHTTP)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/boom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response ErrorResponse
+	json.NewDecoder(resp.Body).Decode(&response)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || response.Error.Code != errCodeInternal || resp.Header.Get("X-Request-ID") == "" {
+		t.Errorf("panicking request = %d %+v, request ID %q", resp.StatusCode, response, resp.Header.Get("X-Request-ID"))
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/hosts")
+	if err != nil {
+		t.Fatalf("request after the panic: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("request after the panic = %d, want 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+// requestIDHeader carries the ID correlating a request with its log lines
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds a client-supplied request ID
+const maxRequestIDLength = 128
+
+// loggerContextKey is the context key of the request-scoped logger
+type loggerContextKey struct{}
+
+// withRequestID takes the request ID from the X-Request-ID header, or
+// generates one, echoes it in the response, and gives the handler a logger
+// that includes it
+func (ds *S01Server) withRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		logger := ds.logger.With("request_id", requestID)
+		next(w, r.WithContext(context.WithValue(r.Context(), loggerContextKey{}, logger)))
+	}
+}
+
+// requestLogger returns the request-scoped logger, or the server logger for
+// requests that did not pass through withRequestID
+func (ds *S01Server) requestLogger(r *http.Request) *slog.Logger {
+	if logger, ok := r.Context().Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return ds.logger
+}
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether a client-supplied ID is safe to log and echo:
+// non-empty, bounded, and limited to printable ASCII without spaces
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
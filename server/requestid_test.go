@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"3f2a9c", true},
+		{"req-42_retry.1", true},
+		{"", false},
+		{"has space", false},
+		{"tab\there", false},
+		{"line\nbreak", false},
+		{"ünïcode", false},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestRequestIDRoundTripsIntoLogs(t *testing.T) {
+	ds := newTestServer(t, nil)
+	var logs strings.Builder
+	ds.logger = slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	generated := regexp.MustCompile(`^[0-9a-f]{32}$`)
+	handler := ds.withRequestID(ds.routes().ServeHTTP)
+
+	tests := []struct {
+		name   string
+		sent   string
+		target string
+		body   string
+	}{
+		{"client ID kept", "client-req-7", "/api/v1/report", `{"service_name": "web", "instance_name": "w1", "status": "healthy"}`},
+		{"unsafe ID replaced", "bad id\r\nX-Injected: 1", "/api/v1/report", `{"service_name": `},
+		{"none sent", "", "/api/v1/hosts", ""},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		method := http.MethodPost
+		if tt.body == "" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, tt.target, strings.NewReader(tt.body))
+		if tt.sent != "" {
+			req.Header.Set(requestIDHeader, tt.sent)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+
+		echoed := recorder.Header().Get(requestIDHeader)
+		if validRequestID(tt.sent) && echoed != tt.sent || !validRequestID(tt.sent) && !generated.MatchString(echoed) {
+			t.Errorf("%s: echoed %q for %q sent", tt.name, echoed, tt.sent)
+			continue
+		}
+
+		// Every line the request logged carries the echoed ID
+		lines := 0
+		scanner := bufio.NewScanner(strings.NewReader(logs.String()))
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			if line["request_id"] != echoed {
+				t.Errorf("%s: log line %s lacks request_id %s", tt.name, scanner.Text(), echoed)
+			}
+			lines++
+		}
+		if lines == 0 {
+			t.Errorf("%s: nothing logged", tt.name)
+		}
+	}
+}
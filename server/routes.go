@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+)
+
+// routes builds the mux serving the API, plus the probes when the health
+// server is disabled. Every route also matches with a
+// trailing slash; other methods on a route's path get 405, paths matching no
+// route get 404, both as ErrorResponse bodies.
+func (ds *S01Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	handle(mux, http.MethodGet, "/health", ds.health)
+	handle(mux, http.MethodPost, "/api/v1/report",
+		ds.withDraining(ds.withTracing("POST /api/v1/report", ds.reportStatus)))
+	handle(mux, http.MethodPost, "/api/v1/report/batch",
+		ds.withDraining(ds.withTracing("POST /api/v1/report/batch", ds.reportBatch)))
+	handle(mux, http.MethodGet, "/api/v1/hosts", withGzip(ds.getHosts))
+	handle(mux, http.MethodGet, "/api/v1/hosts/export", withGzip(ds.getHostsExport))
+	handle(mux, http.MethodGet, "/api/v1/stats", withGzip(ds.getStats))
+	handle(mux, http.MethodGet, "/api/v1/checks/summary", withGzip(ds.getCheckSummary))
+	handle(mux, http.MethodGet, "/api/v1/hosts/{service_name}/{instance_name}", withGzip(ds.getHostByName))
+	handle(mux, http.MethodGet, "/api/v1/hosts/{service_name}/{instance_name}/latest", ds.getLatestHost)
+	handle(mux, http.MethodGet, "/api/v1/services/{service_name}/instances", ds.getServiceInstances)
+	handle(mux, http.MethodGet, "/api/v1/audit", withGzip(ds.getAudit))
+	if !ds.healthServerEnabled() {
+		handle(mux, http.MethodGet, "/livez", ds.livez)
+		handle(mux, http.MethodGet, "/readyz", ds.readyz)
+	}
+	mux.HandleFunc("/", notFound)
+	return mux
+}
+
+// healthRoutes builds the mux for the health server, which requires no
+// client certificates
+func (ds *S01Server) healthRoutes() *http.ServeMux {
+	mux := http.NewServeMux()
+	handle(mux, http.MethodGet, "/health", ds.health)
+	handle(mux, http.MethodGet, "/livez", ds.livez)
+	handle(mux, http.MethodGet, "/readyz", ds.readyz)
+	mux.HandleFunc("/", notFound)
+	return mux
+}
+
+// handle registers handler for method on path, with and without a trailing
+// slash. The same paths without a method fall back to methodNotAllowed, as
+// ServeMux prefers the more specific pattern that names one.
+func handle(mux *http.ServeMux, method, path string, handler http.HandlerFunc) {
+	for _, pattern := range []string{path, path + "/{$}"} {
+		mux.HandleFunc(method+" "+pattern, handler)
+		mux.HandleFunc(pattern, methodNotAllowed(method))
+	}
+}
+
+// methodNotAllowed answers requests with a method other than the allowed one:
+// OPTIONS with 204 No Content and anything else with 405 Method Not Allowed,
+// both listing the accepted methods in the Allow header
+func methodNotAllowed(method string) http.HandlerFunc {
+	allow := method + ", " + http.MethodOptions
+	if method == http.MethodGet {
+		// ServeMux serves HEAD from GET patterns
+		allow = method + ", " + http.MethodHead + ", " + http.MethodOptions
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// notFound answers requests matching no route
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeJSONError(w, http.StatusNotFound, errCodeNotFound, "Not found")
+}
+
+// withDraining turns new reports away while the server shuts down. In-flight
+// ones finish, and the connection is closed so clients retry elsewhere
+// instead of reusing it.
+func (ds *S01Server) withDraining(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ds.draining.Load() {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "5")
+			writeJSONError(w, http.StatusServiceUnavailable, errCodeUnavailable, "Server is shutting down")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDrainingTurnsAwayNewReports(t *testing.T) {
+	ds := newTestServer(t, nil)
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+	ds.draining.Store(true)
+
+	tests := []struct {
+		method, target, body string
+		want                 int
+	}{
+		{http.MethodPost, "/api/v1/report", `{"service_name":"web","instance_name":"w2","status":"healthy"}`, http.StatusServiceUnavailable},
+		{http.MethodPost, "/api/v1/report/batch", `[{"service_name":"web","instance_name":"w2","status":"healthy"}]`, http.StatusServiceUnavailable},
+		// Reads keep working until the listener closes
+		{http.MethodGet, "/api/v1/hosts", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/hosts/web/w1", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		ds.routes().ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+		if recorder.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, recorder.Code, tt.want)
+			continue
+		}
+		if tt.want == http.StatusServiceUnavailable {
+			if recorder.Header().Get("Connection") != "close" || recorder.Header().Get("Retry-After") == "" {
+				t.Errorf("%s %s headers = %v, want Connection: close and a Retry-After", tt.method, tt.target, recorder.Header())
+			}
+		}
+	}
+	if _, found, _ := ds.storage.GetHostSnapshot("web", "w2"); found {
+		t.Error("report accepted while draining")
+	}
+}
+
+func TestDrainingLetsInFlightReportsFinish(t *testing.T) {
+	ds := newTestServer(t, nil)
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := ds.withDraining(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	done := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/report", nil))
+		done <- recorder.Code
+	}()
+	<-entered
+	ds.draining.Store(true)
+	close(release)
+
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("in-flight report = %d, want 200 despite draining starting mid-request", code)
+	}
+}
+
+func TestWrongMethodListsAllowed(t *testing.T) {
+	ds := newTestServer(t, nil)
+	tests := []struct {
+		method, target string
+		wantCode       int
+		wantAllow      string
+	}{
+		{http.MethodDelete, "/api/v1/hosts", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodPut, "/api/v1/hosts/web/w1/", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodGet, "/api/v1/report", http.StatusMethodNotAllowed, "POST, OPTIONS"},
+		{http.MethodOptions, "/api/v1/report/batch", http.StatusNoContent, "POST, OPTIONS"},
+		{http.MethodOptions, "/api/v1/services/web/instances", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{http.MethodOptions, "/health", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		// Allowed methods and unknown paths carry no Allow
+		{http.MethodGet, "/api/v1/stats", http.StatusOK, ""},
+		{http.MethodOptions, "/api/v1/nowhere", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		recorder := serve(ds, tt.method, tt.target)
+		if recorder.Code != tt.wantCode || recorder.Header().Get("Allow") != tt.wantAllow {
+			t.Errorf("%s %s = %d with Allow %q, want %d with %q", tt.method, tt.target, recorder.Code, recorder.Header().Get("Allow"), tt.wantCode, tt.wantAllow)
+		}
+		if tt.wantCode == http.StatusNoContent && recorder.Body.Len() != 0 {
+			t.Errorf("%s %s sent a %d byte body with its 204", tt.method, tt.target, recorder.Body.Len())
+		}
+	}
+}
+
+func TestRoutesServeEachEndpoint(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) { config.EnableHealthServer = false })
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w2", Status: "degraded"})
+	ds.ready.Store(true)
+	report := `{"service_name":"api","instance_name":"a1","status":"healthy"}`
+
+	// marker is only in the response of the handler the route belongs to
+	tests := []struct {
+		method, path, body string
+		marker             string
+	}{
+		{http.MethodGet, "/health", "", `"uptime`},
+		{http.MethodGet, "/livez", "", `{"status":"ok"}`},
+		{http.MethodGet, "/readyz", "", `{"status":"ok"}`},
+		{http.MethodPost, "/api/v1/report", report, `{"status":"ok"}`},
+		{http.MethodPost, "/api/v1/report/batch", "[" + report + "]", `"accepted":1`},
+		{http.MethodGet, "/api/v1/hosts", "", `"total":`},
+		{http.MethodGet, "/api/v1/hosts/export", "", `"hosts":`},
+		{http.MethodGet, "/api/v1/stats", "", `"total_hosts":`},
+		{http.MethodGet, "/api/v1/checks/summary", "", `"hosts_with_checks":`},
+		{http.MethodGet, "/api/v1/hosts/web/w2", "", `"statuses":`},
+		{http.MethodGet, "/api/v1/hosts/web/w2/latest", "", `"instance_name":"w2","status":"degraded","ip_address"`},
+		{http.MethodGet, "/api/v1/services/web/instances", "", `"instance_name":"w1"`},
+		{http.MethodGet, "/api/v1/audit", "", `"events":`},
+	}
+	for _, tt := range tests {
+		// The trailing slash used to 404 on every route
+		for _, target := range []string{tt.path, tt.path + "/"} {
+			recorder := httptest.NewRecorder()
+			ds.routes().ServeHTTP(recorder, httptest.NewRequest(tt.method, target, strings.NewReader(tt.body)))
+			body := strings.ReplaceAll(recorder.Body.String(), " ", "")
+			if recorder.Code != http.StatusOK || !strings.Contains(body, tt.marker) {
+				t.Errorf("%s %s = %d %s, want 200 with %s", tt.method, target, recorder.Code, body, tt.marker)
+			}
+		}
+	}
+
+	// The probes and the report answer alike, so check they did their own work
+	if _, found, _ := ds.storage.GetHostSnapshot("api", "a1"); !found {
+		t.Error("report route did not store the report")
+	}
+	ds.ready.Store(false)
+	if live, ready := serve(ds, http.MethodGet, "/livez").Code, serve(ds, http.MethodGet, "/readyz").Code; live != http.StatusOK || ready != http.StatusServiceUnavailable {
+		t.Errorf("before Start: /livez = %d, /readyz = %d, want 200 and 503", live, ready)
+	}
+
+	// Path values and query strings reach the handler intact
+	detail := serve(ds, http.MethodGet, "/api/v1/hosts/web/w1/?status=healthy")
+	if !strings.Contains(detail.Body.String(), `"instance_name":"w1"`) {
+		t.Errorf("host detail with a trailing slash and query = %d %s, want w1", detail.Code, detail.Body)
+	}
+	if listing := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts/?status=degraded")); len(listing.Hosts) != 1 || listing.Hosts[0].InstanceName != "w2" {
+		t.Errorf("/api/v1/hosts/?status=degraded = %+v, want w2", listing.Hosts)
+	}
+	for _, target := range []string{"/api/v1/hosts/web", "/api/v1/hosts/web/w1/latest/extra", "/api/v1/services/web", "/api/v1/reports"} {
+		if code := serve(ds, http.MethodGet, target).Code; code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", target, code)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// recentPanicWindow is how long a recovered panic keeps /health failing
+const recentPanicWindow = 5 * time.Minute
+
+// selfHealth tracks failures of the server itself that /health reports, as
+// opposed to the health of the hosts it monitors. The zero value is healthy.
+type selfHealth struct {
+	mutex        sync.Mutex
+	storageError error     // last failure to store a report; nil once a store succeeds
+	storageSince time.Time // when storing started failing
+	lastPanic    time.Time // when a handler last panicked
+}
+
+// observeStorage records the outcome of storing a report. Rejections caused
+// by the report itself, such as an out-of-order sequence, say nothing about
+// the backend and are ignored.
+func (sh *selfHealth) observeStorage(err error, now time.Time) {
+	if errors.Is(err, errStaleSequence) || errors.Is(err, errTooManyHosts) || errors.Is(err, errNoPriorStatus) {
+		return
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if err == nil {
+		sh.storageError = nil
+		return
+	}
+	if sh.storageError == nil {
+		sh.storageSince = now
+	}
+	sh.storageError = err
+}
+
+// recordPanic notes that a handler panicked at now
+func (sh *selfHealth) recordPanic(now time.Time) {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	sh.lastPanic = now
+}
+
+// problems lists what makes the server unhealthy at now: storage that
+// failed the last write or countErr, more goroutines than maxGoroutines (0
+// disables the ceiling) and a panic within recentPanicWindow. It is empty
+// when the server is healthy.
+func (sh *selfHealth) problems(now time.Time, countErr error, maxGoroutines int) []string {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	var problems []string
+	if sh.storageError != nil {
+		problems = append(problems, fmt.Sprintf("storage writes failing since %s: %v", sh.storageSince.UTC().Format(time.RFC3339), sh.storageError))
+	}
+	if countErr != nil {
+		problems = append(problems, fmt.Sprintf("storage unreadable: %v", countErr))
+	}
+	if goroutines := runtime.NumGoroutine(); maxGoroutines > 0 && goroutines > maxGoroutines {
+		problems = append(problems, fmt.Sprintf("%d goroutines exceed the limit of %d", goroutines, maxGoroutines))
+	}
+	if !sh.lastPanic.IsZero() && now.Sub(sh.lastPanic) < recentPanicWindow {
+		problems = append(problems, fmt.Sprintf("handler panicked at %s", sh.lastPanic.UTC().Format(time.RFC3339)))
+	}
+	return problems
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelfHealthProblems(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	diskFull := errors.New("disk full")
+	var sh selfHealth
+
+	steps := []struct {
+		name string
+		at   time.Duration
+		do   func(now time.Time)
+		want []string // substrings, one per expected problem
+	}{
+		{"fresh", 0, func(time.Time) {}, nil},
+		{"rejected reports are not failures", time.Second, func(now time.Time) {
+			sh.observeStorage(errStaleSequence, now)
+			sh.observeStorage(errTooManyHosts, now)
+			sh.observeStorage(errNoPriorStatus, now)
+		}, nil},
+		{"write fails", time.Minute, func(now time.Time) { sh.observeStorage(diskFull, now) },
+			[]string{"storage writes failing since 2026-03-01T12:01:00Z: disk full"}},
+		{"keeps failing", 2 * time.Minute, func(now time.Time) { sh.observeStorage(errors.New("disk still full"), now) },
+			[]string{"since 2026-03-01T12:01:00Z: disk still full"}},
+		{"write succeeds", 3 * time.Minute, func(now time.Time) { sh.observeStorage(nil, now) }, nil},
+		{"handler panics", 4 * time.Minute, func(now time.Time) { sh.recordPanic(now) },
+			[]string{"handler panicked at 2026-03-01T12:04:00Z"}},
+		{"panic still recent", 8 * time.Minute, func(time.Time) {}, []string{"handler panicked"}},
+		{"panic forgotten", 9 * time.Minute, func(time.Time) {}, nil},
+	}
+	for _, step := range steps {
+		now := start.Add(step.at)
+		step.do(now)
+		problems := sh.problems(now, nil, 0)
+		if len(problems) != len(step.want) {
+			t.Errorf("%s: problems %q, want %d", step.name, problems, len(step.want))
+			continue
+		}
+		for i, want := range step.want {
+			if !strings.Contains(problems[i], want) {
+				t.Errorf("%s: problem %q, want it to mention %q", step.name, problems[i], want)
+			}
+		}
+	}
+
+	var fresh selfHealth
+	if problems := fresh.problems(start, errors.New("database is locked"), 0); len(problems) != 1 || !strings.Contains(problems[0], "storage unreadable: database is locked") {
+		t.Errorf("unreadable storage: %q", problems)
+	}
+	if problems := fresh.problems(start, nil, 1); len(problems) != 1 || !strings.Contains(problems[0], "exceed the limit of 1") {
+		t.Errorf("goroutine ceiling of 1: %q", problems)
+	}
+	if problems := fresh.problems(start, nil, 1_000_000); len(problems) != 0 {
+		t.Errorf("generous goroutine ceiling: %q", problems)
+	}
+}
+
+// brokenStorage fails writes or counts on demand
+type brokenStorage struct {
+	Storage
+	writeErr, countErr error
+}
+
+func (bs *brokenStorage) AddStatus(status HostStatus) (StatusChange, error) {
+	if bs.writeErr != nil {
+		return StatusChange{}, bs.writeErr
+	}
+	return bs.Storage.AddStatus(status)
+}
+
+func (bs *brokenStorage) Count() (int, error) {
+	if bs.countErr != nil {
+		return 0, bs.countErr
+	}
+	return bs.Storage.Count()
+}
+
+func TestHealthAnswers503WhenServerUnhealthy(t *testing.T) {
+	ds := newTestServer(t, nil)
+	storage := &brokenStorage{Storage: ds.storage}
+	ds.storage = storage
+
+	health := func() (int, map[string]interface{}) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		ds.healthRoutes().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var body map[string]interface{}
+		if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return recorder.Code, body
+	}
+	report := `{"service_name":"web","instance_name":"w1","status":"healthy"}`
+
+	steps := []struct {
+		name       string
+		do         func()
+		wantCode   int
+		wantStatus string
+		wantIssue  string
+	}{
+		{"healthy", func() { post(ds, "/api/v1/report", report) }, http.StatusOK, "ok", ""},
+		{"storage rejects writes", func() {
+			storage.writeErr = errors.New("no space left on device")
+			if code := post(ds, "/api/v1/report", report).Code; code != http.StatusInternalServerError {
+				t.Errorf("report to broken storage = %d, want 500", code)
+			}
+		}, http.StatusServiceUnavailable, "unhealthy", "no space left on device"},
+		{"storage recovers", func() {
+			storage.writeErr = nil
+			post(ds, "/api/v1/report", report)
+		}, http.StatusOK, "ok", ""},
+		{"storage unreadable", func() { storage.countErr = errors.New("database is locked") }, http.StatusServiceUnavailable, "unhealthy", "storage unreadable"},
+		{"handler panicked", func() {
+			storage.countErr = nil
+			ds.withRecovery(func(http.ResponseWriter, *http.Request) { panic("boom") })(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+		}, http.StatusServiceUnavailable, "unhealthy", "handler panicked"},
+		{"too many goroutines", func() {
+			ds.self.lastPanic = time.Time{}
+			ds.config.MaxGoroutines = 1
+		}, http.StatusServiceUnavailable, "unhealthy", "goroutines exceed the limit of 1"},
+	}
+	for _, step := range steps {
+		step.do()
+		code, body := health()
+		if code != step.wantCode || body["status"] != step.wantStatus {
+			t.Errorf("%s: /health = %d %v, want %d %s", step.name, code, body["status"], step.wantCode, step.wantStatus)
+		}
+		problems, _ := json.Marshal(body["problems"])
+		if step.wantIssue == "" && body["problems"] != nil || !strings.Contains(string(problems), step.wantIssue) {
+			t.Errorf("%s: problems %s, want %q", step.name, problems, step.wantIssue)
+		}
+		// The probe's usual fields are there either way
+		if _, ok := body["total_hosts"]; !ok {
+			t.Errorf("%s: /health body lacks total_hosts: %v", step.name, body)
+		}
+	}
+}
+
+func TestLoadConfigMaxGoroutines(t *testing.T) {
+	t.Setenv("ENABLE_TLS", "false")
+	if config, err := loadConfig(); err != nil || config.MaxGoroutines != 10000 {
+		t.Errorf("default MAX_GOROUTINES = %v, %v; want 10000", config, err)
+	}
+	t.Setenv("MAX_GOROUTINES", "-5")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "max_goroutines") {
+		t.Errorf("MAX_GOROUTINES=-5: err = %v", err)
+	}
+}
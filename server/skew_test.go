@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockSkewSeconds(t *testing.T) {
+	received := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		clientTime := received.Add(d)
+		return &clientTime
+	}
+	tests := []struct {
+		name       string
+		clientTime *time.Time
+		want       *float64
+	}{
+		{"no client timestamp", nil, nil},
+		{"in sync", at(0), skewOf(0.0)},
+		{"client behind", at(-2 * time.Hour), skewOf(7200.0)},
+		{"client ahead", at(90 * time.Second), skewOf(-90.0)},
+		{"rounded to milliseconds", at(-1234567 * time.Microsecond), skewOf(1.235)},
+	}
+	for _, tt := range tests {
+		got := clockSkewSeconds(HostStatus{Timestamp: received, ClientTime: tt.clientTime})
+		if (got == nil) != (tt.want == nil) || got != nil && math.Abs(*got-*tt.want) > 1e-9 {
+			t.Errorf("%s: skew = %v, want %v", tt.name, formatSkew(got), formatSkew(tt.want))
+		}
+	}
+}
+
+func skewOf(f float64) *float64 { return &f }
+
+// formatSkew formats a skew for messages, nil included
+func formatSkew(f *float64) interface{} {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+func TestReportedClockSkew(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.ClockSkewWarn = 30
+		config.MaxReportAge = 0
+	})
+	tests := []struct {
+		instance string
+		offset   time.Duration // of the client clock from the server's
+		wantWarn bool
+	}{
+		{"behind", -2 * time.Hour, true},
+		{"ahead", 10 * time.Minute, true},
+		{"close", -5 * time.Second, false},
+	}
+	for _, tt := range tests {
+		var logs strings.Builder
+		ds.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+		clientTime := time.Now().Add(tt.offset)
+		mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: tt.instance, Status: "healthy", Timestamp: &clientTime})
+
+		if warned := strings.Contains(logs.String(), "Client clock skew exceeds threshold"); warned != tt.wantWarn {
+			t.Errorf("%s: warned = %v, want %v; logs %s", tt.instance, warned, tt.wantWarn, logs.String())
+		}
+
+		recorder := serve(ds, http.MethodGet, "/api/v1/hosts/web/"+tt.instance+"/latest")
+		var host HostResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&host); err != nil || host.ClockSkew == nil {
+			t.Fatalf("%s: host = %+v, %v; want clock_skew_seconds", tt.instance, host, err)
+		}
+		// The skew includes the moments between taking and storing the report
+		if want := -tt.offset.Seconds(); *host.ClockSkew < want || *host.ClockSkew > want+5 {
+			t.Errorf("%s: clock_skew_seconds = %v, want about %v", tt.instance, *host.ClockSkew, want)
+		}
+	}
+
+	// Clients that send no timestamp have no measurable skew
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "untimed", Status: "healthy"})
+	recorder := serve(ds, http.MethodGet, "/api/v1/hosts/web/untimed/latest")
+	if strings.Contains(recorder.Body.String(), "clock_skew_seconds") {
+		t.Errorf("host without a client timestamp reports skew: %s", recorder.Body)
+	}
+}
+
+func TestClockSkewWarnDisabled(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.ClockSkewWarn = 0
+		config.MaxReportAge = 0
+	})
+	var logs strings.Builder
+	ds.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	clientTime := time.Now().Add(-24 * time.Hour)
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: &clientTime})
+	if strings.Contains(logs.String(), "clock skew") {
+		t.Errorf("skew logged with CLOCK_SKEW_WARN=0: %s", logs.String())
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Status smoothing modes
+const (
+	smoothingOff      = "off"      // current status is the latest report's status
+	smoothingMajority = "majority" // most common status among the last SmoothingWindow reports
+	smoothingEWMA     = "ewma"     // status from an exponentially weighted moving average of OverallScore
+)
+
+// statusSeverity orders reportable statuses from best to worst
+var statusSeverity = map[string]int{
+	"healthy":   0,
+	"degraded":  1,
+	"unhealthy": 2,
+}
+
+// statusDeriver computes a host's current status from its non-empty history,
+// oldest first
+type statusDeriver func(statuses []HostStatus) string
+
+// newStatusDeriver returns the deriver for config's smoothing mode
+func newStatusDeriver(config *Config) (statusDeriver, error) {
+	switch config.StatusSmoothing {
+	case smoothingOff, "":
+		return latestStatus, nil
+	case smoothingMajority:
+		window := config.SmoothingWindow
+		if window < 1 {
+			return nil, fmt.Errorf("smoothing_window must be at least 1, got %d", window)
+		}
+		return func(statuses []HostStatus) string {
+			return majorityStatus(statuses, window)
+		}, nil
+	case smoothingEWMA:
+		alpha := config.SmoothingAlpha
+		if alpha <= 0 || alpha > 1 {
+			return nil, fmt.Errorf("smoothing_alpha must be greater than 0 and at most 1, got %g", alpha)
+		}
+		healthyMin, degradedMin := config.SmoothingHealthyScore, config.SmoothingDegradedScore
+		if degradedMin > healthyMin {
+			return nil, fmt.Errorf("smoothing_degraded_score (%d) must not exceed smoothing_healthy_score (%d)", degradedMin, healthyMin)
+		}
+		global := ScoreThresholds{HealthyScore: healthyMin, DegradedScore: degradedMin}
+		return func(statuses []HostStatus) string {
+			thresholds, ok := config.ServiceThresholds[statuses[0].ServiceName]
+			if !ok {
+				thresholds = global
+			}
+			return ewmaStatus(statuses, alpha, thresholds)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown status_smoothing %q (expected %s, %s or %s)",
+			config.StatusSmoothing, smoothingOff, smoothingMajority, smoothingEWMA)
+	}
+}
+
+// latestStatus returns the status of the most recent report
+func latestStatus(statuses []HostStatus) string {
+	return statuses[len(statuses)-1].Status
+}
+
+// majorityStatus returns the most common status among the last window
+// reports. A tie goes to the worse status, so a host flapping between
+// healthy and unhealthy is not shown as healthy.
+func majorityStatus(statuses []HostStatus, window int) string {
+	if len(statuses) > window {
+		statuses = statuses[len(statuses)-window:]
+	}
+
+	counts := make(map[string]int)
+	for _, status := range statuses {
+		counts[status.Status]++
+	}
+
+	best := latestStatus(statuses)
+	for status, count := range counts {
+		switch {
+		case count > counts[best]:
+			best = status
+		case count == counts[best] && statusSeverity[status] > statusSeverity[best]:
+			best = status
+		}
+	}
+	return best
+}
+
+// ewmaStatus maps an exponentially weighted moving average of the reported
+// OverallScore onto a status with thresholds like those the client applies to
+// a single score, so one bad interval only dents the average instead of
+// flipping the status. Reports without health metrics are skipped; when no
+// report has any, the latest status is used.
+func ewmaStatus(statuses []HostStatus, alpha float64, thresholds ScoreThresholds) string {
+	var score float64
+	scored := false
+	for _, status := range statuses {
+		if status.HealthMetrics == nil {
+			continue
+		}
+		value := float64(status.HealthMetrics.OverallScore)
+		if scored {
+			score = alpha*value + (1-alpha)*score
+		} else {
+			score, scored = value, true
+		}
+	}
+	if !scored {
+		return latestStatus(statuses)
+	}
+	return thresholds.status(score)
+}
+
+// statusStability summarizes a history, oldest first: stableSince is when
+// the current unbroken run of the latest reported status began, and flaps is
+// the number of status changes within the retained history. stableSince is
+// the zero time for an empty history.
+func statusStability(statuses []HostStatus) (stableSince time.Time, flaps int) {
+	if len(statuses) == 0 {
+		return time.Time{}, 0
+	}
+	stableSince = statuses[0].Timestamp
+	for i := 1; i < len(statuses); i++ {
+		if statuses[i].Status != statuses[i-1].Status {
+			flaps++
+			stableSince = statuses[i].Timestamp
+		}
+	}
+	return stableSince, flaps
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// reportHistory builds a host's statuses, oldest first
+func reportHistory(statuses ...string) []HostStatus {
+	out := make([]HostStatus, len(statuses))
+	for i, status := range statuses {
+		out[i] = HostStatus{ServiceName: "web", InstanceName: "w1", Status: status}
+	}
+	return out
+}
+
+func TestMajorityStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []HostStatus
+		window   int
+		want     string
+	}{
+		{"single report", reportHistory("degraded"), 5, "degraded"},
+		{"steady", reportHistory("healthy", "healthy", "healthy"), 5, "healthy"},
+		{"flapping tie goes to the worse status", reportHistory("healthy", "unhealthy", "healthy", "unhealthy"), 4, "unhealthy"},
+		{"flapping ending healthy", reportHistory("unhealthy", "healthy", "unhealthy", "healthy"), 4, "unhealthy"},
+		{"one bad report is outvoted", reportHistory("healthy", "healthy", "unhealthy", "healthy", "healthy"), 5, "healthy"},
+		{"window drops older reports", reportHistory("unhealthy", "unhealthy", "unhealthy", "healthy", "healthy"), 2, "healthy"},
+		{"window of one is the latest", reportHistory("unhealthy", "unhealthy", "healthy"), 1, "healthy"},
+		{"three-way tie", reportHistory("healthy", "degraded", "unhealthy"), 3, "unhealthy"},
+		{"degraded beats a healthy minority", reportHistory("degraded", "healthy", "degraded"), 3, "degraded"},
+	}
+	for _, tt := range tests {
+		if got := majorityStatus(tt.statuses, tt.window); got != tt.want {
+			t.Errorf("%s: majorityStatus = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewStatusDeriverMajority(t *testing.T) {
+	flapping := reportHistory("healthy", "unhealthy", "healthy", "unhealthy", "healthy")
+	tests := []struct {
+		mode    string
+		window  int
+		want    string
+		wantErr string
+	}{
+		{"", 0, "healthy", ""},
+		{smoothingOff, 0, "healthy", ""},
+		{smoothingMajority, 5, "healthy", ""},
+		{smoothingMajority, 4, "unhealthy", ""},
+		{smoothingMajority, 0, "", "smoothing_window"},
+		{"median", 5, "", "unknown status_smoothing"},
+	}
+	for _, tt := range tests {
+		derive, err := newStatusDeriver(&Config{StatusSmoothing: tt.mode, SmoothingWindow: tt.window})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q window %d: err = %v, want %q", tt.mode, tt.window, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q window %d: %v", tt.mode, tt.window, err)
+		}
+		if got := derive(flapping); got != tt.want {
+			t.Errorf("%q window %d: derived %s, want %s", tt.mode, tt.window, got, tt.want)
+		}
+	}
+}
+
+func TestSmoothedStatusKeepsRawHistory(t *testing.T) {
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+				config.StatusSmoothing = smoothingMajority
+				config.SmoothingWindow = 3
+			})
+
+			steps := []struct {
+				report, want string
+			}{
+				{"healthy", "healthy"},
+				{"unhealthy", "unhealthy"},
+				{"healthy", "healthy"},
+				{"unhealthy", "unhealthy"},
+				{"unhealthy", "unhealthy"},
+				{"healthy", "unhealthy"},
+				{"healthy", "healthy"},
+			}
+			for i, step := range steps {
+				mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: step.report})
+
+				hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"))
+				if len(hosts.Hosts) != 1 || hosts.Hosts[0].Status != step.want {
+					t.Fatalf("report %d (%s): listed %+v, want %s", i+1, step.report, hosts.Hosts, step.want)
+				}
+
+				var detail HostHistoryResponse
+				if err := json.NewDecoder(serve(ds, http.MethodGet, "/api/v1/hosts/web/w1").Body).Decode(&detail); err != nil {
+					t.Fatal(err)
+				}
+				latest := detail.Statuses[len(detail.Statuses)-1]
+				if detail.CurrentStatus != step.want || latest.Status != step.report {
+					t.Errorf("report %d: detail current %s latest %s, want %s and the raw %s",
+						i+1, detail.CurrentStatus, latest.Status, step.want, step.report)
+				}
+			}
+		})
+	}
+}
+
+// scoredHistory builds a host's statuses from overall scores, oldest first,
+// each reported with the status the client would give that score alone
+func scoredHistory(scores ...int) []HostStatus {
+	out := make([]HostStatus, len(scores))
+	for i, score := range scores {
+		status := "healthy"
+		if score < 60 {
+			status = "unhealthy"
+		} else if score < 80 {
+			status = "degraded"
+		}
+		out[i] = HostStatus{ServiceName: "web", InstanceName: "w1", Status: status, HealthMetrics: &HealthMetrics{OverallScore: score}}
+	}
+	return out
+}
+
+func TestEWMAStatus(t *testing.T) {
+	thresholds := ScoreThresholds{HealthyScore: 80, DegradedScore: 60}
+	tests := []struct {
+		name     string
+		statuses []HostStatus
+		alpha    float64
+		want     string
+	}{
+		{"single score", scoredHistory(70), 0.2, "degraded"},
+		{"spike dents the average", scoredHistory(100, 100, 100, 20), 0.2, "healthy"},       // 84
+		{"sustained drop", scoredHistory(100, 20, 20, 20), 0.2, "degraded"},                 // 60.96
+		{"long drop", scoredHistory(100, 20, 20, 20, 20), 0.2, "unhealthy"},                 // 52.8
+		{"recovery takes time", scoredHistory(20, 20, 100), 0.2, "unhealthy"},               // 36
+		{"alpha 1 is the latest score", scoredHistory(100, 100, 20), 1, "unhealthy"},        // 20
+		{"heavier alpha follows faster", scoredHistory(100, 100, 100, 20), 0.5, "degraded"}, // 60
+		{"no metrics falls back to the latest status", reportHistory("healthy", "degraded"), 0.2, "degraded"},
+	}
+	for _, tt := range tests {
+		if got := ewmaStatus(tt.statuses, tt.alpha, thresholds); got != tt.want {
+			t.Errorf("%s: ewmaStatus = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Reports without metrics, such as heartbeats, leave the average alone
+	mixed := append(scoredHistory(100, 100), reportHistory("unhealthy")...)
+	mixed = append(mixed, scoredHistory(100)...)
+	if got := ewmaStatus(mixed, 0.2, thresholds); got != "healthy" {
+		t.Errorf("unscored report in the middle: ewmaStatus = %s, want healthy", got)
+	}
+}
+
+func TestNewStatusDeriverEWMA(t *testing.T) {
+	spike := scoredHistory(100, 100, 100, 20)
+	tests := []struct {
+		alpha                  float64
+		healthyMin, degradeMin int
+		want                   string
+		wantErr                string
+	}{
+		{0.2, 80, 60, "healthy", ""},
+		{0.2, 90, 60, "degraded", ""},
+		{1, 80, 60, "unhealthy", ""},
+		{0, 80, 60, "", "smoothing_alpha"},
+		{1.5, 80, 60, "", "smoothing_alpha"},
+		{0.2, 60, 80, "", "smoothing_degraded_score"},
+	}
+	for _, tt := range tests {
+		derive, err := newStatusDeriver(&Config{StatusSmoothing: smoothingEWMA, SmoothingAlpha: tt.alpha,
+			SmoothingHealthyScore: tt.healthyMin, SmoothingDegradedScore: tt.degradeMin})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("alpha %g, scores %d/%d: err = %v, want %q", tt.alpha, tt.healthyMin, tt.degradeMin, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("alpha %g: %v", tt.alpha, err)
+		}
+		if got := derive(spike); got != tt.want {
+			t.Errorf("alpha %g, scores %d/%d: derived %s, want %s", tt.alpha, tt.healthyMin, tt.degradeMin, got, tt.want)
+		}
+	}
+}
+
+func TestEWMASmoothsSpikeThenRecover(t *testing.T) {
+	t.Setenv("STATUS_SMOOTHING", smoothingEWMA)
+	t.Setenv("SMOOTHING_ALPHA", "0.1")
+	ds := newTestServer(t, nil)
+	if ds.config.SmoothingAlpha != 0.1 {
+		t.Fatalf("SMOOTHING_ALPHA loaded as %g", ds.config.SmoothingAlpha)
+	}
+
+	// One interval of pegged CPU, then back to normal
+	for i, history := range scoredHistory(95, 95, 95, 15, 95, 95) {
+		mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: history.Status, HealthMetrics: history.HealthMetrics})
+		hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"))
+		if len(hosts.Hosts) != 1 || hosts.Hosts[0].Status != "healthy" {
+			t.Errorf("report %d (score %d): listed %+v, want healthy throughout", i+1, history.HealthMetrics.OverallScore, hosts.Hosts)
+		}
+	}
+
+	var detail HostHistoryResponse
+	if err := json.NewDecoder(serve(ds, http.MethodGet, "/api/v1/hosts/web/w1").Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	var raw []string
+	for _, status := range detail.Statuses {
+		raw = append(raw, status.Status)
+	}
+	if !slices.Contains(raw, "unhealthy") || raw[len(raw)-1] != "healthy" {
+		t.Errorf("history %v lost the raw unhealthy report", raw)
+	}
+}
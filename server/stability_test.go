@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatusStability(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sequence := func(statuses ...string) []HostStatus {
+		var history []HostStatus
+		for i, status := range statuses {
+			history = append(history, HostStatus{Status: status, Timestamp: base.Add(time.Duration(i) * time.Minute)})
+		}
+		return history
+	}
+	tests := []struct {
+		name      string
+		statuses  []HostStatus
+		wantSince time.Time
+		wantFlaps int
+	}{
+		{"no history", nil, time.Time{}, 0},
+		{"single report", sequence("healthy"), base, 0},
+		{"steady", sequence("healthy", "healthy", "healthy"), base, 0},
+		{"recovered", sequence("healthy", "healthy", "degraded", "degraded", "healthy"), base.Add(4 * time.Minute), 2},
+		{"flapping", sequence("healthy", "unhealthy", "healthy", "unhealthy", "healthy", "unhealthy"), base.Add(5 * time.Minute), 5},
+		{"settled after lost", sequence("lost", "healthy", "healthy"), base.Add(time.Minute), 1},
+	}
+	for _, tt := range tests {
+		since, flaps := statusStability(tt.statuses)
+		if !since.Equal(tt.wantSince) || flaps != tt.wantFlaps {
+			t.Errorf("%s: stable since %v with %d flaps, want %v with %d", tt.name, since, flaps, tt.wantSince, tt.wantFlaps)
+		}
+	}
+}
+
+func TestHostStabilityEndpoints(t *testing.T) {
+	base := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StorageBackend = backend
+				config.StoragePath = filepath.Join(t.TempDir(), "s01.db")
+				config.MaxHistory = 4
+			})
+			// The first two changes fall out of the four retained statuses
+			for i, status := range []string{"healthy", "unhealthy", "healthy", "degraded", "degraded", "healthy"} {
+				ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: status, Timestamp: base.Add(time.Duration(i) * time.Minute)})
+			}
+			wantSince := base.Add(5 * time.Minute)
+
+			recorder := serve(ds, http.MethodGet, "/api/v1/hosts/web/w1")
+			var detail HostHistoryResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&detail); err != nil || recorder.Code != http.StatusOK {
+				t.Fatalf("host detail = %d, %v", recorder.Code, err)
+			}
+			if detail.StableSince == nil || !detail.StableSince.Equal(wantSince) || detail.FlapCount != 2 {
+				t.Errorf("detail stable since %v with %d flaps, want %v with 2", detail.StableSince, detail.FlapCount, wantSince)
+			}
+
+			hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"))
+			if len(hosts.Hosts) != 1 {
+				t.Fatalf("listing = %+v, want w1", hosts.Hosts)
+			}
+			if host := hosts.Hosts[0]; host.StableSince == nil || !host.StableSince.Equal(wantSince) || host.FlapCount != 2 {
+				t.Errorf("listing stable since %v with %d flaps, want %v with 2", host.StableSince, host.FlapCount, wantSince)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigStaleStatus(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "lost", false},
+		{"offline", "offline", false},
+		{" Offline ", "offline", false},
+		{"healthy", "", true},
+		{"DEGRADED", "", true},
+		{"pending", "", true},
+		{"   ", "", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("ENABLE_TLS", "false")
+		t.Setenv("STALE_STATUS", tt.value)
+		config, err := loadConfig()
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "STALE_STATUS") {
+				t.Errorf("STALE_STATUS=%q: err = %v, want it refused", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("STALE_STATUS=%q: %v", tt.value, err)
+		} else if config.StaleStatus != tt.want {
+			t.Errorf("STALE_STATUS=%q loaded as %q, want %q", tt.value, config.StaleStatus, tt.want)
+		}
+	}
+}
+
+func TestCustomStaleStatusThroughout(t *testing.T) {
+	url, events := webhookEvents(t)
+	ds := newTestServer(t, func(config *Config) {
+		config.StaleStatus = "offline"
+		config.WebhookURL = url
+		config.WebhookDebounce = 0
+	})
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w2", Status: "healthy"})
+	later := time.Now().Add(time.Duration(ds.config.StaleTimeout+60) * time.Second)
+	ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w2", Status: "healthy", Timestamp: later})
+	if lost := ds.sweepStaleHosts(later); lost != 1 {
+		t.Fatalf("%d hosts swept, want w1", lost)
+	}
+
+	hosts := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts"))
+	statuses := make(map[string]string)
+	for _, host := range hosts.Hosts {
+		statuses[host.InstanceName] = host.Status
+	}
+	if statuses["w1"] != "offline" || statuses["w2"] != "healthy" {
+		t.Errorf("listing statuses = %v, want w1 offline", statuses)
+	}
+	if filtered := decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts?status=offline")); len(filtered.Hosts) != 1 || filtered.Hosts[0].InstanceName != "w1" {
+		t.Errorf("?status=offline = %+v, want w1", filtered.Hosts)
+	}
+	if body := serve(ds, http.MethodGet, "/api/v1/hosts?status=lost").Body.String(); strings.Contains(body, `"w1"`) {
+		t.Errorf("?status=lost still matches the offline host: %s", body)
+	}
+
+	select {
+	case event := <-events:
+		if event.InstanceName != "w1" || event.NewStatus != "offline" {
+			t.Errorf("webhook event %+v, want w1 going offline", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("no webhook event for the host going offline")
+	}
+
+	// Clients cannot claim the stale status for themselves
+	if recorder := post(ds, "/api/v1/report", `{"service_name":"web","instance_name":"w3","status":"offline"}`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("report with status offline = %d, want 400", recorder.Code)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInStaleGrace(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		period    int
+		reports   int
+		firstSeen time.Duration // before now
+		sent      int
+		want      bool
+	}{
+		{"grace off", 0, 0, time.Minute, 1, false},
+		{"new host", 600, 0, time.Minute, 1, true},
+		{"grace just over", 600, 0, 10 * time.Minute, 1, false},
+		{"long established", 600, 0, 24 * time.Hour, 500, false},
+		{"new host, few reports", 600, 3, time.Minute, 2, true},
+		{"new host, enough reports", 600, 3, time.Minute, 3, false},
+		{"report count alone grants nothing", 0, 3, time.Minute, 1, false},
+	}
+	for _, tt := range tests {
+		ds := &S01Server{config: &Config{StaleGracePeriod: tt.period, StaleGraceReports: tt.reports}}
+		snapshot := HostSnapshot{FirstSeen: now.Add(-tt.firstSeen), Reports: tt.sent}
+		if got := ds.inStaleGrace(snapshot, now); got != tt.want {
+			t.Errorf("%s: in grace = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStaleGraceSparesFreshHosts(t *testing.T) {
+	for _, backend := range []string{storageMemory, storageSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "s01.db")
+			configure := func(config *Config) {
+				config.StorageBackend, config.StoragePath = backend, path
+				config.StaleTimeout = 30
+				config.StaleGracePeriod = 600
+			}
+			ds := newTestServer(t, configure)
+			now := time.Now()
+
+			// Established a day ago, silent for five minutes
+			for _, ago := range []time.Duration{24 * time.Hour, time.Hour, 5 * time.Minute} {
+				ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "old", Status: "healthy", Timestamp: now.Add(-ago)})
+			}
+			// Deployed two minutes ago, and its second report is late
+			ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "new", Status: "healthy", Timestamp: now.Add(-2 * time.Minute)})
+
+			if lost := ds.sweepStaleHosts(now); lost != 1 {
+				t.Errorf("first sweep marked %d hosts lost, want only the established one", lost)
+			}
+			statuses := make(map[string]string)
+			for _, host := range decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts")).Hosts {
+				statuses[host.InstanceName] = host.Status
+			}
+			if statuses["old"] != "lost" || statuses["new"] != "healthy" {
+				t.Errorf("listing = %v, want old lost and new spared", statuses)
+			}
+
+			snapshot, _, _ := ds.storage.GetHostSnapshot("web", "new")
+			if !snapshot.FirstSeen.Equal(now.Add(-2*time.Minute)) || snapshot.Reports != 1 {
+				t.Errorf("new host first seen %v with %d reports", snapshot.FirstSeen, snapshot.Reports)
+			}
+			if backend == storageSQLite {
+				// The grace is measured from the first status still stored after a restart
+				ds.storage.Close()
+				ds = newTestServer(t, configure)
+				if restored, _, _ := ds.storage.GetHostSnapshot("web", "new"); !restored.FirstSeen.Equal(snapshot.FirstSeen) || restored.Reports != 1 {
+					t.Errorf("after a restart first seen %v with %d reports, want %v with 1", restored.FirstSeen, restored.Reports, snapshot.FirstSeen)
+				}
+			}
+
+			// Once the grace runs out the silent newcomer is lost like any other
+			if lost := ds.sweepStaleHosts(now.Add(9 * time.Minute)); lost != 1 {
+				t.Errorf("sweep after the grace marked %d hosts lost, want the new one", lost)
+			}
+		})
+	}
+}
+
+func TestStaleGraceEndsAfterEnoughReports(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.StaleTimeout = 30
+		config.StaleGracePeriod = 600
+		config.StaleGraceReports = 3
+	})
+	now := time.Now()
+	report := func(instance string, ago time.Duration) HostStatus {
+		return HostStatus{ServiceName: "web", InstanceName: instance, Status: "healthy", Timestamp: now.Add(-ago)}
+	}
+	ds.storage.AddStatus(report("two", 3*time.Minute))
+	ds.storage.AddStatus(report("two", 2*time.Minute))
+	ds.storage.AddStatus(report("three", 3*time.Minute))
+	ds.storage.AddStatus(report("three", 2*time.Minute))
+	// An unchanged heartbeat counts as a report
+	if _, touched, err := ds.storage.Touch(report("three", 90*time.Second)); !touched || err != nil {
+		t.Fatalf("heartbeat = %v, %v", touched, err)
+	}
+
+	if lost := ds.sweepStaleHosts(now); lost != 1 {
+		t.Errorf("sweep marked %d hosts lost, want the host with three reports", lost)
+	}
+	if two, _, _ := ds.storage.GetHostSnapshot("web", "two"); two.CurrentStatus == "lost" {
+		t.Error("host with two reports marked lost within its grace")
+	}
+	if three, _, _ := ds.storage.GetHostSnapshot("web", "three"); three.CurrentStatus != "lost" || three.Reports != 3 {
+		t.Errorf("host with three reports: %s after %d reports, want lost", three.CurrentStatus, three.Reports)
+	}
+}
+
+func TestLoadConfigStaleGrace(t *testing.T) {
+	t.Setenv("ENABLE_TLS", "false")
+	t.Setenv("STALE_GRACE_PERIOD", "120")
+	t.Setenv("STALE_GRACE_REPORTS", "2")
+	config, err := loadConfig()
+	if err != nil || config.StaleGracePeriod != 120 || config.StaleGraceReports != 2 {
+		t.Errorf("grace loaded as %+v, %v", config, err)
+	}
+	for _, name := range []string{"STALE_GRACE_PERIOD", "STALE_GRACE_REPORTS"} {
+		t.Setenv(name, "-1")
+		if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+			t.Errorf("%s=-1: err = %v", name, err)
+		}
+		t.Setenv(name, "0")
+	}
+}
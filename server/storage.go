@@ -0,0 +1,757 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Storage holds the status history of every reporting host
+type Storage interface {
+	// AddStatus appends a status to its host's history and returns the
+	// host's current status before and after it. It returns errStaleSequence
+	// when the status has a sequence that is not newer than the host's last
+	// accepted one, and errTooManyHosts when it comes from a new host that
+	// the host limit turns away.
+	AddStatus(status HostStatus) (StatusChange, error)
+	// Touch refreshes a host's LastSeen to status.Timestamp when its latest
+	// stored status equals status.Status and returns the change of its
+	// current status; it returns false when the host is unknown or its
+	// status differs, and errStaleSequence like AddStatus
+	Touch(status HostStatus) (StatusChange, bool, error)
+	// UpdateMetrics replaces the health metrics, and recent errors when
+	// given, of a host's latest stored status and refreshes its LastSeen,
+	// keeping the status itself. It returns the change of the host's current
+	// status, false when the host has no stored status, and
+	// errStaleSequence like AddStatus.
+	UpdateMetrics(status HostStatus) (StatusChange, bool, error)
+	// MarkLost sets a host's current status to staleStatus if it has not
+	// been seen since staleBefore and returns the change; it returns false
+	// when the host is unknown, already stale, or was seen again
+	MarkLost(serviceName, instanceName string, staleBefore time.Time, staleStatus string) (StatusChange, bool, error)
+	// GetHosts returns the most recent state of every host
+	GetHosts() ([]HostSnapshot, error)
+	// GetHostSnapshot returns the most recent state of one host
+	GetHostSnapshot(serviceName, instanceName string) (HostSnapshot, bool, error)
+	// GetHost returns the full history of one host
+	GetHost(serviceName, instanceName string) (HostHistoryResponse, bool, error)
+	// Prune drops statuses recorded before cutoff and any host left without statuses
+	Prune(cutoff time.Time) (int, error)
+	// Count returns the number of known hosts
+	Count() (int, error)
+	// Close releases the backend's resources
+	Close() error
+}
+
+// StatusChange is a host's current status before and after a storage update,
+// read under the host's lock so concurrent updates cannot interleave their
+// pairs. Previous is empty when the update added the host.
+type StatusChange struct {
+	Previous string
+	Current  string
+}
+
+// HostSnapshot is a host's most recent state as held by storage
+type HostSnapshot struct {
+	ServiceName   string
+	InstanceName  string
+	LastSeen      time.Time
+	CurrentStatus string      // latest reported status, the stale status, or "pending" before the first report
+	Latest        *HostStatus // nil when the host has no statuses
+	StableSince   time.Time   // start of the latest reported status's unbroken run; zero without statuses
+	FlapCount     int         // status changes within the retained history
+	FirstSeen     time.Time   // when the host was first seen; zero without statuses
+	Reports       int         // statuses accepted from the host
+}
+
+// Storage backends
+const (
+	storageMemory = "memory"
+	storageSQLite = "sqlite"
+)
+
+// newStorage creates the storage backend selected by config. forget is
+// called with each host the storage drops, by eviction or by pruning, and
+// may be nil.
+func newStorage(config *Config, logger *slog.Logger, forget hostForgetter) (Storage, error) {
+	retention := time.Duration(config.HistoryRetention) * time.Second
+	deriveStatus, err := newStatusDeriver(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.StorageBackend {
+	case storageMemory, "":
+		storage, err := NewInMemoryStorage(config.MaxHistory, retention, deriveStatus, config.PersistPath, logger)
+		if err != nil {
+			return nil, err
+		}
+		storage.limitHosts(config.MaxHosts, config.HostEviction, config.StaleStatus)
+		storage.onForget(forget)
+		return storage, nil
+	case storageSQLite:
+		if config.PersistPath != "" {
+			logger.Warn("PERSIST_PATH is ignored with the sqlite storage backend")
+		}
+		storage, err := NewSQLiteStorage(config.StoragePath, config.MaxHistory, retention, deriveStatus, logger)
+		if err != nil {
+			return nil, err
+		}
+		storage.limitHosts(config.MaxHosts, config.HostEviction, config.StaleStatus)
+		storage.onForget(forget)
+		return storage, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (expected %s or %s)", config.StorageBackend, storageMemory, storageSQLite)
+	}
+}
+
+// errStaleSequence rejects a report older than, or a replay of, one already accepted
+var errStaleSequence = errors.New("report sequence is not newer than the last accepted report")
+
+// hostKey builds the storage key for a host
+func hostKey(serviceName, instanceName string) string {
+	return fmt.Sprintf("%s:%s", serviceName, instanceName)
+}
+
+// InMemoryStorage keeps host history in a map, optionally backed by an
+// append-only JSON-lines file so history survives restarts
+type InMemoryStorage struct {
+	hosts        map[string]*HostHistory // key: service_name:instance_name
+	maxHistory   int
+	retention    time.Duration // statuses older than this are dropped on append; 0 keeps all
+	deriveStatus statusDeriver // sets CurrentStatus from the history after each report
+	mutex        sync.RWMutex
+	logger       *slog.Logger
+	persistLog   *os.File      // append-only status log, nil when persistence is off
+	maxHosts     int           // cap on len(hosts); 0 disables, see limitHosts
+	eviction     string        // host eviction policy applied past maxHosts
+	lostStatus   string        // current status of hosts the lost policy evicts
+	forget       hostForgetter // told of hosts dropped from hosts; may be nil
+}
+
+// NewInMemoryStorage creates an in-memory store. When persistPath is set,
+// history is restored from that file and every new status is appended to it.
+// A nil deriveStatus makes the latest report's status the current status.
+func NewInMemoryStorage(maxHistory int, retention time.Duration, deriveStatus statusDeriver, persistPath string, logger *slog.Logger) (*InMemoryStorage, error) {
+	if deriveStatus == nil {
+		deriveStatus = latestStatus
+	}
+	s := &InMemoryStorage{
+		hosts:        make(map[string]*HostHistory),
+		maxHistory:   maxHistory,
+		retention:    retention,
+		deriveStatus: deriveStatus,
+		logger:       logger,
+	}
+
+	if persistPath != "" {
+		if err := s.openPersistence(persistPath); err != nil {
+			return nil, fmt.Errorf("failed to open persistence file: %v", err)
+		}
+	}
+
+	return s, nil
+}
+
+// AddStatus appends a status to its host's history
+func (s *InMemoryStorage) AddStatus(status HostStatus) (StatusChange, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	evicted, err := s.admitLocked(status)
+	if err != nil {
+		return StatusChange{}, err
+	}
+	return s.applyLocked(status, evicted), nil
+}
+
+// admitLocked checks that status may be added without changing anything. It
+// returns errStaleSequence or errTooManyHosts when it may not, and otherwise
+// the hosts to evict to make room for it. The caller must hold s.mutex.
+func (s *InMemoryStorage) admitLocked(status HostStatus) ([]*HostHistory, error) {
+	if s.staleSequenceLocked(status) {
+		return nil, errStaleSequence
+	}
+	return s.evictionsLocked(status)
+}
+
+// applyLocked evicts the hosts admitLocked chose and appends status, which
+// can no longer fail, returning the change of the host's current status;
+// the caller must hold s.mutex for writing
+func (s *InMemoryStorage) applyLocked(status HostStatus, evicted []*HostHistory) StatusChange {
+	s.evictLocked(evicted)
+	change := s.appendStatus(status)
+	s.persist(status, persistAppend)
+	return change
+}
+
+// staleSequenceLocked reports whether status is older than, or a replay of,
+// the host's last accepted report; the caller must hold s.mutex
+func (s *InMemoryStorage) staleSequenceLocked(status HostStatus) bool {
+	hostHistory, exists := s.hosts[hostKey(status.ServiceName, status.InstanceName)]
+	if !exists {
+		return false
+	}
+
+	hostHistory.mutex.RLock()
+	defer hostHistory.mutex.RUnlock()
+
+	return hostHistory.staleSequence(status.Sequence)
+}
+
+// staleSequence reports whether a sequence is not newer than the last
+// accepted one; 0 means the client does not number its reports. The caller
+// must hold h.mutex.
+func (h *HostHistory) staleSequence(sequence uint64) bool {
+	return sequence != 0 && sequence <= h.LastSequence
+}
+
+// appendStatus records a status in memory and returns the change of the
+// host's current status; the caller must hold s.mutex
+func (s *InMemoryStorage) appendStatus(status HostStatus) StatusChange {
+	key := hostKey(status.ServiceName, status.InstanceName)
+
+	hostHistory, exists := s.hosts[key]
+	if !exists {
+		hostHistory = &HostHistory{
+			ServiceName:  status.ServiceName,
+			InstanceName: status.InstanceName,
+			Statuses:     make([]HostStatus, 0, s.maxHistory),
+			FirstSeen:    status.Timestamp,
+		}
+		s.hosts[key] = hostHistory
+	}
+
+	hostHistory.mutex.Lock()
+	defer hostHistory.mutex.Unlock()
+
+	previous := hostHistory.CurrentStatus
+
+	// Add new status
+	hostHistory.Statuses = append(hostHistory.Statuses, status)
+	hostHistory.LastSeen = status.Timestamp
+	hostHistory.Reports++
+	if status.Sequence > hostHistory.LastSequence {
+		hostHistory.LastSequence = status.Sequence
+	}
+	// Trim by age first, always keeping the status just added
+	if s.retention > 0 {
+		cutoff := time.Now().Add(-s.retention)
+		expired := 0
+		for expired < len(hostHistory.Statuses)-1 && hostHistory.Statuses[expired].Timestamp.Before(cutoff) {
+			expired++
+		}
+		if expired > 0 {
+			hostHistory.Statuses = append(hostHistory.Statuses[:0], hostHistory.Statuses[expired:]...)
+		}
+	}
+
+	// Then enforce the count ceiling
+	if len(hostHistory.Statuses) > s.maxHistory {
+		copy(hostHistory.Statuses, hostHistory.Statuses[1:])
+		hostHistory.Statuses = hostHistory.Statuses[:s.maxHistory]
+	}
+
+	hostHistory.CurrentStatus = s.deriveStatus(hostHistory.Statuses)
+	return StatusChange{Previous: previous, Current: hostHistory.CurrentStatus}
+}
+
+// hostWriter durably records a change just applied to a host, which the
+// caller holds locked. An error makes the caller undo the change in memory.
+type hostWriter func(hostHistory *HostHistory) error
+
+// hostState is the part of a host that a heartbeat, metrics-only report or
+// stale mark changes, saved to undo a change that could not be written
+type hostState struct {
+	lastSeen      time.Time
+	lastSequence  uint64
+	reports       int
+	currentStatus string
+	latest        HostStatus
+}
+
+// saveState captures the host's state; the caller must hold h.mutex and h
+// must have a status
+func (h *HostHistory) saveState() hostState {
+	return hostState{
+		lastSeen:      h.LastSeen,
+		lastSequence:  h.LastSequence,
+		reports:       h.Reports,
+		currentStatus: h.CurrentStatus,
+		latest:        h.Statuses[len(h.Statuses)-1],
+	}
+}
+
+// restoreState undoes changes made since saveState; the caller must hold h.mutex
+func (h *HostHistory) restoreState(saved hostState) {
+	h.LastSeen = saved.lastSeen
+	h.LastSequence = saved.lastSequence
+	h.Reports = saved.reports
+	h.CurrentStatus = saved.currentStatus
+	h.Statuses[len(h.Statuses)-1] = saved.latest
+}
+
+// Touch refreshes LastSeen when the host's latest status is unchanged
+func (s *InMemoryStorage) Touch(status HostStatus) (StatusChange, bool, error) {
+	return s.touchHost(status, nil)
+}
+
+// touchHost is Touch, passing the touched host to write when it is not nil
+func (s *InMemoryStorage) touchHost(status HostStatus, write hostWriter) (StatusChange, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	hostHistory, exists := s.hosts[hostKey(status.ServiceName, status.InstanceName)]
+
+	if !exists {
+		return StatusChange{}, false, nil
+	}
+
+	hostHistory.mutex.Lock()
+	defer hostHistory.mutex.Unlock()
+
+	if hostHistory.staleSequence(status.Sequence) {
+		return StatusChange{}, false, errStaleSequence
+	}
+	n := len(hostHistory.Statuses)
+	if n == 0 || hostHistory.Statuses[n-1].Status != status.Status {
+		return StatusChange{}, false, nil
+	}
+	saved := hostHistory.saveState()
+	hostHistory.touch(status)
+	hostHistory.CurrentStatus = s.deriveStatus(hostHistory.Statuses)
+	if write != nil {
+		if err := write(hostHistory); err != nil {
+			hostHistory.restoreState(saved)
+			return StatusChange{}, false, err
+		}
+	}
+	s.persist(status, persistTouch)
+	return StatusChange{Previous: saved.currentStatus, Current: hostHistory.CurrentStatus}, true, nil
+}
+
+// touch records a report that left the latest status unchanged; the caller
+// must hold h.mutex
+func (h *HostHistory) touch(status HostStatus) {
+	h.LastSeen = status.Timestamp
+	h.Reports++
+	if status.Sequence > h.LastSequence {
+		h.LastSequence = status.Sequence
+	}
+}
+
+// UpdateMetrics swaps the metrics of the host's latest status in place, so
+// metrics pushed between status evaluations do not grow its history
+func (s *InMemoryStorage) UpdateMetrics(status HostStatus) (StatusChange, bool, error) {
+	return s.updateHostMetrics(status, nil)
+}
+
+// updateHostMetrics is UpdateMetrics, passing the updated host to write when
+// it is not nil
+func (s *InMemoryStorage) updateHostMetrics(status HostStatus, write hostWriter) (StatusChange, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	hostHistory, exists := s.hosts[hostKey(status.ServiceName, status.InstanceName)]
+
+	if !exists {
+		return StatusChange{}, false, nil
+	}
+
+	hostHistory.mutex.Lock()
+	defer hostHistory.mutex.Unlock()
+
+	if hostHistory.staleSequence(status.Sequence) {
+		return StatusChange{}, false, errStaleSequence
+	}
+	n := len(hostHistory.Statuses)
+	if n == 0 {
+		return StatusChange{}, false, nil
+	}
+	saved := hostHistory.saveState()
+	hostHistory.updateMetrics(status)
+	hostHistory.CurrentStatus = s.deriveStatus(hostHistory.Statuses)
+	if write != nil {
+		if err := write(hostHistory); err != nil {
+			hostHistory.restoreState(saved)
+			return StatusChange{}, false, err
+		}
+	}
+	s.persist(status, persistMetrics)
+	return StatusChange{Previous: saved.currentStatus, Current: hostHistory.CurrentStatus}, true, nil
+}
+
+// updateMetrics applies a metrics-only report to the latest status; the
+// caller must hold h.mutex and h must have a status
+func (h *HostHistory) updateMetrics(status HostStatus) {
+	latest := &h.Statuses[len(h.Statuses)-1]
+	latest.HealthMetrics = status.HealthMetrics
+	if status.RecentErrors != nil {
+		latest.RecentErrors = status.RecentErrors
+	}
+	h.touch(status)
+}
+
+// MarkLost flags a host that has not been seen since staleBefore with staleStatus
+func (s *InMemoryStorage) MarkLost(serviceName, instanceName string, staleBefore time.Time, staleStatus string) (StatusChange, bool, error) {
+	return s.markHostLost(serviceName, instanceName, staleBefore, staleStatus, nil)
+}
+
+// markHostLost is MarkLost, passing the marked host to write when it is not nil
+func (s *InMemoryStorage) markHostLost(serviceName, instanceName string, staleBefore time.Time, staleStatus string, write hostWriter) (StatusChange, bool, error) {
+	s.mutex.RLock()
+	hostHistory, exists := s.hosts[hostKey(serviceName, instanceName)]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return StatusChange{}, false, nil
+	}
+
+	hostHistory.mutex.Lock()
+	defer hostHistory.mutex.Unlock()
+
+	// A host that has never reported is pending, not lost
+	if len(hostHistory.Statuses) == 0 || hostHistory.CurrentStatus == staleStatus || !hostHistory.LastSeen.Before(staleBefore) {
+		return StatusChange{}, false, nil
+	}
+	saved := hostHistory.saveState()
+	hostHistory.CurrentStatus = staleStatus
+	if write != nil {
+		if err := write(hostHistory); err != nil {
+			hostHistory.restoreState(saved)
+			return StatusChange{}, false, err
+		}
+	}
+	return StatusChange{Previous: saved.currentStatus, Current: staleStatus}, true, nil
+}
+
+// GetHosts returns the most recent state of every host
+func (s *InMemoryStorage) GetHosts() ([]HostSnapshot, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	snapshots := make([]HostSnapshot, 0, len(s.hosts))
+	for _, hostHistory := range s.hosts {
+		snapshots = append(snapshots, hostHistory.snapshot())
+	}
+	return snapshots, nil
+}
+
+// GetHostSnapshot returns the most recent state of one host without copying
+// its history
+func (s *InMemoryStorage) GetHostSnapshot(serviceName, instanceName string) (HostSnapshot, bool, error) {
+	s.mutex.RLock()
+	hostHistory, exists := s.hosts[hostKey(serviceName, instanceName)]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return HostSnapshot{}, false, nil
+	}
+	return hostHistory.snapshot(), true, nil
+}
+
+// snapshot captures the host's most recent state
+func (h *HostHistory) snapshot() HostSnapshot {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	snapshot := HostSnapshot{
+		ServiceName:   h.ServiceName,
+		InstanceName:  h.InstanceName,
+		LastSeen:      h.LastSeen,
+		CurrentStatus: h.CurrentStatus,
+		FirstSeen:     h.FirstSeen,
+		Reports:       h.Reports,
+	}
+	if n := len(h.Statuses); n > 0 {
+		latest := h.Statuses[n-1]
+		snapshot.Latest = &latest
+		snapshot.StableSince, snapshot.FlapCount = statusStability(h.Statuses)
+	} else {
+		snapshot.CurrentStatus = "pending"
+		snapshot.LastSeen = time.Time{}
+	}
+	return snapshot
+}
+
+// GetHost returns a copy of one host's full history
+func (s *InMemoryStorage) GetHost(serviceName, instanceName string) (HostHistoryResponse, bool, error) {
+	s.mutex.RLock()
+	hostHistory, exists := s.hosts[hostKey(serviceName, instanceName)]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return HostHistoryResponse{}, false, nil
+	}
+
+	hostHistory.mutex.RLock()
+	defer hostHistory.mutex.RUnlock()
+
+	historyCopy := HostHistoryResponse{
+		ServiceName:   hostHistory.ServiceName,
+		InstanceName:  hostHistory.InstanceName,
+		LastSeen:      hostHistory.LastSeen,
+		CurrentStatus: hostHistory.CurrentStatus,
+		Statuses:      make([]HostStatus, len(hostHistory.Statuses)),
+	}
+	copy(historyCopy.Statuses, hostHistory.Statuses)
+	if len(historyCopy.Statuses) == 0 {
+		historyCopy.CurrentStatus = "pending"
+		historyCopy.LastSeen = time.Time{}
+	}
+	return historyCopy, true, nil
+}
+
+// Prune drops statuses recorded before cutoff and any host left without statuses
+func (s *InMemoryStorage) Prune(cutoff time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.pruneLocked(cutoff), nil
+}
+
+// pruneLocked is Prune for callers holding s.mutex for writing
+func (s *InMemoryStorage) pruneLocked(cutoff time.Time) int {
+	pruned := 0
+	for key, hostHistory := range s.hosts {
+		hostHistory.mutex.Lock()
+		keep := 0
+		for keep < len(hostHistory.Statuses) && hostHistory.Statuses[keep].Timestamp.Before(cutoff) {
+			keep++
+		}
+		if keep > 0 {
+			pruned += keep
+			hostHistory.Statuses = append(hostHistory.Statuses[:0], hostHistory.Statuses[keep:]...)
+		}
+		empty := len(hostHistory.Statuses) == 0
+		hostHistory.mutex.Unlock()
+
+		if empty {
+			delete(s.hosts, key)
+			s.forgetLocked(hostHistory.ServiceName, hostHistory.InstanceName)
+		}
+	}
+	return pruned
+}
+
+// hostForgetter is told of a host the storage no longer holds
+type hostForgetter func(serviceName, instanceName string)
+
+// onForget sets the function told of each host dropped by eviction or
+// pruning, so state kept elsewhere per host can go with it
+func (s *InMemoryStorage) onForget(forget hostForgetter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.forget = forget
+}
+
+// forgetLocked passes a dropped host to the forget function, if any; the
+// caller must hold s.mutex for writing
+func (s *InMemoryStorage) forgetLocked(serviceName, instanceName string) {
+	if s.forget != nil {
+		s.forget(serviceName, instanceName)
+	}
+}
+
+// Count returns the number of known hosts
+func (s *InMemoryStorage) Count() (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.hosts), nil
+}
+
+// Close flushes and closes the persistence file, if any
+func (s *InMemoryStorage) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.persistLog == nil {
+		return nil
+	}
+
+	err := s.persistLog.Sync()
+	if closeErr := s.persistLog.Close(); err == nil {
+		err = closeErr
+	}
+	s.persistLog = nil
+	return err
+}
+
+// openPersistence restores host history from the status log at path, compacts
+// the log down to the retained history, and opens it for appending
+func (s *InMemoryStorage) openPersistence(path string) error {
+	restored, err := s.replayStatusLog(path)
+	if err != nil {
+		return err
+	}
+
+	if err := s.compactStatusLog(path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.persistLog = file
+
+	s.logger.Info("Host history restored",
+		"path", path,
+		"statuses", restored,
+		"hosts", len(s.hosts),
+	)
+	return nil
+}
+
+// replayStatusLog rebuilds the hosts map from a JSON-lines status log. A missing
+// file is not an error; undecodable lines (e.g. a torn final write) are skipped.
+func (s *InMemoryStorage) replayStatusLog(path string) (int, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	restored := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record persistedStatus
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			s.logger.Warn("Skipping unreadable persisted status", "path", path, "line", line, "error", err)
+			continue
+		}
+		if record.Op == persistAppend {
+			s.appendStatus(record.HostStatus)
+			restored++
+			continue
+		}
+		if !s.replayUpdate(record) {
+			s.logger.Warn("Skipping persisted update of a host without statuses", "path", path, "line", line, "op", record.Op)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return restored, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return restored, nil
+}
+
+// replayUpdate applies a persisted heartbeat or metrics-only report to its
+// host's latest status; the caller must hold s.mutex. It returns false when
+// the host has no status to apply it to, or the op is unknown.
+func (s *InMemoryStorage) replayUpdate(record persistedStatus) bool {
+	hostHistory, exists := s.hosts[hostKey(record.ServiceName, record.InstanceName)]
+	if !exists {
+		return false
+	}
+
+	hostHistory.mutex.Lock()
+	defer hostHistory.mutex.Unlock()
+
+	if len(hostHistory.Statuses) == 0 {
+		return false
+	}
+	switch record.Op {
+	case persistTouch:
+		hostHistory.touch(record.HostStatus)
+	case persistMetrics:
+		hostHistory.updateMetrics(record.HostStatus)
+	default:
+		return false
+	}
+	hostHistory.CurrentStatus = s.deriveStatus(hostHistory.Statuses)
+	return true
+}
+
+// compactStatusLog rewrites the status log with only the history still held
+// in memory, so the file doesn't grow without bound across restarts. A host
+// seen since its latest status gets a trailing touch carrying its LastSeen.
+func (s *InMemoryStorage) compactStatusLog(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	s.mutex.RLock()
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, hostHistory := range s.hosts {
+		hostHistory.mutex.RLock()
+		for _, status := range hostHistory.Statuses {
+			if err = encoder.Encode(status); err != nil {
+				break
+			}
+		}
+		if n := len(hostHistory.Statuses); err == nil && n > 0 && hostHistory.LastSeen.After(hostHistory.Statuses[n-1].Timestamp) {
+			seen := hostHistory.Statuses[n-1]
+			seen.Timestamp = hostHistory.LastSeen
+			seen.Sequence = hostHistory.LastSequence
+			err = encoder.Encode(persistedStatus{HostStatus: seen, Op: persistTouch})
+		}
+		hostHistory.mutex.RUnlock()
+		if err != nil {
+			break
+		}
+	}
+	s.mutex.RUnlock()
+
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(0600)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to compact %s: %v", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Status log ops; a line without one is a status appended to the history
+const (
+	persistAppend  = ""
+	persistTouch   = "touch"   // a heartbeat that refreshed LastSeen
+	persistMetrics = "metrics" // a metrics-only report applied to the latest status
+)
+
+// persistedStatus is one line of the status log
+type persistedStatus struct {
+	HostStatus
+	Op string `json:"op,omitempty"`
+}
+
+// persist appends a status to the log under op; the caller must hold s.mutex,
+// for reading at least. The status is already held in memory, so failures are
+// logged, not returned.
+func (s *InMemoryStorage) persist(status HostStatus, op string) {
+	if s.persistLog == nil {
+		return
+	}
+
+	data, err := json.Marshal(persistedStatus{HostStatus: status, Op: op})
+	if err != nil {
+		s.logger.Error("Failed to encode status for persistence", "error", err)
+		return
+	}
+	if _, err := s.persistLog.Write(append(data, '\n')); err != nil {
+		s.logger.Error("Failed to persist status", "path", s.persistLog.Name(), "error", err)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" database/sql driver
+)
+
+// SQLiteStorage persists every status in a SQLite table keyed by
+// service_name/instance_name, and what heartbeats, metrics-only reports and
+// stale marks change in a second table of per-host state. Reads are served
+// from an in-memory index rebuilt from the tables at startup, so the tables
+// are the durable record and can be queried ad hoc without slowing down the
+// API. The driver is pure Go,
+// so the binary still builds with CGO_ENABLED=0.
+type SQLiteStorage struct {
+	*InMemoryStorage
+	db         *sql.DB
+	maxHistory int
+	retention  time.Duration
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS host_statuses (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	service_name  TEXT    NOT NULL,
+	instance_name TEXT    NOT NULL,
+	status        TEXT    NOT NULL,
+	recorded_at   INTEGER NOT NULL,
+	data          TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS host_statuses_host ON host_statuses (service_name, instance_name, id);
+CREATE INDEX IF NOT EXISTS host_statuses_recorded_at ON host_statuses (recorded_at);
+CREATE TABLE IF NOT EXISTS host_state (
+	service_name  TEXT    NOT NULL,
+	instance_name TEXT    NOT NULL,
+	last_seen     INTEGER NOT NULL,
+	last_sequence INTEGER NOT NULL,
+	reports       INTEGER NOT NULL,
+	stale_status  TEXT    NOT NULL DEFAULT '',
+	PRIMARY KEY (service_name, instance_name)
+);
+`
+
+// sqlExecer is implemented by *sql.DB and *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// NewSQLiteStorage opens the database at dsn, creates the schema if needed,
+// and loads the retained history into memory
+func NewSQLiteStorage(dsn string, maxHistory int, retention time.Duration, deriveStatus statusDeriver, logger *slog.Logger) (*SQLiteStorage, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %v", err)
+	}
+
+	// SQLite allows a single writer; serialize through one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %v", err)
+	}
+
+	memory, err := NewInMemoryStorage(maxHistory, retention, deriveStatus, "", logger)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStorage{
+		InMemoryStorage: memory,
+		db:              db,
+		maxHistory:      maxHistory,
+		retention:       retention,
+	}
+
+	restored, err := s.load()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	logger.Info("Host history restored from sqlite",
+		"dsn", dsn,
+		"statuses", restored,
+	)
+	return s, nil
+}
+
+// load replays the table into the in-memory index in insertion order
+func (s *SQLiteStorage) load() (int, error) {
+	rows, err := s.db.Query(`SELECT data FROM host_statuses ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load host history: %v", err)
+	}
+	defer rows.Close()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	restored := 0
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return restored, fmt.Errorf("failed to load host history: %v", err)
+		}
+		var status HostStatus
+		if err := json.Unmarshal([]byte(data), &status); err != nil {
+			s.logger.Warn("Skipping unreadable stored status", "error", err)
+			continue
+		}
+		s.appendStatus(status)
+		restored++
+	}
+	if err := rows.Err(); err != nil {
+		return restored, fmt.Errorf("failed to load host history: %v", err)
+	}
+	return restored, s.loadHostStates()
+}
+
+// loadHostStates applies the stored per-host state over the replayed
+// statuses; the caller must hold s.mutex
+func (s *SQLiteStorage) loadHostStates() error {
+	rows, err := s.db.Query(`SELECT service_name, instance_name, last_seen, last_sequence, reports, stale_status FROM host_state`)
+	if err != nil {
+		return fmt.Errorf("failed to load host state: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var serviceName, instanceName, staleStatus string
+		var lastSeen, lastSequence int64
+		var reports int
+		if err := rows.Scan(&serviceName, &instanceName, &lastSeen, &lastSequence, &reports, &staleStatus); err != nil {
+			return fmt.Errorf("failed to load host state: %v", err)
+		}
+		hostHistory, exists := s.hosts[hostKey(serviceName, instanceName)]
+		if !exists {
+			continue
+		}
+		if seen := time.Unix(0, lastSeen).UTC(); seen.After(hostHistory.LastSeen) {
+			hostHistory.LastSeen = seen
+		}
+		if uint64(lastSequence) > hostHistory.LastSequence {
+			hostHistory.LastSequence = uint64(lastSequence)
+		}
+		if reports > hostHistory.Reports {
+			hostHistory.Reports = reports
+		}
+		if staleStatus != "" {
+			hostHistory.CurrentStatus = staleStatus
+		}
+	}
+	return rows.Err()
+}
+
+// saveHostState stores the liveness of a host, which the caller holds
+// locked, with staleStatus when the sweeper marked it stale
+func saveHostState(db sqlExecer, hostHistory *HostHistory, staleStatus string) error {
+	_, err := db.Exec(
+		`INSERT INTO host_state (service_name, instance_name, last_seen, last_sequence, reports, stale_status)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (service_name, instance_name) DO UPDATE SET
+			last_seen = excluded.last_seen,
+			last_sequence = excluded.last_sequence,
+			reports = excluded.reports,
+			stale_status = excluded.stale_status`,
+		hostHistory.ServiceName, hostHistory.InstanceName, hostHistory.LastSeen.UnixNano(),
+		int64(hostHistory.LastSequence), hostHistory.Reports, staleStatus,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store host state: %v", err)
+	}
+	return nil
+}
+
+// deleteHostRows deletes every stored row of a host
+func deleteHostRows(db sqlExecer, serviceName, instanceName string) error {
+	for _, table := range []string{"host_statuses", "host_state"} {
+		if _, err := db.Exec(`DELETE FROM `+table+` WHERE service_name = ? AND instance_name = ?`, serviceName, instanceName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Touch refreshes LastSeen like InMemoryStorage.Touch and stores it
+func (s *SQLiteStorage) Touch(status HostStatus) (StatusChange, bool, error) {
+	return s.touchHost(status, func(hostHistory *HostHistory) error {
+		return saveHostState(s.db, hostHistory, "")
+	})
+}
+
+// UpdateMetrics updates the latest status like InMemoryStorage.UpdateMetrics
+// and rewrites its row
+func (s *SQLiteStorage) UpdateMetrics(status HostStatus) (StatusChange, bool, error) {
+	return s.updateHostMetrics(status, func(hostHistory *HostHistory) error {
+		data, err := json.Marshal(hostHistory.Statuses[len(hostHistory.Statuses)-1])
+		if err != nil {
+			return fmt.Errorf("failed to encode status: %v", err)
+		}
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(
+			`UPDATE host_statuses SET data = ? WHERE id = (
+				SELECT MAX(id) FROM host_statuses WHERE service_name = ? AND instance_name = ?)`,
+			string(data), hostHistory.ServiceName, hostHistory.InstanceName,
+		); err != nil {
+			return fmt.Errorf("failed to update status: %v", err)
+		}
+		if err := saveHostState(tx, hostHistory, ""); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit status: %v", err)
+		}
+		return nil
+	})
+}
+
+// MarkLost marks the host stale like InMemoryStorage.MarkLost and stores the
+// mark, so a restart does not bring a lost host back with its last status
+func (s *SQLiteStorage) MarkLost(serviceName, instanceName string, staleBefore time.Time, staleStatus string) (StatusChange, bool, error) {
+	return s.markHostLost(serviceName, instanceName, staleBefore, staleStatus, func(hostHistory *HostHistory) error {
+		return saveHostState(s.db, hostHistory, staleStatus)
+	})
+}
+
+// AddStatus inserts the status, trims the host's rows by age and then to
+// maxHistory, and deletes the rows of hosts evicted to make room for a new
+// one, in one transaction. The in-memory index is checked before and updated
+// after it under the same lock, so concurrent reports cannot commit rows the
+// index then rejects, and a failed transaction leaves the index unchanged.
+func (s *SQLiteStorage) AddStatus(status HostStatus) (StatusChange, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	evicted, err := s.admitLocked(status)
+	if err != nil {
+		return StatusChange{}, err
+	}
+	if err := s.insertStatus(status, evicted); err != nil {
+		return StatusChange{}, err
+	}
+	return s.applyLocked(status, evicted), nil
+}
+
+// insertStatus stores status and deletes the evicted hosts in one transaction
+func (s *SQLiteStorage) insertStatus(status HostStatus, evicted []*HostHistory) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`INSERT INTO host_statuses (service_name, instance_name, status, recorded_at, data) VALUES (?, ?, ?, ?, ?)`,
+		status.ServiceName, status.InstanceName, status.Status, status.Timestamp.UnixNano(), string(data),
+	); err != nil {
+		return fmt.Errorf("failed to insert status: %v", err)
+	}
+
+	// A report brings a host marked stale back
+	if _, err := tx.Exec(
+		`UPDATE host_state SET
+			last_seen = ?,
+			last_sequence = MAX(last_sequence, ?),
+			reports = reports + 1,
+			stale_status = ''
+		 WHERE service_name = ? AND instance_name = ?`,
+		status.Timestamp.UnixNano(), int64(status.Sequence), status.ServiceName, status.InstanceName,
+	); err != nil {
+		return fmt.Errorf("failed to update host state: %v", err)
+	}
+
+	if s.retention > 0 {
+		if _, err := tx.Exec(
+			`DELETE FROM host_statuses
+			 WHERE service_name = ? AND instance_name = ? AND recorded_at < ? AND id < (
+				SELECT MAX(id) FROM host_statuses
+				WHERE service_name = ? AND instance_name = ?)`,
+			status.ServiceName, status.InstanceName, time.Now().Add(-s.retention).UnixNano(),
+			status.ServiceName, status.InstanceName,
+		); err != nil {
+			return fmt.Errorf("failed to expire history: %v", err)
+		}
+	}
+
+	if _, err := tx.Exec(
+		`DELETE FROM host_statuses
+		 WHERE service_name = ? AND instance_name = ? AND id NOT IN (
+			SELECT id FROM host_statuses
+			WHERE service_name = ? AND instance_name = ?
+			ORDER BY id DESC LIMIT ?)`,
+		status.ServiceName, status.InstanceName,
+		status.ServiceName, status.InstanceName, s.maxHistory,
+	); err != nil {
+		return fmt.Errorf("failed to trim history: %v", err)
+	}
+
+	for _, hostHistory := range evicted {
+		if err := deleteHostRows(tx, hostHistory.ServiceName, hostHistory.InstanceName); err != nil {
+			return fmt.Errorf("failed to delete evicted host: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit status: %v", err)
+	}
+	return nil
+}
+
+// Prune deletes statuses recorded before cutoff, and the state of hosts left
+// without statuses, from the tables and the index
+func (s *SQLiteStorage) Prune(cutoff time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM host_statuses WHERE recorded_at < ?`, cutoff.UnixNano()); err != nil {
+		return 0, fmt.Errorf("failed to prune statuses: %v", err)
+	}
+	if _, err := tx.Exec(
+		`DELETE FROM host_state WHERE NOT EXISTS (
+			SELECT 1 FROM host_statuses
+			WHERE host_statuses.service_name = host_state.service_name
+			AND host_statuses.instance_name = host_state.instance_name)`,
+	); err != nil {
+		return 0, fmt.Errorf("failed to prune host state: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit prune: %v", err)
+	}
+	return s.pruneLocked(cutoff), nil
+}
+
+// Close closes the database
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openSQLite opens SQLite storage at path keeping maxHistory statuses per
+// host, closing it when the test ends
+func openSQLite(t *testing.T, path string, maxHistory int) *SQLiteStorage {
+	t.Helper()
+	storage, err := NewSQLiteStorage(path, maxHistory, 0, nil, discardLogger)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+func TestSQLiteStorageRestoresHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s01.db")
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	storage := openSQLite(t, path, 3)
+	for i, status := range []string{"healthy", "degraded", "unhealthy", "healthy"} {
+		if _, err := storage.AddStatus(HostStatus{ServiceName: "db", InstanceName: "d1", Status: status, Timestamp: start.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("AddStatus %d: %v", i, err)
+		}
+	}
+	var rows int
+	if err := storage.db.QueryRow(`SELECT COUNT(*) FROM host_statuses`).Scan(&rows); err != nil || rows != 3 {
+		t.Fatalf("rows = %d, %v; want the table trimmed to max history", rows, err)
+	}
+	storage.Close()
+
+	restored := openSQLite(t, path, 3)
+	history, ok, err := restored.GetHost("db", "d1")
+	if err != nil || !ok {
+		t.Fatalf("GetHost = %v, %v", ok, err)
+	}
+	var got []string
+	for _, status := range history.Statuses {
+		got = append(got, status.Status)
+	}
+	if want := []string{"degraded", "unhealthy", "healthy"}; len(got) != len(want) || got[0] != want[0] || got[2] != want[2] {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if history.CurrentStatus != "healthy" || !history.LastSeen.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("current = %s seen %v, want healthy seen at the last report", history.CurrentStatus, history.LastSeen)
+	}
+}
+
+func TestSQLiteStorageWritesThroughUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s01.db")
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	storage := openSQLite(t, path, 10)
+	for _, instance := range []string{"w1", "w2"} {
+		if _, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: instance, Status: "healthy", Timestamp: start, Sequence: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, touched, err := storage.Touch(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: start.Add(time.Minute), Sequence: 2}); err != nil || !touched {
+		t.Fatalf("Touch = %v, %v", touched, err)
+	}
+	metrics := &HealthMetrics{CPUUsage: 12, OverallScore: 91}
+	if _, ok, err := storage.UpdateMetrics(HostStatus{ServiceName: "web", InstanceName: "w1", Timestamp: start.Add(2 * time.Minute), Sequence: 3, HealthMetrics: metrics}); err != nil || !ok {
+		t.Fatalf("UpdateMetrics = %v, %v", ok, err)
+	}
+	if _, marked, err := storage.MarkLost("web", "w2", start.Add(time.Hour), "lost"); err != nil || !marked {
+		t.Fatalf("MarkLost = %v, %v", marked, err)
+	}
+	storage.Close()
+
+	restored := openSQLite(t, path, 10)
+	w1, _, _ := restored.GetHostSnapshot("web", "w1")
+	if !w1.LastSeen.Equal(start.Add(2*time.Minute)) || w1.Reports != 3 {
+		t.Errorf("w1 seen %v after %d reports, want the metrics-only report's time after 3", w1.LastSeen, w1.Reports)
+	}
+	if w1.Latest.HealthMetrics == nil || w1.Latest.HealthMetrics.OverallScore != 91 {
+		t.Errorf("w1 metrics = %+v, want the metrics-only update", w1.Latest.HealthMetrics)
+	}
+	if _, _, err := restored.Touch(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: start.Add(3 * time.Minute), Sequence: 3}); err != errStaleSequence {
+		t.Errorf("Touch with a replayed sequence = %v, want errStaleSequence", err)
+	}
+	if w2, _, _ := restored.GetHostSnapshot("web", "w2"); w2.CurrentStatus != "lost" {
+		t.Errorf("w2 status = %s, want lost kept across the restart", w2.CurrentStatus)
+	}
+
+	// A report from the lost host clears the stored mark
+	if _, err := restored.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w2", Status: "healthy", Timestamp: start.Add(2 * time.Hour), Sequence: 2}); err != nil {
+		t.Fatal(err)
+	}
+	restored.Close()
+	if w2, _, _ := openSQLite(t, path, 10).GetHostSnapshot("web", "w2"); w2.CurrentStatus != "healthy" {
+		t.Errorf("w2 status = %s after reporting again, want healthy", w2.CurrentStatus)
+	}
+}
+
+func TestSQLiteStorageUndoesFailedWrites(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	storage := openSQLite(t, filepath.Join(t.TempDir(), "s01.db"), 10)
+	if _, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: start}); err != nil {
+		t.Fatal(err)
+	}
+	storage.db.Close()
+
+	if _, _, err := storage.Touch(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: start.Add(time.Minute)}); err == nil {
+		t.Fatal("Touch on a closed database succeeded")
+	}
+	if _, marked, err := storage.MarkLost("web", "w1", start.Add(time.Hour), "lost"); err == nil || marked {
+		t.Fatalf("MarkLost on a closed database = %v, %v", marked, err)
+	}
+	snapshot, _, _ := storage.GetHostSnapshot("web", "w1")
+	if !snapshot.LastSeen.Equal(start) || snapshot.Reports != 1 || snapshot.CurrentStatus != "healthy" {
+		t.Errorf("snapshot = seen %v, %d reports, %s; want the failed writes undone", snapshot.LastSeen, snapshot.Reports, snapshot.CurrentStatus)
+	}
+}
+
+func TestSQLiteStorageConcurrentReportsMatchIndex(t *testing.T) {
+	storage := openSQLite(t, filepath.Join(t.TempDir(), "s01.db"), 100)
+	storage.limitHosts(5, hostEvictionReject, "lost")
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Sequences race on one host and new hosts race for the last slots
+	var wg sync.WaitGroup
+	for i := 1; i <= 40; i++ {
+		wg.Add(2)
+		go func(sequence uint64) {
+			defer wg.Done()
+			storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w0", Status: "healthy", Timestamp: start, Sequence: sequence})
+		}(uint64(i))
+		go func(instance string) {
+			defer wg.Done()
+			storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: instance, Status: "healthy", Timestamp: start})
+		}(fmt.Sprintf("w%d", i))
+	}
+	wg.Wait()
+
+	hosts, _ := storage.Count()
+	var storedHosts int
+	if err := storage.db.QueryRow(`SELECT COUNT(DISTINCT instance_name) FROM host_statuses`).Scan(&storedHosts); err != nil {
+		t.Fatal(err)
+	}
+	if hosts != 5 || storedHosts != hosts {
+		t.Errorf("%d hosts in memory and %d stored, want the 5 admitted in both", hosts, storedHosts)
+	}
+
+	history, _, _ := storage.GetHost("web", "w0")
+	var storedRows int
+	if err := storage.db.QueryRow(`SELECT COUNT(*) FROM host_statuses WHERE instance_name = 'w0'`).Scan(&storedRows); err != nil {
+		t.Fatal(err)
+	}
+	if storedRows != len(history.Statuses) {
+		t.Errorf("%d rows stored for w0 but %d statuses accepted", storedRows, len(history.Statuses))
+	}
+	for i := 1; i < len(history.Statuses); i++ {
+		if history.Statuses[i].Sequence <= history.Statuses[i-1].Sequence {
+			t.Fatalf("statuses out of sequence order: %d after %d", history.Statuses[i].Sequence, history.Statuses[i-1].Sequence)
+		}
+	}
+}
+
+func TestSQLiteStorageFailedInsertLeavesIndex(t *testing.T) {
+	storage := openSQLite(t, filepath.Join(t.TempDir(), "s01.db"), 10)
+	storage.db.Close()
+
+	if _, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: time.Now()}); err == nil {
+		t.Fatal("AddStatus on a closed database succeeded")
+	}
+	if hosts, _ := storage.Count(); hosts != 0 {
+		t.Errorf("%d hosts indexed after a failed insert, want 0", hosts)
+	}
+}
+
+func TestSQLiteStoragePrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s01.db")
+	now := time.Now()
+
+	storage := openSQLite(t, path, 10)
+	storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "silent", Status: "healthy", Timestamp: now.Add(-2 * time.Hour)})
+	storage.Touch(HostStatus{ServiceName: "web", InstanceName: "silent", Status: "healthy", Timestamp: now.Add(-90 * time.Minute)})
+	storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "current", Status: "healthy", Timestamp: now.Add(-2 * time.Hour)})
+	storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "current", Status: "degraded", Timestamp: now})
+
+	if pruned, err := storage.Prune(now.Add(-time.Hour)); err != nil || pruned != 2 {
+		t.Fatalf("Prune = %d, %v; want 2 statuses dropped", pruned, err)
+	}
+	var states int
+	if err := storage.db.QueryRow(`SELECT COUNT(*) FROM host_state`).Scan(&states); err != nil || states != 0 {
+		t.Errorf("%d host_state rows left, want the silent host's removed", states)
+	}
+	storage.Close()
+
+	restored := openSQLite(t, path, 10)
+	if _, found, _ := restored.GetHostSnapshot("web", "silent"); found {
+		t.Error("pruned host restored")
+	}
+	if history, _, _ := restored.GetHost("web", "current"); len(history.Statuses) != 1 || history.Statuses[0].Status != "degraded" {
+		t.Errorf("current host history = %+v, want only its recent status", history.Statuses)
+	}
+}
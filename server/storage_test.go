@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// discardLogger drops everything logged through it
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// openPersisted opens in-memory storage persisted to path, closing it when
+// the test ends
+func openPersisted(t *testing.T, path string) *InMemoryStorage {
+	t.Helper()
+	storage, err := NewInMemoryStorage(10, 0, nil, path, discardLogger)
+	if err != nil {
+		t.Fatalf("NewInMemoryStorage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+func TestPersistedHeartbeatsAndMetricsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	storage := openPersisted(t, path)
+	if _, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: start, Sequence: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_, touched, err := storage.Touch(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: start.Add(time.Minute), Sequence: 2})
+	if err != nil || !touched {
+		t.Fatalf("Touch = %v, %v", touched, err)
+	}
+	metrics := &HealthMetrics{CPUUsage: 42, OverallScore: 77}
+	if _, ok, err := storage.UpdateMetrics(HostStatus{ServiceName: "web", InstanceName: "w1", Timestamp: start.Add(2 * time.Minute), Sequence: 3, HealthMetrics: metrics}); err != nil || !ok {
+		t.Fatalf("UpdateMetrics = %v, %v", ok, err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restored once from the raw log, then again from the compacted one
+	for _, restart := range []string{"replayed", "compacted"} {
+		restored := openPersisted(t, path)
+		snapshot, ok, err := restored.GetHostSnapshot("web", "w1")
+		if err != nil || !ok {
+			t.Fatalf("%s: GetHostSnapshot = %v, %v", restart, ok, err)
+		}
+		if want := start.Add(2 * time.Minute); !snapshot.LastSeen.Equal(want) {
+			t.Errorf("%s: LastSeen = %v, want %v", restart, snapshot.LastSeen, want)
+		}
+		if snapshot.Latest.HealthMetrics == nil || snapshot.Latest.HealthMetrics.OverallScore != 77 {
+			t.Errorf("%s: metrics = %+v, want the metrics-only update", restart, snapshot.Latest.HealthMetrics)
+		}
+		history, _, _ := restored.GetHost("web", "w1")
+		if len(history.Statuses) != 1 {
+			t.Errorf("%s: %d statuses, want heartbeats and metrics kept out of the history", restart, len(history.Statuses))
+		}
+		if _, _, err := restored.Touch(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: start.Add(3 * time.Minute), Sequence: 3}); err != errStaleSequence {
+			t.Errorf("%s: Touch with a replayed sequence = %v, want errStaleSequence", restart, err)
+		}
+		restored.Close()
+	}
+}
+
+func TestServerRestoresPersistedHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	persisted := func(config *Config) {
+		config.PersistPath = path
+		config.MaxHistory = 3
+	}
+
+	ds := newTestServer(t, persisted)
+	for _, status := range []string{"healthy", "degraded", "unhealthy", "degraded", "healthy"} {
+		mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: status})
+	}
+	mustReport(t, ds, StatusRequest{ServiceName: "db", InstanceName: "d1", Status: "degraded"})
+	if err := ds.storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash can leave a half-written last line
+	log, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.WriteString(`{"service_name":"web","instance_name":"w1","sta`)
+	log.Close()
+
+	restarted := newTestServer(t, persisted)
+	tests := []struct {
+		service, instance string
+		want              []string
+	}{
+		{"web", "w1", []string{"unhealthy", "degraded", "healthy"}},
+		{"db", "d1", []string{"degraded"}},
+	}
+	for _, tt := range tests {
+		recorder := serve(restarted, http.MethodGet, "/api/v1/hosts/"+tt.service+"/"+tt.instance)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s/%s: status = %d after restart", tt.service, tt.instance, recorder.Code)
+		}
+		var history HostHistory
+		if err := json.NewDecoder(recorder.Body).Decode(&history); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, status := range history.Statuses {
+			got = append(got, status.Status)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s/%s: restored %v, want %v", tt.service, tt.instance, got, tt.want)
+		}
+	}
+}
+
+func TestNoPersistPathWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	ds := newTestServer(t, func(config *Config) { config.PersistPath = "" })
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy"})
+	ds.storage.Close()
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files written without PERSIST_PATH: %v", entries)
+	}
+	if hosts, _ := newTestServer(t, nil).storage.Count(); hosts != 0 {
+		t.Errorf("%d hosts held by a fresh server without persistence, want 0", hosts)
+	}
+}
+
+// storageBackends opens each backend keeping three statuses per host
+var storageBackends = map[string]func(t *testing.T) Storage{
+	storageMemory: func(t *testing.T) Storage {
+		storage, _ := NewInMemoryStorage(3, 0, nil, "", discardLogger)
+		t.Cleanup(func() { storage.Close() })
+		return storage
+	},
+	storageSQLite: func(t *testing.T) Storage {
+		return openSQLite(t, filepath.Join(t.TempDir(), "s01.db"), 3)
+	},
+}
+
+func TestStorageBackendsBehaveAlike(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	for backend, open := range storageBackends {
+		t.Run(backend, func(t *testing.T) {
+			storage := open(t)
+			previous := ""
+			for i, status := range []string{"healthy", "degraded", "unhealthy", "healthy"} {
+				change, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: status, Timestamp: at(i), Sequence: uint64(i + 1)})
+				if want := (StatusChange{Previous: previous, Current: status}); err != nil || change != want {
+					t.Fatalf("AddStatus %s = %+v, %v; want %+v", status, change, err, want)
+				}
+				previous = status
+			}
+			storage.AddStatus(HostStatus{ServiceName: "db", InstanceName: "d1", Status: "healthy", Timestamp: at(0)})
+
+			if _, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: at(5), Sequence: 2}); err != errStaleSequence {
+				t.Errorf("AddStatus with an old sequence = %v, want errStaleSequence", err)
+			}
+			if _, touched, _ := storage.Touch(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "degraded", Timestamp: at(5)}); touched {
+				t.Error("Touch with a different status refreshed the host")
+			}
+			if _, touched, _ := storage.Touch(HostStatus{ServiceName: "web", InstanceName: "nobody", Status: "healthy", Timestamp: at(5)}); touched {
+				t.Error("Touch refreshed an unknown host")
+			}
+			if _, ok, _ := storage.UpdateMetrics(HostStatus{ServiceName: "web", InstanceName: "nobody", Timestamp: at(5), HealthMetrics: &HealthMetrics{}}); ok {
+				t.Error("UpdateMetrics applied to an unknown host")
+			}
+
+			history, found, err := storage.GetHost("web", "w1")
+			if err != nil || !found {
+				t.Fatalf("GetHost = %v, %v", found, err)
+			}
+			var statuses []string
+			for _, status := range history.Statuses {
+				statuses = append(statuses, status.Status)
+			}
+			if strings.Join(statuses, ",") != "degraded,unhealthy,healthy" || history.CurrentStatus != "healthy" {
+				t.Errorf("history = %v current %s, want the last three ending healthy", statuses, history.CurrentStatus)
+			}
+			if _, found, _ := storage.GetHost("web", "nobody"); found {
+				t.Error("GetHost found an unknown host")
+			}
+
+			if change, marked, _ := storage.MarkLost("db", "d1", at(1), "lost"); !marked || change != (StatusChange{Previous: "healthy", Current: "lost"}) {
+				t.Error("MarkLost skipped a host silent since before the cutoff")
+			}
+			if _, marked, _ := storage.MarkLost("web", "w1", at(1), "lost"); marked {
+				t.Error("MarkLost marked a host seen after the cutoff")
+			}
+
+			hosts, _ := storage.GetHosts()
+			current := make(map[string]string)
+			for _, host := range hosts {
+				current[host.InstanceName] = host.CurrentStatus
+			}
+			if len(hosts) != 2 || current["w1"] != "healthy" || current["d1"] != "lost" {
+				t.Errorf("GetHosts = %v, want w1 healthy and d1 lost", current)
+			}
+
+			if pruned, err := storage.Prune(at(3)); err != nil || pruned != 3 {
+				t.Errorf("Prune = %d, %v; want w1's two oldest and d1's only status dropped", pruned, err)
+			}
+			if count, _ := storage.Count(); count != 1 {
+				t.Errorf("Count = %d after pruning, want w1 alone", count)
+			}
+		})
+	}
+}
+
+func TestNewStorageSelectsBackend(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		backend string
+		want    string
+	}{
+		{"", "*main.InMemoryStorage"},
+		{storageMemory, "*main.InMemoryStorage"},
+		{storageSQLite, "*main.SQLiteStorage"},
+		{"postgres", ""},
+	}
+	for _, tt := range tests {
+		config := &Config{StorageBackend: tt.backend, StoragePath: filepath.Join(dir, "s01.db"), MaxHistory: 10}
+		storage, err := newStorage(config, discardLogger, nil)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("backend %q accepted", tt.backend)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("backend %q: %v", tt.backend, err)
+		}
+		if got := fmt.Sprintf("%T", storage); got != tt.want {
+			t.Errorf("backend %q = %s, want %s", tt.backend, got, tt.want)
+		}
+		storage.Close()
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	tests := []struct {
+		name       string
+		retention  time.Duration
+		maxHistory int
+		ages       []time.Duration // how long ago each status was taken, oldest first
+		want       int             // statuses kept, the newest ones
+	}{
+		{"no age limit", 0, 5, []time.Duration{90 * time.Minute, time.Hour, 30 * time.Minute, 0}, 4},
+		{"older than the window dropped", time.Hour, 10, []time.Duration{2 * time.Hour, 61 * time.Minute, 59 * time.Minute, 0}, 2},
+		{"a day kept whatever the count", 24 * time.Hour, 100, []time.Duration{25 * time.Hour, 24*time.Hour + time.Minute, 23 * time.Hour, time.Hour, time.Minute}, 3},
+		{"age then count", time.Hour, 2, []time.Duration{50 * time.Minute, 40 * time.Minute, 30 * time.Minute, 0}, 2},
+		{"newest kept even when expired", time.Hour, 10, []time.Duration{5 * time.Hour, 3 * time.Hour}, 1},
+	}
+	for _, tt := range tests {
+		backends := map[string]func(t *testing.T) Storage{
+			storageMemory: func(t *testing.T) Storage {
+				storage, _ := NewInMemoryStorage(tt.maxHistory, tt.retention, nil, "", discardLogger)
+				return storage
+			},
+			storageSQLite: func(t *testing.T) Storage {
+				storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "s01.db"), tt.maxHistory, tt.retention, nil, discardLogger)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return storage
+			},
+		}
+		for backend, open := range backends {
+			t.Run(tt.name+"/"+backend, func(t *testing.T) {
+				storage := open(t)
+				defer storage.Close()
+				now := time.Now()
+				for _, age := range tt.ages {
+					storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: now.Add(-age)})
+				}
+
+				history, _, err := storage.GetHost("web", "w1")
+				if err != nil || len(history.Statuses) != tt.want {
+					t.Fatalf("%d statuses kept (%v), want %d", len(history.Statuses), err, tt.want)
+				}
+				wantOldest := now.Add(-tt.ages[len(tt.ages)-tt.want])
+				if oldest := history.Statuses[0].Timestamp; !oldest.Equal(wantOldest) {
+					t.Errorf("oldest kept status from %v, want %v", oldest, wantOldest)
+				}
+			})
+		}
+	}
+}
+
+func TestCurrentStatusIsStored(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	for backend, open := range storageBackends {
+		t.Run(backend, func(t *testing.T) {
+			storage := open(t)
+			markLost := func(serviceName, instanceName string, staleBefore time.Time, staleStatus string) (bool, error) {
+				_, marked, err := storage.MarkLost(serviceName, instanceName, staleBefore, staleStatus)
+				return marked, err
+			}
+			steps := []struct {
+				name string
+				do   func() (bool, error) // reports whether the step changed anything
+				want string
+			}{
+				{"first report", func() (bool, error) {
+					_, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: at(0)})
+					return true, err
+				}, "healthy"},
+				{"new status", func() (bool, error) {
+					_, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "degraded", Timestamp: at(1)})
+					return true, err
+				}, "degraded"},
+				// Reads long after the last report still see the stored status
+				// until the sweeper marks the host lost
+				{"no sweep", func() (bool, error) { return false, nil }, "degraded"},
+				{"swept", func() (bool, error) { return markLost("web", "w1", at(2), "lost") }, "lost"},
+				{"swept again", func() (bool, error) { return markLost("web", "w1", at(3), "lost") }, "lost"},
+				{"back", func() (bool, error) {
+					_, err := storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: at(10)})
+					return true, err
+				}, "healthy"},
+				{"seen since the cutoff", func() (bool, error) { return markLost("web", "w1", at(5), "lost") }, "healthy"},
+			}
+			wantChanged := map[string]bool{"swept": true, "swept again": false, "seen since the cutoff": false}
+
+			for _, step := range steps {
+				changed, err := step.do()
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if want, ok := wantChanged[step.name]; ok && changed != want {
+					t.Errorf("%s: MarkLost = %v, want %v", step.name, changed, want)
+				}
+				snapshots, _ := storage.GetHosts()
+				if len(snapshots) != 1 || snapshots[0].CurrentStatus != step.want {
+					t.Fatalf("%s: hosts = %+v, want w1 %s", step.name, snapshots, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageReturnsStatusChanges(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	report := func(status string, minutes int) HostStatus {
+		return HostStatus{ServiceName: "web", InstanceName: "w1", Status: status, Timestamp: at(minutes)}
+	}
+
+	for backend, open := range storageBackends {
+		t.Run(backend, func(t *testing.T) {
+			storage := open(t)
+			steps := []struct {
+				name string
+				do   func() (StatusChange, error)
+				want StatusChange
+			}{
+				{"first report", func() (StatusChange, error) { return storage.AddStatus(report("healthy", 0)) },
+					StatusChange{Current: "healthy"}},
+				{"heartbeat", func() (StatusChange, error) {
+					change, _, err := storage.Touch(report("healthy", 1))
+					return change, err
+				}, StatusChange{Previous: "healthy", Current: "healthy"}},
+				{"swept", func() (StatusChange, error) {
+					change, _, err := storage.MarkLost("web", "w1", at(5), "lost")
+					return change, err
+				}, StatusChange{Previous: "healthy", Current: "lost"}},
+				// A heartbeat or metrics push brings a lost host back
+				{"heartbeat after lost", func() (StatusChange, error) {
+					change, _, err := storage.Touch(report("healthy", 6))
+					return change, err
+				}, StatusChange{Previous: "lost", Current: "healthy"}},
+				{"swept again", func() (StatusChange, error) {
+					change, _, err := storage.MarkLost("web", "w1", at(10), "lost")
+					return change, err
+				}, StatusChange{Previous: "healthy", Current: "lost"}},
+				{"metrics after lost", func() (StatusChange, error) {
+					change, _, err := storage.UpdateMetrics(HostStatus{ServiceName: "web", InstanceName: "w1", Timestamp: at(11), HealthMetrics: &HealthMetrics{}})
+					return change, err
+				}, StatusChange{Previous: "lost", Current: "healthy"}},
+				{"new status", func() (StatusChange, error) { return storage.AddStatus(report("unhealthy", 12)) },
+					StatusChange{Previous: "healthy", Current: "unhealthy"}},
+			}
+			for _, step := range steps {
+				change, err := step.do()
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if change != step.want {
+					t.Errorf("%s: change = %+v, want %+v", step.name, change, step.want)
+				}
+			}
+		})
+	}
+}
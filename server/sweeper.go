@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// timeNow is the clock used by the stale sweeper
+var timeNow = time.Now
+
+// runStaleSweeper periodically marks hosts that stopped reporting as stale
+// and prunes expired history until ctx is cancelled
+func (ds *S01Server) runStaleSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Hosts restored from persistence may already be stale
+	ds.sweepStaleHosts(timeNow())
+	ds.pruneHistory(timeNow())
+
+	for {
+		select {
+		case <-ticker.C:
+			now := timeNow()
+			ds.sweepStaleHosts(now)
+			ds.pruneHistory(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sweepStaleHosts sets the current status of hosts whose LastSeen exceeds
+// StaleTimeout to StaleStatus ("lost" by default), sparing hosts still within
+// their stale grace. It returns the number of hosts newly marked stale.
+func (ds *S01Server) sweepStaleHosts(now time.Time) int {
+	snapshots, err := ds.storage.GetHosts()
+	if err != nil {
+		ds.logger.Error("Stale sweep failed to load hosts", "error", err)
+		return 0
+	}
+
+	staleBefore := now.Add(-time.Duration(ds.config.StaleTimeout) * time.Second)
+	newlyLost := 0
+
+	for _, snapshot := range snapshots {
+		if snapshot.Latest == nil || snapshot.CurrentStatus == ds.config.StaleStatus || !snapshot.LastSeen.Before(staleBefore) {
+			continue
+		}
+		if ds.inStaleGrace(snapshot, now) {
+			ds.logger.Debug("Host past stale timeout spared during grace period",
+				"service_name", snapshot.ServiceName,
+				"instance_name", snapshot.InstanceName,
+				"first_seen", snapshot.FirstSeen,
+				"reports", snapshot.Reports,
+			)
+			continue
+		}
+
+		// The host may have reported since the snapshot was taken
+		change, marked, err := ds.storage.MarkLost(snapshot.ServiceName, snapshot.InstanceName, staleBefore, ds.config.StaleStatus)
+		if err != nil {
+			ds.logger.Error("Failed to mark host lost",
+				"service_name", snapshot.ServiceName,
+				"instance_name", snapshot.InstanceName,
+				"error", err,
+			)
+			continue
+		}
+		if !marked {
+			continue
+		}
+
+		newlyLost++
+		ds.hostsChanged()
+		ds.logger.Warn("Host marked lost",
+			"service_name", snapshot.ServiceName,
+			"instance_name", snapshot.InstanceName,
+			"last_seen", snapshot.LastSeen,
+			"status", ds.config.StaleStatus,
+		)
+		ds.webhook.observe(snapshot.ServiceName, snapshot.InstanceName, change, now)
+		ds.audit.observe(snapshot.ServiceName, snapshot.InstanceName, change, "", now)
+	}
+
+	return newlyLost
+}
+
+// pruneHistory drops statuses older than HistoryRetention, which reports
+// only trim from hosts that keep reporting. A host silent for longer than
+// that is forgotten. It returns the number of statuses dropped.
+func (ds *S01Server) pruneHistory(now time.Time) int {
+	if ds.config.HistoryRetention == 0 {
+		return 0
+	}
+
+	pruned, err := ds.storage.Prune(now.Add(-time.Duration(ds.config.HistoryRetention) * time.Second))
+	if err != nil {
+		ds.logger.Error("Failed to prune expired history", "error", err)
+		return 0
+	}
+	if pruned > 0 {
+		ds.hostsChanged()
+		ds.logger.Info("Pruned expired history",
+			"statuses", pruned,
+			"history_retention", ds.config.HistoryRetention,
+		)
+	}
+	return pruned
+}
+
+// inStaleGrace reports whether a host is too new to be marked lost: first
+// seen less than StaleGracePeriod ago and, when StaleGraceReports is set,
+// with fewer reports than that. A slow first interval right after a deploy
+// then does not flag the host.
+func (ds *S01Server) inStaleGrace(snapshot HostSnapshot, now time.Time) bool {
+	grace := time.Duration(ds.config.StaleGracePeriod) * time.Second
+	if grace == 0 || !now.Before(snapshot.FirstSeen.Add(grace)) {
+		return false
+	}
+	return ds.config.StaleGraceReports == 0 || snapshot.Reports < ds.config.StaleGraceReports
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPruneHistoryForgetsSilentHosts(t *testing.T) {
+	// Appends trim by age against the wall clock, so statuses must be recent
+	now := time.Now()
+	tests := []struct {
+		name        string
+		retention   int
+		wantPruned  int
+		wantSilent  bool
+		wantCurrent int
+	}{
+		{"retention off", 0, 0, true, 2},
+		// Appends already trimmed all but its latest status
+		{"silent host past retention", 3600, 1, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) { config.HistoryRetention = tt.retention })
+			for i := 0; i < 3; i++ {
+				ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "silent", Status: "healthy", Timestamp: now.Add(-3 * time.Hour).Add(time.Duration(i) * time.Minute)})
+			}
+			ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "current", Status: "healthy", Timestamp: now.Add(-time.Minute)})
+
+			before := ds.revision.Load()
+			if pruned := ds.pruneHistory(now); pruned != tt.wantPruned {
+				t.Errorf("pruned %d statuses, want %d", pruned, tt.wantPruned)
+			}
+			if _, found, _ := ds.storage.GetHostSnapshot("web", "silent"); found != tt.wantSilent {
+				t.Errorf("silent host kept = %v, want %v", found, tt.wantSilent)
+			}
+			if hosts, _ := ds.storage.Count(); hosts != tt.wantCurrent {
+				t.Errorf("%d hosts left, want %d", hosts, tt.wantCurrent)
+			}
+			if changed := ds.revision.Load() != before; changed != (tt.wantPruned > 0) {
+				t.Errorf("hosts ETag changed = %v, want %v", changed, tt.wantPruned > 0)
+			}
+
+			// A forgotten host that reports again is new to webhooks and the audit log
+			change, err := ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "silent", Status: "healthy", Timestamp: now})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if known := change.Previous != ""; known != tt.wantSilent {
+				t.Errorf("returning silent host has previous status %q, want it known = %v", change.Previous, tt.wantSilent)
+			}
+		})
+	}
+}
+
+func TestStaleSweeperMarksHostLost(t *testing.T) {
+	url, events := webhookEvents(t)
+	ds := newTestServer(t, func(config *Config) {
+		config.StaleTimeout = 30
+		config.StaleGracePeriod = 0
+		config.WebhookURL = url
+	})
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, instance := range []string{"quiet", "chatty"} {
+		ds.addHostStatus(HostStatus{ServiceName: "web", InstanceName: instance, Status: "healthy", Timestamp: start})
+	}
+
+	// The sweeper reads a fake clock that jumps past the stale timeout
+	var clock atomic.Int64
+	clock.Store(start.Add(10 * time.Second).UnixNano())
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	timeNow = func() time.Time { return time.Unix(0, clock.Load()).UTC() }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		ds.runStaleSweeper(ctx, 10*time.Millisecond)
+		close(stopped)
+	}()
+
+	ds.addHostStatus(HostStatus{ServiceName: "web", InstanceName: "chatty", Status: "healthy", Timestamp: start.Add(50 * time.Second)})
+	clock.Store(start.Add(time.Minute).UnixNano())
+
+	select {
+	case event := <-events:
+		if event.InstanceName != "quiet" || event.OldStatus != "healthy" || event.NewStatus != "lost" || !event.Timestamp.Equal(start.Add(time.Minute)) {
+			t.Errorf("event = %+v, want quiet healthy -> lost at the fake clock's time", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("sweeper did not mark the quiet host lost")
+	}
+	if snapshot, _, _ := ds.storage.GetHostSnapshot("web", "chatty"); snapshot.CurrentStatus != "healthy" {
+		t.Errorf("chatty host = %s, want healthy", snapshot.CurrentStatus)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("sweeper still running after its context was cancelled")
+	}
+}
+
+func TestSweepStaleHosts(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		lastSeen    time.Duration // before now
+		reports     int
+		gracePeriod int
+		want        int
+	}{
+		{"within the timeout", 20 * time.Second, 1, 0, 0},
+		{"past the timeout", 45 * time.Second, 1, 0, 1},
+		{"new host within grace", 45 * time.Second, 1, 300, 0},
+		// The first report came long before the grace period ended
+		{"old host past grace", 45 * time.Second, 12, 300, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.StaleTimeout = 30
+				config.StaleGracePeriod = tt.gracePeriod
+			})
+			for i := tt.reports - 1; i >= 0; i-- {
+				at := now.Add(-tt.lastSeen).Add(-time.Duration(i) * time.Minute)
+				ds.storage.AddStatus(HostStatus{ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: at})
+			}
+			if got := ds.sweepStaleHosts(now); got != tt.want {
+				t.Errorf("marked %d hosts lost, want %d", got, tt.want)
+			}
+			// A host already lost is not marked again
+			if again := ds.sweepStaleHosts(now.Add(time.Hour)); tt.want == 1 && again != 0 {
+				t.Errorf("second sweep marked %d hosts, want 0", again)
+			}
+		})
+	}
+}
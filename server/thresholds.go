@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// ScoreThresholds map an OverallScore onto a status for one service
+type ScoreThresholds struct {
+	HealthyScore  int `json:"healthy_score"`  // score at or above which a host is healthy
+	DegradedScore int `json:"degraded_score"` // score at or above which a host is degraded; below it is unhealthy
+}
+
+// status maps score onto a status
+func (t ScoreThresholds) status(score float64) string {
+	switch {
+	case score >= float64(t.HealthyScore):
+		return "healthy"
+	case score >= float64(t.DegradedScore):
+		return "degraded"
+	default:
+		return "unhealthy"
+	}
+}
+
+// validateServiceThresholds rejects overrides whose degraded score exceeds
+// their healthy score
+func validateServiceThresholds(thresholds map[string]ScoreThresholds) error {
+	for service, t := range thresholds {
+		if service == "" {
+			return fmt.Errorf("service_thresholds: empty service name")
+		}
+		if t.DegradedScore > t.HealthyScore {
+			return fmt.Errorf("service_thresholds[%s]: degraded_score (%d) must not exceed healthy_score (%d)", service, t.DegradedScore, t.HealthyScore)
+		}
+	}
+	return nil
+}
+
+// applyServiceThresholds replaces the status a client reported with the one
+// its service's thresholds give its OverallScore, so policy lives on the
+// server and every client can run the same health config. Reports of
+// services without an override, or without health metrics, keep theirs.
+func (ds *S01Server) applyServiceThresholds(logger *slog.Logger, status *HostStatus) {
+	thresholds, ok := ds.config.ServiceThresholds[status.ServiceName]
+	if !ok || status.HealthMetrics == nil {
+		return
+	}
+
+	derived := thresholds.status(float64(status.HealthMetrics.OverallScore))
+	if derived != status.Status {
+		logger.Debug("Service thresholds override reported status",
+			"service_name", status.ServiceName,
+			"instance_name", status.InstanceName,
+			"reported_status", status.Status,
+			"status", derived,
+			"health_score", status.HealthMetrics.OverallScore,
+		)
+	}
+	status.Status = derived
+}
+
+// keepThresholdStatus gives a heartbeat from a service with an override the
+// status stored from the host's last scored report. Heartbeats carry no
+// score, so the status the client worked out itself would otherwise replace
+// the one the thresholds gave and flip the host on every heartbeat.
+func (ds *S01Server) keepThresholdStatus(status *HostStatus) {
+	if _, ok := ds.config.ServiceThresholds[status.ServiceName]; !ok || status.HealthMetrics != nil {
+		return
+	}
+
+	snapshot, found, err := ds.storage.GetHostSnapshot(status.ServiceName, status.InstanceName)
+	if err != nil || !found || snapshot.Latest == nil {
+		return
+	}
+	status.Status = snapshot.Latest.Status
+}
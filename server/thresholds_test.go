@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestScoreThresholdsStatus(t *testing.T) {
+	thresholds := ScoreThresholds{HealthyScore: 80, DegradedScore: 60}
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{100, "healthy"},
+		{80, "healthy"},
+		{79.9, "degraded"},
+		{60, "degraded"},
+		{59, "unhealthy"},
+		{0, "unhealthy"},
+	}
+	for _, tt := range tests {
+		if got := thresholds.status(tt.score); got != tt.want {
+			t.Errorf("status(%v) = %s, want %s", tt.score, got, tt.want)
+		}
+	}
+	// Equal scores leave no degraded band
+	if got := (ScoreThresholds{HealthyScore: 70, DegradedScore: 70}).status(69); got != "unhealthy" {
+		t.Errorf("69 under 70/70 = %s, want unhealthy", got)
+	}
+}
+
+func TestServiceThresholdsFromSameScore(t *testing.T) {
+	ds := newTestServer(t, func(config *Config) {
+		config.ServiceThresholds = map[string]ScoreThresholds{
+			"batch": {HealthyScore: 50, DegradedScore: 20}, // busy is normal
+			"api":   {HealthyScore: 90, DegradedScore: 75},
+		}
+	})
+	// Every client scored 70 and called itself degraded
+	for _, service := range []string{"batch", "api", "web"} {
+		mustReport(t, ds, StatusRequest{ServiceName: service, InstanceName: "i1", Status: "degraded", HealthMetrics: &HealthMetrics{CPUUsage: 95, OverallScore: 70}})
+	}
+	// A report without metrics has no score to judge, so its status stands
+	mustReport(t, ds, StatusRequest{ServiceName: "api", InstanceName: "i2", Status: "healthy"})
+
+	statuses := make(map[string]string)
+	for _, host := range decodeDiscovery(t, serve(ds, http.MethodGet, "/api/v1/hosts")).Hosts {
+		statuses[host.ServiceName+"/"+host.InstanceName] = host.Status
+	}
+	want := map[string]string{"batch/i1": "healthy", "api/i1": "unhealthy", "web/i1": "degraded", "api/i2": "healthy"}
+	for host, status := range want {
+		if statuses[host] != status {
+			t.Errorf("%s = %s, want %s", host, statuses[host], status)
+		}
+	}
+	// The stored history records the derived status, not the reported one
+	if history, _, _ := ds.storage.GetHost("batch", "i1"); history.Statuses[0].Status != "healthy" {
+		t.Errorf("stored batch status %s, want healthy", history.Statuses[0].Status)
+	}
+}
+
+func TestServiceThresholdsUnderEWMA(t *testing.T) {
+	derive, err := newStatusDeriver(&Config{StatusSmoothing: smoothingEWMA, SmoothingAlpha: 1,
+		SmoothingHealthyScore: 80, SmoothingDegradedScore: 60,
+		ServiceThresholds: map[string]ScoreThresholds{"batch": {HealthyScore: 50, DegradedScore: 20}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := scoredHistory(70)
+	if got := derive(history); got != "degraded" {
+		t.Errorf("web at 70 = %s, want degraded under the global scores", got)
+	}
+	history[0].ServiceName = "batch"
+	if got := derive(history); got != "healthy" {
+		t.Errorf("batch at 70 = %s, want healthy under its own scores", got)
+	}
+}
+
+func TestLoadConfigServiceThresholds(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]ScoreThresholds
+		wantErr string
+	}{
+		{`{"batch":{"healthy_score":50,"degraded_score":20}}`, map[string]ScoreThresholds{"batch": {50, 20}}, ""},
+		{`{"batch":{"healthy_score":50,"degraded_score":20},"api":{"healthy_score":90,"degraded_score":90}}`,
+			map[string]ScoreThresholds{"batch": {50, 20}, "api": {90, 90}}, ""},
+		{`{"batch":{"healthy_score":50,"degraded_score":60}}`, nil, "must not exceed healthy_score"},
+		{`{"":{"healthy_score":50,"degraded_score":20}}`, nil, "empty service name"},
+		{`[50, 20]`, nil, "invalid SERVICE_THRESHOLDS"},
+	}
+	for _, tt := range tests {
+		t.Setenv("ENABLE_TLS", "false")
+		t.Setenv("SERVICE_THRESHOLDS", tt.value)
+		config, err := loadConfig()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.value, err)
+			continue
+		}
+		if len(config.ServiceThresholds) != len(tt.want) {
+			t.Errorf("%s: loaded %v, want %v", tt.value, config.ServiceThresholds, tt.want)
+		}
+		for service, thresholds := range tt.want {
+			if config.ServiceThresholds[service] != thresholds {
+				t.Errorf("%s: %s loaded as %+v, want %+v", tt.value, service, config.ServiceThresholds[service], thresholds)
+			}
+		}
+	}
+}
+
+func TestHeartbeatKeepsThresholdStatus(t *testing.T) {
+	tests := []struct {
+		service      string
+		heartbeat    string
+		wantCurrent  string
+		wantStatuses int
+	}{
+		// The batch override scores 60 healthy where the client said unhealthy
+		{"batch", "unhealthy", "healthy", 1},
+		{"batch", "healthy", "healthy", 1},
+		// Without an override the client's status stands, heartbeat or not
+		{"api", "unhealthy", "unhealthy", 2},
+		{"api", "healthy", "healthy", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.service+"/"+tt.heartbeat, func(t *testing.T) {
+			ds := newTestServer(t, func(config *Config) {
+				config.ServiceThresholds = map[string]ScoreThresholds{"batch": {HealthyScore: 50, DegradedScore: 20}}
+			})
+			full := "healthy"
+			if tt.service == "batch" {
+				full = "unhealthy"
+			}
+			mustReport(t, ds, StatusRequest{ServiceName: tt.service, InstanceName: "i1", Status: full, HealthMetrics: &HealthMetrics{OverallScore: 60}})
+			for i := 0; i < 3; i++ {
+				mustReport(t, ds, StatusRequest{ServiceName: tt.service, InstanceName: "i1", Status: tt.heartbeat, Detail: reportDetailHeartbeat})
+			}
+
+			history, _, _ := ds.storage.GetHost(tt.service, "i1")
+			if history.CurrentStatus != tt.wantCurrent || len(history.Statuses) != tt.wantStatuses {
+				t.Errorf("current %s with %d statuses, want %s with %d", history.CurrentStatus, len(history.Statuses), tt.wantCurrent, tt.wantStatuses)
+			}
+		})
+	}
+}
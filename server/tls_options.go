@@ -0,0 +1,25 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+
+	"github.com/management/s01-shared"
+)
+
+// parseTLSOptions parses the configured minimum version and cipher suites,
+// and also refuses a TLS 1.2 suite list HTTP/2 cannot use
+func parseTLSOptions(minVersion, cipherSuites string) (uint16, []uint16, error) {
+	version, suites, err := shared.ParseTLSOptions(minVersion, cipherSuites)
+	if err != nil {
+		return 0, nil, err
+	}
+	// net/http refuses to serve HTTP/2 over TLS 1.2 without one of these, and
+	// the mTLS listener would only fail once it starts serving
+	if version < tls.VersionTLS13 && !slices.Contains(suites, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256) &&
+		!slices.Contains(suites, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256) {
+		return 0, nil, fmt.Errorf("cipher_suites must include TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, which HTTP/2 requires")
+	}
+	return version, suites, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTLSOptionsRequiresHTTP2Suite(t *testing.T) {
+	tests := []struct {
+		minVersion, suites string
+		wantErr            string
+	}{
+		{"1.2", "", ""},
+		{"1.2", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", ""},
+		{"1.2", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256", "HTTP/2 requires"},
+		// TLS 1.3 suites always satisfy HTTP/2, whatever the TLS 1.2 list says
+		{"1.3", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256", ""},
+		// Errors from the shared parser come through unchanged
+		{"1.1", "", "unsupported tls_min_version"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseTLSOptions(tt.minVersion, tt.suites)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%q %q: %v", tt.minVersion, tt.suites, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%q %q: err = %v, want %q", tt.minVersion, tt.suites, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfigRejectsBadTLSOptions(t *testing.T) {
+	t.Setenv("ENABLE_TLS", "false")
+	t.Setenv("CIPHER_SUITES", "TLS_NOT_A_SUITE")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "TLS_NOT_A_SUITE") {
+		t.Errorf("loadConfig with an unknown cipher suite = %v, want it refused at startup", err)
+	}
+}
+
+func TestServerEnforcesTLSOptions(t *testing.T) {
+	ca := newTestCA(t, "s01 test CA")
+	ds, url := newTLSTestServer(t, ca, func(config *Config) {
+		config.TLSMinVersion = "1.3"
+	})
+	if ds.tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("server MinVersion = %x, want TLS 1.3", ds.tlsConfig.MinVersion)
+	}
+
+	config := ca.clientConfig(t, &x509.Certificate{Subject: pkix.Name{CommonName: "web-w1"}})
+	state, err := handshake(url, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Version != tls.VersionTLS13 {
+		t.Errorf("negotiated %x, want TLS 1.3", state.Version)
+	}
+
+	// A client capped at TLS 1.2 cannot connect
+	config.MaxVersion = tls.VersionTLS12
+	if _, err := handshake(url, config); err == nil {
+		t.Error("TLS 1.2 client connected to a TLS 1.3-only server")
+	}
+}
+
+func TestServerOffersConfiguredSuites(t *testing.T) {
+	ca := newTestCA(t, "s01 test CA")
+	suite := uint16(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+	ds, url := newTLSTestServer(t, ca, func(config *Config) {
+		config.CipherSuites = tls.CipherSuiteName(suite)
+	})
+	if !reflect.DeepEqual(ds.tlsConfig.CipherSuites, []uint16{suite}) {
+		t.Fatalf("server CipherSuites = %x, want only %x", ds.tlsConfig.CipherSuites, suite)
+	}
+
+	config := ca.clientConfig(t, &x509.Certificate{Subject: pkix.Name{CommonName: "web-w1"}})
+	config.MaxVersion = tls.VersionTLS12
+
+	// connect offers the server a single TLS 1.2 suite
+	connect := func(offered uint16) (tls.ConnectionState, error) {
+		config.CipherSuites = []uint16{offered}
+		return handshake(url, config)
+	}
+	if state, err := connect(suite); err != nil || state.CipherSuite != suite {
+		t.Errorf("client offering the configured suite: %v", err)
+	}
+	if _, err := connect(tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305); err == nil {
+		t.Error("client offering only a suite left out of the configuration connected")
+	}
+}
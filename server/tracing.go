@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/management/s01-shared"
+)
+
+// spanContextKey is the context key of the request's server span
+type spanContextKey struct{}
+
+// withTracing runs next inside a server span named name, continuing the
+// client's trace when the request carries a valid traceparent header. The
+// request logger gains the trace ID so log lines can be joined to the trace.
+func (ds *S01Server) withTracing(name string, next http.HandlerFunc) http.HandlerFunc {
+	if ds.tracer == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var parent *shared.SpanContext
+		if sc, ok := shared.ParseTraceparent(r.Header.Get(shared.TraceparentHeader)); ok {
+			parent = &sc
+		}
+		s := ds.tracer.Start(name, shared.SpanKindServer, parent)
+		defer s.End()
+
+		logger := ds.requestLogger(r).With("trace_id", s.TraceID())
+		ctx := context.WithValue(r.Context(), spanContextKey{}, s)
+		ctx = context.WithValue(ctx, loggerContextKey{}, logger)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// requestSpan returns the request's server span, or nil when tracing is off
+func requestSpan(r *http.Request) *shared.Span {
+	s, _ := r.Context().Value(spanContextKey{}).(*shared.Span)
+	return s
+}
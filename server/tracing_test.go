@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/management/s01-shared"
+)
+
+// spanCollector is an OTLP/HTTP endpoint keeping every span exported to it
+type spanCollector struct {
+	mutex sync.Mutex
+	spans []shared.OTLPSpan
+}
+
+func newSpanCollector(t *testing.T) (*spanCollector, string) {
+	t.Helper()
+	collector := &spanCollector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("spans exported to %s", r.URL.Path)
+		}
+		var export shared.OTLPExport
+		if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+			t.Errorf("undecodable export: %v", err)
+		}
+		collector.mutex.Lock()
+		defer collector.mutex.Unlock()
+		for _, resource := range export.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				collector.spans = append(collector.spans, scope.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return collector, server.URL
+}
+
+// exported returns the spans received so far
+func (c *spanCollector) exported() []shared.OTLPSpan {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]shared.OTLPSpan(nil), c.spans...)
+}
+
+// attribute returns the string value of a span attribute
+func attribute(s shared.OTLPSpan, key string) string {
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			return attr.Value.StringValue
+		}
+	}
+	return ""
+}
+
+func TestReportSpanContinuesClientTrace(t *testing.T) {
+	collector, endpoint := newSpanCollector(t)
+	ds := newTestServer(t, func(config *Config) { config.OTLPEndpoint = endpoint })
+
+	const clientTrace, clientSpan = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	tests := []struct {
+		name        string
+		traceparent string
+		body        string
+		wantParent  bool
+		wantError   bool
+	}{
+		{"traced report", "00-" + clientTrace + "-" + clientSpan + "-01", `{"service_name":"web","instance_name":"w1","status":"healthy"}`, true, false},
+		{"untraced report", "", `{"service_name":"web","instance_name":"w2","status":"healthy"}`, false, false},
+		{"malformed traceparent", "00-nonsense-01", `{"service_name":"web","instance_name":"w3","status":"healthy"}`, false, false},
+		{"rejected report", "00-" + clientTrace + "-" + clientSpan + "-01", `{"service_name":"web","instance_name":"w4","status":"fine"}`, true, true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/report", strings.NewReader(tt.body))
+		if tt.traceparent != "" {
+			req.Header.Set(shared.TraceparentHeader, tt.traceparent)
+		}
+		ds.routes().ServeHTTP(httptest.NewRecorder(), req)
+		ds.tracer.Flush()
+
+		spans := collector.exported()
+		if len(spans) == 0 {
+			t.Fatalf("%s: no span exported", tt.name)
+		}
+		s := spans[len(spans)-1]
+		if s.Name != "POST /api/v1/report" || s.Kind != shared.SpanKindServer || s.SpanID == clientSpan {
+			t.Errorf("%s: span %s kind %d id %s, want a new server span", tt.name, s.Name, s.Kind, s.SpanID)
+		}
+		if tt.wantParent && (s.TraceID != clientTrace || s.ParentSpanID != clientSpan) {
+			t.Errorf("%s: span in trace %s under %q, want a child of the client span", tt.name, s.TraceID, s.ParentSpanID)
+		}
+		if !tt.wantParent && (s.TraceID == clientTrace || s.ParentSpanID != "") {
+			t.Errorf("%s: span in trace %s under %q, want a new root", tt.name, s.TraceID, s.ParentSpanID)
+		}
+		if attribute(s, "service_name") != "web" || attribute(s, "instance_name") == "" {
+			t.Errorf("%s: attributes %+v, want service_name and instance_name", tt.name, s.Attributes)
+		}
+		if (s.Status != nil && s.Status.Code == shared.OTLPStatusError) != tt.wantError {
+			t.Errorf("%s: span status %+v, want error %v", tt.name, s.Status, tt.wantError)
+		}
+	}
+}
+
+func TestTracingOffIsNoop(t *testing.T) {
+	ds := newTestServer(t, nil)
+	if ds.tracer != nil {
+		t.Fatal("tracer created without OTLP_ENDPOINT")
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/report",
+		strings.NewReader(`{"service_name":"web","instance_name":"w1","status":"healthy"}`))
+	req.Header.Set(shared.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	ds.routes().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("traced report without a tracer = %d", recorder.Code)
+	}
+	ds.tracer.Flush()
+}
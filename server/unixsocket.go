@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// listenUnixSocket listens on the Unix domain socket at path, first removing
+// a socket left behind by a previous run that did not shut down cleanly. Any
+// other file at path is left alone and makes the listen fail.
+func listenUnixSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %v", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	// Closing the listener on shutdown removes the socket file
+	listener.(*net.UnixListener).SetUnlinkOnClose(true)
+	return listener, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// socketDir is a temporary directory short enough for a socket path, which
+// t.TempDir can exceed
+func socketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "s01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(path string) error
+		wantErr bool
+	}{
+		{"nothing there", func(string) error { return nil }, false},
+		{"stale socket", func(path string) error {
+			// A crashed server leaves its socket file behind
+			listener, err := net.Listen("unix", path)
+			if err != nil {
+				return err
+			}
+			listener.(*net.UnixListener).SetUnlinkOnClose(false)
+			return listener.Close()
+		}, false},
+		{"regular file", func(path string) error { return os.WriteFile(path, []byte("keep me"), 0o644) }, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(socketDir(t), "s01.sock")
+		if err := tt.setup(path); err != nil {
+			t.Fatal(err)
+		}
+		listener, err := listenUnixSocket(path)
+		if tt.wantErr {
+			if err == nil {
+				listener.Close()
+				t.Errorf("%s: listen succeeded, want it refused", tt.name)
+			}
+			if data, _ := os.ReadFile(path); string(data) != "keep me" {
+				t.Errorf("%s: file at the socket path was changed to %q", tt.name, data)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		listener.Close()
+		if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: socket file still present after close (%v)", tt.name, err)
+		}
+	}
+}
+
+func TestStartServesUnixSocket(t *testing.T) {
+	socket := filepath.Join(socketDir(t), "s01.sock")
+	ds := newTestServer(t, func(config *Config) {
+		config.BindAddress = "127.0.0.1"
+		config.ServerPort, config.HealthPort = freePort(t), freePort(t)
+		config.UnixSocket = socket
+		config.DrainPeriod = 0
+	})
+	stop := startServer(t, ds, "http://127.0.0.1:"+ds.config.HealthPort+"/readyz")
+
+	var dialer net.Dialer
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Post("http://localhost/api/v1/report", "application/json",
+		strings.NewReader(`{"service_name":"sidecar","instance_name":"pod-1","status":"healthy"}`))
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("report over the socket = %d, want 200", resp.StatusCode)
+	}
+
+	// The report is visible on the TCP listener too
+	tcp, err := http.Get("http://127.0.0.1:" + ds.config.ServerPort + "/api/v1/hosts/sidecar/pod-1/latest")
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	tcp.Body.Close()
+	if tcp.StatusCode != http.StatusOK {
+		t.Errorf("socket-reported host over TCP = %d, want 200", tcp.StatusCode)
+	}
+
+	stop()
+	if _, err := os.Lstat(socket); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("socket file left after shutdown (%v)", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookEvent is the payload POSTed to the webhook on a status transition
+type WebhookEvent struct {
+	ServiceName  string    `json:"service_name"`
+	InstanceName string    `json:"instance_name"`
+	OldStatus    string    `json:"old_status"`
+	NewStatus    string    `json:"new_status"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// webhookNotifier POSTs an event when a host enters or leaves the unhealthy
+// or stale state
+type webhookNotifier struct {
+	url      string
+	debounce time.Duration
+	stale    string // status the sweeper gives hosts that stopped reporting
+	client   *http.Client
+	logger   *slog.Logger
+
+	mutex    sync.Mutex
+	lastSent map[string]time.Time // keyed by host and transition
+}
+
+// newWebhookNotifier creates a notifier; a nil notifier ignores all observations
+func newWebhookNotifier(url string, debounce time.Duration, staleStatus string, logger *slog.Logger) *webhookNotifier {
+	if url == "" {
+		return nil
+	}
+	return &webhookNotifier{
+		url:      url,
+		debounce: debounce,
+		stale:    staleStatus,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// isAlertStatus reports whether a status should raise an alert
+func (wn *webhookNotifier) isAlertStatus(status string) bool {
+	return status == "unhealthy" || status == wn.stale
+}
+
+// observe notifies the webhook of a change of a host's current status as
+// returned by storage. A host's first status is not a transition. The same
+// transition is not repeated for a host within the debounce window.
+func (wn *webhookNotifier) observe(serviceName, instanceName string, change StatusChange, at time.Time) {
+	if wn == nil {
+		return
+	}
+
+	previous, status := change.Previous, change.Current
+	if previous == "" || previous == status || !(wn.isAlertStatus(previous) || wn.isAlertStatus(status)) {
+		return
+	}
+
+	transition := hostKey(serviceName, instanceName) + "|" + previous + "|" + status
+	wn.mutex.Lock()
+	if sentAt, sent := wn.lastSent[transition]; sent && at.Sub(sentAt) < wn.debounce {
+		wn.mutex.Unlock()
+		wn.logger.Debug("Suppressing repeated webhook transition",
+			"service_name", serviceName,
+			"instance_name", instanceName,
+			"old_status", previous,
+			"new_status", status,
+		)
+		return
+	}
+	wn.lastSent[transition] = at
+	wn.mutex.Unlock()
+
+	go wn.send(WebhookEvent{
+		ServiceName:  serviceName,
+		InstanceName: instanceName,
+		OldStatus:    previous,
+		NewStatus:    status,
+		Timestamp:    at,
+	})
+}
+
+// forget drops a host's sent transitions
+func (wn *webhookNotifier) forget(serviceName, instanceName string) {
+	if wn == nil {
+		return
+	}
+
+	key := hostKey(serviceName, instanceName)
+
+	wn.mutex.Lock()
+	defer wn.mutex.Unlock()
+
+	for transition := range wn.lastSent {
+		if strings.HasPrefix(transition, key+"|") {
+			delete(wn.lastSent, transition)
+		}
+	}
+}
+
+// send POSTs an event to the webhook; failures are logged and not retried
+func (wn *webhookNotifier) send(event WebhookEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		wn.logger.Error("Failed to encode webhook event", "error", err)
+		return
+	}
+
+	resp, err := wn.client.Post(wn.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		wn.logger.Warn("Webhook delivery failed",
+			"service_name", event.ServiceName,
+			"instance_name", event.InstanceName,
+			"error", err,
+		)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		wn.logger.Warn("Webhook rejected event",
+			"service_name", event.ServiceName,
+			"instance_name", event.InstanceName,
+			"status_code", resp.StatusCode,
+		)
+		return
+	}
+
+	wn.logger.Info("Webhook notified of status transition",
+		"service_name", event.ServiceName,
+		"instance_name", event.InstanceName,
+		"old_status", event.OldStatus,
+		"new_status", event.NewStatus,
+	)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// webhookEvents starts a webhook receiver and returns its URL and the events it gets
+func webhookEvents(t *testing.T) (string, <-chan WebhookEvent) {
+	t.Helper()
+	events := make(chan WebhookEvent, 16)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event WebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decode webhook event: %v", err)
+		}
+		events <- event
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver.URL, events
+}
+
+func TestWebhookFollowsSmoothedStatus(t *testing.T) {
+	url, events := webhookEvents(t)
+	ds := newTestServer(t, func(config *Config) {
+		config.WebhookURL = url
+		config.StatusSmoothing = smoothingMajority
+		config.SmoothingWindow = 3
+	})
+
+	// One unhealthy report in a healthy window does not move the current status
+	for _, status := range []string{"healthy", "healthy", "unhealthy"} {
+		mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: status})
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("webhook got %s -> %s for a status smoothing kept healthy", event.OldStatus, event.NewStatus)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mustReport(t, ds, StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "unhealthy"})
+	select {
+	case event := <-events:
+		if event.OldStatus != "healthy" || event.NewStatus != "unhealthy" {
+			t.Errorf("event = %s -> %s, want healthy -> unhealthy", event.OldStatus, event.NewStatus)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no webhook event once the majority turned unhealthy")
+	}
+}
+
+func TestWebhookTransitionSequence(t *testing.T) {
+	url, events := webhookEvents(t)
+	notifier := newWebhookNotifier(url, time.Minute, "lost", discardLogger)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after  time.Duration
+		status string
+		want   string // "old -> new" expected on the webhook, empty for none
+	}{
+		{0, "healthy", ""}, // first sighting
+		{time.Second, "degraded", ""},
+		{2 * time.Second, "unhealthy", "degraded -> unhealthy"},
+		{3 * time.Second, "healthy", "unhealthy -> healthy"},
+		{10 * time.Second, "unhealthy", "healthy -> unhealthy"},
+		{20 * time.Second, "healthy", ""},   // unhealthy -> healthy debounced
+		{30 * time.Second, "unhealthy", ""}, // healthy -> unhealthy debounced
+		{30 * time.Second, "unhealthy", ""}, // no change
+		{2 * time.Minute, "lost", "unhealthy -> lost"},
+		{3 * time.Minute, "healthy", "lost -> healthy"},
+		{4 * time.Minute, "unhealthy", "healthy -> unhealthy"}, // debounce window passed
+	}
+	previous := ""
+	for _, step := range steps {
+		notifier.observe("web", "w1", StatusChange{Previous: previous, Current: step.status}, start.Add(step.after))
+		previous = step.status
+		if step.want == "" {
+			continue
+		}
+		select {
+		case event := <-events:
+			got := event.OldStatus + " -> " + event.NewStatus
+			if got != step.want || event.ServiceName != "web" || event.InstanceName != "w1" || !event.Timestamp.Equal(start.Add(step.after)) {
+				t.Fatalf("at %v: event %s for %s/%s at %v, want %s", step.after, got, event.ServiceName, event.InstanceName, event.Timestamp, step.want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("at %v: no webhook event, want %s", step.after, step.want)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %s -> %s", event.OldStatus, event.NewStatus)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Without a URL the notifier is nil and ignores everything
+	disabled := newWebhookNotifier("", time.Minute, "lost", discardLogger)
+	disabled.observe("web", "w1", StatusChange{Previous: "healthy", Current: "unhealthy"}, start)
+	disabled.forget("web", "w1")
+}
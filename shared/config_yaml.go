@@ -0,0 +1,111 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// UnmarshalConfig decodes a config file into v, choosing YAML or JSON by the
+// file extension
+func UnmarshalConfig(path string, data []byte, v interface{}) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return UnmarshalYAML(data, v)
+	default:
+		return json.Unmarshal(data, v)
+	}
+}
+
+// UnmarshalYAML decodes the flat YAML subset used by config files into the
+// struct v: one "key: value" pair per line, with '#' comments and optionally
+// quoted scalar values. Keys match the struct's JSON tags.
+func UnmarshalYAML(data []byte, v interface{}) error {
+	kinds := jsonFieldKinds(v)
+	fields := make(map[string]json.RawMessage)
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripYAMLComment(line))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("line %d: expected \"key: value\"", i+1)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		kind, known := kinds[key]
+		if !known {
+			continue
+		}
+
+		unquoted := value
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			unquoted = value[1 : len(value)-1]
+		}
+
+		// Map-valued settings take either an inline JSON object or the same
+		// "key=value,..." string as their environment variable
+		var raw []byte
+		switch {
+		case kind == reflect.String, kind == reflect.Map && !strings.HasPrefix(value, "{"):
+			raw, _ = json.Marshal(unquoted)
+		default:
+			raw = []byte(unquoted)
+		}
+		if !json.Valid(raw) {
+			return fmt.Errorf("line %d: invalid value for %s: %q", i+1, key, value)
+		}
+		fields[key] = raw
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, v)
+}
+
+// stripYAMLComment removes a trailing '#' comment that is not inside quotes
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// jsonFieldKinds maps each JSON field name of the struct v points to onto its kind
+func jsonFieldKinds(v interface{}) map[string]reflect.Kind {
+	kinds := make(map[string]reflect.Kind)
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return kinds
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		kinds[name] = field.Type.Kind()
+	}
+	return kinds
+}
@@ -0,0 +1,95 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testLabels stands in for a map setting that, like the client's labels,
+// also accepts its environment variable's "key=value,..." form
+type testLabels map[string]string
+
+func (l *testLabels) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return json.Unmarshal(data, (*map[string]string)(l))
+	}
+	*l = make(testLabels)
+	for _, item := range strings.Split(text, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", item)
+		}
+		(*l)[key] = value
+	}
+	return nil
+}
+
+type testConfig struct {
+	Name       string                    `json:"name"`
+	Interval   int                       `json:"interval"`
+	Enabled    bool                      `json:"enabled"`
+	Alpha      float64                   `json:"alpha"`
+	Pattern    string                    `json:"pattern"`
+	Labels     testLabels                `json:"labels"`
+	Thresholds map[string]map[string]int `json:"thresholds"`
+}
+
+func TestUnmarshalYAMLMatchesJSON(t *testing.T) {
+	const yaml = `---
+# settings
+name: "billing"
+interval: 45   # seconds
+enabled: true
+alpha: 0.5
+pattern: '\bFATAL\b # not a comment'
+labels: zone=us-east-1a,tier=web
+thresholds: {"web": {"healthy": 80}}
+unknown: ignored
+`
+	const jsonConfig = `{
+	"name": "billing",
+	"interval": 45,
+	"enabled": true,
+	"alpha": 0.5,
+	"pattern": "\\bFATAL\\b # not a comment",
+	"labels": {"tier": "web", "zone": "us-east-1a"},
+	"thresholds": {"web": {"healthy": 80}}
+}`
+
+	var fromJSON, fromYAML, fromYML testConfig
+	if err := UnmarshalConfig("config.json", []byte(jsonConfig), &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalConfig("config.yaml", []byte(yaml), &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalConfig("config.YML", []byte(yaml), &fromYML); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) || !reflect.DeepEqual(fromYML, fromJSON) {
+		t.Errorf("YAML = %+v\nYML = %+v\nwant the JSON file's %+v", fromYAML, fromYML, fromJSON)
+	}
+}
+
+func TestUnmarshalYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"missing colon", "interval 25\n"},
+		{"number expected", "interval: lots\n"},
+		{"bool expected", "enabled: maybe\n"},
+		{"bad label list", "labels: zone\n"},
+		{"object expected", "thresholds: web=80\n"},
+	}
+	for _, tt := range tests {
+		var config testConfig
+		if err := UnmarshalYAML([]byte(tt.yaml), &config); err == nil {
+			t.Errorf("%s: %q decoded without error", tt.name, tt.yaml)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/management/s01-shared
+
+go 1.21
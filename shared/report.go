@@ -0,0 +1,68 @@
+// Package shared holds the report payload the client sends and the server
+// decodes, so both sides serialize it identically, and the code both sides
+// run alike, such as the OTLP tracer, the TLS option parsing and the config
+// file decoding
+package shared
+
+import "time"
+
+// StatusRequest is the status report a client sends to the server
+type StatusRequest struct {
+	ServiceName   string            `json:"service_name"`
+	InstanceName  string            `json:"instance_name"`
+	Status        string            `json:"status"`
+	Detail        string            `json:"detail,omitempty"`    // ReportDetailFull (default) or ReportDetailHeartbeat
+	Timestamp     *time.Time        `json:"timestamp,omitempty"` // when the client took the report; kept when it is buffered
+	Sequence      uint64            `json:"sequence,omitempty"`  // increases with every report from a host; 0 skips the ordering check
+	Labels        map[string]string `json:"labels,omitempty"`
+	HealthMetrics *HealthMetrics    `json:"health_metrics,omitempty"`
+	RecentErrors  *RecentErrors     `json:"recent_errors,omitempty"`
+	KernelVersion string            `json:"kernel_version,omitempty"`
+	OSRelease     string            `json:"os_release,omitempty"`
+	Arch          string            `json:"arch,omitempty"`
+	MetricsOnly   bool              `json:"metrics_only,omitempty"` // Status is omitted and the host keeps its recorded one; only HealthMetrics and RecentErrors are updated
+}
+
+// Report detail levels
+const (
+	ReportDetailFull      = "full"
+	ReportDetailHeartbeat = "heartbeat"
+)
+
+// StatusResponse is the server's reply to an accepted report
+type StatusResponse struct {
+	Status              string `json:"status"`
+	NextIntervalSeconds int    `json:"next_interval_seconds,omitempty"` // report interval the server asks for; 0 leaves the client's own
+}
+
+// HealthCheck represents a single health check result
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+// HealthMetrics contains system health metrics
+type HealthMetrics struct {
+	CPUUsage       float64             `json:"cpu_usage"`
+	MemoryUsage    float64             `json:"memory_usage"`
+	DiskUsage      float64             `json:"disk_usage"`
+	NetworkOk      bool                `json:"network_ok"`
+	Checks         []HealthCheck       `json:"checks"`
+	OverallScore   int                 `json:"overall_score"`
+	ScoreBreakdown []ScoreContribution `json:"score_breakdown,omitempty"`
+}
+
+// ScoreContribution records how many points a check contributed out of its weight
+type ScoreContribution struct {
+	Check     string `json:"check"`
+	Points    int    `json:"points"`
+	MaxPoints int    `json:"max_points"`
+}
+
+// RecentErrors summarizes error lines a host logged since its previous report
+type RecentErrors struct {
+	Count   int      `json:"count"`
+	Samples []string `json:"samples,omitempty"`
+}
@@ -0,0 +1,89 @@
+package shared
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusRequestRoundTrip(t *testing.T) {
+	takenAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		req  StatusRequest
+	}{
+		{"full report", StatusRequest{
+			ServiceName:  "web",
+			InstanceName: "w1",
+			Status:       "degraded",
+			Detail:       ReportDetailFull,
+			Timestamp:    &takenAt,
+			Sequence:     42,
+			Labels:       map[string]string{"region": "us-east"},
+			HealthMetrics: &HealthMetrics{
+				CPUUsage:    12.5,
+				MemoryUsage: 81.25,
+				DiskUsage:   40,
+				NetworkOk:   true,
+				Checks: []HealthCheck{
+					{Name: "Memory Usage", Status: "degraded", Message: "Memory usage high", Value: "81.2%"},
+					{Name: "Network Connectivity", Status: "healthy"},
+				},
+				OverallScore:   75,
+				ScoreBreakdown: []ScoreContribution{{Check: "Memory Usage", Points: 10, MaxPoints: 20}},
+			},
+			RecentErrors:  &RecentErrors{Count: 3, Samples: []string{"ERROR disk full"}},
+			KernelVersion: "6.8.0",
+			OSRelease:     "Ubuntu 24.04",
+			Arch:          "amd64",
+		}},
+		{"heartbeat", StatusRequest{ServiceName: "web", InstanceName: "w1", Status: "healthy", Detail: ReportDetailHeartbeat, Sequence: 43}},
+		{"metrics only", StatusRequest{ServiceName: "web", InstanceName: "w1", MetricsOnly: true, HealthMetrics: &HealthMetrics{Checks: []HealthCheck{}}}},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got StatusRequest
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.req) {
+			t.Errorf("%s: decoded %+v\nwant %+v", tt.name, got, tt.req)
+		}
+	}
+}
+
+// The wire names are what older clients and servers speak, so they must not
+// change with the Go field names
+func TestStatusRequestWireNames(t *testing.T) {
+	takenAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(StatusRequest{
+		ServiceName: "web", InstanceName: "w1", Status: "healthy", Timestamp: &takenAt,
+		HealthMetrics: &HealthMetrics{
+			Checks:         []HealthCheck{{Name: "CPU Usage", Status: "healthy", Message: "ok", Value: "3%"}},
+			ScoreBreakdown: []ScoreContribution{{Check: "CPU Usage", Points: 20, MaxPoints: 20}},
+		},
+		RecentErrors: &RecentErrors{Count: 1, Samples: []string{"x"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"service_name":"web","instance_name":"w1","status":"healthy","timestamp":"2026-03-01T12:00:00Z",` +
+		`"health_metrics":{"cpu_usage":0,"memory_usage":0,"disk_usage":0,"network_ok":false,` +
+		`"checks":[{"name":"CPU Usage","status":"healthy","message":"ok","value":"3%"}],"overall_score":0,` +
+		`"score_breakdown":[{"check":"CPU Usage","points":20,"max_points":20}]},` +
+		`"recent_errors":{"count":1,"samples":["x"]}}`
+	if string(data) != want {
+		t.Errorf("encoded\n%s\nwant\n%s", data, want)
+	}
+
+	// A server reply decodes with unknown fields ignored
+	var resp StatusResponse
+	if err := json.NewDecoder(strings.NewReader(`{"status":"ok","next_interval_seconds":60,"extra":1}`)).Decode(&resp); err != nil || resp != (StatusResponse{Status: "ok", NextIntervalSeconds: 60}) {
+		t.Errorf("decoded reply %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,90 @@
+package shared
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// DefaultCipherSuites are the TLS 1.2 suites offered when no suites are
+// configured
+var DefaultCipherSuites = []uint16{
+	// HTTP/2 required cipher suites
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	// Additional secure cipher suites
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+// ParseTLSVersion maps "1.2" or "1.3" (optionally prefixed with "TLS") to a
+// tls.Version constant
+func ParseTLSVersion(name string) (uint16, error) {
+	version := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "TLS")
+	switch strings.TrimSpace(version) {
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls_min_version %q (expected 1.2 or 1.3)", name)
+	}
+}
+
+// ParseCipherSuites maps a comma-separated list of cipher suite names, e.g.
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", to their IDs. An empty list
+// selects DefaultCipherSuites. Suites Go considers insecure are refused.
+// TLS 1.3 suites are accepted but not returned, as Go always enables all of
+// them and only applies the list to TLS 1.2.
+func ParseCipherSuites(names string) ([]uint16, error) {
+	if strings.TrimSpace(names) == "" {
+		return DefaultCipherSuites, nil
+	}
+
+	known := make(map[string]*tls.CipherSuite)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		suite, ok := known[name]
+		switch {
+		case insecure[name]:
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		case !ok:
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		case len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13:
+			continue
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+// ParseTLSOptions parses the configured minimum version and cipher suites
+func ParseTLSOptions(minVersion, cipherSuites string) (uint16, []uint16, error) {
+	version, err := ParseTLSVersion(minVersion)
+	if err != nil {
+		return 0, nil, err
+	}
+	suites, err := ParseCipherSuites(cipherSuites)
+	if err != nil {
+		return 0, nil, err
+	}
+	// An empty list would silently fall back to Go's own TLS 1.2 suites
+	if version < tls.VersionTLS13 && len(suites) == 0 {
+		return 0, nil, fmt.Errorf("cipher_suites names no TLS 1.2 suite; list one or set tls_min_version to 1.3")
+	}
+	return version, suites, nil
+}
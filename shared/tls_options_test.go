@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"crypto/tls"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTLSOptions(t *testing.T) {
+	tests := []struct {
+		minVersion, suites string
+		wantVersion        uint16
+		wantSuites         []uint16
+		wantErr            string
+	}{
+		{"1.2", "", tls.VersionTLS12, DefaultCipherSuites, ""},
+		{"TLS1.3", "", tls.VersionTLS13, DefaultCipherSuites, ""},
+		{" tls 12 ", "", tls.VersionTLS12, DefaultCipherSuites, ""},
+		{"1.2", "tls_ecdhe_ecdsa_with_aes_128_gcm_sha256, TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+			tls.VersionTLS12, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305}, ""},
+		{"1.2", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_AES_256_GCM_SHA384",
+			tls.VersionTLS12, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, ""},
+		// TLS 1.3 suites are always on, so they are accepted and left out
+		{"1.3", "TLS_AES_128_GCM_SHA256", tls.VersionTLS13, nil, ""},
+		{"1.0", "", 0, nil, "unsupported tls_min_version"},
+		{"1.2", "TLS_MADE_UP_SHA1", 0, nil, "unknown cipher suite"},
+		{"1.2", "AES128-GCM-SHA256", 0, nil, "unknown cipher suite"},
+		{"1.2", "TLS_RSA_WITH_RC4_128_SHA", 0, nil, "insecure"},
+		{"1.2", "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256", 0, nil, "insecure"},
+		{"1.2", "TLS_AES_128_GCM_SHA256", 0, nil, "names no TLS 1.2 suite"},
+	}
+	for _, tt := range tests {
+		version, suites, err := ParseTLSOptions(tt.minVersion, tt.suites)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q %q: err = %v, want %q", tt.minVersion, tt.suites, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %q: %v", tt.minVersion, tt.suites, err)
+			continue
+		}
+		if version != tt.wantVersion || !reflect.DeepEqual(suites, tt.wantSuites) {
+			t.Errorf("%q %q = %x %x, want %x %x", tt.minVersion, tt.suites, version, suites, tt.wantVersion, tt.wantSuites)
+		}
+	}
+}
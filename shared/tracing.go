@@ -0,0 +1,309 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader carries the W3C Trace Context of a request
+const TraceparentHeader = "traceparent"
+
+// OTLP span kinds
+const (
+	SpanKindServer = 2 // a span around a handled request
+	SpanKindClient = 3 // a span around an outgoing request
+)
+
+// OTLPStatusError is the OTLP status code of a failed span
+const OTLPStatusError = 2
+
+const (
+	traceFlushInterval = 5 * time.Second // how often finished spans are exported
+	maxPendingSpans    = 2048            // finished spans held for export; newer ones are dropped beyond this
+)
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// ParseTraceparent decodes a W3C traceparent header value
+// ("00-<trace-id>-<span-id>-<flags>"); ok is false when it is malformed
+func ParseTraceparent(value string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return sc, false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	if hex.DecodedLen(len(parts[1])) != len(sc.TraceID) || hex.DecodedLen(len(parts[2])) != len(sc.SpanID) {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	if sc.TraceID == [16]byte{} || sc.SpanID == [8]byte{} {
+		return sc, false
+	}
+	return sc, true
+}
+
+// Traceparent encodes sc as a sampled W3C traceparent header value
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%x-%x-01", sc.TraceID, sc.SpanID)
+}
+
+// Span is one timed operation. All methods are no-ops on a nil span, which is
+// what a nil tracer starts.
+type Span struct {
+	tracer     *Tracer
+	name       string
+	kind       int
+	context    SpanContext
+	parentID   [8]byte // zero for a root span
+	start      time.Time
+	attributes []OTLPAttribute
+	errMessage string
+}
+
+// TraceID returns the hex trace ID of the span, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.context.TraceID[:])
+}
+
+// SetAttribute records a string attribute on the span
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.attributes = append(s.attributes, OTLPAttribute{Key: key, Value: OTLPValue{StringValue: value}})
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.errMessage = message
+}
+
+// Inject sets the traceparent header so the receiver can continue the trace
+func (s *Span) Inject(header http.Header) {
+	if s == nil {
+		return
+	}
+	header.Set(TraceparentHeader, s.context.Traceparent())
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.tracer.record(s, time.Now())
+}
+
+// Tracer collects finished spans and periodically exports them to an
+// OpenTelemetry collector as OTLP/HTTP JSON
+type Tracer struct {
+	url         string
+	serviceName string
+	client      *http.Client
+	logger      *slog.Logger
+
+	mutex   sync.Mutex
+	pending []OTLPSpan
+	dropped int
+}
+
+// NewTracer creates a tracer exporting to the collector at endpoint; a nil
+// tracer, returned when endpoint is empty, starts nil spans
+func NewTracer(endpoint, serviceName string, logger *slog.Logger) *Tracer {
+	if endpoint == "" {
+		return nil
+	}
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &Tracer{
+		url:         url,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		logger:      logger,
+	}
+}
+
+// Start begins a span, as a child of parent when it is non-nil and as the
+// root of a new trace otherwise
+func (t *Tracer) Start(name string, kind int, parent *SpanContext) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if parent != nil {
+		s.context.TraceID = parent.TraceID
+		s.parentID = parent.SpanID
+	} else {
+		rand.Read(s.context.TraceID[:])
+	}
+	rand.Read(s.context.SpanID[:])
+	return s
+}
+
+// record queues a finished span for export
+func (t *Tracer) record(s *Span, end time.Time) {
+	exported := OTLPSpan{
+		TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        s.attributes,
+	}
+	if s.parentID != [8]byte{} {
+		exported.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if s.errMessage != "" {
+		exported.Status = &OTLPStatus{Code: OTLPStatusError, Message: s.errMessage}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.pending) >= maxPendingSpans {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, exported)
+}
+
+// Run exports finished spans every traceFlushInterval until ctx is done
+func (t *Tracer) Run(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush exports all queued spans; failed exports are logged and not retried
+func (t *Tracer) Flush() {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	spans, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mutex.Unlock()
+
+	if dropped > 0 {
+		t.logger.Warn("Dropped trace spans; export queue full", "dropped", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(OTLPExport{ResourceSpans: []OTLPResourceSpans{{
+		Resource: OTLPResource{Attributes: []OTLPAttribute{
+			{Key: "service.name", Value: OTLPValue{StringValue: t.serviceName}},
+		}},
+		ScopeSpans: []OTLPScopeSpans{{Scope: OTLPScope{Name: t.serviceName}, Spans: spans}},
+	}}})
+	if err != nil {
+		t.logger.Error("Failed to encode trace spans", "error", err)
+		return
+	}
+
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.logger.Warn("Trace export failed", "spans", len(spans), "error", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		t.logger.Warn("Trace collector rejected spans", "spans", len(spans), "status_code", resp.StatusCode)
+		return
+	}
+	t.logger.Debug("Exported trace spans", "spans", len(spans))
+}
+
+// OTLPExport is an OTLP/HTTP JSON export request, see opentelemetry-proto
+// trace/v1. The types below are its parts.
+type OTLPExport struct {
+	ResourceSpans []OTLPResourceSpans `json:"resourceSpans"`
+}
+
+type OTLPResourceSpans struct {
+	Resource   OTLPResource     `json:"resource"`
+	ScopeSpans []OTLPScopeSpans `json:"scopeSpans"`
+}
+
+type OTLPResource struct {
+	Attributes []OTLPAttribute `json:"attributes"`
+}
+
+type OTLPScopeSpans struct {
+	Scope OTLPScope  `json:"scope"`
+	Spans []OTLPSpan `json:"spans"`
+}
+
+type OTLPScope struct {
+	Name string `json:"name"`
+}
+
+type OTLPSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []OTLPAttribute `json:"attributes,omitempty"`
+	Status            *OTLPStatus     `json:"status,omitempty"`
+}
+
+type OTLPAttribute struct {
+	Key   string    `json:"key"`
+	Value OTLPValue `json:"value"`
+}
+
+type OTLPValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type OTLPStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
@@ -0,0 +1,152 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value  string
+		wantOK bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{" 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00 ", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		sc, ok := ParseTraceparent(tt.value)
+		if ok != tt.wantOK {
+			t.Errorf("ParseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			continue
+		}
+		if ok && strings.HasPrefix(tt.value, "00-") && sc.Traceparent()[:52] != tt.value[:52] {
+			t.Errorf("%q round-trips as %q", tt.value, sc.Traceparent())
+		}
+	}
+}
+
+// collect starts an OTLP/HTTP endpoint and returns its URL and a function
+// returning the export paths and spans received so far
+func collect(t *testing.T) (string, func() ([]string, []OTLPSpan)) {
+	t.Helper()
+	var mutex sync.Mutex
+	var paths []string
+	var spans []OTLPSpan
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var export OTLPExport
+		if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+			t.Errorf("undecodable export: %v", err)
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		paths = append(paths, r.URL.Path)
+		for _, resource := range export.ResourceSpans {
+			if name := resource.Resource.Attributes[0].Value.StringValue; name != "s01-test" {
+				t.Errorf("spans exported as service %q", name)
+			}
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() ([]string, []OTLPSpan) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string(nil), paths...), append([]OTLPSpan(nil), spans...)
+	}
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestTracerExportsSpans(t *testing.T) {
+	for _, suffix := range []string{"", "/", "/v1/traces"} {
+		url, received := collect(t)
+		tracer := NewTracer(url+suffix, "s01-test", discardLogger)
+
+		root := tracer.Start("report status", SpanKindClient, nil)
+		root.SetAttribute("service_name", "web")
+		header := make(http.Header)
+		root.Inject(header)
+		root.End()
+
+		parent, ok := ParseTraceparent(header.Get(TraceparentHeader))
+		if !ok {
+			t.Fatalf("injected traceparent %q does not parse", header.Get(TraceparentHeader))
+		}
+		child := tracer.Start("POST /api/v1/report", SpanKindServer, &parent)
+		child.SetError("rejected")
+		child.End()
+		tracer.Flush()
+		tracer.Flush() // nothing left to export
+
+		paths, spans := received()
+		if len(paths) != 1 || paths[0] != "/v1/traces" {
+			t.Errorf("endpoint %q: exports to %v, want one to /v1/traces", suffix, paths)
+		}
+		if len(spans) != 2 {
+			t.Fatalf("endpoint %q: %d spans exported, want 2", suffix, len(spans))
+		}
+		r, c := spans[0], spans[1]
+		if r.Kind != SpanKindClient || r.ParentSpanID != "" || r.Status != nil || len(r.Attributes) != 1 || r.TraceID != root.TraceID() {
+			t.Errorf("root span = %+v, want a successful client span with its attribute", r)
+		}
+		if c.Kind != SpanKindServer || c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID || c.SpanID == r.SpanID {
+			t.Errorf("child span = %+v, want a new span under %s in trace %s", c, r.SpanID, r.TraceID)
+		}
+		if c.Status == nil || c.Status.Code != OTLPStatusError || c.Status.Message != "rejected" {
+			t.Errorf("child status = %+v, want the error", c.Status)
+		}
+	}
+}
+
+func TestTracerDropsSpansPastQueueLimit(t *testing.T) {
+	url, received := collect(t)
+	tracer := NewTracer(url, "s01-test", discardLogger)
+	for i := 0; i < maxPendingSpans+5; i++ {
+		tracer.Start("span", SpanKindClient, nil).End()
+	}
+	if tracer.dropped != 5 {
+		t.Errorf("%d spans dropped, want the 5 past the limit", tracer.dropped)
+	}
+	tracer.Flush()
+	if _, spans := received(); len(spans) != maxPendingSpans || tracer.dropped != 0 {
+		t.Errorf("%d spans exported and %d still counted as dropped, want %d and 0", len(spans), tracer.dropped, maxPendingSpans)
+	}
+}
+
+func TestNilTracerIsNoop(t *testing.T) {
+	tracer := NewTracer("", "s01-test", discardLogger)
+	if tracer != nil {
+		t.Fatal("tracer created without an endpoint")
+	}
+	span := tracer.Start("report status", SpanKindClient, nil)
+	if span != nil {
+		t.Fatal("nil tracer started a span")
+	}
+	header := make(http.Header)
+	span.SetAttribute("service_name", "web")
+	span.SetError("failed")
+	span.Inject(header)
+	span.End()
+	if header.Get(TraceparentHeader) != "" || span.TraceID() != "" {
+		t.Error("nil span set a traceparent or has a trace ID")
+	}
+	tracer.Flush()
+	tracer.Run(context.Background()) // returns at once
+}
@@ -23,6 +23,7 @@ TEST_TIMEOUT="${TEST_TIMEOUT:-300}"
 PARALLEL_TESTS="${PARALLEL_TESTS:-4}"
 RESULTS_DIR="${RESULTS_DIR:-/results}"
 LOG_DIR="${LOG_DIR:-/tmp/test-logs}"
+UNIX_SOCKET="${UNIX_SOCKET:-}"
 
 # Test counters
 TOTAL_TESTS=0
@@ -524,6 +525,1002 @@ test_error_handling() {
     fi
 }
 
+# Test: Label predicates on service instances
+test_service_instances_match() {
+    local test_name="Service Instances Label Match"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local service="match-test-$$"
+    local instance zone
+    for instance in east west; do
+        [ "$instance" = "east" ] && zone="us-east-1a" || zone="us-west-2a"
+        curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"$service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"labels\": {\"zone\": \"$zone\"}}" \
+            "$SERVER_URL/api/v1/report"
+    done
+
+    local url="$SERVER_URL/api/v1/services/$service/instances"
+    local same_zone=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$url?match=zone=us-east-1a" 2>/dev/null | jq -r '[.hosts[].instance_name] | join(",")')
+    local other_zone=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$url?match=zone!=us-east-1a" 2>/dev/null | jq -r '[.hosts[].instance_name] | join(",")')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$same_zone" = "east" ] && [ "$other_zone" = "west" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "match=zone=us-east-1a gave '$same_zone', zone!=us-east-1a gave '$other_zone'"
+        return 1
+    fi
+}
+
+# Test: Recent log errors are stored with the report
+test_recent_errors() {
+    local test_name="Recent Errors Reporting"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="errors-$$"
+    curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"degraded\", \"recent_errors\": {\"count\": 3, \"samples\": [\"ERROR disk full\"]}}" \
+        "$SERVER_URL/api/v1/report"
+
+    local errors=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance" 2>/dev/null | \
+        jq -r '.statuses[-1].recent_errors | "\(.count) \(.samples[0])"')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$errors" = "3 ERROR disk full" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "Stored recent errors: '$errors'"
+        return 1
+    fi
+}
+
+# Test: Hosts carry their kernel and can be filtered by its prefix
+test_kernel_inventory() {
+    local test_name="Kernel Inventory Filter"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="kernel-$$"
+    local kernel="9.99.$$-test"
+    curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"kernel_version\": \"$kernel\", \"arch\": \"riscv64\"}" \
+        "$SERVER_URL/api/v1/report"
+
+    local matched=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts?kernel=9.99.$$" 2>/dev/null | \
+        jq -r '[.hosts[] | "\(.instance_name)/\(.arch)"] | join(",")')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$matched" = "$instance/riscv64" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "kernel=9.99.$$ matched '$matched'"
+        return 1
+    fi
+}
+
+# Test: Reports stamped long ago are refused when MAX_REPORT_AGE is set
+test_max_report_age() {
+    local test_name="Max Report Age"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local body=$(curl -s -w "\n%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"backfill-$$\", \"status\": \"healthy\", \"timestamp\": \"2001-01-01T00:00:00Z\"}" \
+        "$SERVER_URL/api/v1/report")
+    local code=$(echo "$body" | tail -n 1)
+    local error_code=$(echo "$body" | head -n -1 | jq -r '.error.code // empty' 2>/dev/null)
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$code" = "400" ] && [ "$error_code" = "stale_report" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    elif [ "$code" = "200" ] || [ "$code" = "204" ]; then
+        add_test_result "$test_name" "skip" "$duration" "MAX_REPORT_AGE is not set on the server"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "Expected 400 stale_report, got $code '$error_code'"
+        return 1
+    fi
+}
+
+# Test: The per-check score breakdown comes back with the host's latest report
+test_score_breakdown() {
+    local test_name="Score Breakdown"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="breakdown-$$"
+    curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"degraded\", \"health_metrics\": {\"overall_score\": 55, \"score_breakdown\": [{\"check\": \"CPU Usage\", \"points\": 25, \"max_points\": 25}, {\"check\": \"Disk Usage\", \"points\": 30, \"max_points\": 50}]}}" \
+        "$SERVER_URL/api/v1/report"
+
+    local lost=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance/latest" 2>/dev/null | \
+        jq -r '[.health_metrics.score_breakdown[] | "\(.check)=\(.max_points - .points)"] | join(",")')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$lost" = "CPU Usage=0,Disk Usage=20" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "Points lost per check: '$lost'"
+        return 1
+    fi
+}
+
+# Test: Heartbeats refresh a host without adding to its history
+test_heartbeat_reports() {
+    local test_name="Heartbeat Reports"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="heartbeat-$$"
+    local report
+    for detail in full heartbeat heartbeat; do
+        report="{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"detail\": \"$detail\"}"
+        curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" -d "$report" "$SERVER_URL/api/v1/report"
+    done
+    local history=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance" 2>/dev/null | \
+        jq '.statuses | length')
+    local invalid=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"detail\": \"verbose\"}" \
+        "$SERVER_URL/api/v1/report")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$history" = "1" ] && [ "$invalid" = "400" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "History after heartbeats: $history, unknown detail: HTTP $invalid"
+        return 1
+    fi
+}
+
+# Test: Host listings filtered by service and status
+test_host_filters() {
+    local test_name="Host Filters"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local service="filter-$$"
+    local host
+    for host in a:healthy b:unhealthy c:degraded; do
+        curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"$service\", \"instance_name\": \"${host%%:*}\", \"status\": \"${host#*:}\"}" \
+            "$SERVER_URL/api/v1/report"
+    done
+
+    local by_service=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts?service=$service" 2>/dev/null | jq -r '.total')
+    local by_status=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts?service=$service&status=unhealthy,degraded" 2>/dev/null | \
+        jq -r '"\(.total):\([.hosts[].instance_name] | sort | join(","))"')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$by_service" = "3" ] && [ "$by_status" = "2:b,c" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "service filter total '$by_service', status filter '$by_status'"
+        return 1
+    fi
+}
+
+# Test: Fleet stats count hosts per service and status
+test_stats() {
+    local test_name="Fleet Stats"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local service="stats-$$"
+    local instance
+    for instance in s1 s2; do
+        curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"$service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"health_metrics\": {\"cpu_usage\": 20, \"memory_usage\": 30, \"disk_usage\": 40}}" \
+            "$SERVER_URL/api/v1/report"
+    done
+
+    local response=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/stats" 2>/dev/null)
+    local in_service=$(echo "$response" | jq -r --arg service "$service" '.by_service[$service]')
+    local consistent=$(echo "$response" | jq -r '([.by_status[]] | add) == .total_hosts and .hosts_with_metrics <= .total_hosts')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$in_service" = "2" ] && [ "$consistent" = "true" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "service count '$in_service', status counts add up: $consistent"
+        return 1
+    fi
+}
+
+# Test: Discovery returns only live instances of a service
+test_service_instances() {
+    local test_name="Service Instance Discovery"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local service="discovery-$$"
+    local host
+    for host in up:healthy slow:degraded down:unhealthy; do
+        curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"$service\", \"instance_name\": \"${host%%:*}\", \"status\": \"${host#*:}\"}" \
+            "$SERVER_URL/api/v1/report"
+    done
+
+    local url="$SERVER_URL/api/v1/services/$service/instances"
+    local live=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$url" 2>/dev/null | jq -r '[.hosts[].instance_name] | join(",")')
+    local with_degraded=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$url?include_degraded=true" 2>/dev/null | jq -r '[.hosts[].instance_name] | sort | join(",")')
+    local unknown_code=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/services/never-reported-$$/instances")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$live" = "up" ] && [ "$with_degraded" = "slow,up" ] && [ "$unknown_code" = "404" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "live '$live', with degraded '$with_degraded', unknown service HTTP $unknown_code"
+        return 1
+    fi
+}
+
+# Test: Only client-computable statuses are accepted
+test_status_validation() {
+    local test_name="Status Validation"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="status-check-$$"
+    local status codes=""
+    for status in Degraded lost "HEALTHY!!"; do
+        codes="$codes $(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"$status\"}" \
+            "$SERVER_URL/api/v1/report")"
+    done
+    local stored=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance" 2>/dev/null | \
+        jq -r '[.statuses[].status] | join(",")')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$codes" = " 200 400 400" ] && [ "$stored" = "degraded" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "HTTP codes:$codes, stored statuses '$stored'"
+        return 1
+    fi
+}
+
+# Test: Oversized report bodies are refused
+test_body_size_limit() {
+    local test_name="Report Body Size Limit"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="oversized-$$"
+    local padding=$(head -c 100000 /dev/zero | tr '\0' 'x')
+    local response_code=$(printf '{"service_name": "test-service", "instance_name": "%s", "status": "healthy", "os_release": "%s"}' "$instance" "$padding" | \
+        curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" --data-binary @- \
+            "$SERVER_URL/api/v1/report")
+    local stored_code=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$response_code" = "413" ] && [ "$stored_code" = "404" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "100KB report got HTTP $response_code, host lookup HTTP $stored_code"
+        return 1
+    fi
+}
+
+# Test: API errors carry a stable JSON error code
+test_json_errors() {
+    local test_name="JSON Error Responses"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local bad_json=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" -d "{not json" \
+        "$SERVER_URL/api/v1/report" | jq -r '.error.code')
+    local unknown_host=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/hosts/test-service/no-such-host-$$" | jq -r '.error.code')
+    local unknown_route=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/no-such-endpoint" | jq -r '.error.code')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$bad_json" = "invalid_json" ] && [ "$unknown_host" = "not_found" ] && [ "$unknown_route" = "not_found" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "codes: bad JSON '$bad_json', unknown host '$unknown_host', unknown route '$unknown_route'"
+        return 1
+    fi
+}
+
+# Test: /health reports when the server certificate expires
+test_certificate_expiry() {
+    local test_name="Certificate Expiry in Health"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local expires_at=$(curl -sf "$HEALTH_URL" 2>/dev/null | jq -r '.certificate_expires_at // empty')
+    local duration=$(($(date +%s) - start_time))
+    if [[ "$SERVER_URL" != https://* ]]; then
+        add_test_result "$test_name" "skip" "$duration" "Server is not using TLS"
+        return 0
+    fi
+
+    local expires_epoch=$(date -d "$expires_at" +%s 2>/dev/null || echo 0)
+    if [ "$expires_epoch" -gt "$(date +%s)" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "certificate_expires_at '$expires_at' is missing or past"
+        return 1
+    fi
+}
+
+# Test: A batch with one invalid report stores the rest and reports 207
+test_batch_reports() {
+    local test_name="Batch Reporting"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local service="batch-$$"
+    local response=$(curl -s -w "\n%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "[{\"service_name\": \"$service\", \"instance_name\": \"b1\", \"status\": \"healthy\"}, {\"service_name\": \"$service\", \"instance_name\": \"b2\", \"status\": \"bogus\"}, {\"service_name\": \"$service\", \"instance_name\": \"b3\", \"status\": \"degraded\"}]" \
+        "$SERVER_URL/api/v1/report/batch")
+    local code=$(echo "$response" | tail -1)
+    local results=$(echo "$response" | head -n -1 | jq -r '"\(.accepted)/\(.rejected) \([.results[].status] | join(","))"')
+    local stored=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts?service=$service" 2>/dev/null | jq -r '.total')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$code" = "207" ] && [ "$results" = "2/1 ok,error,ok" ] && [ "$stored" = "2" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "HTTP $code, results '$results', hosts stored $stored"
+        return 1
+    fi
+}
+
+# Test: Large host listings are gzipped for clients that accept it
+test_gzip_responses() {
+    local test_name="Gzip Responses"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local service="gzip-$$"
+    local i
+    for i in $(seq 1 10); do
+        curl -sf -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"$service\", \"instance_name\": \"gzip-host-$i\", \"status\": \"healthy\"}" \
+            "$SERVER_URL/api/v1/report"
+    done
+
+    local encoding=$(curl -s -o /dev/null -D - -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -H "Accept-Encoding: gzip" "$SERVER_URL/api/v1/hosts?service=$service" | \
+        tr -d '\r' | awk -F': ' 'tolower($1) == "content-encoding" {print $2}')
+    local total=$(curl -sf --compressed -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts?service=$service" 2>/dev/null | jq -r '.total')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$encoding" = "gzip" ] && [ "$total" = "10" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "Content-Encoding '$encoding', decompressed total '$total'"
+        return 1
+    fi
+}
+
+# Test: The request ID is echoed back and generated when absent
+test_request_id() {
+    local test_name="Request ID Propagation"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local sent="itest-$$-$RANDOM"
+    local echoed=$(curl -s -o /dev/null -D - -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -H "X-Request-ID: $sent" "$SERVER_URL/api/v1/hosts" | \
+        tr -d '\r' | awk -F': ' 'tolower($1) == "x-request-id" {print $2}')
+    local generated=$(curl -s -o /dev/null -D - -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/hosts" | \
+        tr -d '\r' | awk -F': ' 'tolower($1) == "x-request-id" {print $2}')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$echoed" = "$sent" ] && [[ "$generated" =~ ^[0-9a-f]{32}$ ]]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "sent '$sent', echoed '$echoed', generated '$generated'"
+        return 1
+    fi
+}
+
+# Test: /health reports Go runtime stats with a growing uptime
+test_health_runtime() {
+    local test_name="Health Runtime Stats"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local first=$(curl -sf "$HEALTH_URL")
+    sleep 1
+    local second=$(curl -sf "$HEALTH_URL")
+
+    local duration=$(($(date +%s) - start_time))
+    if echo "$first" | jq -e '(.goroutines > 0) and (.heap_alloc_bytes > 0) and (.heap_sys_bytes > 0) and (.gc_count >= 0) and (.uptime_seconds >= 0)' >/dev/null 2>&1 && \
+       [ "$(jq -n --argjson a "$first" --argjson b "$second" '$b.uptime_seconds > $a.uptime_seconds')" = "true" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "runtime stats missing or uptime not increasing: $first"
+        return 1
+    fi
+}
+
+# Test: /health names the running build
+test_build_info() {
+    local test_name="Health Build Info"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local response=$(curl -sf "$HEALTH_URL" 2>/dev/null || echo "{}")
+
+    local duration=$(($(date +%s) - start_time))
+    if echo "$response" | jq -e '[.version, .git_commit, .build_date] | all(type == "string" and length > 0)' >/dev/null 2>&1 && \
+       [ "$(echo "$response" | jq -r '.version')" != "1.0.0" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "build info missing: $(echo "$response" | jq -c '{version, git_commit, build_date}' 2>/dev/null)"
+        return 1
+    fi
+}
+
+# Test: Replayed and out-of-order report sequences are refused
+test_report_sequence() {
+    local test_name="Report Sequence Ordering"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="sequence-check-$$"
+    local sequence codes=""
+    for sequence in 5 6 6 4 9; do
+        codes="$codes $(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"sequence\": $sequence}" \
+            "$SERVER_URL/api/v1/report")"
+    done
+    local stored=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance" 2>/dev/null | \
+        jq -r '[.statuses[].sequence] | join(",")')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$codes" = " 200 200 409 409 200" ] && [ "$stored" = "5,6,9" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "HTTP codes:$codes, stored sequences '$stored'"
+        return 1
+    fi
+}
+
+# Test: Reports record the identity of the client certificate
+test_client_id() {
+    local test_name="Client Certificate Identity"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    if [[ "$SERVER_URL" != https://* ]]; then
+        add_test_result "$test_name" "skip" "$(($(date +%s) - start_time))" "Server is not using TLS"
+        return 0
+    fi
+
+    # The SPIFFE URI SAN when the certificate has one, otherwise its CN
+    local expected=$(openssl x509 -in "$CERT_FILE" -noout -ext subjectAltName 2>/dev/null | grep -o 'URI:spiffe://[^, ]*' | head -1 | cut -c5-)
+    if [ -z "$expected" ]; then
+        expected=$(openssl x509 -in "$CERT_FILE" -noout -subject -nameopt multiline 2>/dev/null | awk -F' = ' '/commonName/ {print $2}')
+    fi
+
+    local instance="identity-check-$$"
+    curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\"}" \
+        "$SERVER_URL/api/v1/report"
+    local client_id=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts?service=test-service" 2>/dev/null | \
+        jq -r --arg instance "$instance" '.hosts[] | select(.instance_name == $instance) | .client_id // empty')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ -n "$client_id" ] && [ "$client_id" = "$expected" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "client_id '$client_id', expected '$expected'"
+        return 1
+    fi
+}
+
+# Test: Labels are stored with a report and filter the host listing
+test_labels() {
+    local test_name="Host Labels"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="labels-check-$$"
+    curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"labels\": {\"region\": \"labels-$$\"}}" \
+        "$SERVER_URL/api/v1/report"
+    local matched=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts?service=test-service&label=region=labels-$$" 2>/dev/null | \
+        jq -r '[.hosts[] | .instance_name + ":" + .labels.region] | join(",")')
+    local unmatched=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts?service=test-service&label=region=elsewhere-$$" 2>/dev/null | \
+        jq -r '.hosts | length')
+    local oversized=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"labels\": {\"region\": \"$(printf 'x%.0s' {1..300})\"}}" \
+        "$SERVER_URL/api/v1/report")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$matched" = "$instance:labels-$$" ] && [ "$unmatched" = "0" ] && [ "$oversized" = "400" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "matched '$matched', unmatched count '$unmatched', oversized label HTTP $oversized"
+        return 1
+    fi
+}
+
+# Test: A host reporting in a tight loop is rate limited with 429 and Retry-After
+test_rate_limit() {
+    local test_name="Report Rate Limiting"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="ratelimit-check-$$"
+    local i code first="" limited=0 retry_after=""
+    for i in $(seq 1 50); do
+        local headers=$(curl -s -o /dev/null -D - -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\"}" \
+            "$SERVER_URL/api/v1/report")
+        code=$(echo "$headers" | awk 'NR == 1 {print $2}')
+        [ -z "$first" ] && first="$code"
+        if [ "$code" = "429" ]; then
+            limited=$i
+            retry_after=$(echo "$headers" | tr -d '\r' | awk -F': ' 'tolower($1) == "retry-after" {print $2}')
+            break
+        fi
+    done
+    # Another host is unaffected by this one's bucket
+    local other=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance-other\", \"status\": \"healthy\"}" \
+        "$SERVER_URL/api/v1/report")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$limited" -eq 0 ]; then
+        add_test_result "$test_name" "skip" "$duration" "No 429 after 50 reports; rate limiting appears disabled"
+        return 0
+    elif [ "$first" = "200" ] && [[ "$retry_after" =~ ^[0-9]+$ ]] && [ "$other" = "200" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "first report HTTP $first, 429 after $limited reports with Retry-After '$retry_after', other host HTTP $other"
+        return 1
+    fi
+}
+
+# Test: Liveness and readiness probes answer on the health server
+test_probes() {
+    local test_name="Liveness and Readiness Probes"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local base="${HEALTH_URL%/health}"
+    local livez=$(curl -s -o /dev/null -w "%{http_code}" "$base/livez")
+    local readyz=$(curl -s "$base/readyz" 2>/dev/null | jq -r '.status // empty')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$livez" = "200" ] && [ "$readyz" = "ok" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "/livez HTTP $livez, /readyz status '$readyz'"
+        return 1
+    fi
+}
+
+# Test: A client timestamp behind the server clock is reported as clock skew
+test_clock_skew() {
+    local test_name="Client Clock Skew"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="skew-check-$$"
+    local client_time=$(date -u -d '-20 seconds' +%Y-%m-%dT%H:%M:%SZ)
+    local code=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"timestamp\": \"$client_time\"}" \
+        "$SERVER_URL/api/v1/report")
+    local skew=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance/latest" 2>/dev/null | \
+        jq -r '.clock_skew_seconds // empty')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$code" = "200" ] && [ -n "$skew" ] && jq -en --argjson skew "$skew" '$skew >= 18 and $skew <= 30' >/dev/null; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "report HTTP $code, clock_skew_seconds '$skew', expected about 20"
+        return 1
+    fi
+}
+
+# Test: The check summary tallies a check failing on several hosts
+test_check_summary() {
+    local test_name="Fleet Check Summary"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local check="Queue Drained $$"
+    local instance status
+    for instance in summary-a-$$:unhealthy summary-b-$$:unhealthy summary-c-$$:healthy; do
+        status="${instance#*:}"
+        curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"test-service\", \"instance_name\": \"${instance%%:*}\", \"status\": \"healthy\", \"health_metrics\": {\"checks\": [{\"name\": \"$check\", \"status\": \"$status\"}]}}" \
+            "$SERVER_URL/api/v1/report"
+    done
+    local tally=$(curl -sf -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/checks/summary" 2>/dev/null | \
+        jq -r --arg check "$check" '.checks[] | select(.name == $check) | "\(.healthy)/\(.degraded)/\(.unhealthy)"')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$tally" = "1/0/2" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "healthy/degraded/unhealthy tally '$tally', expected 1/0/2"
+        return 1
+    fi
+}
+
+# Test: The host listing answers a matching If-None-Match with 304 until a report arrives
+test_hosts_etag() {
+    local test_name="Host Listing ETag"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    # Other clients may report in between, so allow a few attempts at a 304
+    local etag cached attempt
+    for attempt in 1 2 3; do
+        etag=$(curl -s -o /dev/null -D - -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts" | \
+            tr -d '\r' | awk -F': ' 'tolower($1) == "etag" {print $2}')
+        cached=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -H "If-None-Match: $etag" "$SERVER_URL/api/v1/hosts")
+        [ "$cached" = "304" ] && break
+    done
+    curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"etag-check-$$\", \"status\": \"healthy\"}" \
+        "$SERVER_URL/api/v1/report"
+    local fresh=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -H "If-None-Match: $etag" "$SERVER_URL/api/v1/hosts")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ -n "$etag" ] && [ "$cached" = "304" ] && [ "$fresh" = "200" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "ETag '$etag', conditional HTTP $cached before a report and $fresh after"
+        return 1
+    fi
+}
+
+# Test: uptime and flap count on the host detail
+test_host_stability() {
+    local test_name="Host Stability"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="stability-check-$$" status
+    for status in healthy unhealthy healthy; do
+        curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"$status\"}" \
+            "$SERVER_URL/api/v1/report"
+    done
+    local response=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance")
+    local flaps=$(echo "$response" | jq -r '.flap_count')
+    local since=$(echo "$response" | jq -r '.stable_since // empty')
+    local last=$(echo "$response" | jq -r '.statuses[-1].timestamp // empty')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$flaps" = "2" ] && [ -n "$since" ] && [ "$since" = "$last" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "flap_count $flaps, stable_since '$since' against latest status at '$last'"
+        return 1
+    fi
+}
+
+# Test: reporting over the server's Unix socket
+test_unix_socket() {
+    local test_name="Unix Socket Reporting"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    if [ -z "$UNIX_SOCKET" ] || [ ! -S "$UNIX_SOCKET" ]; then
+        add_test_result "$test_name" "skip" "$(($(date +%s) - start_time))" "UNIX_SOCKET is not set or not reachable"
+        return 0
+    fi
+
+    # Plain HTTP over the socket, no certificate
+    local instance="socket-check-$$"
+    local code=$(curl -s -o /dev/null -w "%{http_code}" --unix-socket "$UNIX_SOCKET" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\"}" \
+        "http://localhost/api/v1/report")
+    # The report shows up over TCP too
+    local status=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/hosts/test-service/$instance/latest" | jq -r '.status // empty')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$code" = "200" ] && [ "$status" = "healthy" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "report over the socket HTTP $code, host status over TCP '$status'"
+        return 1
+    fi
+}
+
+# Test: Allow header on 405 and 204 on OPTIONS
+test_method_allow() {
+    local test_name="Method Allow Header"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local wrong=$(curl -s -o /dev/null -D - -X DELETE -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/hosts" | tr -d '\r')
+    local options=$(curl -s -o /dev/null -D - -X OPTIONS -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/report" | tr -d '\r')
+    local wrong_code=$(echo "$wrong" | awk 'NR == 1 {print $2}')
+    local wrong_allow=$(echo "$wrong" | awk -F': ' 'tolower($1) == "allow" {print $2}')
+    local options_code=$(echo "$options" | awk 'NR == 1 {print $2}')
+    local options_allow=$(echo "$options" | awk -F': ' 'tolower($1) == "allow" {print $2}')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$wrong_code" = "405" ] && [ "$wrong_allow" = "GET, HEAD, OPTIONS" ] && \
+       [ "$options_code" = "204" ] && [ "$options_allow" = "POST, OPTIONS" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "DELETE hosts: HTTP $wrong_code Allow '$wrong_allow'; OPTIONS report: HTTP $options_code Allow '$options_allow'"
+        return 1
+    fi
+}
+
+# Test: routes also match with a trailing slash
+test_trailing_slash() {
+    local test_name="Trailing Slash Routes"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local hosts=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/" | jq -r '.hosts | type')
+    local stats=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/stats/" | jq -r '.total_hosts | type')
+    local missing=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$hosts" = "array" ] && [ "$stats" = "number" ] && [ "$missing" = "404" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "/api/v1/hosts/ gave hosts of type '$hosts', /api/v1/stats/ total_hosts of type '$stats', partial host path HTTP $missing"
+        return 1
+    fi
+}
+
+# Test: report interval advice in report replies
+test_interval_advice() {
+    local test_name="Report Interval Advice"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local reply=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d '{"service_name": "test-service", "instance_name": "advice-check", "status": "healthy"}' \
+        "$SERVER_URL/api/v1/report")
+    local batch=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d '[{"service_name": "test-service", "instance_name": "advice-check", "status": "healthy"}]' \
+        "$SERVER_URL/api/v1/report/batch")
+    # Absent without ADVISED_INTERVAL or TARGET_REPORT_RATE, a positive number of seconds otherwise
+    local single_advice=$(echo "$reply" | jq -r '.next_interval_seconds // "none"')
+    local batch_advice=$(echo "$batch" | jq -r '.next_interval_seconds // "none"')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$(echo "$reply" | jq -r '.status')" = "ok" ] && [ "$single_advice" = "$batch_advice" ] && \
+       { [ "$single_advice" = "none" ] || [ "$single_advice" -gt 0 ] 2>/dev/null; }; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "report reply $reply, batch advice '$batch_advice'"
+        return 1
+    fi
+}
+
+# Test: status transitions in the audit log
+test_audit_log() {
+    local test_name="Status Audit Log"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+    local since=$(date -u +%Y-%m-%dT%H:%M:%SZ -d '-1 second')
+
+    local instance="audit-check-$$" status
+    for status in healthy unhealthy unhealthy; do
+        curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+            -X POST -H "Content-Type: application/json" \
+            -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"$status\"}" \
+            "$SERVER_URL/api/v1/report"
+    done
+    local response=$(curl -s -w "\n%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/audit?service=test-service&since=$since")
+    local code=$(echo "$response" | tail -1)
+    local events=$(echo "$response" | sed '$d' | jq -r --arg instance "$instance" \
+        '[.events[] | select(.instance_name == $instance) | .old_status + ">" + .new_status] | join(",")')
+    local other=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/audit?service=no-such-service" | jq -r '.total')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$code" = "404" ]; then
+        add_test_result "$test_name" "skip" "$duration" "Audit log is disabled on the server"
+        return 0
+    elif [ "$events" = ">healthy,healthy>unhealthy" ] && [ "$other" = "0" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "transitions '$events' (HTTP $code), other service total '$other'"
+        return 1
+    fi
+}
+
+# Test: /latest matches the end of the host's history
+test_latest_host() {
+    local test_name="Latest Host Status"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="latest-check-$$"
+    curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"healthy\", \"health_metrics\": {\"cpu_usage\": 10, \"memory_usage\": 20, \"disk_usage\": 30, \"network_ok\": true, \"checks\": [], \"overall_score\": 95}}" \
+        "$SERVER_URL/api/v1/report"
+    curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"degraded\", \"health_metrics\": {\"cpu_usage\": 85, \"memory_usage\": 20, \"disk_usage\": 30, \"network_ok\": true, \"checks\": [], \"overall_score\": 70}}" \
+        "$SERVER_URL/api/v1/report"
+
+    local history=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance")
+    local latest=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance/latest")
+    local expected=$(echo "$history" | jq -c '{status: .current_status, last_seen, score: .statuses[-1].health_metrics.overall_score}')
+    local actual=$(echo "$latest" | jq -c '{status, last_seen, score: .health_metrics.overall_score}')
+    local missing=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/hosts/test-service/no-such-instance-$$/latest")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$actual" = "$expected" ] && [ "$(echo "$latest" | jq -r '.status')" = "degraded" ] && \
+       [ "$(echo "$latest" | jq 'has("statuses")')" = "false" ] && [ "$missing" = "404" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "/latest $actual, history says $expected, unknown host HTTP $missing"
+        return 1
+    fi
+}
+
+# Test: health server answers h2c (HTTP/2 with prior knowledge) when HEALTH_H2C is on
+test_health_h2c() {
+    local test_name="Health Endpoint over h2c"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local http1=$(curl -s -o /dev/null -w "%{http_code} %{http_version}" --http1.1 "$HEALTH_URL")
+    local h2c=$(curl -s -o /dev/null -w "%{http_code} %{http_version}" --http2-prior-knowledge "$HEALTH_URL")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$http1" != "200 1.1" ]; then
+        add_test_result "$test_name" "fail" "$duration" "HTTP/1.1 health request gave $http1"
+        return 1
+    elif [ "$h2c" = "200 2" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "skip" "$duration" "h2c not enabled on the health server (HEALTH_H2C)"
+        return 0
+    fi
+}
+
+# Test: metrics-only report updates metrics and keeps the recorded status
+test_metrics_only() {
+    local test_name="Metrics-Only Report"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local instance="metrics-only-$$"
+    local unknown=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"metrics_only\": true, \"health_metrics\": {\"overall_score\": 90}}" \
+        "$SERVER_URL/api/v1/report")
+    curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"status\": \"degraded\", \"health_metrics\": {\"cpu_usage\": 91, \"memory_usage\": 20, \"disk_usage\": 30, \"network_ok\": true, \"checks\": [], \"overall_score\": 65}}" \
+        "$SERVER_URL/api/v1/report"
+    local pushed=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"metrics_only\": true, \"health_metrics\": {\"cpu_usage\": 35, \"memory_usage\": 20, \"disk_usage\": 30, \"network_ok\": true, \"checks\": [], \"overall_score\": 88}}" \
+        "$SERVER_URL/api/v1/report")
+    local no_status=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"test-service\", \"instance_name\": \"$instance\", \"health_metrics\": {\"overall_score\": 88}}" \
+        "$SERVER_URL/api/v1/report")
+
+    local detail=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/test-service/$instance")
+    local summary=$(echo "$detail" | jq -r '"\(.current_status) \(.statuses | length) \(.statuses[-1].health_metrics.overall_score)"')
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$unknown" = "409" ] && [ "$pushed" = "200" ] && [ "$no_status" = "400" ] && [ "$summary" = "degraded 1 88" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "unknown host HTTP $unknown, push HTTP $pushed, no status HTTP $no_status, host: $summary"
+        return 1
+    fi
+}
+
+# Test: host export as CSV with a header row, honouring the listing filters
+test_hosts_export() {
+    local test_name="Hosts CSV Export"
+    log_test "$test_name"
+    local start_time=$(date +%s)
+
+    local service="export-service-$$"
+    curl -s -o /dev/null -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        -X POST -H "Content-Type: application/json" \
+        -d "{\"service_name\": \"$service\", \"instance_name\": \"e1\", \"status\": \"degraded\", \"health_metrics\": {\"cpu_usage\": 81.5, \"memory_usage\": 20, \"disk_usage\": 30, \"network_ok\": true, \"checks\": [], \"overall_score\": 72}}" \
+        "$SERVER_URL/api/v1/report"
+
+    local csv=$(curl -s -k --cert "$CERT_FILE" --key "$KEY_FILE" "$SERVER_URL/api/v1/hosts/export?format=csv&service=$service")
+    local header=$(echo "$csv" | sed -n 1p | tr -d '\r')
+    local row=$(echo "$csv" | sed -n 2p | tr -d '\r' | cut -d, -f1-3,6-9)
+    local rows=$(echo "$csv" | wc -l)
+    local bad_format=$(curl -s -o /dev/null -w "%{http_code}" -k --cert "$CERT_FILE" --key "$KEY_FILE" \
+        "$SERVER_URL/api/v1/hosts/export?format=xml")
+
+    local duration=$(($(date +%s) - start_time))
+    if [ "$header" = "service_name,instance_name,status,ip_address,last_seen,cpu_usage,memory_usage,disk_usage,overall_score" ] && \
+       [ "$row" = "$service,e1,degraded,81.50,20.00,30.00,72" ] && [ "$rows" = "2" ] && [ "$bad_format" = "400" ]; then
+        add_test_result "$test_name" "pass" "$duration"
+        return 0
+    else
+        add_test_result "$test_name" "fail" "$duration" "header '$header', row '$row', $rows lines, format=xml HTTP $bad_format"
+        return 1
+    fi
+}
+
 # Run test suite
 run_test_suite() {
     local suite="$1"
@@ -539,11 +1536,47 @@ run_test_suite() {
             test_list_hosts
             test_host_history
             test_status_reporting
+            test_recent_errors
+            test_kernel_inventory
+            test_max_report_age
+            test_score_breakdown
+            test_heartbeat_reports
+            test_host_filters
+            test_stats
+            test_service_instances
+            test_status_validation
+            test_body_size_limit
+            test_json_errors
+            test_certificate_expiry
+            test_batch_reports
+            test_gzip_responses
+            test_request_id
+            test_health_runtime
+            test_build_info
+            test_report_sequence
+            test_client_id
+            test_labels
+            test_rate_limit
+            test_probes
+            test_clock_skew
+            test_check_summary
+            test_hosts_etag
+            test_host_stability
+            test_unix_socket
+            test_method_allow
+            test_trailing_slash
+            test_interval_advice
+            test_audit_log
+            test_latest_host
+            test_health_h2c
+            test_metrics_only
+            test_hosts_export
             test_error_handling
             ;;
         "discovery")
             test_service_discovery
             test_health_status_variations
+            test_service_instances_match
             test_stale_detection
             ;;
         "performance")
@@ -559,7 +1592,43 @@ run_test_suite() {
             test_service_discovery
             test_host_history
             test_status_reporting
+            test_recent_errors
+            test_kernel_inventory
+            test_max_report_age
+            test_score_breakdown
+            test_heartbeat_reports
+            test_host_filters
+            test_stats
+            test_service_instances
+            test_status_validation
+            test_body_size_limit
+            test_json_errors
+            test_certificate_expiry
+            test_batch_reports
+            test_gzip_responses
+            test_request_id
+            test_health_runtime
+            test_build_info
+            test_report_sequence
+            test_client_id
+            test_labels
+            test_rate_limit
+            test_probes
+            test_clock_skew
+            test_check_summary
+            test_hosts_etag
+            test_host_stability
+            test_unix_socket
+            test_method_allow
+            test_trailing_slash
+            test_interval_advice
+            test_audit_log
+            test_latest_host
+            test_health_h2c
+            test_metrics_only
+            test_hosts_export
             test_health_status_variations
+            test_service_instances_match
             test_stale_detection
             test_api_performance
             test_load